@@ -0,0 +1,507 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+// Package apdu decodes and encodes the application-layer services carried by cemi.AppData.
+//
+// The application-layer protocol control information (APCI) has 10 bits. cemi.AppData keeps the
+// upper 4 bits as Command and the lower 6 bits in the first octet of Data. Services whose APCI
+// only needs the upper 4 bits use the lower 6 bits for a parameter, e.g. the value of a
+// GroupValueWrite or the count of a MemoryRead.
+//
+// Decode yields a typed PDU for every service, except for these, which are left as Generic:
+//   - DomainAddressSelectiveRead, whose parameters depend on the medium.
+//   - The routing table, router memory and router status services of couplers, whose parameters
+//     are not part of the application layer specification.
+//   - FileStreamInfoReport, whose file blocks are defined by the file transfer protocol.
+package apdu
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/mobilarte/knx-exp/knx/cemi"
+	"github.com/mobilarte/knx-exp/knx/util"
+)
+
+// APCI is the 10-bit Application-layer Protocol Control Information.
+type APCI uint16
+
+// These are the application-layer services, see 03_03_07 Application Layer.
+const (
+	GroupValueRead            APCI = 0x000
+	GroupValueResponse        APCI = 0x040
+	GroupValueWrite           APCI = 0x080
+	IndividualAddressWrite    APCI = 0x0C0
+	IndividualAddressRead     APCI = 0x100
+	IndividualAddressResponse APCI = 0x140
+	ADCRead                   APCI = 0x180
+	ADCResponse               APCI = 0x1C0
+	MemoryRead                APCI = 0x200
+	MemoryResponse            APCI = 0x240
+	MemoryWrite               APCI = 0x280
+	DeviceDescriptorRead      APCI = 0x300
+	DeviceDescriptorResponse  APCI = 0x340
+	Restart                   APCI = 0x380
+	RestartResponse           APCI = 0x3A1
+
+	SystemNetworkParameterRead     APCI = 0x1C8
+	SystemNetworkParameterResponse APCI = 0x1C9
+	SystemNetworkParameterWrite    APCI = 0x1CA
+
+	PropertyExtValueRead                  APCI = 0x1CC
+	PropertyExtValueResponse              APCI = 0x1CD
+	PropertyExtValueWriteCon              APCI = 0x1CE
+	PropertyExtValueWriteConResponse      APCI = 0x1CF
+	PropertyExtValueWriteUnCon            APCI = 0x1D0
+	PropertyExtValueInfoReport            APCI = 0x1D1
+	PropertyExtDescriptionRead            APCI = 0x1D2
+	PropertyExtDescriptionResponse        APCI = 0x1D3
+	FunctionPropertyExtCommand            APCI = 0x1D4
+	FunctionPropertyExtStateRead          APCI = 0x1D5
+	FunctionPropertyExtStateResponse      APCI = 0x1D6
+	MemoryExtendedWrite                   APCI = 0x1FB
+	MemoryExtendedWriteResponse           APCI = 0x1FC
+	MemoryExtendedRead                    APCI = 0x1FD
+	MemoryExtendedReadResponse            APCI = 0x1FE
+	UserMemoryRead                        APCI = 0x2C0
+	UserMemoryResponse                    APCI = 0x2C1
+	UserMemoryWrite                       APCI = 0x2C2
+	UserMemoryBitWrite                    APCI = 0x2C4
+	UserManufacturerInfoRead              APCI = 0x2C5
+	UserManufacturerInfoResponse          APCI = 0x2C6
+	FunctionPropertyCommand               APCI = 0x2C7
+	FunctionPropertyStateRead             APCI = 0x2C8
+	FunctionPropertyStateResponse         APCI = 0x2C9
+	OpenRoutingTableRequest               APCI = 0x3C0
+	ReadRoutingTableRequest               APCI = 0x3C1
+	ReadRoutingTableResponse              APCI = 0x3C2
+	WriteRoutingTableRequest              APCI = 0x3C3
+	ReadRouterMemoryRequest               APCI = 0x3C8
+	ReadRouterMemoryResponse              APCI = 0x3C9
+	WriteRouterMemoryRequest              APCI = 0x3CA
+	ReadRouterStatusRequest               APCI = 0x3CD
+	ReadRouterStatusResponse              APCI = 0x3CE
+	WriteRouterStatusRequest              APCI = 0x3CF
+	MemoryBitWrite                        APCI = 0x3D0
+	AuthorizeRequest                      APCI = 0x3D1
+	AuthorizeResponse                     APCI = 0x3D2
+	KeyWrite                              APCI = 0x3D3
+	KeyResponse                           APCI = 0x3D4
+	PropertyValueRead                     APCI = 0x3D5
+	PropertyValueResponse                 APCI = 0x3D6
+	PropertyValueWrite                    APCI = 0x3D7
+	PropertyDescriptionRead               APCI = 0x3D8
+	PropertyDescriptionResponse           APCI = 0x3D9
+	NetworkParameterRead                  APCI = 0x3DA
+	NetworkParameterResponse              APCI = 0x3DB
+	IndividualAddressSerialNumberRead     APCI = 0x3DC
+	IndividualAddressSerialNumberResponse APCI = 0x3DD
+	IndividualAddressSerialNumberWrite    APCI = 0x3DE
+	DomainAddressWrite                    APCI = 0x3E0
+	DomainAddressRead                     APCI = 0x3E1
+	DomainAddressResponse                 APCI = 0x3E2
+	DomainAddressSelectiveRead            APCI = 0x3E3
+	NetworkParameterWrite                 APCI = 0x3E4
+	LinkRead                              APCI = 0x3E5
+	LinkResponse                          APCI = 0x3E6
+	LinkWrite                             APCI = 0x3E7
+	GroupPropValueRead                    APCI = 0x3E8
+	GroupPropValueResponse                APCI = 0x3E9
+	GroupPropValueWrite                   APCI = 0x3EA
+	GroupPropValueInfoReport              APCI = 0x3EB
+	DomainAddressSerialNumberRead         APCI = 0x3EC
+	DomainAddressSerialNumberResponse     APCI = 0x3ED
+	DomainAddressSerialNumberWrite        APCI = 0x3EE
+	FileStreamInfoReport                  APCI = 0x3F0
+)
+
+// apciNames maps the services to their names in the specification.
+var apciNames = map[APCI]string{
+	GroupValueRead:                        "A_GroupValue_Read",
+	GroupValueResponse:                    "A_GroupValue_Response",
+	GroupValueWrite:                       "A_GroupValue_Write",
+	IndividualAddressWrite:                "A_IndividualAddress_Write",
+	IndividualAddressRead:                 "A_IndividualAddress_Read",
+	IndividualAddressResponse:             "A_IndividualAddress_Response",
+	ADCRead:                               "A_ADC_Read",
+	ADCResponse:                           "A_ADC_Response",
+	MemoryRead:                            "A_Memory_Read",
+	MemoryResponse:                        "A_Memory_Response",
+	MemoryWrite:                           "A_Memory_Write",
+	DeviceDescriptorRead:                  "A_DeviceDescriptor_Read",
+	DeviceDescriptorResponse:              "A_DeviceDescriptor_Response",
+	Restart:                               "A_Restart",
+	RestartResponse:                       "A_Restart_Response",
+	SystemNetworkParameterRead:            "A_SystemNetworkParameter_Read",
+	SystemNetworkParameterResponse:        "A_SystemNetworkParameter_Response",
+	SystemNetworkParameterWrite:           "A_SystemNetworkParameter_Write",
+	PropertyExtValueRead:                  "A_PropertyExtValue_Read",
+	PropertyExtValueResponse:              "A_PropertyExtValue_Response",
+	PropertyExtValueWriteCon:              "A_PropertyExtValue_WriteCon",
+	PropertyExtValueWriteConResponse:      "A_PropertyExtValue_WriteConRes",
+	PropertyExtValueWriteUnCon:            "A_PropertyExtValue_WriteUnCon",
+	PropertyExtValueInfoReport:            "A_PropertyExtValue_InfoReport",
+	PropertyExtDescriptionRead:            "A_PropertyExtDescription_Read",
+	PropertyExtDescriptionResponse:        "A_PropertyExtDescription_Response",
+	FunctionPropertyExtCommand:            "A_FunctionPropertyExtCommand",
+	FunctionPropertyExtStateRead:          "A_FunctionPropertyExtState_Read",
+	FunctionPropertyExtStateResponse:      "A_FunctionPropertyExtState_Response",
+	MemoryExtendedWrite:                   "A_MemoryExtended_Write",
+	MemoryExtendedWriteResponse:           "A_MemoryExtended_WriteResponse",
+	MemoryExtendedRead:                    "A_MemoryExtended_Read",
+	MemoryExtendedReadResponse:            "A_MemoryExtended_ReadResponse",
+	UserMemoryRead:                        "A_UserMemory_Read",
+	UserMemoryResponse:                    "A_UserMemory_Response",
+	UserMemoryWrite:                       "A_UserMemory_Write",
+	UserMemoryBitWrite:                    "A_UserMemoryBit_Write",
+	UserManufacturerInfoRead:              "A_UserManufacturerInfo_Read",
+	UserManufacturerInfoResponse:          "A_UserManufacturerInfo_Response",
+	FunctionPropertyCommand:               "A_FunctionPropertyCommand",
+	FunctionPropertyStateRead:             "A_FunctionPropertyState_Read",
+	FunctionPropertyStateResponse:         "A_FunctionPropertyState_Response",
+	OpenRoutingTableRequest:               "A_Open_Routing_Table_Req",
+	ReadRoutingTableRequest:               "A_Read_Routing_Table_Req",
+	ReadRoutingTableResponse:              "A_Read_Routing_Table_Res",
+	WriteRoutingTableRequest:              "A_Write_Routing_Table_Req",
+	ReadRouterMemoryRequest:               "A_Read_Router_Memory_Req",
+	ReadRouterMemoryResponse:              "A_Read_Router_Memory_Res",
+	WriteRouterMemoryRequest:              "A_Write_Router_Memory_Req",
+	ReadRouterStatusRequest:               "A_Read_Router_Status_Req",
+	ReadRouterStatusResponse:              "A_Read_Router_Status_Res",
+	WriteRouterStatusRequest:              "A_Write_Router_Status_Req",
+	MemoryBitWrite:                        "A_MemoryBit_Write",
+	AuthorizeRequest:                      "A_Authorize_Request",
+	AuthorizeResponse:                     "A_Authorize_Response",
+	KeyWrite:                              "A_Key_Write",
+	KeyResponse:                           "A_Key_Response",
+	PropertyValueRead:                     "A_PropertyValue_Read",
+	PropertyValueResponse:                 "A_PropertyValue_Response",
+	PropertyValueWrite:                    "A_PropertyValue_Write",
+	PropertyDescriptionRead:               "A_PropertyDescription_Read",
+	PropertyDescriptionResponse:           "A_PropertyDescription_Response",
+	NetworkParameterRead:                  "A_NetworkParameter_Read",
+	NetworkParameterResponse:              "A_NetworkParameter_Response",
+	IndividualAddressSerialNumberRead:     "A_IndividualAddressSerialNumber_Read",
+	IndividualAddressSerialNumberResponse: "A_IndividualAddressSerialNumber_Response",
+	IndividualAddressSerialNumberWrite:    "A_IndividualAddressSerialNumber_Write",
+	DomainAddressWrite:                    "A_DomainAddress_Write",
+	DomainAddressRead:                     "A_DomainAddress_Read",
+	DomainAddressResponse:                 "A_DomainAddress_Response",
+	DomainAddressSelectiveRead:            "A_DomainAddressSelective_Read",
+	NetworkParameterWrite:                 "A_NetworkParameter_Write",
+	LinkRead:                              "A_Link_Read",
+	LinkResponse:                          "A_Link_Response",
+	LinkWrite:                             "A_Link_Write",
+	GroupPropValueRead:                    "A_GroupPropValue_Read",
+	GroupPropValueResponse:                "A_GroupPropValue_Response",
+	GroupPropValueWrite:                   "A_GroupPropValue_Write",
+	GroupPropValueInfoReport:              "A_GroupPropValue_InfoReport",
+	DomainAddressSerialNumberRead:         "A_DomainAddressSerialNumber_Read",
+	DomainAddressSerialNumberResponse:     "A_DomainAddressSerialNumber_Response",
+	DomainAddressSerialNumberWrite:        "A_DomainAddressSerialNumber_Write",
+	FileStreamInfoReport:                  "A_FileStream_InfoReport",
+}
+
+// String converts the service to its name in the specification.
+func (apci APCI) String() string {
+	if name, ok := apciNames[apci]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("%#03x", uint16(apci))
+}
+
+// Service strips the parameter from the APCI of a service which only needs the upper 4 bits. The
+// codes of the system network parameter, extended property and extended memory services take
+// precedence over A_ADC_Response, with which they share the upper 4 bits.
+func (apci APCI) Service() APCI {
+	apci &= 0x3FF
+
+	switch short := apci &^ 0x3F; short {
+	case GroupValueRead, GroupValueResponse, GroupValueWrite, IndividualAddressWrite, IndividualAddressRead,
+		IndividualAddressResponse, ADCRead, MemoryRead, MemoryResponse, MemoryWrite, DeviceDescriptorRead,
+		DeviceDescriptorResponse:
+		return short
+
+	case ADCResponse:
+		if _, ok := apciNames[apci]; ok {
+			return apci
+		}
+
+		return ADCResponse
+
+	case Restart:
+		if apci == RestartResponse {
+			return RestartResponse
+		}
+
+		return Restart
+	}
+
+	return apci
+}
+
+// A PDU is an application-layer service with its parameters. Size and Pack cover the octets that
+// follow the two octets which contain the APCI.
+type PDU interface {
+	util.Packable
+	APCI() APCI
+}
+
+// shortPDU is implemented by services which carry a parameter in the lower 6 bits of the APCI.
+type shortPDU interface {
+	PDU
+	short() uint8
+	setShort(bits uint8)
+}
+
+// checkedPDU is implemented by services whose data must fit into a count field.
+type checkedPDU interface {
+	PDU
+	check() error
+}
+
+// These are the errors of Encode and Decode.
+var (
+	ErrExcessData  = errors.New("excess application data")
+	ErrDataTooLong = errors.New("data too long for the count field")
+)
+
+// Encode assembles the application data for the service. Numbered and SeqNumber are left to the
+// caller. ErrDataTooLong is returned if the data of the service does not fit into its count field.
+func Encode(pdu PDU) (*cemi.AppData, error) {
+	apci := pdu.APCI()
+
+	if checked, ok := pdu.(checkedPDU); ok {
+		if err := checked.check(); err != nil {
+			return nil, fmt.Errorf("%v: %w", apci.Service(), err)
+		}
+	}
+
+	data := make([]byte, 1+pdu.Size())
+	data[0] = byte(apci & 0x3F)
+
+	if short, ok := pdu.(shortPDU); ok {
+		data[0] |= short.short() & 0x3F
+	}
+
+	pdu.Pack(data[1:])
+
+	return &cemi.AppData{Command: cemi.APCI(apci >> 6), Data: data}, nil
+}
+
+// Decode parses the application data into its service. Services without typed parameters yield a
+// Generic.
+func Decode(app *cemi.AppData) (PDU, error) {
+	if len(app.Data) < 1 {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	apci := APCI(app.Command&0xF)<<6 | APCI(app.Data[0]&0x3F)
+
+	pdu := newPDU(apci)
+
+	if short, ok := pdu.(shortPDU); ok {
+		short.setShort(app.Data[0] & 0x3F)
+	}
+
+	n, err := pdu.(util.Unpackable).Unpack(app.Data[1:])
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", apci.Service(), err)
+	}
+
+	if int(n) != len(app.Data)-1 {
+		return nil, fmt.Errorf("%v: %w", apci.Service(), ErrExcessData)
+	}
+
+	return pdu, nil
+}
+
+// newPDU allocates the PDU for the given APCI.
+func newPDU(apci APCI) PDU {
+	switch service := apci.Service(); service {
+	case GroupValueRead:
+		return &GroupValueReadPDU{}
+	case GroupValueResponse:
+		return &GroupValueResponsePDU{}
+	case GroupValueWrite:
+		return &GroupValueWritePDU{}
+	case IndividualAddressWrite:
+		return &IndividualAddressWritePDU{}
+	case IndividualAddressRead:
+		return &IndividualAddressReadPDU{}
+	case IndividualAddressResponse:
+		return &IndividualAddressResponsePDU{}
+	case ADCRead:
+		return &ADCReadPDU{}
+	case ADCResponse:
+		return &ADCResponsePDU{}
+	case MemoryRead:
+		return &MemoryReadPDU{}
+	case MemoryResponse:
+		return &MemoryResponsePDU{}
+	case MemoryWrite:
+		return &MemoryWritePDU{}
+	case DeviceDescriptorRead:
+		return &DeviceDescriptorReadPDU{}
+	case DeviceDescriptorResponse:
+		return &DeviceDescriptorResponsePDU{}
+	case Restart:
+		return &RestartPDU{}
+	case RestartResponse:
+		return &RestartResponsePDU{}
+	case SystemNetworkParameterRead:
+		return &SystemNetworkParameterReadPDU{}
+	case SystemNetworkParameterResponse:
+		return &SystemNetworkParameterResponsePDU{}
+	case SystemNetworkParameterWrite:
+		return &SystemNetworkParameterWritePDU{}
+	case PropertyExtValueRead:
+		return &PropertyExtValueReadPDU{}
+	case PropertyExtValueResponse:
+		return &PropertyExtValueResponsePDU{}
+	case PropertyExtValueWriteCon:
+		return &PropertyExtValueWriteConPDU{}
+	case PropertyExtValueWriteConResponse:
+		return &PropertyExtValueWriteConResponsePDU{}
+	case PropertyExtValueWriteUnCon:
+		return &PropertyExtValueWriteUnConPDU{}
+	case PropertyExtValueInfoReport:
+		return &PropertyExtValueInfoReportPDU{}
+	case MemoryExtendedWrite:
+		return &MemoryExtendedWritePDU{}
+	case MemoryExtendedWriteResponse:
+		return &MemoryExtendedWriteResponsePDU{}
+	case MemoryExtendedRead:
+		return &MemoryExtendedReadPDU{}
+	case MemoryExtendedReadResponse:
+		return &MemoryExtendedReadResponsePDU{}
+	case UserMemoryRead:
+		return &UserMemoryReadPDU{}
+	case UserMemoryResponse:
+		return &UserMemoryResponsePDU{}
+	case UserMemoryWrite:
+		return &UserMemoryWritePDU{}
+	case UserManufacturerInfoRead:
+		return &UserManufacturerInfoReadPDU{}
+	case UserManufacturerInfoResponse:
+		return &UserManufacturerInfoResponsePDU{}
+	case FunctionPropertyCommand:
+		return &FunctionPropertyCommandPDU{}
+	case FunctionPropertyStateRead:
+		return &FunctionPropertyStateReadPDU{}
+	case FunctionPropertyStateResponse:
+		return &FunctionPropertyStateResponsePDU{}
+	case MemoryBitWrite:
+		return &MemoryBitWritePDU{}
+	case AuthorizeRequest:
+		return &AuthorizeRequestPDU{}
+	case AuthorizeResponse:
+		return &AuthorizeResponsePDU{}
+	case KeyWrite:
+		return &KeyWritePDU{}
+	case KeyResponse:
+		return &KeyResponsePDU{}
+	case PropertyValueRead:
+		return &PropertyValueReadPDU{}
+	case PropertyValueResponse:
+		return &PropertyValueResponsePDU{}
+	case PropertyValueWrite:
+		return &PropertyValueWritePDU{}
+	case PropertyDescriptionRead:
+		return &PropertyDescriptionReadPDU{}
+	case PropertyDescriptionResponse:
+		return &PropertyDescriptionResponsePDU{}
+	case NetworkParameterRead:
+		return &NetworkParameterReadPDU{}
+	case NetworkParameterResponse:
+		return &NetworkParameterResponsePDU{}
+	case NetworkParameterWrite:
+		return &NetworkParameterWritePDU{}
+	case IndividualAddressSerialNumberRead:
+		return &IndividualAddressSerialNumberReadPDU{}
+	case IndividualAddressSerialNumberResponse:
+		return &IndividualAddressSerialNumberResponsePDU{}
+	case IndividualAddressSerialNumberWrite:
+		return &IndividualAddressSerialNumberWritePDU{}
+	case DomainAddressWrite:
+		return &DomainAddressWritePDU{}
+	case DomainAddressRead:
+		return &DomainAddressReadPDU{}
+	case DomainAddressResponse:
+		return &DomainAddressResponsePDU{}
+	case DomainAddressSerialNumberRead:
+		return &DomainAddressSerialNumberReadPDU{}
+	case DomainAddressSerialNumberResponse:
+		return &DomainAddressSerialNumberResponsePDU{}
+	case DomainAddressSerialNumberWrite:
+		return &DomainAddressSerialNumberWritePDU{}
+	case PropertyExtDescriptionRead:
+		return &PropertyExtDescriptionReadPDU{}
+	case PropertyExtDescriptionResponse:
+		return &PropertyExtDescriptionResponsePDU{}
+	case FunctionPropertyExtCommand:
+		return &FunctionPropertyExtCommandPDU{}
+	case FunctionPropertyExtStateRead:
+		return &FunctionPropertyExtStateReadPDU{}
+	case FunctionPropertyExtStateResponse:
+		return &FunctionPropertyExtStateResponsePDU{}
+	case UserMemoryBitWrite:
+		return &UserMemoryBitWritePDU{}
+	case LinkRead:
+		return &LinkReadPDU{}
+	case LinkResponse:
+		return &LinkResponsePDU{}
+	case LinkWrite:
+		return &LinkWritePDU{}
+	case GroupPropValueRead:
+		return &GroupPropValueReadPDU{}
+	case GroupPropValueResponse:
+		return &GroupPropValueResponsePDU{}
+	case GroupPropValueWrite:
+		return &GroupPropValueWritePDU{}
+	case GroupPropValueInfoReport:
+		return &GroupPropValueInfoReportPDU{}
+	default:
+		return &Generic{Code: service}
+	}
+}
+
+// A Generic is a service without typed parameters. Data holds the octets that follow the APCI.
+type Generic struct {
+	Code APCI
+	Data []byte
+}
+
+// APCI returns the service.
+func (pdu *Generic) APCI() APCI {
+	return pdu.Code
+}
+
+// Size returns the packed size.
+func (pdu *Generic) Size() uint {
+	return uint(len(pdu.Data))
+}
+
+// Pack the parameters into the buffer.
+func (pdu *Generic) Pack(buffer []byte) {
+	copy(buffer, pdu.Data)
+}
+
+// Unpack initializes the structure by parsing the given data.
+func (pdu *Generic) Unpack(data []byte) (uint, error) {
+	pdu.Data = append([]byte(nil), data...)
+
+	return uint(len(data)), nil
+}
+
+// unpackRest copies the remaining data.
+func unpackRest(data []byte, n uint, output *[]byte) (uint, error) {
+	if n > uint(len(data)) {
+		return n, io.ErrUnexpectedEOF
+	}
+
+	*output = append([]byte(nil), data[n:]...)
+
+	return uint(len(data)), nil
+}
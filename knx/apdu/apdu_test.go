@@ -0,0 +1,314 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package apdu
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/mobilarte/knx-exp/knx/cemi"
+)
+
+func TestDecode(t *testing.T) {
+	for _, tc := range []struct {
+		app      cemi.AppData
+		expected PDU
+	}{
+		{
+			cemi.AppData{Command: cemi.GroupValueWrite, Data: []byte{0x01}},
+			&GroupValueWritePDU{GroupValue{Data: []byte{0x01}}},
+		},
+		{
+			cemi.AppData{Command: cemi.GroupValueResponse, Data: []byte{0x00, 0x0c, 0x1a}},
+			&GroupValueResponsePDU{GroupValue{Data: []byte{0x00, 0x0c, 0x1a}}},
+		},
+		{
+			cemi.AppData{Command: cemi.MemoryRead, Data: []byte{0x04, 0x01, 0x00}},
+			&MemoryReadPDU{Count: 4, Address: 0x0100},
+		},
+		{
+			cemi.AppData{Command: cemi.MaskVersionRead, Data: []byte{0x00}},
+			&DeviceDescriptorReadPDU{Type: 0},
+		},
+		{
+			cemi.AppData{Command: cemi.MaskVersionResponse, Data: []byte{0x00, 0x07, 0xb0}},
+			&DeviceDescriptorResponsePDU{Type: 0, Descriptor: []byte{0x07, 0xb0}},
+		},
+		{
+			cemi.AppData{Command: cemi.Restart, Data: []byte{0x01, 0x02, 0x00}},
+			&RestartPDU{MasterReset: true, EraseCode: 2},
+		},
+		{
+			cemi.AppData{Command: cemi.Restart, Data: []byte{0x21, 0x00, 0x00, 0x05}},
+			&RestartResponsePDU{ProcessTime: 5},
+		},
+		{
+			cemi.AppData{Command: cemi.AdcResponse, Data: []byte{0x01, 0x08, 0x12, 0x34}},
+			&ADCResponsePDU{Channel: 1, Count: 8, Sum: 0x1234},
+		},
+		{
+			cemi.AppData{Command: cemi.AdcResponse, Data: []byte{0x08, 0x00, 0x00, 0x0b, 0x00, 0x01}},
+			&SystemNetworkParameterReadPDU{SystemNetworkParameter{PropertyID: 0x0b0, Data: []byte{0x01}}},
+		},
+		{
+			cemi.AppData{Command: cemi.Escape, Data: []byte{0x15, 0x00, 0x0b, 0x10, 0x01}},
+			&PropertyValueReadPDU{PropertyValue{PropertyID: cemi.PIDSerialNumber, Count: 1, StartIndex: 1}},
+		},
+		{
+			cemi.AppData{Command: cemi.Escape, Data: []byte{0x11, 0x00, 0x11, 0x22, 0x33, 0x44}},
+			&AuthorizeRequestPDU{Key: 0x11223344},
+		},
+		{
+			cemi.AppData{Command: cemi.Escape, Data: []byte{0x13, 0x02, 0xff, 0xff, 0xff, 0xff}},
+			&KeyWritePDU{Level: 2, Key: 0xffffffff},
+		},
+		{
+			cemi.AppData{Command: cemi.AdcResponse, Data: []byte{0x3d, 0x02, 0x01, 0x00, 0x00}},
+			&MemoryExtendedReadPDU{Count: 2, Address: 0x010000},
+		},
+		{
+			cemi.AppData{Command: cemi.Escape, Data: []byte{0x1c, 0x00, 0xc5, 0x01, 0x02, 0x03, 0x04}},
+			&IndividualAddressSerialNumberReadPDU{SerialNumber: [6]byte{0x00, 0xc5, 0x01, 0x02, 0x03, 0x04}},
+		},
+		{
+			cemi.AppData{Command: cemi.Escape, Data: []byte{0x1a, 0x00, 0x00, 0x0b, 0x01}},
+			&NetworkParameterReadPDU{NetworkParameter{PropertyID: cemi.PIDSerialNumber, Data: []byte{0x01}}},
+		},
+		{
+			cemi.AppData{Command: cemi.Escape, Data: []byte{0x25, 0x01, 0x00}},
+			&LinkReadPDU{GroupObject: 1},
+		},
+		{
+			cemi.AppData{Command: cemi.Escape, Data: []byte{0x26, 0x01, 0x10, 0x09, 0x01, 0x09, 0x02}},
+			&LinkResponsePDU{GroupObject: 1, SendingIndex: 1, Addresses: []cemi.GroupAddr{0x0901, 0x0902}},
+		},
+		{
+			cemi.AppData{Command: cemi.Escape, Data: []byte{0x29, 0x00, 0x0b, 0x01, 0x3a, 0x2a}},
+			&GroupPropValueResponsePDU{GroupPropValue{
+				ObjectType: 0x0b, ObjectInstance: 1, PropertyID: 0x3a, Data: []byte{0x2a},
+			}},
+		},
+		{
+			cemi.AppData{Command: cemi.UserMessage, Data: []byte{0x04, 0x01, 0x40, 0x00, 0xf0, 0x01}},
+			&UserMemoryBitWritePDU{MemoryBit{Address: 0x4000, AndData: []byte{0xf0}, XorData: []byte{0x01}}},
+		},
+		{
+			cemi.AppData{Command: cemi.Escape, Data: []byte{0x0d}},
+			&Generic{Code: ReadRouterStatusRequest},
+		},
+		{
+			cemi.AppData{Command: cemi.Escape, Data: []byte{0x3f, 0xaa}},
+			&Generic{Code: 0x3ff, Data: []byte{0xaa}},
+		},
+	} {
+		pdu, err := Decode(&tc.app)
+		if err != nil {
+			t.Errorf("Decoding %+v failed: %v", tc.app, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(pdu, tc.expected) {
+			t.Errorf("Unexpected result %#v, expected %#v", pdu, tc.expected)
+		}
+	}
+}
+
+func TestDecode_Invalid(t *testing.T) {
+	for _, app := range []cemi.AppData{
+		{Command: cemi.MemoryRead},
+		{Command: cemi.MemoryRead, Data: []byte{0x04, 0x01}},
+		{Command: cemi.IndividualAddrRequest, Data: []byte{0x00, 0x01}},
+		{Command: cemi.Escape, Data: []byte{0x15, 0x00, 0x0b}},
+		{Command: cemi.Escape, Data: []byte{0x10, 0x02, 0x00, 0x00, 0xff}},
+		{Command: cemi.AdcResponse, Data: []byte{0x3b, 0x02, 0x00, 0x00, 0x00, 0x01}},
+		{Command: cemi.Escape, Data: []byte{0x26, 0x01, 0x10, 0x09}},
+		{Command: cemi.MemoryResponse, Data: []byte{0x02, 0x40, 0x00, 0x01, 0x02, 0x03}},
+		{Command: cemi.MemoryWrite, Data: []byte{0x03, 0x40, 0x00, 0x01}},
+		{Command: cemi.UserMessage, Data: []byte{0x01, 0x02, 0x00, 0x10, 0x01}},
+	} {
+		if pdu, err := Decode(&app); err == nil {
+			t.Errorf("Expected an error for %+v, got %#v", app, pdu)
+		}
+	}
+
+	app := cemi.AppData{Command: cemi.IndividualAddrRequest, Data: []byte{0x00, 0x01}}
+	if _, err := Decode(&app); !errors.Is(err, ErrExcessData) {
+		t.Errorf("Unexpected error %v", err)
+	}
+
+	app = cemi.AppData{Command: cemi.MemoryRead, Data: []byte{0x04, 0x01}}
+	if _, err := Decode(&app); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("Unexpected error %v", err)
+	}
+}
+
+func TestEncode_DataTooLong(t *testing.T) {
+	for _, pdu := range []PDU{
+		&MemoryWritePDU{Memory{Data: make([]byte, 64)}},
+		&MemoryResponsePDU{Memory{Data: make([]byte, 256)}},
+		&UserMemoryWritePDU{UserMemory{Data: make([]byte, 16)}},
+		&MemoryExtendedWritePDU{Data: make([]byte, 256)},
+		&MemoryBitWritePDU{MemoryBit{AndData: []byte{0xff}, XorData: []byte{0x01, 0x02}}},
+	} {
+		if _, err := Encode(pdu); !errors.Is(err, ErrDataTooLong) {
+			t.Errorf("Unexpected error %v for %T", err, pdu)
+		}
+	}
+
+	if _, err := Encode(&MemoryWritePDU{Memory{Data: make([]byte, 63)}}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestEncode_RoundTrip(t *testing.T) {
+	for _, pdu := range []PDU{
+		&GroupValueReadPDU{},
+		&GroupValueWritePDU{GroupValue{Data: []byte{0x01}}},
+		&GroupValueWritePDU{GroupValue{Data: []byte{0x00, 0x12, 0x34}}},
+		&IndividualAddressWritePDU{Address: 0x1102},
+		&IndividualAddressReadPDU{},
+		&ADCReadPDU{Channel: 3, Count: 8},
+		&MemoryReadPDU{Count: 12, Address: 0x4000},
+		&MemoryWritePDU{Memory{Address: 0x4000, Data: []byte{1, 2, 3}}},
+		&MemoryBitWritePDU{MemoryBit{Address: 0x10, AndData: []byte{0xf0, 0x0f}, XorData: []byte{0x01, 0x02}}},
+		&UserMemoryBitWritePDU{MemoryBit{Address: 0x20, AndData: []byte{0xff}, XorData: []byte{0x80}}},
+		&UserMemoryReadPDU{Count: 4, Address: 0x12345},
+		&UserMemoryWritePDU{UserMemory{Address: 0xf0001, Data: []byte{9}}},
+		&MemoryExtendedWritePDU{Address: 0x123456, Data: []byte{1, 2}},
+		&MemoryExtendedReadResponsePDU{MemoryExtendedResult{Address: 0x123456, Data: []byte{3, 4}}},
+		&MemoryExtendedWriteResponsePDU{MemoryExtendedResult{ReturnCode: 0xf1, Address: 0x10}},
+		&DeviceDescriptorResponsePDU{Type: 2, Descriptor: []byte{0, 0xc5, 8, 1}},
+		&RestartPDU{},
+		&RestartPDU{MasterReset: true, EraseCode: 3, Channel: 1},
+		&RestartResponsePDU{ErrorCode: 1, ProcessTime: 6},
+		&AuthorizeResponsePDU{Level: 3},
+		&KeyResponsePDU{Level: 3},
+		&UserManufacturerInfoResponsePDU{Manufacturer: 0xc5, Info: 0x1234},
+		&PropertyValueResponsePDU{PropertyValue{ObjectIndex: 3, PropertyID: 54, Count: 1, StartIndex: 1, Data: []byte{1}}},
+		&PropertyValueWritePDU{PropertyValue{ObjectIndex: 0, PropertyID: 54, Count: 15, StartIndex: 0xfff}},
+		&PropertyDescriptionReadPDU{ObjectIndex: 1, PropertyIndex: 4},
+		&PropertyDescriptionResponsePDU{
+			ObjectIndex: 1, PropertyID: 54, PropertyIndex: 4, WriteEnable: true, Type: 0x11,
+			MaxCount: 0xabc, ReadLevel: 3, WriteLevel: 1,
+		},
+		&FunctionPropertyCommandPDU{FunctionProperty{ObjectIndex: 2, PropertyID: 60, Data: []byte{0, 1}}},
+		&FunctionPropertyStateResponsePDU{FunctionProperty{ObjectIndex: 2, PropertyID: 60, Data: []byte{0}}},
+		&PropertyExtValueReadPDU{PropertyExtValue{ObjectType: cemi.RFMediumObject, ObjectInstance: 0xabc,
+			PropertyID: 0xdef, Count: 1, StartIndex: 0x102}},
+		&PropertyExtValueWriteConPDU{PropertyExtValue{ObjectType: 0x0b, ObjectInstance: 1, PropertyID: 52,
+			Count: 1, StartIndex: 1, Data: []byte{0x11, 0x02}}},
+		&NetworkParameterResponsePDU{NetworkParameter{ObjectType: 0, PropertyID: 11, Data: []byte{1, 2}}},
+		&SystemNetworkParameterWritePDU{SystemNetworkParameter{ObjectType: 0, PropertyID: 0xabc, Data: []byte{1}}},
+		&IndividualAddressSerialNumberResponsePDU{SerialNumber: [6]byte{1, 2, 3, 4, 5, 6}, DomainAddress: 0x1234},
+		&IndividualAddressSerialNumberWritePDU{SerialNumber: [6]byte{1, 2, 3, 4, 5, 6}, Address: 0x1105},
+		&DomainAddressWritePDU{DomainAddress{DomainAddress: []byte{0x12, 0x34}}},
+		&DomainAddressReadPDU{},
+		&DomainAddressSerialNumberWritePDU{SerialDomainAddress{SerialNumber: [6]byte{1}, DomainAddress: []byte{1, 2}}},
+		&PropertyExtDescriptionReadPDU{
+			ObjectType: 0x0b, ObjectInstance: 0xabc, PropertyID: 0x123, DescriptionType: 1, PropertyIndex: 0x456,
+		},
+		&PropertyExtDescriptionResponsePDU{
+			ObjectType: 0x0b, ObjectInstance: 1, PropertyID: 52, DescriptionType: 2, PropertyIndex: 7,
+			DatapointMain: 9, DatapointSub: 1, WriteEnable: true, Type: 0x11, MaxCount: 0xabc, ReadLevel: 3, WriteLevel: 1,
+		},
+		&FunctionPropertyExtCommandPDU{FunctionPropertyExt{ObjectType: 0x0b, ObjectInstance: 2, PropertyID: 60,
+			Data: []byte{0, 1}}},
+		&FunctionPropertyExtStateResponsePDU{FunctionPropertyExt{ObjectType: 0x0b, ObjectInstance: 0xfff,
+			PropertyID: 0xfff, Data: []byte{0}}},
+		&LinkReadPDU{GroupObject: 3, StartIndex: 2},
+		&LinkResponsePDU{GroupObject: 3, SendingIndex: 2, StartIndex: 1, Addresses: []cemi.GroupAddr{0x0a01}},
+		&LinkWritePDU{GroupObject: 3, Flags: 1, Address: 0x0a01},
+		&GroupPropValueWritePDU{GroupPropValue{ObjectType: 0x0b, ObjectInstance: 1, PropertyID: 52, Data: []byte{1}}},
+		&GroupPropValueInfoReportPDU{GroupPropValue{ObjectType: 0x0b, ObjectInstance: 1, PropertyID: 52}},
+		&Generic{Code: ReadRouterStatusResponse, Data: []byte{0x80}},
+	} {
+		encoded, err := Encode(pdu)
+		if err != nil {
+			t.Fatalf("Encoding %T failed: %v", pdu, err)
+		}
+
+		ldata := cemi.LData{
+			Control1:    cemi.Control1StdFrame | cemi.Control1Prio(cemi.PrioSystem),
+			Control2:    cemi.Control2Hops(6),
+			Source:      0x1101,
+			Destination: 0x1102,
+			Data:        encoded,
+		}
+
+		buffer := make([]byte, ldata.Size())
+		ldata.Pack(buffer)
+
+		var result cemi.LData
+		if _, err := result.Unpack(buffer); err != nil {
+			t.Fatalf("Unpacking %T failed: %v", pdu, err)
+		}
+
+		app, ok := result.Data.(*cemi.AppData)
+		if !ok {
+			t.Fatalf("Unexpected transport unit %T", result.Data)
+		}
+
+		decoded, err := Decode(app)
+		if err != nil {
+			t.Errorf("Decoding %T failed: %v", pdu, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(decoded, pdu) {
+			t.Errorf("Unexpected result %#v, expected %#v", decoded, pdu)
+		}
+	}
+}
+
+func TestAPCI_Coverage(t *testing.T) {
+	for apci, name := range apciNames {
+		if apci.Service() != apci {
+			t.Errorf("%s is not its own service", name)
+		}
+
+		if apci.String() != name {
+			t.Errorf("Unexpected name %s for %s", apci.String(), name)
+		}
+
+		// Every service must round-trip with its zero parameters.
+		app, err := Encode(newPDU(apci))
+		if err != nil {
+			t.Errorf("Encoding %s failed: %v", name, err)
+			continue
+		}
+
+		pdu, err := Decode(app)
+		if err != nil {
+			t.Errorf("Decoding %s failed: %v", name, err)
+			continue
+		}
+
+		if pdu.APCI() != apci {
+			t.Errorf("Unexpected service %v for %s", pdu.APCI(), name)
+		}
+	}
+}
+
+func TestAPCI_Service(t *testing.T) {
+	for apci, service := range map[APCI]APCI{
+		0x081: GroupValueWrite,
+		0x23f: MemoryRead,
+		0x1c1: ADCResponse,
+		0x1c8: SystemNetworkParameterRead,
+		0x1fd: MemoryExtendedRead,
+		0x381: Restart,
+		0x3a1: RestartResponse,
+		0x3d5: PropertyValueRead,
+		0x3ff: 0x3ff,
+	} {
+		if apci.Service() != service {
+			t.Errorf("Unexpected service %v for %#x, expected %v", apci.Service(), uint16(apci), service)
+		}
+	}
+
+	if APCI(0x3ff).String() != "0x3ff" {
+		t.Errorf("Unexpected name %s", APCI(0x3ff))
+	}
+}
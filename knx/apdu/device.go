@@ -0,0 +1,356 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package apdu
+
+import "github.com/mobilarte/knx-exp/knx/util"
+
+// An ADCReadPDU is an A_ADC_Read. It requests the sum of Count conversions of the channel.
+type ADCReadPDU struct {
+	Channel uint8 // 6 bits
+	Count   uint8
+}
+
+// APCI returns ADCRead.
+func (ADCReadPDU) APCI() APCI {
+	return ADCRead
+}
+
+// Size returns the packed size.
+func (*ADCReadPDU) Size() uint {
+	return 1
+}
+
+// Pack the parameters into the buffer.
+func (pdu *ADCReadPDU) Pack(buffer []byte) {
+	buffer[0] = pdu.Count
+}
+
+// Unpack initializes the structure by parsing the given data.
+func (pdu *ADCReadPDU) Unpack(data []byte) (uint, error) {
+	return util.Unpack(data, &pdu.Count)
+}
+
+func (pdu *ADCReadPDU) short() uint8 {
+	return pdu.Channel
+}
+
+func (pdu *ADCReadPDU) setShort(bits uint8) {
+	pdu.Channel = bits
+}
+
+// An ADCResponsePDU is an A_ADC_Response.
+type ADCResponsePDU struct {
+	Channel uint8 // 6 bits
+	Count   uint8
+	Sum     uint16
+}
+
+// APCI returns ADCResponse.
+func (ADCResponsePDU) APCI() APCI {
+	return ADCResponse
+}
+
+// Size returns the packed size.
+func (*ADCResponsePDU) Size() uint {
+	return 3
+}
+
+// Pack the parameters into the buffer.
+func (pdu *ADCResponsePDU) Pack(buffer []byte) {
+	util.PackSome(buffer, pdu.Count, pdu.Sum)
+}
+
+// Unpack initializes the structure by parsing the given data.
+func (pdu *ADCResponsePDU) Unpack(data []byte) (uint, error) {
+	return util.UnpackSome(data, &pdu.Count, &pdu.Sum)
+}
+
+func (pdu *ADCResponsePDU) short() uint8 {
+	return pdu.Channel
+}
+
+func (pdu *ADCResponsePDU) setShort(bits uint8) {
+	pdu.Channel = bits
+}
+
+// A DeviceDescriptorReadPDU is an A_DeviceDescriptor_Read.
+type DeviceDescriptorReadPDU struct {
+	Type uint8 // 6 bits
+}
+
+// APCI returns DeviceDescriptorRead.
+func (DeviceDescriptorReadPDU) APCI() APCI {
+	return DeviceDescriptorRead
+}
+
+// Size returns the packed size.
+func (*DeviceDescriptorReadPDU) Size() uint {
+	return 0
+}
+
+// Pack the parameters into the buffer.
+func (*DeviceDescriptorReadPDU) Pack([]byte) {}
+
+// Unpack initializes the structure by parsing the given data.
+func (*DeviceDescriptorReadPDU) Unpack([]byte) (uint, error) {
+	return 0, nil
+}
+
+func (pdu *DeviceDescriptorReadPDU) short() uint8 {
+	return pdu.Type
+}
+
+func (pdu *DeviceDescriptorReadPDU) setShort(bits uint8) {
+	pdu.Type = bits
+}
+
+// A DeviceDescriptorResponsePDU is an A_DeviceDescriptor_Response. Device descriptor type 0 is
+// the 2 octet mask version.
+type DeviceDescriptorResponsePDU struct {
+	Type       uint8 // 6 bits
+	Descriptor []byte
+}
+
+// APCI returns DeviceDescriptorResponse.
+func (DeviceDescriptorResponsePDU) APCI() APCI {
+	return DeviceDescriptorResponse
+}
+
+// Size returns the packed size.
+func (pdu *DeviceDescriptorResponsePDU) Size() uint {
+	return uint(len(pdu.Descriptor))
+}
+
+// Pack the parameters into the buffer.
+func (pdu *DeviceDescriptorResponsePDU) Pack(buffer []byte) {
+	copy(buffer, pdu.Descriptor)
+}
+
+// Unpack initializes the structure by parsing the given data.
+func (pdu *DeviceDescriptorResponsePDU) Unpack(data []byte) (uint, error) {
+	pdu.Descriptor = append([]byte(nil), data...)
+
+	return uint(len(data)), nil
+}
+
+func (pdu *DeviceDescriptorResponsePDU) short() uint8 {
+	return pdu.Type
+}
+
+func (pdu *DeviceDescriptorResponsePDU) setShort(bits uint8) {
+	pdu.Type = bits
+}
+
+// A RestartPDU is an A_Restart. A master reset carries the erase code and the channel.
+type RestartPDU struct {
+	MasterReset bool
+	EraseCode   uint8
+	Channel     uint8
+}
+
+// APCI returns Restart.
+func (RestartPDU) APCI() APCI {
+	return Restart
+}
+
+// Size returns the packed size.
+func (pdu *RestartPDU) Size() uint {
+	if pdu.MasterReset {
+		return 2
+	}
+
+	return 0
+}
+
+// Pack the parameters into the buffer.
+func (pdu *RestartPDU) Pack(buffer []byte) {
+	if pdu.MasterReset {
+		util.PackSome(buffer, pdu.EraseCode, pdu.Channel)
+	}
+}
+
+// Unpack initializes the structure by parsing the given data.
+func (pdu *RestartPDU) Unpack(data []byte) (uint, error) {
+	if !pdu.MasterReset {
+		return 0, nil
+	}
+
+	return util.UnpackSome(data, &pdu.EraseCode, &pdu.Channel)
+}
+
+func (pdu *RestartPDU) short() uint8 {
+	if pdu.MasterReset {
+		return 1
+	}
+
+	return 0
+}
+
+func (pdu *RestartPDU) setShort(bits uint8) {
+	pdu.MasterReset = bits&1 != 0
+}
+
+// A RestartResponsePDU is an A_Restart_Response, which answers a master reset.
+type RestartResponsePDU struct {
+	ErrorCode   uint8
+	ProcessTime uint16 // seconds
+}
+
+// APCI returns RestartResponse.
+func (RestartResponsePDU) APCI() APCI {
+	return RestartResponse
+}
+
+// Size returns the packed size.
+func (*RestartResponsePDU) Size() uint {
+	return 3
+}
+
+// Pack the parameters into the buffer.
+func (pdu *RestartResponsePDU) Pack(buffer []byte) {
+	util.PackSome(buffer, pdu.ErrorCode, pdu.ProcessTime)
+}
+
+// Unpack initializes the structure by parsing the given data.
+func (pdu *RestartResponsePDU) Unpack(data []byte) (uint, error) {
+	return util.UnpackSome(data, &pdu.ErrorCode, &pdu.ProcessTime)
+}
+
+// An AuthorizeRequestPDU is an A_Authorize_Request.
+type AuthorizeRequestPDU struct {
+	Key uint32
+}
+
+// APCI returns AuthorizeRequest.
+func (AuthorizeRequestPDU) APCI() APCI {
+	return AuthorizeRequest
+}
+
+// Size returns the packed size.
+func (*AuthorizeRequestPDU) Size() uint {
+	return 5
+}
+
+// Pack the parameters into the buffer.
+func (pdu *AuthorizeRequestPDU) Pack(buffer []byte) {
+	util.PackSome(buffer, uint8(0), pdu.Key)
+}
+
+// Unpack initializes the structure by parsing the given data.
+func (pdu *AuthorizeRequestPDU) Unpack(data []byte) (uint, error) {
+	var reserved uint8
+
+	return util.UnpackSome(data, &reserved, &pdu.Key)
+}
+
+// An AuthorizeResponsePDU is an A_Authorize_Response. It carries the granted access level.
+type AuthorizeResponsePDU struct {
+	Level uint8
+}
+
+// APCI returns AuthorizeResponse.
+func (AuthorizeResponsePDU) APCI() APCI {
+	return AuthorizeResponse
+}
+
+// Size returns the packed size.
+func (*AuthorizeResponsePDU) Size() uint {
+	return 1
+}
+
+// Pack the parameters into the buffer.
+func (pdu *AuthorizeResponsePDU) Pack(buffer []byte) {
+	buffer[0] = pdu.Level
+}
+
+// Unpack initializes the structure by parsing the given data.
+func (pdu *AuthorizeResponsePDU) Unpack(data []byte) (uint, error) {
+	return util.Unpack(data, &pdu.Level)
+}
+
+// A KeyWritePDU is an A_Key_Write. It sets the key of an access level.
+type KeyWritePDU struct {
+	Level uint8
+	Key   uint32
+}
+
+// APCI returns KeyWrite.
+func (KeyWritePDU) APCI() APCI {
+	return KeyWrite
+}
+
+// Size returns the packed size.
+func (*KeyWritePDU) Size() uint {
+	return 5
+}
+
+// Pack the parameters into the buffer.
+func (pdu *KeyWritePDU) Pack(buffer []byte) {
+	util.PackSome(buffer, pdu.Level, pdu.Key)
+}
+
+// Unpack initializes the structure by parsing the given data.
+func (pdu *KeyWritePDU) Unpack(data []byte) (uint, error) {
+	return util.UnpackSome(data, &pdu.Level, &pdu.Key)
+}
+
+// A KeyResponsePDU is an A_Key_Response. It carries the changed access level.
+type KeyResponsePDU struct {
+	Level uint8
+}
+
+// APCI returns KeyResponse.
+func (KeyResponsePDU) APCI() APCI {
+	return KeyResponse
+}
+
+// Size returns the packed size.
+func (*KeyResponsePDU) Size() uint {
+	return 1
+}
+
+// Pack the parameters into the buffer.
+func (pdu *KeyResponsePDU) Pack(buffer []byte) {
+	buffer[0] = pdu.Level
+}
+
+// Unpack initializes the structure by parsing the given data.
+func (pdu *KeyResponsePDU) Unpack(data []byte) (uint, error) {
+	return util.Unpack(data, &pdu.Level)
+}
+
+// A UserManufacturerInfoReadPDU is an A_UserManufacturerInfo_Read.
+type UserManufacturerInfoReadPDU struct {
+	noParameters
+}
+
+// APCI returns UserManufacturerInfoRead.
+func (UserManufacturerInfoReadPDU) APCI() APCI {
+	return UserManufacturerInfoRead
+}
+
+// A UserManufacturerInfoResponsePDU is an A_UserManufacturerInfo_Response.
+type UserManufacturerInfoResponsePDU struct {
+	Manufacturer uint8
+	Info         uint16
+}
+
+// APCI returns UserManufacturerInfoResponse.
+func (UserManufacturerInfoResponsePDU) APCI() APCI {
+	return UserManufacturerInfoResponse
+}
+
+// Size returns the packed size.
+func (*UserManufacturerInfoResponsePDU) Size() uint {
+	return 3
+}
+
+// Pack the parameters into the buffer.
+func (pdu *UserManufacturerInfoResponsePDU) Pack(buffer []byte) {
+	util.PackSome(buffer, pdu.Manufacturer, pdu.Info)
+}
+
+// Unpack initializes the structure by parsing the given data.
+func (pdu *UserManufacturerInfoResponsePDU) Unpack(data []byte) (uint, error) {
+	return util.UnpackSome(data, &pdu.Manufacturer, &pdu.Info)
+}
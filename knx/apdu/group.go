@@ -0,0 +1,222 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package apdu
+
+import (
+	"io"
+
+	"github.com/mobilarte/knx-exp/knx/cemi"
+	"github.com/mobilarte/knx-exp/knx/util"
+)
+
+// noParameters implements the PDU methods of services without parameters.
+type noParameters struct{}
+
+// Size returns the packed size.
+func (noParameters) Size() uint {
+	return 0
+}
+
+// Pack the parameters into the buffer.
+func (noParameters) Pack([]byte) {}
+
+// Unpack initializes the structure by parsing the given data.
+func (*noParameters) Unpack([]byte) (uint, error) {
+	return 0, nil
+}
+
+// A GroupValueReadPDU is an A_GroupValue_Read.
+type GroupValueReadPDU struct {
+	noParameters
+}
+
+// APCI returns GroupValueRead.
+func (GroupValueReadPDU) APCI() APCI {
+	return GroupValueRead
+}
+
+// GroupValue holds the value of A_GroupValue_Response and A_GroupValue_Write. As in
+// cemi.AppData, values of up to 6 bits are carried in the first octet of Data, larger values
+// follow it.
+type GroupValue struct {
+	Data []byte
+}
+
+// Size returns the packed size.
+func (gv *GroupValue) Size() uint {
+	if len(gv.Data) == 0 {
+		return 0
+	}
+
+	return uint(len(gv.Data) - 1)
+}
+
+// Pack the parameters into the buffer.
+func (gv *GroupValue) Pack(buffer []byte) {
+	if len(gv.Data) > 0 {
+		copy(buffer, gv.Data[1:])
+	}
+}
+
+// Unpack initializes the structure by parsing the given data.
+func (gv *GroupValue) Unpack(data []byte) (uint, error) {
+	var first byte
+	if len(gv.Data) > 0 {
+		first = gv.Data[0]
+	}
+
+	gv.Data = append([]byte{first}, data...)
+
+	return uint(len(data)), nil
+}
+
+// short returns the value bits of the first octet.
+func (gv *GroupValue) short() uint8 {
+	if len(gv.Data) == 0 {
+		return 0
+	}
+
+	return gv.Data[0] & 0x3F
+}
+
+// setShort sets the value bits of the first octet.
+func (gv *GroupValue) setShort(bits uint8) {
+	gv.Data = []byte{bits}
+}
+
+// A GroupValueResponsePDU is an A_GroupValue_Response.
+type GroupValueResponsePDU struct {
+	GroupValue
+}
+
+// APCI returns GroupValueResponse.
+func (GroupValueResponsePDU) APCI() APCI {
+	return GroupValueResponse
+}
+
+// A GroupValueWritePDU is an A_GroupValue_Write.
+type GroupValueWritePDU struct {
+	GroupValue
+}
+
+// APCI returns GroupValueWrite.
+func (GroupValueWritePDU) APCI() APCI {
+	return GroupValueWrite
+}
+
+// A LinkReadPDU is an A_Link_Read. It reads the group addresses of a group object, beginning at
+// StartIndex.
+type LinkReadPDU struct {
+	GroupObject uint8
+	StartIndex  uint8 // 4 bits
+}
+
+// APCI returns LinkRead.
+func (LinkReadPDU) APCI() APCI {
+	return LinkRead
+}
+
+// Size returns the packed size.
+func (*LinkReadPDU) Size() uint {
+	return 2
+}
+
+// Pack the parameters into the buffer.
+func (pdu *LinkReadPDU) Pack(buffer []byte) {
+	util.PackSome(buffer, pdu.GroupObject, pdu.StartIndex&0xF)
+}
+
+// Unpack initializes the structure by parsing the given data.
+func (pdu *LinkReadPDU) Unpack(data []byte) (uint, error) {
+	n, err := util.UnpackSome(data, &pdu.GroupObject, &pdu.StartIndex)
+	pdu.StartIndex &= 0xF
+
+	return n, err
+}
+
+// A LinkResponsePDU is an A_Link_Response. SendingIndex is the index of the sending address among
+// all addresses of the group object, Addresses are those beginning at StartIndex.
+type LinkResponsePDU struct {
+	GroupObject  uint8
+	SendingIndex uint8 // 4 bits
+	StartIndex   uint8 // 4 bits
+	Addresses    []cemi.GroupAddr
+}
+
+// APCI returns LinkResponse.
+func (LinkResponsePDU) APCI() APCI {
+	return LinkResponse
+}
+
+// Size returns the packed size.
+func (pdu *LinkResponsePDU) Size() uint {
+	return 2 + 2*uint(len(pdu.Addresses))
+}
+
+// Pack the parameters into the buffer.
+func (pdu *LinkResponsePDU) Pack(buffer []byte) {
+	util.PackSome(buffer, pdu.GroupObject, pdu.SendingIndex&0xF<<4|pdu.StartIndex&0xF)
+
+	for i, addr := range pdu.Addresses {
+		util.Pack(buffer[2+2*i:], uint16(addr))
+	}
+}
+
+// Unpack initializes the structure by parsing the given data.
+func (pdu *LinkResponsePDU) Unpack(data []byte) (uint, error) {
+	var indices uint8
+
+	n, err := util.UnpackSome(data, &pdu.GroupObject, &indices)
+	if err != nil {
+		return n, err
+	}
+
+	pdu.SendingIndex = indices >> 4
+	pdu.StartIndex = indices & 0xF
+
+	if (uint(len(data))-n)%2 != 0 {
+		return n, io.ErrUnexpectedEOF
+	}
+
+	pdu.Addresses = make([]cemi.GroupAddr, 0, (uint(len(data))-n)/2)
+
+	for ; n < uint(len(data)); n += 2 {
+		var addr uint16
+
+		if _, err := util.Unpack(data[n:], &addr); err != nil {
+			return n, err
+		}
+
+		pdu.Addresses = append(pdu.Addresses, cemi.GroupAddr(addr))
+	}
+
+	return n, nil
+}
+
+// A LinkWritePDU is an A_Link_Write. Flags select whether the address is added to or deleted from
+// the group object, and whether it becomes the sending address.
+type LinkWritePDU struct {
+	GroupObject uint8
+	Flags       uint8
+	Address     cemi.GroupAddr
+}
+
+// APCI returns LinkWrite.
+func (LinkWritePDU) APCI() APCI {
+	return LinkWrite
+}
+
+// Size returns the packed size.
+func (*LinkWritePDU) Size() uint {
+	return 4
+}
+
+// Pack the parameters into the buffer.
+func (pdu *LinkWritePDU) Pack(buffer []byte) {
+	util.PackSome(buffer, pdu.GroupObject, pdu.Flags, uint16(pdu.Address))
+}
+
+// Unpack initializes the structure by parsing the given data.
+func (pdu *LinkWritePDU) Unpack(data []byte) (uint, error) {
+	return util.UnpackSome(data, &pdu.GroupObject, &pdu.Flags, (*uint16)(&pdu.Address))
+}
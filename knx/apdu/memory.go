@@ -0,0 +1,438 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package apdu
+
+import (
+	"fmt"
+
+	"github.com/mobilarte/knx-exp/knx/util"
+)
+
+// A MemoryReadPDU is an A_Memory_Read.
+type MemoryReadPDU struct {
+	Count   uint8 // 6 bits
+	Address uint16
+}
+
+// APCI returns MemoryRead.
+func (MemoryReadPDU) APCI() APCI {
+	return MemoryRead
+}
+
+// Size returns the packed size.
+func (*MemoryReadPDU) Size() uint {
+	return 2
+}
+
+// Pack the parameters into the buffer.
+func (pdu *MemoryReadPDU) Pack(buffer []byte) {
+	util.Pack(buffer, pdu.Address)
+}
+
+// Unpack initializes the structure by parsing the given data.
+func (pdu *MemoryReadPDU) Unpack(data []byte) (uint, error) {
+	return util.Unpack(data, &pdu.Address)
+}
+
+func (pdu *MemoryReadPDU) short() uint8 {
+	return pdu.Count
+}
+
+func (pdu *MemoryReadPDU) setShort(bits uint8) {
+	pdu.Count = bits
+}
+
+// Memory holds the parameters of A_Memory_Response and A_Memory_Write. The count is the length of
+// Data, which is limited to 63 octets.
+type Memory struct {
+	Address uint16
+	Data    []byte
+}
+
+// Size returns the packed size.
+func (mem *Memory) Size() uint {
+	return 2 + uint(len(mem.Data))
+}
+
+// Pack the parameters into the buffer.
+func (mem *Memory) Pack(buffer []byte) {
+	util.PackSome(buffer, mem.Address, mem.Data)
+}
+
+// Unpack initializes the structure by parsing the given data. The length of Data must be the
+// count, as set up by Decode; the data must match it.
+func (mem *Memory) Unpack(data []byte) (uint, error) {
+	n, err := util.Unpack(data, &mem.Address)
+	if err != nil {
+		return n, err
+	}
+
+	if len(mem.Data) != len(data)-int(n) {
+		return n, fmt.Errorf("count %d does not match %d octets of data", len(mem.Data), len(data)-int(n))
+	}
+
+	return unpackRest(data, n, &mem.Data)
+}
+
+func (mem *Memory) check() error {
+	if len(mem.Data) > 0x3F {
+		return ErrDataTooLong
+	}
+
+	return nil
+}
+
+func (mem *Memory) short() uint8 {
+	return uint8(len(mem.Data))
+}
+
+// setShort keeps the count as the length of Data until Unpack has checked it.
+func (mem *Memory) setShort(bits uint8) {
+	mem.Data = make([]byte, bits)
+}
+
+// A MemoryResponsePDU is an A_Memory_Response. A device answers with no data if it cannot read
+// the memory.
+type MemoryResponsePDU struct {
+	Memory
+}
+
+// APCI returns MemoryResponse.
+func (MemoryResponsePDU) APCI() APCI {
+	return MemoryResponse
+}
+
+// A MemoryWritePDU is an A_Memory_Write.
+type MemoryWritePDU struct {
+	Memory
+}
+
+// APCI returns MemoryWrite.
+func (MemoryWritePDU) APCI() APCI {
+	return MemoryWrite
+}
+
+// MemoryBit holds the parameters of A_MemoryBit_Write and A_UserMemoryBit_Write. Each bit of the
+// memory is combined with the corresponding bits of AndData and XorData. The count is the length
+// of AndData, which is limited to 255 octets; a shorter XorData is padded with zeros.
+type MemoryBit struct {
+	Address uint16
+	AndData []byte
+	XorData []byte
+}
+
+// Size returns the packed size.
+func (mem *MemoryBit) Size() uint {
+	return 3 + 2*uint(len(mem.AndData))
+}
+
+// Pack the parameters into the buffer.
+func (mem *MemoryBit) Pack(buffer []byte) {
+	count := len(mem.AndData)
+
+	xor := make([]byte, count)
+	copy(xor, mem.XorData)
+
+	util.PackSome(buffer, uint8(count), mem.Address, mem.AndData, xor)
+}
+
+// Unpack initializes the structure by parsing the given data.
+func (mem *MemoryBit) Unpack(data []byte) (uint, error) {
+	var count uint8
+
+	n, err := util.UnpackSome(data, &count, &mem.Address)
+	if err != nil {
+		return n, err
+	}
+
+	mem.AndData = make([]byte, count)
+	mem.XorData = make([]byte, count)
+
+	m, err := util.UnpackSome(data[n:], mem.AndData, mem.XorData)
+
+	return n + m, err
+}
+
+func (mem *MemoryBit) check() error {
+	if len(mem.AndData) > 0xFF || len(mem.XorData) > len(mem.AndData) {
+		return ErrDataTooLong
+	}
+
+	return nil
+}
+
+// A MemoryBitWritePDU is an A_MemoryBit_Write.
+type MemoryBitWritePDU struct {
+	MemoryBit
+}
+
+// APCI returns MemoryBitWrite.
+func (MemoryBitWritePDU) APCI() APCI {
+	return MemoryBitWrite
+}
+
+// A UserMemoryReadPDU is an A_UserMemory_Read.
+type UserMemoryReadPDU struct {
+	Count   uint8  // 4 bits
+	Address uint32 // 20 bits
+}
+
+// APCI returns UserMemoryRead.
+func (UserMemoryReadPDU) APCI() APCI {
+	return UserMemoryRead
+}
+
+// Size returns the packed size.
+func (*UserMemoryReadPDU) Size() uint {
+	return 3
+}
+
+// Pack the parameters into the buffer.
+func (pdu *UserMemoryReadPDU) Pack(buffer []byte) {
+	packUserMemory(buffer, pdu.Count, pdu.Address)
+}
+
+// Unpack initializes the structure by parsing the given data.
+func (pdu *UserMemoryReadPDU) Unpack(data []byte) (uint, error) {
+	return unpackUserMemory(data, &pdu.Count, &pdu.Address)
+}
+
+// UserMemory holds the parameters of A_UserMemory_Response and A_UserMemory_Write. The count is
+// the length of Data, which is limited to 15 octets.
+type UserMemory struct {
+	Address uint32 // 20 bits
+	Data    []byte
+}
+
+// Size returns the packed size.
+func (mem *UserMemory) Size() uint {
+	return 3 + uint(len(mem.Data))
+}
+
+// Pack the parameters into the buffer.
+func (mem *UserMemory) Pack(buffer []byte) {
+	packUserMemory(buffer, uint8(len(mem.Data)), mem.Address)
+	copy(buffer[3:], mem.Data)
+}
+
+// Unpack initializes the structure by parsing the given data.
+func (mem *UserMemory) Unpack(data []byte) (uint, error) {
+	var count uint8
+
+	n, err := unpackUserMemory(data, &count, &mem.Address)
+	if err != nil {
+		return n, err
+	}
+
+	if int(count) != len(data)-int(n) {
+		return n, fmt.Errorf("count %d does not match %d octets of data", count, len(data)-int(n))
+	}
+
+	return unpackRest(data, n, &mem.Data)
+}
+
+func (mem *UserMemory) check() error {
+	if len(mem.Data) > 0xF {
+		return ErrDataTooLong
+	}
+
+	return nil
+}
+
+// A UserMemoryResponsePDU is an A_UserMemory_Response.
+type UserMemoryResponsePDU struct {
+	UserMemory
+}
+
+// APCI returns UserMemoryResponse.
+func (UserMemoryResponsePDU) APCI() APCI {
+	return UserMemoryResponse
+}
+
+// A UserMemoryWritePDU is an A_UserMemory_Write.
+type UserMemoryWritePDU struct {
+	UserMemory
+}
+
+// APCI returns UserMemoryWrite.
+func (UserMemoryWritePDU) APCI() APCI {
+	return UserMemoryWrite
+}
+
+// A UserMemoryBitWritePDU is an A_UserMemoryBit_Write.
+type UserMemoryBitWritePDU struct {
+	MemoryBit
+}
+
+// APCI returns UserMemoryBitWrite.
+func (UserMemoryBitWritePDU) APCI() APCI {
+	return UserMemoryBitWrite
+}
+
+// packUserMemory packs the address extension and count octet, followed by the address.
+func packUserMemory(buffer []byte, count uint8, address uint32) {
+	util.PackSome(buffer, uint8(address>>16&0xF)<<4|count&0xF, uint16(address))
+}
+
+// unpackUserMemory parses the address extension and count octet, followed by the address.
+func unpackUserMemory(data []byte, count *uint8, address *uint32) (uint, error) {
+	var (
+		first uint8
+		low   uint16
+	)
+
+	n, err := util.UnpackSome(data, &first, &low)
+	if err != nil {
+		return n, err
+	}
+
+	*count = first & 0xF
+	*address = uint32(first>>4)<<16 | uint32(low)
+
+	return n, nil
+}
+
+// A MemoryExtendedReadPDU is an A_MemoryExtended_Read.
+type MemoryExtendedReadPDU struct {
+	Count   uint8
+	Address uint32 // 24 bits
+}
+
+// APCI returns MemoryExtendedRead.
+func (MemoryExtendedReadPDU) APCI() APCI {
+	return MemoryExtendedRead
+}
+
+// Size returns the packed size.
+func (*MemoryExtendedReadPDU) Size() uint {
+	return 4
+}
+
+// Pack the parameters into the buffer.
+func (pdu *MemoryExtendedReadPDU) Pack(buffer []byte) {
+	packMemoryExtended(buffer, pdu.Count, pdu.Address)
+}
+
+// Unpack initializes the structure by parsing the given data.
+func (pdu *MemoryExtendedReadPDU) Unpack(data []byte) (uint, error) {
+	return unpackMemoryExtended(data, &pdu.Count, &pdu.Address)
+}
+
+// A MemoryExtendedWritePDU is an A_MemoryExtended_Write. The count is the length of Data, which is
+// limited to 255 octets.
+type MemoryExtendedWritePDU struct {
+	Address uint32 // 24 bits
+	Data    []byte
+}
+
+// APCI returns MemoryExtendedWrite.
+func (MemoryExtendedWritePDU) APCI() APCI {
+	return MemoryExtendedWrite
+}
+
+// Size returns the packed size.
+func (pdu *MemoryExtendedWritePDU) Size() uint {
+	return 4 + uint(len(pdu.Data))
+}
+
+// Pack the parameters into the buffer.
+func (pdu *MemoryExtendedWritePDU) Pack(buffer []byte) {
+	packMemoryExtended(buffer, uint8(len(pdu.Data)), pdu.Address)
+	copy(buffer[4:], pdu.Data)
+}
+
+// Unpack initializes the structure by parsing the given data.
+func (pdu *MemoryExtendedWritePDU) Unpack(data []byte) (uint, error) {
+	var count uint8
+
+	n, err := unpackMemoryExtended(data, &count, &pdu.Address)
+	if err != nil {
+		return n, err
+	}
+
+	if int(count) != len(data)-int(n) {
+		return n, fmt.Errorf("count %d does not match %d octets of data", count, len(data)-int(n))
+	}
+
+	return unpackRest(data, n, &pdu.Data)
+}
+
+func (pdu *MemoryExtendedWritePDU) check() error {
+	if len(pdu.Data) > 0xFF {
+		return ErrDataTooLong
+	}
+
+	return nil
+}
+
+// MemoryExtendedResult holds the parameters of A_MemoryExtended_WriteResponse and
+// A_MemoryExtended_ReadResponse. Data is the memory content of a read response, or the CRC of the
+// written data if the write is confirmed.
+type MemoryExtendedResult struct {
+	ReturnCode uint8
+	Address    uint32 // 24 bits
+	Data       []byte
+}
+
+// Size returns the packed size.
+func (res *MemoryExtendedResult) Size() uint {
+	return 4 + uint(len(res.Data))
+}
+
+// Pack the parameters into the buffer.
+func (res *MemoryExtendedResult) Pack(buffer []byte) {
+	packMemoryExtended(buffer, res.ReturnCode, res.Address)
+	copy(buffer[4:], res.Data)
+}
+
+// Unpack initializes the structure by parsing the given data.
+func (res *MemoryExtendedResult) Unpack(data []byte) (uint, error) {
+	n, err := unpackMemoryExtended(data, &res.ReturnCode, &res.Address)
+	if err != nil {
+		return n, err
+	}
+
+	return unpackRest(data, n, &res.Data)
+}
+
+// A MemoryExtendedWriteResponsePDU is an A_MemoryExtended_WriteResponse.
+type MemoryExtendedWriteResponsePDU struct {
+	MemoryExtendedResult
+}
+
+// APCI returns MemoryExtendedWriteResponse.
+func (MemoryExtendedWriteResponsePDU) APCI() APCI {
+	return MemoryExtendedWriteResponse
+}
+
+// A MemoryExtendedReadResponsePDU is an A_MemoryExtended_ReadResponse.
+type MemoryExtendedReadResponsePDU struct {
+	MemoryExtendedResult
+}
+
+// APCI returns MemoryExtendedReadResponse.
+func (MemoryExtendedReadResponsePDU) APCI() APCI {
+	return MemoryExtendedReadResponse
+}
+
+// packMemoryExtended packs the leading octet, followed by the 24-bit address.
+func packMemoryExtended(buffer []byte, first uint8, address uint32) {
+	util.PackSome(buffer, first, uint8(address>>16), uint16(address))
+}
+
+// unpackMemoryExtended parses the leading octet, followed by the 24-bit address.
+func unpackMemoryExtended(data []byte, first *uint8, address *uint32) (uint, error) {
+	var (
+		high uint8
+		low  uint16
+	)
+
+	n, err := util.UnpackSome(data, first, &high, &low)
+	if err != nil {
+		return n, err
+	}
+
+	*address = uint32(high)<<16 | uint32(low)
+
+	return n, nil
+}
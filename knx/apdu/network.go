@@ -0,0 +1,377 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package apdu
+
+import (
+	"github.com/mobilarte/knx-exp/knx/cemi"
+	"github.com/mobilarte/knx-exp/knx/util"
+)
+
+// An IndividualAddressWritePDU is an A_IndividualAddress_Write. It is sent as broadcast and
+// accepted by the devices in programming mode.
+type IndividualAddressWritePDU struct {
+	Address cemi.IndividualAddr
+}
+
+// APCI returns IndividualAddressWrite.
+func (IndividualAddressWritePDU) APCI() APCI {
+	return IndividualAddressWrite
+}
+
+// Size returns the packed size.
+func (*IndividualAddressWritePDU) Size() uint {
+	return 2
+}
+
+// Pack the parameters into the buffer.
+func (pdu *IndividualAddressWritePDU) Pack(buffer []byte) {
+	util.Pack(buffer, uint16(pdu.Address))
+}
+
+// Unpack initializes the structure by parsing the given data.
+func (pdu *IndividualAddressWritePDU) Unpack(data []byte) (uint, error) {
+	return util.Unpack(data, (*uint16)(&pdu.Address))
+}
+
+// An IndividualAddressReadPDU is an A_IndividualAddress_Read.
+type IndividualAddressReadPDU struct {
+	noParameters
+}
+
+// APCI returns IndividualAddressRead.
+func (IndividualAddressReadPDU) APCI() APCI {
+	return IndividualAddressRead
+}
+
+// An IndividualAddressResponsePDU is an A_IndividualAddress_Response. The address is the source
+// of the frame.
+type IndividualAddressResponsePDU struct {
+	noParameters
+}
+
+// APCI returns IndividualAddressResponse.
+func (IndividualAddressResponsePDU) APCI() APCI {
+	return IndividualAddressResponse
+}
+
+// An IndividualAddressSerialNumberReadPDU is an A_IndividualAddressSerialNumber_Read.
+type IndividualAddressSerialNumberReadPDU struct {
+	SerialNumber [6]byte
+}
+
+// APCI returns IndividualAddressSerialNumberRead.
+func (IndividualAddressSerialNumberReadPDU) APCI() APCI {
+	return IndividualAddressSerialNumberRead
+}
+
+// Size returns the packed size.
+func (*IndividualAddressSerialNumberReadPDU) Size() uint {
+	return 6
+}
+
+// Pack the parameters into the buffer.
+func (pdu *IndividualAddressSerialNumberReadPDU) Pack(buffer []byte) {
+	copy(buffer, pdu.SerialNumber[:])
+}
+
+// Unpack initializes the structure by parsing the given data.
+func (pdu *IndividualAddressSerialNumberReadPDU) Unpack(data []byte) (uint, error) {
+	return util.Unpack(data, pdu.SerialNumber[:])
+}
+
+// An IndividualAddressSerialNumberResponsePDU is an A_IndividualAddressSerialNumber_Response. The
+// address is the source of the frame; the domain address is reserved on TP1.
+type IndividualAddressSerialNumberResponsePDU struct {
+	SerialNumber  [6]byte
+	DomainAddress uint16
+}
+
+// APCI returns IndividualAddressSerialNumberResponse.
+func (IndividualAddressSerialNumberResponsePDU) APCI() APCI {
+	return IndividualAddressSerialNumberResponse
+}
+
+// Size returns the packed size.
+func (*IndividualAddressSerialNumberResponsePDU) Size() uint {
+	return 8
+}
+
+// Pack the parameters into the buffer.
+func (pdu *IndividualAddressSerialNumberResponsePDU) Pack(buffer []byte) {
+	util.PackSome(buffer, pdu.SerialNumber[:], pdu.DomainAddress)
+}
+
+// Unpack initializes the structure by parsing the given data.
+func (pdu *IndividualAddressSerialNumberResponsePDU) Unpack(data []byte) (uint, error) {
+	return util.UnpackSome(data, pdu.SerialNumber[:], &pdu.DomainAddress)
+}
+
+// An IndividualAddressSerialNumberWritePDU is an A_IndividualAddressSerialNumber_Write. It
+// assigns the address to the device with the serial number.
+type IndividualAddressSerialNumberWritePDU struct {
+	SerialNumber [6]byte
+	Address      cemi.IndividualAddr
+}
+
+// APCI returns IndividualAddressSerialNumberWrite.
+func (IndividualAddressSerialNumberWritePDU) APCI() APCI {
+	return IndividualAddressSerialNumberWrite
+}
+
+// Size returns the packed size, including 4 reserved octets.
+func (*IndividualAddressSerialNumberWritePDU) Size() uint {
+	return 12
+}
+
+// Pack the parameters into the buffer.
+func (pdu *IndividualAddressSerialNumberWritePDU) Pack(buffer []byte) {
+	util.PackSome(buffer, pdu.SerialNumber[:], uint16(pdu.Address), uint32(0))
+}
+
+// Unpack initializes the structure by parsing the given data.
+func (pdu *IndividualAddressSerialNumberWritePDU) Unpack(data []byte) (uint, error) {
+	var reserved uint32
+
+	return util.UnpackSome(data, pdu.SerialNumber[:], (*uint16)(&pdu.Address), &reserved)
+}
+
+// DomainAddress holds the domain address of the domain address services. It has 2 octets on PL110
+// and 6 octets on RF.
+type DomainAddress struct {
+	DomainAddress []byte
+}
+
+// Size returns the packed size.
+func (da *DomainAddress) Size() uint {
+	return uint(len(da.DomainAddress))
+}
+
+// Pack the parameters into the buffer.
+func (da *DomainAddress) Pack(buffer []byte) {
+	copy(buffer, da.DomainAddress)
+}
+
+// Unpack initializes the structure by parsing the given data.
+func (da *DomainAddress) Unpack(data []byte) (uint, error) {
+	return unpackRest(data, 0, &da.DomainAddress)
+}
+
+// A DomainAddressWritePDU is an A_DomainAddress_Write.
+type DomainAddressWritePDU struct {
+	DomainAddress
+}
+
+// APCI returns DomainAddressWrite.
+func (DomainAddressWritePDU) APCI() APCI {
+	return DomainAddressWrite
+}
+
+// A DomainAddressReadPDU is an A_DomainAddress_Read.
+type DomainAddressReadPDU struct {
+	noParameters
+}
+
+// APCI returns DomainAddressRead.
+func (DomainAddressReadPDU) APCI() APCI {
+	return DomainAddressRead
+}
+
+// A DomainAddressResponsePDU is an A_DomainAddress_Response.
+type DomainAddressResponsePDU struct {
+	DomainAddress
+}
+
+// APCI returns DomainAddressResponse.
+func (DomainAddressResponsePDU) APCI() APCI {
+	return DomainAddressResponse
+}
+
+// A DomainAddressSerialNumberReadPDU is an A_DomainAddressSerialNumber_Read.
+type DomainAddressSerialNumberReadPDU struct {
+	SerialNumber [6]byte
+}
+
+// APCI returns DomainAddressSerialNumberRead.
+func (DomainAddressSerialNumberReadPDU) APCI() APCI {
+	return DomainAddressSerialNumberRead
+}
+
+// Size returns the packed size.
+func (*DomainAddressSerialNumberReadPDU) Size() uint {
+	return 6
+}
+
+// Pack the parameters into the buffer.
+func (pdu *DomainAddressSerialNumberReadPDU) Pack(buffer []byte) {
+	copy(buffer, pdu.SerialNumber[:])
+}
+
+// Unpack initializes the structure by parsing the given data.
+func (pdu *DomainAddressSerialNumberReadPDU) Unpack(data []byte) (uint, error) {
+	return util.Unpack(data, pdu.SerialNumber[:])
+}
+
+// SerialDomainAddress holds the parameters of A_DomainAddressSerialNumber_Response and
+// A_DomainAddressSerialNumber_Write.
+type SerialDomainAddress struct {
+	SerialNumber  [6]byte
+	DomainAddress []byte
+}
+
+// Size returns the packed size.
+func (sda *SerialDomainAddress) Size() uint {
+	return 6 + uint(len(sda.DomainAddress))
+}
+
+// Pack the parameters into the buffer.
+func (sda *SerialDomainAddress) Pack(buffer []byte) {
+	util.PackSome(buffer, sda.SerialNumber[:], sda.DomainAddress)
+}
+
+// Unpack initializes the structure by parsing the given data.
+func (sda *SerialDomainAddress) Unpack(data []byte) (uint, error) {
+	n, err := util.Unpack(data, sda.SerialNumber[:])
+	if err != nil {
+		return n, err
+	}
+
+	return unpackRest(data, n, &sda.DomainAddress)
+}
+
+// A DomainAddressSerialNumberResponsePDU is an A_DomainAddressSerialNumber_Response.
+type DomainAddressSerialNumberResponsePDU struct {
+	SerialDomainAddress
+}
+
+// APCI returns DomainAddressSerialNumberResponse.
+func (DomainAddressSerialNumberResponsePDU) APCI() APCI {
+	return DomainAddressSerialNumberResponse
+}
+
+// A DomainAddressSerialNumberWritePDU is an A_DomainAddressSerialNumber_Write.
+type DomainAddressSerialNumberWritePDU struct {
+	SerialDomainAddress
+}
+
+// APCI returns DomainAddressSerialNumberWrite.
+func (DomainAddressSerialNumberWritePDU) APCI() APCI {
+	return DomainAddressSerialNumberWrite
+}
+
+// NetworkParameter holds the parameters of the network parameter services. Data is the test info
+// of a read, the test info followed by the test result of a response, or the value of a write.
+type NetworkParameter struct {
+	ObjectType cemi.ObjectType
+	PropertyID cemi.PropertyID
+	Data       []byte
+}
+
+// Size returns the packed size.
+func (param *NetworkParameter) Size() uint {
+	return 3 + uint(len(param.Data))
+}
+
+// Pack the parameters into the buffer.
+func (param *NetworkParameter) Pack(buffer []byte) {
+	util.PackSome(buffer, uint16(param.ObjectType), uint8(param.PropertyID), param.Data)
+}
+
+// Unpack initializes the structure by parsing the given data.
+func (param *NetworkParameter) Unpack(data []byte) (uint, error) {
+	n, err := util.UnpackSome(data, (*uint16)(&param.ObjectType), (*uint8)(&param.PropertyID))
+	if err != nil {
+		return n, err
+	}
+
+	return unpackRest(data, n, &param.Data)
+}
+
+// A NetworkParameterReadPDU is an A_NetworkParameter_Read.
+type NetworkParameterReadPDU struct {
+	NetworkParameter
+}
+
+// APCI returns NetworkParameterRead.
+func (NetworkParameterReadPDU) APCI() APCI {
+	return NetworkParameterRead
+}
+
+// A NetworkParameterResponsePDU is an A_NetworkParameter_Response.
+type NetworkParameterResponsePDU struct {
+	NetworkParameter
+}
+
+// APCI returns NetworkParameterResponse.
+func (NetworkParameterResponsePDU) APCI() APCI {
+	return NetworkParameterResponse
+}
+
+// A NetworkParameterWritePDU is an A_NetworkParameter_Write.
+type NetworkParameterWritePDU struct {
+	NetworkParameter
+}
+
+// APCI returns NetworkParameterWrite.
+func (NetworkParameterWritePDU) APCI() APCI {
+	return NetworkParameterWrite
+}
+
+// SystemNetworkParameter holds the parameters of the system network parameter services. It is
+// like NetworkParameter with a 12-bit property identifier.
+type SystemNetworkParameter struct {
+	ObjectType cemi.ObjectType
+	PropertyID uint16 // 12 bits
+	Data       []byte
+}
+
+// Size returns the packed size.
+func (param *SystemNetworkParameter) Size() uint {
+	return 4 + uint(len(param.Data))
+}
+
+// Pack the parameters into the buffer.
+func (param *SystemNetworkParameter) Pack(buffer []byte) {
+	util.PackSome(buffer, uint16(param.ObjectType), param.PropertyID&0xFFF<<4, param.Data)
+}
+
+// Unpack initializes the structure by parsing the given data.
+func (param *SystemNetworkParameter) Unpack(data []byte) (uint, error) {
+	n, err := util.UnpackSome(data, (*uint16)(&param.ObjectType), &param.PropertyID)
+	if err != nil {
+		return n, err
+	}
+
+	param.PropertyID >>= 4
+
+	return unpackRest(data, n, &param.Data)
+}
+
+// A SystemNetworkParameterReadPDU is an A_SystemNetworkParameter_Read.
+type SystemNetworkParameterReadPDU struct {
+	SystemNetworkParameter
+}
+
+// APCI returns SystemNetworkParameterRead.
+func (SystemNetworkParameterReadPDU) APCI() APCI {
+	return SystemNetworkParameterRead
+}
+
+// A SystemNetworkParameterResponsePDU is an A_SystemNetworkParameter_Response.
+type SystemNetworkParameterResponsePDU struct {
+	SystemNetworkParameter
+}
+
+// APCI returns SystemNetworkParameterResponse.
+func (SystemNetworkParameterResponsePDU) APCI() APCI {
+	return SystemNetworkParameterResponse
+}
+
+// A SystemNetworkParameterWritePDU is an A_SystemNetworkParameter_Write.
+type SystemNetworkParameterWritePDU struct {
+	SystemNetworkParameter
+}
+
+// APCI returns SystemNetworkParameterWrite.
+func (SystemNetworkParameterWritePDU) APCI() APCI {
+	return SystemNetworkParameterWrite
+}
@@ -0,0 +1,597 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package apdu
+
+import (
+	"github.com/mobilarte/knx-exp/knx/cemi"
+	"github.com/mobilarte/knx-exp/knx/util"
+)
+
+// PropertyValue holds the parameters of the property value services. It addresses Count elements
+// of a property, beginning at StartIndex. A response with a zero count is negative.
+type PropertyValue struct {
+	ObjectIndex uint8
+	PropertyID  cemi.PropertyID
+	Count       uint8  // 4 bits
+	StartIndex  uint16 // 12 bits
+	Data        []byte
+}
+
+// Size returns the packed size.
+func (prop *PropertyValue) Size() uint {
+	return 4 + uint(len(prop.Data))
+}
+
+// Pack the parameters into the buffer.
+func (prop *PropertyValue) Pack(buffer []byte) {
+	util.PackSome(
+		buffer,
+		prop.ObjectIndex,
+		uint8(prop.PropertyID),
+		uint16(prop.Count&0xF)<<12|prop.StartIndex&0xFFF,
+		prop.Data,
+	)
+}
+
+// Unpack initializes the structure by parsing the given data.
+func (prop *PropertyValue) Unpack(data []byte) (uint, error) {
+	var countIndex uint16
+
+	n, err := util.UnpackSome(data, &prop.ObjectIndex, (*uint8)(&prop.PropertyID), &countIndex)
+	if err != nil {
+		return n, err
+	}
+
+	prop.Count = uint8(countIndex >> 12)
+	prop.StartIndex = countIndex & 0xFFF
+
+	return unpackRest(data, n, &prop.Data)
+}
+
+// A PropertyValueReadPDU is an A_PropertyValue_Read. It carries no data.
+type PropertyValueReadPDU struct {
+	PropertyValue
+}
+
+// APCI returns PropertyValueRead.
+func (PropertyValueReadPDU) APCI() APCI {
+	return PropertyValueRead
+}
+
+// A PropertyValueResponsePDU is an A_PropertyValue_Response.
+type PropertyValueResponsePDU struct {
+	PropertyValue
+}
+
+// APCI returns PropertyValueResponse.
+func (PropertyValueResponsePDU) APCI() APCI {
+	return PropertyValueResponse
+}
+
+// A PropertyValueWritePDU is an A_PropertyValue_Write.
+type PropertyValueWritePDU struct {
+	PropertyValue
+}
+
+// APCI returns PropertyValueWrite.
+func (PropertyValueWritePDU) APCI() APCI {
+	return PropertyValueWrite
+}
+
+// A PropertyDescriptionReadPDU is an A_PropertyDescription_Read. If PropertyID is zero, the
+// property is selected by PropertyIndex.
+type PropertyDescriptionReadPDU struct {
+	ObjectIndex   uint8
+	PropertyID    cemi.PropertyID
+	PropertyIndex uint8
+}
+
+// APCI returns PropertyDescriptionRead.
+func (PropertyDescriptionReadPDU) APCI() APCI {
+	return PropertyDescriptionRead
+}
+
+// Size returns the packed size.
+func (*PropertyDescriptionReadPDU) Size() uint {
+	return 3
+}
+
+// Pack the parameters into the buffer.
+func (pdu *PropertyDescriptionReadPDU) Pack(buffer []byte) {
+	util.PackSome(buffer, pdu.ObjectIndex, uint8(pdu.PropertyID), pdu.PropertyIndex)
+}
+
+// Unpack initializes the structure by parsing the given data.
+func (pdu *PropertyDescriptionReadPDU) Unpack(data []byte) (uint, error) {
+	return util.UnpackSome(data, &pdu.ObjectIndex, (*uint8)(&pdu.PropertyID), &pdu.PropertyIndex)
+}
+
+// A PropertyDescriptionResponsePDU is an A_PropertyDescription_Response.
+type PropertyDescriptionResponsePDU struct {
+	ObjectIndex   uint8
+	PropertyID    cemi.PropertyID
+	PropertyIndex uint8
+	WriteEnable   bool
+	Type          uint8  // 6 bits, property datatype
+	MaxCount      uint16 // 12 bits
+	ReadLevel     uint8  // 4 bits
+	WriteLevel    uint8  // 4 bits
+}
+
+// APCI returns PropertyDescriptionResponse.
+func (PropertyDescriptionResponsePDU) APCI() APCI {
+	return PropertyDescriptionResponse
+}
+
+// Size returns the packed size.
+func (*PropertyDescriptionResponsePDU) Size() uint {
+	return 7
+}
+
+// Pack the parameters into the buffer.
+func (pdu *PropertyDescriptionResponsePDU) Pack(buffer []byte) {
+	typ := pdu.Type & 0x3F
+	if pdu.WriteEnable {
+		typ |= 1 << 7
+	}
+
+	util.PackSome(
+		buffer,
+		pdu.ObjectIndex,
+		uint8(pdu.PropertyID),
+		pdu.PropertyIndex,
+		typ,
+		pdu.MaxCount&0xFFF,
+		pdu.ReadLevel&0xF<<4|pdu.WriteLevel&0xF,
+	)
+}
+
+// Unpack initializes the structure by parsing the given data.
+func (pdu *PropertyDescriptionResponsePDU) Unpack(data []byte) (uint, error) {
+	var typ, access uint8
+
+	n, err := util.UnpackSome(
+		data, &pdu.ObjectIndex, (*uint8)(&pdu.PropertyID), &pdu.PropertyIndex, &typ, &pdu.MaxCount, &access,
+	)
+	if err != nil {
+		return n, err
+	}
+
+	pdu.WriteEnable = typ&(1<<7) != 0
+	pdu.Type = typ & 0x3F
+	pdu.MaxCount &= 0xFFF
+	pdu.ReadLevel = access >> 4
+	pdu.WriteLevel = access & 0xF
+
+	return n, nil
+}
+
+// FunctionProperty holds the parameters of the function property services. The data of a state
+// response starts with the return code.
+type FunctionProperty struct {
+	ObjectIndex uint8
+	PropertyID  cemi.PropertyID
+	Data        []byte
+}
+
+// Size returns the packed size.
+func (prop *FunctionProperty) Size() uint {
+	return 2 + uint(len(prop.Data))
+}
+
+// Pack the parameters into the buffer.
+func (prop *FunctionProperty) Pack(buffer []byte) {
+	util.PackSome(buffer, prop.ObjectIndex, uint8(prop.PropertyID), prop.Data)
+}
+
+// Unpack initializes the structure by parsing the given data.
+func (prop *FunctionProperty) Unpack(data []byte) (uint, error) {
+	n, err := util.UnpackSome(data, &prop.ObjectIndex, (*uint8)(&prop.PropertyID))
+	if err != nil {
+		return n, err
+	}
+
+	return unpackRest(data, n, &prop.Data)
+}
+
+// A FunctionPropertyCommandPDU is an A_FunctionPropertyCommand.
+type FunctionPropertyCommandPDU struct {
+	FunctionProperty
+}
+
+// APCI returns FunctionPropertyCommand.
+func (FunctionPropertyCommandPDU) APCI() APCI {
+	return FunctionPropertyCommand
+}
+
+// A FunctionPropertyStateReadPDU is an A_FunctionPropertyState_Read.
+type FunctionPropertyStateReadPDU struct {
+	FunctionProperty
+}
+
+// APCI returns FunctionPropertyStateRead.
+func (FunctionPropertyStateReadPDU) APCI() APCI {
+	return FunctionPropertyStateRead
+}
+
+// A FunctionPropertyStateResponsePDU is an A_FunctionPropertyState_Response.
+type FunctionPropertyStateResponsePDU struct {
+	FunctionProperty
+}
+
+// APCI returns FunctionPropertyStateResponse.
+func (FunctionPropertyStateResponsePDU) APCI() APCI {
+	return FunctionPropertyStateResponse
+}
+
+// PropertyExtValue holds the parameters of the extended property value services, which address
+// the interface object by type and instance rather than by index.
+type PropertyExtValue struct {
+	ObjectType     cemi.ObjectType
+	ObjectInstance uint16 // 12 bits
+	PropertyID     uint16 // 12 bits
+	Count          uint8
+	StartIndex     uint16
+	Data           []byte
+}
+
+// Size returns the packed size.
+func (prop *PropertyExtValue) Size() uint {
+	return 8 + uint(len(prop.Data))
+}
+
+// Pack the parameters into the buffer.
+func (prop *PropertyExtValue) Pack(buffer []byte) {
+	util.PackSome(
+		buffer,
+		uint16(prop.ObjectType),
+		packExtIDs(prop.ObjectInstance, prop.PropertyID),
+		prop.Count,
+		prop.StartIndex,
+		prop.Data,
+	)
+}
+
+// Unpack initializes the structure by parsing the given data.
+func (prop *PropertyExtValue) Unpack(data []byte) (uint, error) {
+	var ids [3]byte
+
+	n, err := util.UnpackSome(data, (*uint16)(&prop.ObjectType), ids[:], &prop.Count, &prop.StartIndex)
+	if err != nil {
+		return n, err
+	}
+
+	prop.ObjectInstance, prop.PropertyID = unpackExtIDs(ids)
+
+	return unpackRest(data, n, &prop.Data)
+}
+
+// A PropertyExtValueReadPDU is an A_PropertyExtValue_Read. It carries no data.
+type PropertyExtValueReadPDU struct {
+	PropertyExtValue
+}
+
+// APCI returns PropertyExtValueRead.
+func (PropertyExtValueReadPDU) APCI() APCI {
+	return PropertyExtValueRead
+}
+
+// A PropertyExtValueResponsePDU is an A_PropertyExtValue_Response.
+type PropertyExtValueResponsePDU struct {
+	PropertyExtValue
+}
+
+// APCI returns PropertyExtValueResponse.
+func (PropertyExtValueResponsePDU) APCI() APCI {
+	return PropertyExtValueResponse
+}
+
+// A PropertyExtValueWriteConPDU is an A_PropertyExtValue_WriteCon.
+type PropertyExtValueWriteConPDU struct {
+	PropertyExtValue
+}
+
+// APCI returns PropertyExtValueWriteCon.
+func (PropertyExtValueWriteConPDU) APCI() APCI {
+	return PropertyExtValueWriteCon
+}
+
+// A PropertyExtValueWriteConResponsePDU is an A_PropertyExtValue_WriteConRes. Its data is the
+// return code.
+type PropertyExtValueWriteConResponsePDU struct {
+	PropertyExtValue
+}
+
+// APCI returns PropertyExtValueWriteConResponse.
+func (PropertyExtValueWriteConResponsePDU) APCI() APCI {
+	return PropertyExtValueWriteConResponse
+}
+
+// A PropertyExtValueWriteUnConPDU is an A_PropertyExtValue_WriteUnCon.
+type PropertyExtValueWriteUnConPDU struct {
+	PropertyExtValue
+}
+
+// APCI returns PropertyExtValueWriteUnCon.
+func (PropertyExtValueWriteUnConPDU) APCI() APCI {
+	return PropertyExtValueWriteUnCon
+}
+
+// A PropertyExtValueInfoReportPDU is an A_PropertyExtValue_InfoReport.
+type PropertyExtValueInfoReportPDU struct {
+	PropertyExtValue
+}
+
+// APCI returns PropertyExtValueInfoReport.
+func (PropertyExtValueInfoReportPDU) APCI() APCI {
+	return PropertyExtValueInfoReport
+}
+
+// A PropertyExtDescriptionReadPDU is an A_PropertyExtDescription_Read. If PropertyID is zero, the
+// property is selected by PropertyIndex.
+type PropertyExtDescriptionReadPDU struct {
+	ObjectType      cemi.ObjectType
+	ObjectInstance  uint16 // 12 bits
+	PropertyID      uint16 // 12 bits
+	DescriptionType uint8  // 4 bits
+	PropertyIndex   uint16 // 12 bits
+}
+
+// APCI returns PropertyExtDescriptionRead.
+func (PropertyExtDescriptionReadPDU) APCI() APCI {
+	return PropertyExtDescriptionRead
+}
+
+// Size returns the packed size.
+func (*PropertyExtDescriptionReadPDU) Size() uint {
+	return 7
+}
+
+// Pack the parameters into the buffer.
+func (pdu *PropertyExtDescriptionReadPDU) Pack(buffer []byte) {
+	util.PackSome(
+		buffer,
+		uint16(pdu.ObjectType),
+		packExtIDs(pdu.ObjectInstance, pdu.PropertyID),
+		uint16(pdu.DescriptionType&0xF)<<12|pdu.PropertyIndex&0xFFF,
+	)
+}
+
+// Unpack initializes the structure by parsing the given data.
+func (pdu *PropertyExtDescriptionReadPDU) Unpack(data []byte) (uint, error) {
+	var (
+		ids       [3]byte
+		typeIndex uint16
+	)
+
+	n, err := util.UnpackSome(data, (*uint16)(&pdu.ObjectType), ids[:], &typeIndex)
+	if err != nil {
+		return n, err
+	}
+
+	pdu.ObjectInstance, pdu.PropertyID = unpackExtIDs(ids)
+	pdu.DescriptionType = uint8(typeIndex >> 12)
+	pdu.PropertyIndex = typeIndex & 0xFFF
+
+	return n, nil
+}
+
+// A PropertyExtDescriptionResponsePDU is an A_PropertyExtDescription_Response.
+type PropertyExtDescriptionResponsePDU struct {
+	ObjectType      cemi.ObjectType
+	ObjectInstance  uint16 // 12 bits
+	PropertyID      uint16 // 12 bits
+	DescriptionType uint8  // 4 bits
+	PropertyIndex   uint16 // 12 bits
+	DatapointMain   uint16
+	DatapointSub    uint16
+	WriteEnable     bool
+	Type            uint8  // 6 bits, property datatype
+	MaxCount        uint16 // 12 bits
+	ReadLevel       uint8  // 4 bits
+	WriteLevel      uint8  // 4 bits
+}
+
+// APCI returns PropertyExtDescriptionResponse.
+func (PropertyExtDescriptionResponsePDU) APCI() APCI {
+	return PropertyExtDescriptionResponse
+}
+
+// Size returns the packed size.
+func (*PropertyExtDescriptionResponsePDU) Size() uint {
+	return 15
+}
+
+// Pack the parameters into the buffer.
+func (pdu *PropertyExtDescriptionResponsePDU) Pack(buffer []byte) {
+	typ := pdu.Type & 0x3F
+	if pdu.WriteEnable {
+		typ |= 1 << 7
+	}
+
+	util.PackSome(
+		buffer,
+		uint16(pdu.ObjectType),
+		packExtIDs(pdu.ObjectInstance, pdu.PropertyID),
+		uint16(pdu.DescriptionType&0xF)<<12|pdu.PropertyIndex&0xFFF,
+		pdu.DatapointMain,
+		pdu.DatapointSub,
+		typ,
+		pdu.MaxCount&0xFFF,
+		pdu.ReadLevel&0xF<<4|pdu.WriteLevel&0xF,
+	)
+}
+
+// Unpack initializes the structure by parsing the given data.
+func (pdu *PropertyExtDescriptionResponsePDU) Unpack(data []byte) (uint, error) {
+	var (
+		ids         [3]byte
+		typeIndex   uint16
+		typ, access uint8
+	)
+
+	n, err := util.UnpackSome(
+		data, (*uint16)(&pdu.ObjectType), ids[:], &typeIndex, &pdu.DatapointMain, &pdu.DatapointSub,
+		&typ, &pdu.MaxCount, &access,
+	)
+	if err != nil {
+		return n, err
+	}
+
+	pdu.ObjectInstance, pdu.PropertyID = unpackExtIDs(ids)
+	pdu.DescriptionType = uint8(typeIndex >> 12)
+	pdu.PropertyIndex = typeIndex & 0xFFF
+	pdu.WriteEnable = typ&(1<<7) != 0
+	pdu.Type = typ & 0x3F
+	pdu.MaxCount &= 0xFFF
+	pdu.ReadLevel = access >> 4
+	pdu.WriteLevel = access & 0xF
+
+	return n, nil
+}
+
+// FunctionPropertyExt holds the parameters of the extended function property services. The data
+// of a state response starts with the return code.
+type FunctionPropertyExt struct {
+	ObjectType     cemi.ObjectType
+	ObjectInstance uint16 // 12 bits
+	PropertyID     uint16 // 12 bits
+	Data           []byte
+}
+
+// Size returns the packed size.
+func (prop *FunctionPropertyExt) Size() uint {
+	return 5 + uint(len(prop.Data))
+}
+
+// Pack the parameters into the buffer.
+func (prop *FunctionPropertyExt) Pack(buffer []byte) {
+	util.PackSome(buffer, uint16(prop.ObjectType), packExtIDs(prop.ObjectInstance, prop.PropertyID), prop.Data)
+}
+
+// Unpack initializes the structure by parsing the given data.
+func (prop *FunctionPropertyExt) Unpack(data []byte) (uint, error) {
+	var ids [3]byte
+
+	n, err := util.UnpackSome(data, (*uint16)(&prop.ObjectType), ids[:])
+	if err != nil {
+		return n, err
+	}
+
+	prop.ObjectInstance, prop.PropertyID = unpackExtIDs(ids)
+
+	return unpackRest(data, n, &prop.Data)
+}
+
+// A FunctionPropertyExtCommandPDU is an A_FunctionPropertyExtCommand.
+type FunctionPropertyExtCommandPDU struct {
+	FunctionPropertyExt
+}
+
+// APCI returns FunctionPropertyExtCommand.
+func (FunctionPropertyExtCommandPDU) APCI() APCI {
+	return FunctionPropertyExtCommand
+}
+
+// A FunctionPropertyExtStateReadPDU is an A_FunctionPropertyExtState_Read.
+type FunctionPropertyExtStateReadPDU struct {
+	FunctionPropertyExt
+}
+
+// APCI returns FunctionPropertyExtStateRead.
+func (FunctionPropertyExtStateReadPDU) APCI() APCI {
+	return FunctionPropertyExtStateRead
+}
+
+// A FunctionPropertyExtStateResponsePDU is an A_FunctionPropertyExtState_Response.
+type FunctionPropertyExtStateResponsePDU struct {
+	FunctionPropertyExt
+}
+
+// APCI returns FunctionPropertyExtStateResponse.
+func (FunctionPropertyExtStateResponsePDU) APCI() APCI {
+	return FunctionPropertyExtStateResponse
+}
+
+// GroupPropValue holds the parameters of the group property value services, which address the
+// property of an interface object in all devices that listen on a group address.
+type GroupPropValue struct {
+	ObjectType     cemi.ObjectType
+	ObjectInstance uint8
+	PropertyID     cemi.PropertyID
+	Data           []byte
+}
+
+// Size returns the packed size.
+func (prop *GroupPropValue) Size() uint {
+	return 4 + uint(len(prop.Data))
+}
+
+// Pack the parameters into the buffer.
+func (prop *GroupPropValue) Pack(buffer []byte) {
+	util.PackSome(buffer, uint16(prop.ObjectType), prop.ObjectInstance, uint8(prop.PropertyID), prop.Data)
+}
+
+// Unpack initializes the structure by parsing the given data.
+func (prop *GroupPropValue) Unpack(data []byte) (uint, error) {
+	n, err := util.UnpackSome(
+		data, (*uint16)(&prop.ObjectType), &prop.ObjectInstance, (*uint8)(&prop.PropertyID),
+	)
+	if err != nil {
+		return n, err
+	}
+
+	return unpackRest(data, n, &prop.Data)
+}
+
+// A GroupPropValueReadPDU is an A_GroupPropValue_Read. It carries no data.
+type GroupPropValueReadPDU struct {
+	GroupPropValue
+}
+
+// APCI returns GroupPropValueRead.
+func (GroupPropValueReadPDU) APCI() APCI {
+	return GroupPropValueRead
+}
+
+// A GroupPropValueResponsePDU is an A_GroupPropValue_Response.
+type GroupPropValueResponsePDU struct {
+	GroupPropValue
+}
+
+// APCI returns GroupPropValueResponse.
+func (GroupPropValueResponsePDU) APCI() APCI {
+	return GroupPropValueResponse
+}
+
+// A GroupPropValueWritePDU is an A_GroupPropValue_Write.
+type GroupPropValueWritePDU struct {
+	GroupPropValue
+}
+
+// APCI returns GroupPropValueWrite.
+func (GroupPropValueWritePDU) APCI() APCI {
+	return GroupPropValueWrite
+}
+
+// A GroupPropValueInfoReportPDU is an A_GroupPropValue_InfoReport.
+type GroupPropValueInfoReportPDU struct {
+	GroupPropValue
+}
+
+// APCI returns GroupPropValueInfoReport.
+func (GroupPropValueInfoReportPDU) APCI() APCI {
+	return GroupPropValueInfoReport
+}
+
+// packExtIDs packs the 12-bit object instance and the 12-bit property identifier into 3 octets.
+func packExtIDs(instance, pid uint16) []byte {
+	instance, pid = instance&0xFFF, pid&0xFFF
+
+	return []byte{uint8(instance >> 4), uint8(instance<<4) | uint8(pid>>8), uint8(pid)}
+}
+
+// unpackExtIDs parses the 12-bit object instance and the 12-bit property identifier.
+func unpackExtIDs(ids [3]byte) (instance, pid uint16) {
+	return uint16(ids[0])<<4 | uint16(ids[1]>>4), uint16(ids[1]&0xF)<<8 | uint16(ids[2])
+}
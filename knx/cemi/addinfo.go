@@ -0,0 +1,639 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package cemi
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/mobilarte/knx-exp/knx/util"
+)
+
+// InfoType identifies an entry of the additional info.
+type InfoType uint8
+
+// Additional info types, see 03_06_03 EMI_IMI 4.1.4.3.
+const (
+	InfoPLMedium          InfoType = 0x01
+	InfoRFMedium          InfoType = 0x02
+	InfoBusmonitorStatus  InfoType = 0x03
+	InfoTimestamp         InfoType = 0x04
+	InfoTimeDelay         InfoType = 0x05
+	InfoExtendedTimestamp InfoType = 0x06
+	InfoBiBat             InfoType = 0x07
+	InfoRFMulti           InfoType = 0x08
+	InfoPreamble          InfoType = 0x09
+	InfoRFFastAck         InfoType = 0x0A
+	InfoManufacturer      InfoType = 0xFE
+)
+
+// String converts the info type to a string.
+func (typ InfoType) String() string {
+	switch typ {
+	case InfoPLMedium:
+		return "PL medium info"
+	case InfoRFMedium:
+		return "RF medium info"
+	case InfoBusmonitorStatus:
+		return "busmonitor status info"
+	case InfoTimestamp:
+		return "relative timestamp"
+	case InfoTimeDelay:
+		return "time delay until sending"
+	case InfoExtendedTimestamp:
+		return "extended relative timestamp"
+	case InfoBiBat:
+		return "BiBat info"
+	case InfoRFMulti:
+		return "RF multi info"
+	case InfoPreamble:
+		return "preamble and postamble"
+	case InfoRFFastAck:
+		return "RF fast ACK info"
+	case InfoManufacturer:
+		return "manufacturer specific data"
+	default:
+		return fmt.Sprintf("%#x", uint8(typ))
+	}
+}
+
+// An InfoEntry is an entry of the additional info. Size and Pack cover the data of the entry,
+// without its type and length.
+type InfoEntry interface {
+	util.Packable
+	InfoType() InfoType
+}
+
+// ErrInfoTooLong is returned when the additional info would exceed 255 bytes.
+var ErrInfoTooLong = errors.New("additional info exceeds 255 bytes")
+
+// AdditionalInfo is the additional info segment of a CEMI-encoded frame, split into its entries.
+type AdditionalInfo []InfoEntry
+
+// Size returns the packed size.
+func (ai AdditionalInfo) Size() uint {
+	var size uint
+
+	for _, entry := range ai {
+		size += 2 + entry.Size()
+	}
+
+	return size
+}
+
+// Pack the entries into the buffer. Each entry is preceded by its type and length.
+func (ai AdditionalInfo) Pack(buffer []byte) {
+	for _, entry := range ai {
+		size := entry.Size()
+
+		buffer[0] = byte(entry.InfoType())
+		buffer[1] = byte(size)
+		entry.Pack(buffer[2 : 2+size])
+
+		buffer = buffer[2+size:]
+	}
+}
+
+// Unpack initializes the entries by parsing the given data, which must consist of whole entries.
+// Entries of an unknown type become an UnknownInfo.
+func (ai *AdditionalInfo) Unpack(data []byte) (n uint, err error) {
+	var entries AdditionalInfo
+
+	for n < uint(len(data)) {
+		if uint(len(data))-n < 2 {
+			return n, fmt.Errorf("additional info entry header: %w", io.ErrUnexpectedEOF)
+		}
+
+		typ, length := InfoType(data[n]), uint(data[n+1])
+		if uint(len(data))-n-2 < length {
+			return n, fmt.Errorf("%v: %w", typ, io.ErrUnexpectedEOF)
+		}
+
+		entry, err := unpackInfoEntry(typ, data[n+2:n+2+length])
+		if err != nil {
+			return n, fmt.Errorf("%v: %w", typ, err)
+		}
+
+		entries = append(entries, entry)
+		n += 2 + length
+	}
+
+	*ai = entries
+
+	return n, nil
+}
+
+// Entries parses the additional info into its entries.
+func (info Info) Entries() (AdditionalInfo, error) {
+	var ai AdditionalInfo
+
+	if _, err := ai.Unpack(info); err != nil {
+		return nil, err
+	}
+
+	return ai, nil
+}
+
+// Add appends the entries to the additional info.
+func (info *Info) Add(entries ...InfoEntry) error {
+	ai := AdditionalInfo(entries)
+
+	for _, entry := range ai {
+		if entry.Size() > 255 {
+			return fmt.Errorf("%v: %w", entry.InfoType(), ErrInfoTooLong)
+		}
+	}
+
+	size := ai.Size()
+	if uint(len(*info))+size > 255 {
+		return ErrInfoTooLong
+	}
+
+	buffer := make([]byte, uint(len(*info))+size)
+	copy(buffer, *info)
+	ai.Pack(buffer[len(*info):])
+
+	*info = buffer
+
+	return nil
+}
+
+// FindInfo returns the first entry of type E.
+func FindInfo[E InfoEntry](ai AdditionalInfo) (E, bool) {
+	for _, entry := range ai {
+		if entry, ok := entry.(E); ok {
+			return entry, true
+		}
+	}
+
+	var zero E
+
+	return zero, false
+}
+
+// unpackInfoEntry parses the data of an entry.
+func unpackInfoEntry(typ InfoType, data []byte) (InfoEntry, error) {
+	switch typ {
+	case InfoPLMedium:
+		return unpackFixedEntry[PLMediumInfo](data)
+	case InfoRFMedium:
+		return unpackFixedEntry[RFMediumInfo](data)
+	case InfoBusmonitorStatus:
+		return unpackFixedEntry[BusmonitorStatus](data)
+	case InfoTimestamp:
+		return unpackFixedEntry[RelativeTimestamp](data)
+	case InfoTimeDelay:
+		return unpackFixedEntry[TimeDelay](data)
+	case InfoExtendedTimestamp:
+		return unpackFixedEntry[ExtendedTimestamp](data)
+	case InfoBiBat:
+		return unpackFixedEntry[BiBatInfo](data)
+	case InfoRFMulti:
+		return unpackFixedEntry[RFMultiInfo](data)
+	case InfoPreamble:
+		return unpackFixedEntry[PreambleInfo](data)
+	case InfoRFFastAck:
+		if len(data)%2 != 0 {
+			return nil, fmt.Errorf("odd length %d", len(data))
+		}
+
+		acks := make(RFFastAckInfo, len(data)/2)
+		for i := range acks {
+			acks[i] = FastAck{Status: data[2*i], Info: data[2*i+1]}
+		}
+
+		return acks, nil
+	case InfoManufacturer:
+		if len(data) < 3 {
+			return nil, io.ErrUnexpectedEOF
+		}
+
+		return ManufacturerInfo{
+			Manufacturer: uint16(data[0])<<8 | uint16(data[1]),
+			Subfunction:  data[2],
+			Data:         append([]byte(nil), data[3:]...),
+		}, nil
+	default:
+		return UnknownInfo{Type: typ, Data: append([]byte(nil), data...)}, nil
+	}
+}
+
+// unpackFixedEntry parses the data of an entry which has a fixed size.
+func unpackFixedEntry[E InfoEntry, P interface {
+	*E
+	util.Unpackable
+}](data []byte) (InfoEntry, error) {
+	var entry E
+
+	if size := entry.Size(); uint(len(data)) != size {
+		return nil, fmt.Errorf("length is %d instead of %d", len(data), size)
+	}
+
+	if _, err := P(&entry).Unpack(data); err != nil {
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+// PLMediumInfo carries the domain address of a powerline frame.
+type PLMediumInfo struct {
+	DomainAddress uint16
+}
+
+// InfoType returns InfoPLMedium.
+func (PLMediumInfo) InfoType() InfoType {
+	return InfoPLMedium
+}
+
+// Size returns the packed size.
+func (PLMediumInfo) Size() uint {
+	return 2
+}
+
+// Pack the entry into the buffer.
+func (pl PLMediumInfo) Pack(buffer []byte) {
+	util.Pack(buffer, pl.DomainAddress)
+}
+
+// Unpack initializes the structure by parsing the given data.
+func (pl *PLMediumInfo) Unpack(data []byte) (uint, error) {
+	return util.Unpack(data, &pl.DomainAddress)
+}
+
+// SignalStrength is the received signal strength of a RF frame.
+type SignalStrength uint8
+
+// These are the levels of the signal strength.
+const (
+	SignalVoid SignalStrength = iota
+	SignalWeak
+	SignalMedium
+	SignalGood
+)
+
+// String converts the signal strength to a string.
+func (rss SignalStrength) String() string {
+	switch rss {
+	case SignalVoid:
+		return "void"
+	case SignalWeak:
+		return "weak"
+	case SignalMedium:
+		return "medium"
+	case SignalGood:
+		return "good"
+	default:
+		return fmt.Sprintf("%#x", uint8(rss))
+	}
+}
+
+// RFInfo is the RF-Info field of a RF frame.
+type RFInfo uint8
+
+// MakeRFInfo generates the RF-Info field.
+func MakeRFInfo(rss, retransmitterRSS SignalStrength, batteryOK, unidirectional bool) RFInfo {
+	info := RFInfo(rss&3)<<4 | RFInfo(retransmitterRSS&3)<<2
+
+	if batteryOK {
+		info |= 1 << 1
+	}
+
+	if unidirectional {
+		info |= 1
+	}
+
+	return info
+}
+
+// SignalStrength retrieves the signal strength with which the frame has been received.
+func (info RFInfo) SignalStrength() SignalStrength {
+	return SignalStrength(info>>4) & 3
+}
+
+// RetransmitterSignalStrength retrieves the signal strength with which a retransmitter has
+// received the frame.
+func (info RFInfo) RetransmitterSignalStrength() SignalStrength {
+	return SignalStrength(info>>2) & 3
+}
+
+// BatteryOK determines whether the battery of the sender is fine.
+func (info RFInfo) BatteryOK() bool {
+	return info&(1<<1) != 0
+}
+
+// Unidirectional determines whether the sender is a unidirectional device.
+func (info RFInfo) Unidirectional() bool {
+	return info&1 != 0
+}
+
+// RFMediumInfo carries the RF specific fields of a RF frame. SerialNumber holds the serial number
+// of the sender, or the domain address for frames of system broadcast communication mode.
+type RFMediumInfo struct {
+	RFInfo       RFInfo
+	SerialNumber [6]byte
+	LFN          uint8
+}
+
+// InfoType returns InfoRFMedium.
+func (RFMediumInfo) InfoType() InfoType {
+	return InfoRFMedium
+}
+
+// Size returns the packed size.
+func (RFMediumInfo) Size() uint {
+	return 8
+}
+
+// Pack the entry into the buffer.
+func (rf RFMediumInfo) Pack(buffer []byte) {
+	buffer[0] = byte(rf.RFInfo)
+	copy(buffer[1:7], rf.SerialNumber[:])
+	buffer[7] = rf.LFN
+}
+
+// Unpack initializes the structure by parsing the given data.
+func (rf *RFMediumInfo) Unpack(data []byte) (uint, error) {
+	if len(data) < 8 {
+		return 0, io.ErrUnexpectedEOF
+	}
+
+	rf.RFInfo = RFInfo(data[0])
+	copy(rf.SerialNumber[:], data[1:7])
+	rf.LFN = data[7]
+
+	return 8, nil
+}
+
+// BusmonitorStatus carries the status of a frame received in busmonitor mode.
+type BusmonitorStatus uint8
+
+// FrameError determines whether the frame is corrupt.
+func (status BusmonitorStatus) FrameError() bool {
+	return status&(1<<7) != 0
+}
+
+// BitError determines whether a bit of a character has been received incorrectly.
+func (status BusmonitorStatus) BitError() bool {
+	return status&(1<<6) != 0
+}
+
+// ParityError determines whether the parity of a character is wrong.
+func (status BusmonitorStatus) ParityError() bool {
+	return status&(1<<5) != 0
+}
+
+// Lost determines whether frames have been lost before this one.
+func (status BusmonitorStatus) Lost() bool {
+	return status&(1<<3) != 0
+}
+
+// Sequence retrieves the sequence number.
+func (status BusmonitorStatus) Sequence() uint8 {
+	return uint8(status) & 7
+}
+
+// InfoType returns InfoBusmonitorStatus.
+func (BusmonitorStatus) InfoType() InfoType {
+	return InfoBusmonitorStatus
+}
+
+// Size returns the packed size.
+func (BusmonitorStatus) Size() uint {
+	return 1
+}
+
+// Pack the entry into the buffer.
+func (status BusmonitorStatus) Pack(buffer []byte) {
+	buffer[0] = byte(status)
+}
+
+// Unpack initializes the structure by parsing the given data.
+func (status *BusmonitorStatus) Unpack(data []byte) (uint, error) {
+	return util.Unpack(data, (*uint8)(status))
+}
+
+// RelativeTimestamp is the time at which the frame has been received, in ticks of a free running
+// counter. The duration of a tick depends on the medium.
+type RelativeTimestamp uint16
+
+// InfoType returns InfoTimestamp.
+func (RelativeTimestamp) InfoType() InfoType {
+	return InfoTimestamp
+}
+
+// Size returns the packed size.
+func (RelativeTimestamp) Size() uint {
+	return 2
+}
+
+// Pack the entry into the buffer.
+func (ts RelativeTimestamp) Pack(buffer []byte) {
+	util.Pack(buffer, uint16(ts))
+}
+
+// Unpack initializes the structure by parsing the given data.
+func (ts *RelativeTimestamp) Unpack(data []byte) (uint, error) {
+	return util.Unpack(data, (*uint16)(ts))
+}
+
+// TimeDelay is the time in milliseconds until the frame is sent.
+type TimeDelay uint32
+
+// InfoType returns InfoTimeDelay.
+func (TimeDelay) InfoType() InfoType {
+	return InfoTimeDelay
+}
+
+// Size returns the packed size.
+func (TimeDelay) Size() uint {
+	return 4
+}
+
+// Pack the entry into the buffer.
+func (delay TimeDelay) Pack(buffer []byte) {
+	util.Pack(buffer, uint32(delay))
+}
+
+// Unpack initializes the structure by parsing the given data.
+func (delay *TimeDelay) Unpack(data []byte) (uint, error) {
+	return util.Unpack(data, (*uint32)(delay))
+}
+
+// ExtendedTimestamp is the time in microseconds at which the frame has been received, relative to
+// a free running counter.
+type ExtendedTimestamp uint32
+
+// InfoType returns InfoExtendedTimestamp.
+func (ExtendedTimestamp) InfoType() InfoType {
+	return InfoExtendedTimestamp
+}
+
+// Size returns the packed size.
+func (ExtendedTimestamp) Size() uint {
+	return 4
+}
+
+// Pack the entry into the buffer.
+func (ts ExtendedTimestamp) Pack(buffer []byte) {
+	util.Pack(buffer, uint32(ts))
+}
+
+// Unpack initializes the structure by parsing the given data.
+func (ts *ExtendedTimestamp) Unpack(data []byte) (uint, error) {
+	return util.Unpack(data, (*uint32)(ts))
+}
+
+// BiBatInfo carries the BiBat specific fields of a RF frame.
+type BiBatInfo struct {
+	Control     uint8
+	BlockNumber uint8
+}
+
+// InfoType returns InfoBiBat.
+func (BiBatInfo) InfoType() InfoType {
+	return InfoBiBat
+}
+
+// Size returns the packed size.
+func (BiBatInfo) Size() uint {
+	return 2
+}
+
+// Pack the entry into the buffer.
+func (bibat BiBatInfo) Pack(buffer []byte) {
+	util.PackSome(buffer, bibat.Control, bibat.BlockNumber)
+}
+
+// Unpack initializes the structure by parsing the given data.
+func (bibat *BiBatInfo) Unpack(data []byte) (uint, error) {
+	return util.UnpackSome(data, &bibat.Control, &bibat.BlockNumber)
+}
+
+// RFMultiInfo carries the channels of a KNX RF Multi frame.
+type RFMultiInfo struct {
+	TransmissionFrequency uint8
+	CallChannel           uint8
+	FastAck               uint8
+	ReceptionFrequency    uint8
+}
+
+// InfoType returns InfoRFMulti.
+func (RFMultiInfo) InfoType() InfoType {
+	return InfoRFMulti
+}
+
+// Size returns the packed size.
+func (RFMultiInfo) Size() uint {
+	return 4
+}
+
+// Pack the entry into the buffer.
+func (multi RFMultiInfo) Pack(buffer []byte) {
+	util.PackSome(buffer, multi.TransmissionFrequency, multi.CallChannel, multi.FastAck, multi.ReceptionFrequency)
+}
+
+// Unpack initializes the structure by parsing the given data.
+func (multi *RFMultiInfo) Unpack(data []byte) (uint, error) {
+	return util.UnpackSome(
+		data, &multi.TransmissionFrequency, &multi.CallChannel, &multi.FastAck, &multi.ReceptionFrequency,
+	)
+}
+
+// PreambleInfo carries the lengths of the preamble and the postamble of a frame.
+type PreambleInfo struct {
+	PreambleLength  uint16
+	PostambleLength uint8
+}
+
+// InfoType returns InfoPreamble.
+func (PreambleInfo) InfoType() InfoType {
+	return InfoPreamble
+}
+
+// Size returns the packed size.
+func (PreambleInfo) Size() uint {
+	return 3
+}
+
+// Pack the entry into the buffer.
+func (pre PreambleInfo) Pack(buffer []byte) {
+	util.PackSome(buffer, pre.PreambleLength, pre.PostambleLength)
+}
+
+// Unpack initializes the structure by parsing the given data.
+func (pre *PreambleInfo) Unpack(data []byte) (uint, error) {
+	return util.UnpackSome(data, &pre.PreambleLength, &pre.PostambleLength)
+}
+
+// A FastAck is the acknowledgement of a single receiver of a KNX RF Multi frame.
+type FastAck struct {
+	Status uint8
+	Info   uint8
+}
+
+// RFFastAckInfo carries the fast acknowledgements of a KNX RF Multi frame.
+type RFFastAckInfo []FastAck
+
+// InfoType returns InfoRFFastAck.
+func (RFFastAckInfo) InfoType() InfoType {
+	return InfoRFFastAck
+}
+
+// Size returns the packed size.
+func (acks RFFastAckInfo) Size() uint {
+	return 2 * uint(len(acks))
+}
+
+// Pack the entry into the buffer.
+func (acks RFFastAckInfo) Pack(buffer []byte) {
+	for i, ack := range acks {
+		buffer[2*i] = ack.Status
+		buffer[2*i+1] = ack.Info
+	}
+}
+
+// ManufacturerInfo carries manufacturer specific data.
+type ManufacturerInfo struct {
+	Manufacturer uint16
+	Subfunction  uint8
+	Data         []byte
+}
+
+// InfoType returns InfoManufacturer.
+func (ManufacturerInfo) InfoType() InfoType {
+	return InfoManufacturer
+}
+
+// Size returns the packed size.
+func (mfr ManufacturerInfo) Size() uint {
+	return 3 + uint(len(mfr.Data))
+}
+
+// Pack the entry into the buffer.
+func (mfr ManufacturerInfo) Pack(buffer []byte) {
+	util.PackSome(buffer, mfr.Manufacturer, mfr.Subfunction)
+	copy(buffer[3:], mfr.Data)
+}
+
+// UnknownInfo is an entry of a type which has no representation of its own.
+type UnknownInfo struct {
+	Type InfoType
+	Data []byte
+}
+
+// InfoType returns the type of the entry.
+func (unknown UnknownInfo) InfoType() InfoType {
+	return unknown.Type
+}
+
+// Size returns the packed size.
+func (unknown UnknownInfo) Size() uint {
+	return uint(len(unknown.Data))
+}
+
+// Pack the entry into the buffer.
+func (unknown UnknownInfo) Pack(buffer []byte) {
+	copy(buffer, unknown.Data)
+}
@@ -0,0 +1,140 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package cemi
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestAdditionalInfo_RoundTrip(t *testing.T) {
+	entries := AdditionalInfo{
+		PLMediumInfo{DomainAddress: 0x1234},
+		RFMediumInfo{
+			RFInfo:       MakeRFInfo(SignalMedium, SignalGood, true, false),
+			SerialNumber: [6]byte{0, 0xc5, 1, 2, 3, 4},
+			LFN:          7,
+		},
+		BusmonitorStatus(0x83),
+		RelativeTimestamp(0xbeef),
+		TimeDelay(1000),
+		ExtendedTimestamp(0xdeadbeef),
+		BiBatInfo{Control: 0x20, BlockNumber: 3},
+		RFMultiInfo{TransmissionFrequency: 1, CallChannel: 2, FastAck: 3, ReceptionFrequency: 4},
+		PreambleInfo{PreambleLength: 0x0102, PostambleLength: 3},
+		RFFastAckInfo{{Status: 1, Info: 2}, {Status: 3, Info: 4}},
+		ManufacturerInfo{Manufacturer: 0x00c5, Subfunction: 1, Data: []byte{9, 8}},
+		UnknownInfo{Type: 0x42, Data: []byte{1, 2, 3}},
+	}
+
+	var info Info
+	if err := info.Add(entries...); err != nil {
+		t.Fatal(err)
+	}
+
+	if uint(len(info)) != entries.Size() {
+		t.Fatalf("Unexpected length %d, expected %d", len(info), entries.Size())
+	}
+
+	ldata := LData{
+		Info:        info,
+		Control1:    Control1StdFrame,
+		Control2:    Control2GroupAddr,
+		Source:      0x1101,
+		Destination: 0x0901,
+		Data:        &AppData{Command: GroupValueWrite, Data: []byte{1}},
+	}
+
+	buffer := make([]byte, ldata.Size())
+	ldata.Pack(buffer)
+
+	var result LData
+	if _, err := result.Unpack(buffer); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(result.Info, info) {
+		t.Fatalf("Unexpected info % x, expected % x", result.Info, info)
+	}
+
+	parsed, err := result.Info.Entries()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(parsed, entries) {
+		t.Fatalf("Unexpected entries %+v, expected %+v", parsed, entries)
+	}
+}
+
+func TestAdditionalInfo_Find(t *testing.T) {
+	// A busmonitor frame with status and timestamp, and a RF frame with a weak signal.
+	info := Info{0x03, 0x01, 0x09, 0x04, 0x02, 0x12, 0x34, 0x02, 0x08, 0x1a, 0, 0, 0, 0, 0, 1, 0}
+
+	ai, err := info.Entries()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	status, ok := FindInfo[BusmonitorStatus](ai)
+	if !ok || !status.Lost() || status.Sequence() != 1 || status.FrameError() {
+		t.Errorf("Unexpected status %v %#x", ok, status)
+	}
+
+	ts, ok := FindInfo[RelativeTimestamp](ai)
+	if !ok || ts != 0x1234 {
+		t.Errorf("Unexpected timestamp %v %#x", ok, ts)
+	}
+
+	rf, ok := FindInfo[RFMediumInfo](ai)
+	if !ok || rf.RFInfo.SignalStrength() != SignalWeak || rf.RFInfo.RetransmitterSignalStrength() != SignalMedium ||
+		!rf.RFInfo.BatteryOK() || rf.RFInfo.Unidirectional() {
+		t.Errorf("Unexpected RF info %v %+v", ok, rf)
+	}
+
+	if _, ok := FindInfo[ExtendedTimestamp](ai); ok {
+		t.Error("Unexpected extended timestamp")
+	}
+}
+
+func TestAdditionalInfo_Invalid(t *testing.T) {
+	for _, info := range []Info{
+		{0x04},
+		{0x04, 0x03, 0x00},
+		{0x04, 0x03, 0x00, 0x00, 0x00},
+		{0x0a, 0x01, 0x00},
+		{0xfe, 0x02, 0x00, 0xc5},
+	} {
+		if _, err := info.Entries(); err == nil {
+			t.Errorf("Expected an error for % x", info)
+		}
+	}
+
+	if _, err := (Info{0x04, 0x03, 0x00}).Entries(); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("Unexpected error %v", err)
+	}
+}
+
+func TestInfo_Add(t *testing.T) {
+	info := Info{0x04, 0x02, 0x00, 0x01}
+
+	if err := info.Add(TimeDelay(0x0a0b0c0d)); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := Info{0x04, 0x02, 0x00, 0x01, 0x05, 0x04, 0x0a, 0x0b, 0x0c, 0x0d}
+	if !bytes.Equal(info, expected) {
+		t.Errorf("Unexpected info % x", info)
+	}
+
+	if err := info.Add(UnknownInfo{Type: 0x42, Data: make([]byte, 250)}); !errors.Is(err, ErrInfoTooLong) {
+		t.Errorf("Unexpected error %v", err)
+	}
+
+	if !bytes.Equal(info, expected) {
+		t.Errorf("Info has been modified: % x", info)
+	}
+}
@@ -6,6 +6,7 @@ package cemi
 
 import (
 	"fmt"
+	"io"
 
 	"github.com/mobilarte/knx-exp/knx/util"
 )
@@ -35,6 +36,36 @@ const (
 	// LRawConCode is the message code for L_Raw.con.
 	LRawConCode MessageCode = 0x2F
 
+	// MPropReadReqCode is the message code for M_PropRead.req.
+	MPropReadReqCode MessageCode = 0xFC
+
+	// MPropReadConCode is the message code for M_PropRead.con.
+	MPropReadConCode MessageCode = 0xFB
+
+	// MPropWriteReqCode is the message code for M_PropWrite.req.
+	MPropWriteReqCode MessageCode = 0xF6
+
+	// MPropWriteConCode is the message code for M_PropWrite.con.
+	MPropWriteConCode MessageCode = 0xF5
+
+	// MPropInfoIndCode is the message code for M_PropInfo.ind.
+	MPropInfoIndCode MessageCode = 0xF7
+
+	// MFuncPropCommandReqCode is the message code for M_FuncPropCommand.req.
+	MFuncPropCommandReqCode MessageCode = 0xF8
+
+	// MFuncPropStateReadReqCode is the message code for M_FuncPropStateRead.req.
+	MFuncPropStateReadReqCode MessageCode = 0xF9
+
+	// MFuncPropConCode is the message code for M_FuncPropCommand.con and M_FuncPropStateRead.con.
+	MFuncPropConCode MessageCode = 0xFA
+
+	// MResetReqCode is the message code for M_Reset.req.
+	MResetReqCode MessageCode = 0xF1
+
+	// MResetIndCode is the message code for M_Reset.ind.
+	MResetIndCode MessageCode = 0xF0
+
 	// LPollDataReqCode MessageCode = 0x13
 	// LPollDataConCode MessageCode = 0x25
 )
@@ -63,12 +94,43 @@ func (code MessageCode) String() string {
 	case LRawConCode:
 		return "LRaw.con"
 
+	case MPropReadReqCode:
+		return "MPropRead.req"
+
+	case MPropReadConCode:
+		return "MPropRead.con"
+
+	case MPropWriteReqCode:
+		return "MPropWrite.req"
+
+	case MPropWriteConCode:
+		return "MPropWrite.con"
+
+	case MPropInfoIndCode:
+		return "MPropInfo.ind"
+
+	case MFuncPropCommandReqCode:
+		return "MFuncPropCommand.req"
+
+	case MFuncPropStateReadReqCode:
+		return "MFuncPropStateRead.req"
+
+	case MFuncPropConCode:
+		return "MFuncProp.con"
+
+	case MResetReqCode:
+		return "MReset.req"
+
+	case MResetIndCode:
+		return "MReset.ind"
+
 	default:
 		return fmt.Sprintf("%#x", uint8(code))
 	}
 }
 
-// Info is the additional info segment of a CEMI-encoded frame.
+// Info is the additional info segment of a CEMI-encoded frame. It is kept as is; use Entries to
+// parse it and Add to extend it.
 type Info []byte
 
 // Size returns the packed size.
@@ -100,6 +162,10 @@ func (info *Info) Unpack(data []byte) (n uint, err error) {
 		return
 	}
 
+	if uint(len(data)) < n+uint(length) {
+		return n, io.ErrUnexpectedEOF
+	}
+
 	if length > 0 {
 		buf := make([]byte, length)
 		n += uint(copy(buf, data[n:n+uint(length)]))
@@ -187,6 +253,36 @@ func Unpack(data []byte, message *Message) (n uint, err error) {
 	case LRawIndCode:
 		body = &LRawInd{}
 
+	case MPropReadReqCode:
+		body = &MPropReadReq{}
+
+	case MPropReadConCode:
+		body = &MPropReadCon{}
+
+	case MPropWriteReqCode:
+		body = &MPropWriteReq{}
+
+	case MPropWriteConCode:
+		body = &MPropWriteCon{}
+
+	case MPropInfoIndCode:
+		body = &MPropInfoInd{}
+
+	case MFuncPropCommandReqCode:
+		body = &MFuncPropCommandReq{}
+
+	case MFuncPropStateReadReqCode:
+		body = &MFuncPropStateReadReq{}
+
+	case MFuncPropConCode:
+		body = &MFuncPropCon{}
+
+	case MResetReqCode:
+		body = &MResetReq{}
+
+	case MResetIndCode:
+		body = &MResetInd{}
+
 	default:
 		body = &UnsupportedMessage{Code: code}
 	}
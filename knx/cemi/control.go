@@ -48,6 +48,11 @@ func Control1Prio(prio Priority) ControlField1 {
 	return ControlField1(prio&3) << 2
 }
 
+// Priority retrieves the priority.
+func (ctrl1 ControlField1) Priority() Priority {
+	return Priority(ctrl1>>2) & 3
+}
+
 // ControlField2 contains various control information.
 type ControlField2 uint8
 
@@ -58,7 +63,7 @@ func (ctrl2 ControlField2) IsGroupAddr() bool {
 
 // Hops retrieves the number of hops.
 func (ctrl2 ControlField2) Hops() uint8 {
-	return uint8(ctrl2>>7) & 7
+	return uint8(ctrl2>>4) & 7
 }
 
 const (
@@ -3,6 +3,12 @@
 
 package cemi
 
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
 // A LBusmonInd represents a L_Busmon.ind message.
 type LBusmonInd []byte
 
@@ -34,3 +40,132 @@ func (lbm *LBusmonInd) Unpack(data []byte) (n uint, err error) {
 
 	return
 }
+
+// Decode parses the additional info and the frame of the message. If the frame cannot be decoded,
+// the other fields are still initialized and the error is returned alongside them.
+func (lbm LBusmonInd) Decode() (*BusmonFrame, error) {
+	var info Info
+
+	n, err := info.Unpack(lbm)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := info.Entries()
+	if err != nil {
+		return nil, err
+	}
+
+	bm := &BusmonFrame{
+		Info: entries,
+		Raw:  append([]byte(nil), lbm[n:]...),
+	}
+
+	if status, ok := FindInfo[BusmonitorStatus](entries); ok {
+		bm.Status = status
+	}
+
+	if ts, ok := FindInfo[ExtendedTimestamp](entries); ok {
+		bm.Timestamp, bm.ExtendedTimestamp = uint32(ts), true
+	} else if ts, ok := FindInfo[RelativeTimestamp](entries); ok {
+		bm.Timestamp = uint32(ts)
+	}
+
+	if _, ok := bm.Ack(); ok {
+		return bm, nil
+	}
+
+	var frame TP1Frame
+
+	_, err = frame.Unpack(bm.Raw)
+	if err != nil && !errors.Is(err, ErrTP1Checksum) {
+		return bm, err
+	}
+
+	bm.Frame = &frame
+	bm.ChecksumOK = err == nil
+
+	return bm, nil
+}
+
+// A BusmonFrame is a decoded L_Busmon.ind message. It is either a single acknowledgement
+// character or a data frame.
+type BusmonFrame struct {
+	Info   AdditionalInfo
+	Status BusmonitorStatus
+
+	// Timestamp is the extended relative timestamp if ExtendedTimestamp is set, the relative
+	// timestamp otherwise.
+	Timestamp         uint32
+	ExtendedTimestamp bool
+
+	// Raw is the frame as received on the medium.
+	Raw []byte
+
+	// Frame is the decoded data frame, if any. ChecksumOK tells whether its check octet is valid.
+	Frame      *TP1Frame
+	ChecksumOK bool
+}
+
+// Ack returns the acknowledgement character, if the frame is one.
+func (bm *BusmonFrame) Ack() (TP1Ack, bool) {
+	if len(bm.Raw) != 1 || !TP1Ack(bm.Raw[0]).Valid() {
+		return 0, false
+	}
+
+	return TP1Ack(bm.Raw[0]), true
+}
+
+// String generates a single line description of the frame.
+func (bm *BusmonFrame) String() string {
+	var out strings.Builder
+
+	fmt.Fprintf(&out, "#%d t=%d ", bm.Status.Sequence(), bm.Timestamp)
+
+	switch ack, ok := bm.Ack(); {
+	case ok:
+		out.WriteString(ack.String())
+
+	case bm.Frame != nil:
+		dest := IndividualAddr(bm.Frame.Destination).String()
+		if bm.Frame.Control2.IsGroupAddr() {
+			dest = GroupAddr(bm.Frame.Destination).String()
+		}
+
+		fmt.Fprintf(&out, "%v -> %s prio=%d hops=%d", bm.Frame.Source, dest,
+			bm.Frame.Control1.Priority(), bm.Frame.Control2.Hops())
+
+		if bm.Frame.Control1&Control1NoRepeat == 0 {
+			out.WriteString(" repeated")
+		}
+
+		if app, ok := bm.Frame.Data.(*AppData); ok {
+			fmt.Fprintf(&out, " apci=%d data=% x", app.Command, app.Data)
+		} else if control, ok := bm.Frame.Data.(*ControlData); ok {
+			fmt.Fprintf(&out, " control=%d", control.Command)
+		}
+
+		if !bm.ChecksumOK {
+			out.WriteString(" checksum error")
+		}
+
+	default:
+		fmt.Fprintf(&out, "% x", bm.Raw)
+	}
+
+	for _, flag := range []struct {
+		set  bool
+		name string
+	}{
+		{bm.Status.FrameError(), "frame error"},
+		{bm.Status.BitError(), "bit error"},
+		{bm.Status.ParityError(), "parity error"},
+		{bm.Status.Lost(), "lost"},
+	} {
+		if flag.set {
+			out.WriteString(", " + flag.name)
+		}
+	}
+
+	return out.String()
+}
@@ -0,0 +1,89 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package cemi
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+func makeBusmonInd(info Info, frame ...byte) LBusmonInd {
+	return LBusmonInd(append(append([]byte{byte(len(info))}, info...), frame...))
+}
+
+func TestLBusmonInd_DecodeFrame(t *testing.T) {
+	frame := withTP1Checksum(0xbc, 0x11, 0x01, 0x09, 0x01, 0xe1, 0x00, 0x81)
+	lbm := makeBusmonInd(Info{0x03, 0x01, 0x0a, 0x04, 0x02, 0x12, 0x34}, frame...)
+
+	bm, err := lbm.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bm.Status.Sequence() != 2 || !bm.Status.Lost() || bm.Status.FrameError() {
+		t.Errorf("Unexpected status %#x", bm.Status)
+	}
+
+	if bm.Timestamp != 0x1234 || bm.ExtendedTimestamp {
+		t.Errorf("Unexpected timestamp %#x", bm.Timestamp)
+	}
+
+	if !bytes.Equal(bm.Raw, frame) {
+		t.Errorf("Unexpected raw frame % x", bm.Raw)
+	}
+
+	if bm.Frame == nil || !bm.ChecksumOK || bm.Frame.Source != 0x1101 {
+		t.Fatalf("Unexpected frame %+v", bm.Frame)
+	}
+
+	if _, ok := bm.Ack(); ok {
+		t.Error("Data frame is reported as acknowledgement")
+	}
+
+	expected := "#2 t=4660 1.1.1 -> 1/1/1 prio=3 hops=6 apci=2 data=01, lost"
+	if bm.String() != expected {
+		t.Errorf("Unexpected description %q, expected %q", bm.String(), expected)
+	}
+}
+
+func TestLBusmonInd_DecodeAck(t *testing.T) {
+	for _, ack := range []TP1Ack{TP1AckChar, TP1NakChar, TP1BusyChar, TP1NakBusyChar} {
+		bm, err := makeBusmonInd(Info{0x06, 0x04, 0, 0, 1, 0}, byte(ack)).Decode()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got, ok := bm.Ack(); !ok || got != ack || bm.Frame != nil {
+			t.Errorf("Unexpected acknowledgement %v %v", got, ok)
+		}
+
+		if bm.Timestamp != 256 || !bm.ExtendedTimestamp {
+			t.Errorf("Unexpected timestamp %d", bm.Timestamp)
+		}
+	}
+}
+
+func TestLBusmonInd_DecodeCorrupt(t *testing.T) {
+	frame := withTP1Checksum(0xbc, 0x11, 0x01, 0x09, 0x01, 0xe1, 0x00, 0x81)
+	frame[len(frame)-1] ^= 0x01
+
+	bm, err := makeBusmonInd(Info{0x03, 0x01, 0x80}, frame...).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bm.Frame == nil || bm.ChecksumOK || !bm.Status.FrameError() {
+		t.Errorf("Unexpected result %+v", bm)
+	}
+
+	bm, err = makeBusmonInd(Info{0x03, 0x01, 0x80}, frame[:4]...).Decode()
+	if !errors.Is(err, io.ErrUnexpectedEOF) || bm == nil || bm.Frame != nil || !bm.Status.FrameError() {
+		t.Errorf("Unexpected result %+v %v", bm, err)
+	}
+
+	if _, err := makeBusmonInd(Info{0x03, 0x02, 0x80}, frame...).Decode(); err == nil {
+		t.Error("Expected an error for malformed additional info")
+	}
+}
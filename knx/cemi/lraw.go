@@ -3,9 +3,49 @@
 
 package cemi
 
-// A LRaw is a raw link-layer frame. L_Raw.req, L_Raw.con and L_Raw.ind share this structure.
+import (
+	"errors"
+	"fmt"
+
+	"github.com/mobilarte/knx-exp/knx/util"
+)
+
+// A LRaw is a raw link-layer frame. L_Raw.req, L_Raw.con and L_Raw.ind share this structure. It
+// holds the additional info followed by the frame as it appears on the medium.
 type LRaw []byte
 
+// NewLRaw assembles a raw link-layer frame from the additional info and a TP1 frame.
+func NewLRaw(info Info, frame *TP1Frame) LRaw {
+	buffer := make([]byte, info.Size()+frame.Size())
+	util.PackSome(buffer, info, frame)
+
+	return LRaw(buffer)
+}
+
+// Decode parses the additional info and the TP1 frame. If only the check octet of the frame is
+// wrong, the frame is returned along with ErrTP1Checksum.
+func (lraw LRaw) Decode() (Info, *TP1Frame, error) {
+	var info Info
+
+	n, err := info.Unpack(lraw)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var frame TP1Frame
+
+	m, err := frame.Unpack(lraw[n:])
+	if err != nil && !errors.Is(err, ErrTP1Checksum) {
+		return nil, nil, err
+	}
+
+	if rest := len(lraw) - int(n+m); rest != 0 {
+		return nil, nil, fmt.Errorf("%d octets after the TP1 frame", rest)
+	}
+
+	return info, &frame, err
+}
+
 // Size returns the packed size.
 func (lraw LRaw) Size() uint {
 	return uint(len(lraw))
@@ -57,5 +97,5 @@ type LRawInd struct {
 
 // MessageCode returns the message code for L_Raw.ind.
 func (LRawInd) MessageCode() MessageCode {
-	return LRawConCode
+	return LRawIndCode
 }
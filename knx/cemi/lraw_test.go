@@ -0,0 +1,83 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package cemi
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestLRaw_RoundTrip(t *testing.T) {
+	frame := TP1Frame{
+		Control1:    Control1StdFrame | Control1NoRepeat | Control1NoSysBroadcast | Control1Prio(PrioLow),
+		Control2:    Control2GroupAddr | Control2Hops(6),
+		Source:      0x1101,
+		Destination: 0x0901,
+		Data:        &AppData{Command: GroupValueRead},
+	}
+
+	var info Info
+	if err := info.Add(RelativeTimestamp(7)); err != nil {
+		t.Fatal(err)
+	}
+
+	var msg Message = &LRawReq{NewLRaw(info, &frame)}
+
+	buffer := make([]byte, Size(msg))
+	Pack(buffer, msg)
+
+	var result Message
+	if _, err := Unpack(buffer, &result); err != nil {
+		t.Fatal(err)
+	}
+
+	req, ok := result.(*LRawReq)
+	if !ok {
+		t.Fatalf("Unexpected message %T", result)
+	}
+
+	resultInfo, resultFrame, err := req.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(resultInfo, info) {
+		t.Errorf("Unexpected info % x", resultInfo)
+	}
+
+	if resultFrame.Source != frame.Source || resultFrame.Destination != frame.Destination ||
+		resultFrame.Control2 != frame.Control2 {
+		t.Errorf("Unexpected frame %+v", resultFrame)
+	}
+
+	if app, ok := resultFrame.Data.(*AppData); !ok || app.Command != GroupValueRead {
+		t.Errorf("Unexpected TPDU %+v", resultFrame.Data)
+	}
+}
+
+func TestLRaw_DecodeInvalid(t *testing.T) {
+	raw := append(LRaw{0}, withTP1Checksum(0xbc, 0x11, 0x01, 0x09, 0x01, 0xe1, 0x00, 0x81)...)
+	raw[len(raw)-1] ^= 0xff
+
+	if _, frame, err := raw.Decode(); !errors.Is(err, ErrTP1Checksum) || frame == nil {
+		t.Errorf("Unexpected result %v %v", frame, err)
+	}
+
+	for _, raw := range []LRaw{
+		{},
+		{3, 0x04, 0x02},
+		append(LRaw{0}, withTP1Checksum(0xbc, 0x11, 0x01, 0x09, 0x01, 0xe1, 0x00, 0x81, 0xff)...),
+	} {
+		if _, _, err := raw.Decode(); err == nil {
+			t.Errorf("Expected an error for % x", raw)
+		}
+	}
+}
+
+func TestLRaw_MessageCode(t *testing.T) {
+	if (LRawReq{}).MessageCode() != LRawReqCode || (LRawCon{}).MessageCode() != LRawConCode ||
+		(LRawInd{}).MessageCode() != LRawIndCode {
+		t.Error("Unexpected message codes")
+	}
+}
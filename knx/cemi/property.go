@@ -0,0 +1,341 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package cemi
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/mobilarte/knx-exp/knx/util"
+)
+
+// ObjectType identifies the type of an interface object.
+type ObjectType uint16
+
+// Interface object types, see 03_07_03 Standardized Identifier Tables.
+const (
+	DeviceObject             ObjectType = 0
+	AddressTableObject       ObjectType = 1
+	AssociationTableObject   ObjectType = 2
+	ApplicationProgramObject ObjectType = 3
+	InterfaceProgramObject   ObjectType = 4
+	RouterObject             ObjectType = 6
+	CEMIServerObject         ObjectType = 8
+	GroupObjectTableObject   ObjectType = 9
+	KNXnetIPParameterObject  ObjectType = 11
+	SecurityObject           ObjectType = 17
+	RFMediumObject           ObjectType = 19
+)
+
+// PropertyID identifies a property of an interface object. Identifiers below 50 have the same
+// meaning for all object types, the others depend on the object type.
+type PropertyID uint8
+
+// Property identifiers, see 03_07_03 Standardized Identifier Tables.
+const (
+	// Properties of all object types
+	PIDObjectType     PropertyID = 1
+	PIDObjectName     PropertyID = 2
+	PIDLoadStateCtrl  PropertyID = 5
+	PIDSerialNumber   PropertyID = 11
+	PIDManufacturerID PropertyID = 12
+	PIDOrderInfo      PropertyID = 15
+	PIDVersion        PropertyID = 25
+
+	// Properties of the device object
+	PIDProgMode         PropertyID = 54
+	PIDMaxAPDULength    PropertyID = 56
+	PIDSubnetAddr       PropertyID = 57
+	PIDDeviceAddr       PropertyID = 58
+	PIDDeviceDescriptor PropertyID = 83
+
+	// Properties of the cEMI server object
+	PIDMediumType  PropertyID = 51
+	PIDCommMode    PropertyID = 52
+	PIDMediumAvail PropertyID = 53
+
+	// Properties of the KNXnet/IP parameter object
+	PIDProjectInstallationID         PropertyID = 51
+	PIDKNXIndividualAddress          PropertyID = 52
+	PIDAdditionalIndividualAddresses PropertyID = 53
+	PIDCurrentIPAssignmentMethod     PropertyID = 54
+	PIDIPAssignmentMethod            PropertyID = 55
+	PIDCurrentIPAddress              PropertyID = 57
+	PIDCurrentSubnetMask             PropertyID = 58
+	PIDCurrentDefaultGateway         PropertyID = 59
+	PIDIPAddress                     PropertyID = 60
+	PIDSubnetMask                    PropertyID = 61
+	PIDDefaultGateway                PropertyID = 62
+	PIDMACAddress                    PropertyID = 64
+	PIDRoutingMulticastAddress       PropertyID = 66
+	PIDFriendlyName                  PropertyID = 76
+)
+
+// PropertyErrorCode describes why a property service has failed.
+type PropertyErrorCode uint8
+
+// Property service error codes, see 03_06_03 EMI_IMI 4.1.7.3.7.2.
+const (
+	PropErrUnspecified       PropertyErrorCode = 0x00
+	PropErrOutOfRange        PropertyErrorCode = 0x01
+	PropErrOutOfMaxRange     PropertyErrorCode = 0x02
+	PropErrOutOfMinRange     PropertyErrorCode = 0x03
+	PropErrMemory            PropertyErrorCode = 0x04
+	PropErrReadOnly          PropertyErrorCode = 0x05
+	PropErrIllegalCommand    PropertyErrorCode = 0x06
+	PropErrVoidDP            PropertyErrorCode = 0x07
+	PropErrTypeConflict      PropertyErrorCode = 0x08
+	PropErrIndexRange        PropertyErrorCode = 0x09
+	PropErrTemporarilyLocked PropertyErrorCode = 0x0A
+)
+
+// String describes the error code.
+func (code PropertyErrorCode) String() string {
+	switch code {
+	case PropErrUnspecified:
+		return "unspecified error"
+	case PropErrOutOfRange:
+		return "write value out of range"
+	case PropErrOutOfMaxRange:
+		return "write value too high"
+	case PropErrOutOfMinRange:
+		return "write value too low"
+	case PropErrMemory:
+		return "memory cannot be written or only with fault(s)"
+	case PropErrReadOnly:
+		return "write access to a read-only or protected property"
+	case PropErrIllegalCommand:
+		return "command not valid or not supported"
+	case PropErrVoidDP:
+		return "read or write access to a non-existing property"
+	case PropErrTypeConflict:
+		return "write access with a wrong data type"
+	case PropErrIndexRange:
+		return "read or write access to a non-existing property array index"
+	case PropErrTemporarilyLocked:
+		return "property exists but cannot be set to a new value at the time"
+	default:
+		return fmt.Sprintf("unknown property error %#x", uint8(code))
+	}
+}
+
+// Error implements the error interface.
+func (code PropertyErrorCode) Error() string {
+	return code.String()
+}
+
+// MaxPropertyCount is the maximum number of elements that a single property service can access.
+const MaxPropertyCount = 15
+
+// PropertyData is the body shared by the property services. It addresses Count elements of a
+// property, beginning at StartIndex. The element at index 0 holds the current number of elements.
+type PropertyData struct {
+	ObjectType     ObjectType
+	ObjectInstance uint8
+	PropertyID     PropertyID
+	Count          uint8  // 4 bits
+	StartIndex     uint16 // 12 bits
+	Data           []byte
+}
+
+// Size returns the packed size.
+func (prop *PropertyData) Size() uint {
+	return 6 + uint(len(prop.Data))
+}
+
+// Pack the message body into the buffer.
+func (prop *PropertyData) Pack(buffer []byte) {
+	util.PackSome(
+		buffer,
+		uint16(prop.ObjectType),
+		prop.ObjectInstance,
+		uint8(prop.PropertyID),
+		uint16(prop.Count&0xF)<<12|prop.StartIndex&0xFFF,
+	)
+	copy(buffer[6:], prop.Data)
+}
+
+// Unpack initializes the structure by parsing the given data.
+func (prop *PropertyData) Unpack(data []byte) (n uint, err error) {
+	var countIndex uint16
+
+	n, err = util.UnpackSome(
+		data, (*uint16)(&prop.ObjectType), &prop.ObjectInstance, (*uint8)(&prop.PropertyID), &countIndex,
+	)
+	if err != nil {
+		return
+	}
+
+	prop.Count = uint8(countIndex >> 12)
+	prop.StartIndex = countIndex & 0xFFF
+	prop.Data = append([]byte(nil), data[n:]...)
+
+	return uint(len(data)), nil
+}
+
+// ErrMissingPropertyErrorCode is returned by PropertyData.Err for a negative confirmation without
+// error code.
+var ErrMissingPropertyErrorCode = errors.New("negative property confirmation without error code")
+
+// Err returns the error of a negative confirmation, which is indicated by a zero count and carries
+// the error code as data. It returns nil for a positive confirmation.
+func (prop *PropertyData) Err() error {
+	if prop.Count != 0 {
+		return nil
+	}
+
+	if len(prop.Data) == 0 {
+		return ErrMissingPropertyErrorCode
+	}
+
+	return PropertyErrorCode(prop.Data[0])
+}
+
+// Answers determines whether the confirmation prop refers to the same property elements as the
+// request req.
+func (prop *PropertyData) Answers(req *PropertyData) bool {
+	return prop.ObjectType == req.ObjectType &&
+		prop.ObjectInstance == req.ObjectInstance &&
+		prop.PropertyID == req.PropertyID &&
+		prop.StartIndex == req.StartIndex
+}
+
+// A MPropReadReq represents a M_PropRead.req message body. It carries no data.
+type MPropReadReq struct {
+	PropertyData
+}
+
+// MessageCode returns the message code for M_PropRead.req.
+func (MPropReadReq) MessageCode() MessageCode {
+	return MPropReadReqCode
+}
+
+// A MPropReadCon represents a M_PropRead.con message body. It carries the requested elements, or
+// an error code if Count is zero.
+type MPropReadCon struct {
+	PropertyData
+}
+
+// MessageCode returns the message code for M_PropRead.con.
+func (MPropReadCon) MessageCode() MessageCode {
+	return MPropReadConCode
+}
+
+// A MPropWriteReq represents a M_PropWrite.req message body. It carries the elements to write.
+type MPropWriteReq struct {
+	PropertyData
+}
+
+// MessageCode returns the message code for M_PropWrite.req.
+func (MPropWriteReq) MessageCode() MessageCode {
+	return MPropWriteReqCode
+}
+
+// A MPropWriteCon represents a M_PropWrite.con message body. It carries an error code if Count is
+// zero.
+type MPropWriteCon struct {
+	PropertyData
+}
+
+// MessageCode returns the message code for M_PropWrite.con.
+func (MPropWriteCon) MessageCode() MessageCode {
+	return MPropWriteConCode
+}
+
+// A MPropInfoInd represents a M_PropInfo.ind message body. The cEMI server sends it when the value
+// of a property has changed.
+type MPropInfoInd struct {
+	PropertyData
+}
+
+// MessageCode returns the message code for M_PropInfo.ind.
+func (MPropInfoInd) MessageCode() MessageCode {
+	return MPropInfoIndCode
+}
+
+// FunctionPropertyData is the body shared by the function property services. Unlike PropertyData,
+// it addresses the property as a whole.
+type FunctionPropertyData struct {
+	ObjectType     ObjectType
+	ObjectInstance uint8
+	PropertyID     PropertyID
+	Data           []byte
+}
+
+// Size returns the packed size.
+func (prop *FunctionPropertyData) Size() uint {
+	return 4 + uint(len(prop.Data))
+}
+
+// Pack the message body into the buffer.
+func (prop *FunctionPropertyData) Pack(buffer []byte) {
+	util.PackSome(buffer, uint16(prop.ObjectType), prop.ObjectInstance, uint8(prop.PropertyID))
+	copy(buffer[4:], prop.Data)
+}
+
+// Unpack initializes the structure by parsing the given data.
+func (prop *FunctionPropertyData) Unpack(data []byte) (n uint, err error) {
+	n, err = util.UnpackSome(
+		data, (*uint16)(&prop.ObjectType), &prop.ObjectInstance, (*uint8)(&prop.PropertyID),
+	)
+	if err != nil {
+		return
+	}
+
+	prop.Data = append([]byte(nil), data[n:]...)
+
+	return uint(len(data)), nil
+}
+
+// A MFuncPropCommandReq represents a M_FuncPropCommand.req message body. It carries the input of
+// the function.
+type MFuncPropCommandReq struct {
+	FunctionPropertyData
+}
+
+// MessageCode returns the message code for M_FuncPropCommand.req.
+func (MFuncPropCommandReq) MessageCode() MessageCode {
+	return MFuncPropCommandReqCode
+}
+
+// A MFuncPropStateReadReq represents a M_FuncPropStateRead.req message body. It carries the input
+// of the function.
+type MFuncPropStateReadReq struct {
+	FunctionPropertyData
+}
+
+// MessageCode returns the message code for M_FuncPropStateRead.req.
+func (MFuncPropStateReadReq) MessageCode() MessageCode {
+	return MFuncPropStateReadReqCode
+}
+
+// A MFuncPropCon represents a M_FuncPropCommand.con or M_FuncPropStateRead.con message body, which
+// share their message code. The data starts with the return code, followed by the output of the
+// function. A confirmation without data is negative.
+type MFuncPropCon struct {
+	FunctionPropertyData
+}
+
+// MessageCode returns the message code for M_FuncPropCommand.con and M_FuncPropStateRead.con.
+func (MFuncPropCon) MessageCode() MessageCode {
+	return MFuncPropConCode
+}
+
+// ReturnCode retrieves the return code of the function. It reports false for a negative
+// confirmation.
+func (con *MFuncPropCon) ReturnCode() (uint8, bool) {
+	if len(con.Data) == 0 {
+		return 0, false
+	}
+
+	return con.Data[0], true
+}
+
+// Output retrieves the output of the function, which follows the return code.
+func (con *MFuncPropCon) Output() []byte {
+	if len(con.Data) == 0 {
+		return nil
+	}
+
+	return con.Data[1:]
+}
@@ -0,0 +1,129 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package cemi
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestPropertyMessages_PackUnpack(t *testing.T) {
+	data := PropertyData{
+		ObjectType:     KNXnetIPParameterObject,
+		ObjectInstance: 1,
+		PropertyID:     PIDFriendlyName,
+		Count:          15,
+		StartIndex:     16,
+		Data:           []byte("KNX IP Interfac"),
+	}
+
+	function := func(data ...byte) FunctionPropertyData {
+		return FunctionPropertyData{ObjectType: RFMediumObject, ObjectInstance: 1, PropertyID: 60, Data: data}
+	}
+
+	for _, msg := range []Message{
+		&MPropReadReq{PropertyData{ObjectType: DeviceObject, ObjectInstance: 1, PropertyID: PIDProgMode, Count: 1}},
+		&MPropReadCon{data},
+		&MPropWriteReq{data},
+		&MPropWriteCon{PropertyData{ObjectType: DeviceObject, ObjectInstance: 1, PropertyID: PIDProgMode}},
+		&MPropInfoInd{data},
+		&MFuncPropCommandReq{function()},
+		&MFuncPropStateReadReq{function(1)},
+		&MFuncPropCon{function(0, 7)},
+		&MResetReq{},
+		&MResetInd{},
+	} {
+		buffer := make([]byte, Size(msg))
+		Pack(buffer, msg)
+
+		var result Message
+
+		n, err := Unpack(buffer, &result)
+		if err != nil {
+			t.Fatalf("Unpacking %T failed: %v", msg, err)
+		}
+
+		if n != uint(len(buffer)) {
+			t.Errorf("Unexpected length for %T: %d != %d", msg, n, len(buffer))
+		}
+
+		if result.MessageCode() != msg.MessageCode() {
+			t.Fatalf("Unexpected message code %v, expected %v", result.MessageCode(), msg.MessageCode())
+		}
+
+		repacked := make([]byte, Size(result))
+		Pack(repacked, result)
+
+		if !bytes.Equal(repacked, buffer) {
+			t.Errorf("Repacked %T differs: %v != %v", msg, repacked, buffer)
+		}
+	}
+}
+
+func TestPropertyData_Unpack(t *testing.T) {
+	var prop PropertyData
+
+	_, err := prop.Unpack([]byte{0x00, 0x0b, 0x01, 0x4c, 0xf0, 0x10, 'a'})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if prop.ObjectType != KNXnetIPParameterObject || prop.ObjectInstance != 1 ||
+		prop.PropertyID != PIDFriendlyName || prop.Count != 15 || prop.StartIndex != 16 ||
+		!bytes.Equal(prop.Data, []byte{'a'}) {
+		t.Errorf("Unexpected result: %+v", prop)
+	}
+
+	if _, err := prop.Unpack([]byte{0x00, 0x0b, 0x01}); err == nil {
+		t.Error("Should not succeed")
+	}
+}
+
+func TestPropertyData_Err(t *testing.T) {
+	positive := PropertyData{Count: 1, Data: []byte{1}}
+	if err := positive.Err(); err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+
+	negative := PropertyData{Count: 0, Data: []byte{byte(PropErrReadOnly)}}
+	if err := negative.Err(); !errors.Is(err, PropErrReadOnly) {
+		t.Errorf("Expected %v, got %v", PropErrReadOnly, err)
+	}
+
+	empty := PropertyData{}
+	if err := empty.Err(); !errors.Is(err, ErrMissingPropertyErrorCode) {
+		t.Errorf("Expected %v, got %v", ErrMissingPropertyErrorCode, err)
+	}
+}
+
+func TestMFuncPropCon(t *testing.T) {
+	var msg Message
+
+	_, err := Unpack([]byte{0xfa, 0x00, 0x13, 0x01, 0x3c, 0x00, 0x07, 0x08}, &msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	con, ok := msg.(*MFuncPropCon)
+	if !ok {
+		t.Fatalf("Unexpected message %T", msg)
+	}
+
+	if con.ObjectType != RFMediumObject || con.ObjectInstance != 1 || con.PropertyID != 60 {
+		t.Errorf("Unexpected property %+v", con.FunctionPropertyData)
+	}
+
+	if code, ok := con.ReturnCode(); !ok || code != 0 || !bytes.Equal(con.Output(), []byte{7, 8}) {
+		t.Errorf("Unexpected result %d %v % x", code, ok, con.Output())
+	}
+
+	negative := MFuncPropCon{}
+	if _, ok := negative.ReturnCode(); ok || negative.Output() != nil {
+		t.Error("Negative confirmation has a return code")
+	}
+
+	if _, err := con.Unpack([]byte{0x00, 0x13}); err == nil {
+		t.Error("Should not succeed")
+	}
+}
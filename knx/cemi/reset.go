@@ -0,0 +1,46 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package cemi
+
+// A MResetReq represents a M_Reset.req message body, which restarts the cEMI server. It is empty.
+type MResetReq struct{}
+
+// MessageCode returns the message code for M_Reset.req.
+func (MResetReq) MessageCode() MessageCode {
+	return MResetReqCode
+}
+
+// Size returns the packed size.
+func (MResetReq) Size() uint {
+	return 0
+}
+
+// Pack the message body into the buffer.
+func (MResetReq) Pack([]byte) {}
+
+// Unpack initializes the structure by parsing the given data.
+func (*MResetReq) Unpack([]byte) (uint, error) {
+	return 0, nil
+}
+
+// A MResetInd represents a M_Reset.ind message body, which the cEMI server sends after it has
+// restarted. It is empty.
+type MResetInd struct{}
+
+// MessageCode returns the message code for M_Reset.ind.
+func (MResetInd) MessageCode() MessageCode {
+	return MResetIndCode
+}
+
+// Size returns the packed size.
+func (MResetInd) Size() uint {
+	return 0
+}
+
+// Pack the message body into the buffer.
+func (MResetInd) Pack([]byte) {}
+
+// Unpack initializes the structure by parsing the given data.
+func (*MResetInd) Unpack([]byte) (uint, error) {
+	return 0, nil
+}
@@ -0,0 +1,192 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package cemi
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/mobilarte/knx-exp/knx/util"
+)
+
+// TP1Ack is an acknowledgement character on twisted pair (TP1) medium.
+type TP1Ack uint8
+
+// These are the acknowledgement characters.
+const (
+	TP1AckChar     TP1Ack = 0xCC
+	TP1NakChar     TP1Ack = 0x0C
+	TP1BusyChar    TP1Ack = 0xC0
+	TP1NakBusyChar TP1Ack = 0x00
+)
+
+// String converts the acknowledgement to a string.
+func (ack TP1Ack) String() string {
+	switch ack {
+	case TP1AckChar:
+		return "ACK"
+	case TP1NakChar:
+		return "NAK"
+	case TP1BusyChar:
+		return "BUSY"
+	case TP1NakBusyChar:
+		return "NAK+BUSY"
+	default:
+		return fmt.Sprintf("%#x", uint8(ack))
+	}
+}
+
+// Valid determines whether the character is one of the acknowledgement characters.
+func (ack TP1Ack) Valid() bool {
+	return ack == TP1AckChar || ack == TP1NakChar || ack == TP1BusyChar || ack == TP1NakBusyChar
+}
+
+// ErrTP1Checksum is returned when the check octet of a TP1 frame does not match its content.
+var ErrTP1Checksum = errors.New("TP1 frame checksum mismatch")
+
+// A TP1Frame is a data frame on twisted pair (TP1) medium, as carried by L_Busmon.ind and L_Raw.
+// The control field of the frame maps to Control1; the address type and hop count map to Control2,
+// as do the extended frame format bits of an extended frame.
+//
+// A frame is packed as a standard frame if Control1StdFrame is set and the TPDU fits, otherwise
+// as an extended frame.
+type TP1Frame struct {
+	Control1    ControlField1
+	Control2    ControlField2
+	Source      IndividualAddr
+	Destination uint16
+	Data        TransportUnit
+}
+
+// TP1Frame converts the link-layer data frame into a TP1 frame. The additional info is dropped.
+func (ldata *LData) TP1Frame() TP1Frame {
+	return TP1Frame{
+		Control1:    ldata.Control1,
+		Control2:    ldata.Control2,
+		Source:      ldata.Source,
+		Destination: ldata.Destination,
+		Data:        ldata.Data,
+	}
+}
+
+// LData converts the frame into a link-layer data frame without additional info.
+func (frame *TP1Frame) LData() LData {
+	return LData{
+		Control1:    frame.Control1,
+		Control2:    frame.Control2,
+		Source:      frame.Source,
+		Destination: frame.Destination,
+		Data:        frame.Data,
+	}
+}
+
+// Size returns the packed size, including the check octet.
+func (frame *TP1Frame) Size() uint {
+	if frame.extended() {
+		return 7 + frame.Data.Size()
+	}
+
+	return 6 + frame.Data.Size()
+}
+
+// Pack the frame into the buffer and append the check octet.
+func (frame *TP1Frame) Pack(buffer []byte) {
+	// The acknowledgement request and the confirmation flag only exist in cEMI.
+	ctrl1 := frame.Control1 &^ (1<<6 | Control1WantAck | Control1HasError)
+
+	var offset int
+
+	if frame.extended() {
+		buffer[0] = byte(ctrl1 &^ Control1StdFrame)
+		buffer[1] = byte(frame.Control2)
+		offset = 6
+	} else {
+		buffer[0] = byte(ctrl1 | Control1StdFrame)
+		offset = 5
+	}
+
+	util.PackSome(buffer[offset-4:], uint16(frame.Source), frame.Destination)
+
+	// The length octet of the TPDU is shared with the address type and hop count in standard frames.
+	frame.Data.Pack(buffer[offset:])
+
+	if !frame.extended() {
+		buffer[offset] = byte(frame.Control2&0xF0) | buffer[offset]&0x0F
+	}
+
+	end := offset + int(frame.Data.Size())
+	buffer[end] = tp1Checksum(buffer[:end])
+}
+
+// Unpack initializes the structure by parsing the given data, which must end with the check
+// octet. If only the check octet is wrong, the frame is initialized and ErrTP1Checksum is returned.
+func (frame *TP1Frame) Unpack(data []byte) (n uint, err error) {
+	if len(data) < 1 {
+		return 0, io.ErrUnexpectedEOF
+	}
+
+	// Bit 6 is set in poll data frames, which carry no addresses of their own.
+	if data[0]&(1<<6) != 0 {
+		return 0, fmt.Errorf("unsupported TP1 frame with control field %#02x", data[0])
+	}
+
+	var (
+		addrs  []byte
+		length byte
+		offset uint
+	)
+
+	if data[0]&byte(Control1StdFrame) != 0 {
+		if len(data) < 6 {
+			return 0, io.ErrUnexpectedEOF
+		}
+
+		frame.Control2 = ControlField2(data[5] & 0xF0)
+		addrs, length, offset = data[1:5], data[5]&0x0F, 6
+	} else {
+		if len(data) < 7 {
+			return 0, io.ErrUnexpectedEOF
+		}
+
+		frame.Control2 = ControlField2(data[1])
+		addrs, length, offset = data[2:6], data[6], 7
+	}
+
+	// The TPDU consists of the TPCI and length more octets.
+	n = offset + 1 + uint(length)
+	if uint(len(data)) < n+1 {
+		return 0, io.ErrUnexpectedEOF
+	}
+
+	frame.Control1 = ControlField1(data[0])
+	frame.Source = IndividualAddr(addrs[0])<<8 | IndividualAddr(addrs[1])
+	frame.Destination = uint16(addrs[2])<<8 | uint16(addrs[3])
+
+	if _, err := unpackTransportUnit(append([]byte{length}, data[offset:n]...), &frame.Data); err != nil {
+		return 0, err
+	}
+
+	if tp1Checksum(data[:n]) != data[n] {
+		return n + 1, ErrTP1Checksum
+	}
+
+	return n + 1, nil
+}
+
+// extended determines whether the frame must be packed as an extended frame. Standard frames carry
+// up to 15 octets after the TPCI.
+func (frame *TP1Frame) extended() bool {
+	return frame.Control1&Control1StdFrame == 0 || frame.Data.Size() > 2+15
+}
+
+// tp1Checksum computes the check octet, which is the inverted exclusive or of all octets.
+func tp1Checksum(data []byte) byte {
+	var sum byte
+
+	for _, b := range data {
+		sum ^= b
+	}
+
+	return ^sum
+}
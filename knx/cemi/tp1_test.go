@@ -0,0 +1,180 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package cemi
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+// withTP1Checksum appends the check octet.
+func withTP1Checksum(data ...byte) []byte {
+	return append(data, tp1Checksum(data))
+}
+
+func TestTP1Frame_UnpackStandard(t *testing.T) {
+	data := withTP1Checksum(0xbc, 0x11, 0x01, 0x09, 0x01, 0xe1, 0x00, 0x81)
+
+	var frame TP1Frame
+
+	n, err := frame.Unpack(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n != uint(len(data)) {
+		t.Errorf("Unexpected length %d", n)
+	}
+
+	if frame.Control1.Priority() != PrioLow || frame.Control1&Control1StdFrame == 0 {
+		t.Errorf("Unexpected control field %#x", frame.Control1)
+	}
+
+	if !frame.Control2.IsGroupAddr() || frame.Control2.Hops() != 6 {
+		t.Errorf("Unexpected address type or hops %#x", frame.Control2)
+	}
+
+	if frame.Source != 0x1101 || frame.Destination != 0x0901 {
+		t.Errorf("Unexpected addresses %v %#x", frame.Source, frame.Destination)
+	}
+
+	app, ok := frame.Data.(*AppData)
+	if !ok || app.Command != GroupValueWrite || !bytes.Equal(app.Data, []byte{1}) {
+		t.Errorf("Unexpected TPDU %+v", frame.Data)
+	}
+}
+
+func TestTP1Frame_UnpackExtended(t *testing.T) {
+	payload := bytes.Repeat([]byte{0x42}, 19)
+	data := withTP1Checksum(append([]byte{0x3c, 0xd0, 0x11, 0x01, 0x09, 0x01, 20, 0x00, 0x80}, payload...)...)
+
+	var frame TP1Frame
+	if _, err := frame.Unpack(data); err != nil {
+		t.Fatal(err)
+	}
+
+	if frame.Control1&Control1StdFrame != 0 || frame.Control2.Hops() != 5 || !frame.Control2.IsGroupAddr() {
+		t.Errorf("Unexpected control fields %#x %#x", frame.Control1, frame.Control2)
+	}
+
+	app, ok := frame.Data.(*AppData)
+	if !ok || app.Command != GroupValueWrite || !bytes.Equal(app.Data[1:], payload) {
+		t.Errorf("Unexpected TPDU %+v", frame.Data)
+	}
+}
+
+func TestTP1Frame_UnpackControl(t *testing.T) {
+	// T_Disconnect to 1.1.2.
+	data := withTP1Checksum(0xb0, 0x11, 0x01, 0x11, 0x02, 0x60, 0x81)
+
+	var frame TP1Frame
+	if _, err := frame.Unpack(data); err != nil {
+		t.Fatal(err)
+	}
+
+	if control, ok := frame.Data.(*ControlData); !ok || control.Command != 1 {
+		t.Errorf("Unexpected TPDU %+v", frame.Data)
+	}
+}
+
+func TestTP1Frame_UnpackInvalid(t *testing.T) {
+	var frame TP1Frame
+
+	data := withTP1Checksum(0xbc, 0x11, 0x01, 0x09, 0x01, 0xe1, 0x00, 0x81)
+	data[len(data)-1] ^= 0xff
+
+	if _, err := frame.Unpack(data); !errors.Is(err, ErrTP1Checksum) {
+		t.Errorf("Unexpected error %v", err)
+	}
+
+	if frame.Source != 0x1101 {
+		t.Errorf("Frame has not been initialized despite checksum error")
+	}
+
+	if _, err := frame.Unpack(data[:len(data)-1]); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("Unexpected error %v", err)
+	}
+
+	if _, err := frame.Unpack([]byte{0xf0, 0x11, 0x01, 0x09}); err == nil {
+		t.Error("Expected an error for a poll data frame")
+	}
+}
+
+func TestControlField2_Hops(t *testing.T) {
+	for hops := range uint8(8) {
+		ctrl2 := Control2GroupAddr | Control2Hops(hops)
+		if ctrl2.Hops() != hops {
+			t.Errorf("Unexpected hops %d, expected %d", ctrl2.Hops(), hops)
+		}
+	}
+}
+
+func TestTP1Frame_Pack(t *testing.T) {
+	std := withTP1Checksum(0xbc, 0x11, 0x01, 0x09, 0x01, 0xe1, 0x00, 0x81)
+
+	ldata := LData{
+		Control1:    Control1StdFrame | Control1NoRepeat | Control1NoSysBroadcast | Control1Prio(PrioLow) | Control1WantAck,
+		Control2:    Control2GroupAddr | Control2Hops(6),
+		Source:      0x1101,
+		Destination: 0x0901,
+		Data:        &AppData{Command: GroupValueWrite, Data: []byte{1}},
+	}
+
+	frame := ldata.TP1Frame()
+
+	buffer := make([]byte, frame.Size())
+	frame.Pack(buffer)
+
+	if !bytes.Equal(buffer, std) {
+		t.Errorf("Unexpected standard frame % x, expected % x", buffer, std)
+	}
+
+	// The TPDU does not fit into a standard frame.
+	frame.Data = &AppData{Command: GroupValueWrite, Data: append([]byte{0}, bytes.Repeat([]byte{0x42}, 15)...)}
+
+	buffer = make([]byte, frame.Size())
+	frame.Pack(buffer)
+
+	if buffer[0] != 0x3c || buffer[1] != 0xe0 || buffer[6] != 16 || len(buffer) != 7+1+16+1 {
+		t.Errorf("Unexpected extended frame % x", buffer)
+	}
+
+	var result TP1Frame
+	if _, err := result.Unpack(buffer); err != nil {
+		t.Fatal(err)
+	}
+
+	converted := result.LData()
+	if converted.Source != ldata.Source || converted.Destination != ldata.Destination ||
+		converted.Control2 != ldata.Control2 || converted.Control1.Priority() != PrioLow {
+		t.Errorf("Unexpected conversion %+v", converted)
+	}
+
+	app, ok := converted.Data.(*AppData)
+	if !ok || !bytes.Equal(app.Data, frame.Data.(*AppData).Data) {
+		t.Errorf("Unexpected TPDU %+v", converted.Data)
+	}
+}
+
+func TestTP1Frame_RoundTrip(t *testing.T) {
+	for _, data := range [][]byte{
+		withTP1Checksum(0xbc, 0x11, 0x01, 0x09, 0x01, 0xe1, 0x00, 0x81),
+		withTP1Checksum(0xb0, 0x11, 0x01, 0x11, 0x02, 0x60, 0x81),
+		withTP1Checksum(0x9c, 0x11, 0x01, 0x0a, 0x01, 0xe3, 0x00, 0x80, 0x0c, 0x1a),
+		withTP1Checksum(0x3c, 0xd0, 0x11, 0x01, 0x09, 0x01, 0x03, 0x00, 0x80, 0x01, 0x02),
+	} {
+		var frame TP1Frame
+		if _, err := frame.Unpack(data); err != nil {
+			t.Fatal(err)
+		}
+
+		buffer := make([]byte, frame.Size())
+		frame.Pack(buffer)
+
+		if !bytes.Equal(buffer, data) {
+			t.Errorf("Unexpected frame % x, expected % x", buffer, data)
+		}
+	}
+}
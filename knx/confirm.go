@@ -0,0 +1,66 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knx
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/mobilarte/knx-exp/knx/cemi"
+	"github.com/mobilarte/knx-exp/knx/util"
+)
+
+// These errors are wrapped by a ConfirmationError.
+var (
+	// ErrNegativeConfirmation indicates that the gateway could not transmit the frame on the bus.
+	ErrNegativeConfirmation = errors.New("negative L_Data.con received")
+
+	// ErrMissingConfirmation indicates that the gateway did not send an L_Data.con in time.
+	ErrMissingConfirmation = errors.New("no L_Data.con received")
+)
+
+// A ConfirmationError is returned by Tunnel.Send when WaitForConfirmation is enabled and the
+// L_Data.req has not been confirmed positively. Use errors.Is with ErrNegativeConfirmation or
+// ErrMissingConfirmation to distinguish both cases.
+type ConfirmationError struct {
+	// Req is the request that has not been confirmed.
+	Req *cemi.LDataReq
+
+	// Con is the negative confirmation. It is nil if no confirmation has been received.
+	Con *cemi.LDataCon
+}
+
+// Error implements the error interface.
+func (err *ConfirmationError) Error() string {
+	return fmt.Sprintf("L_Data.req to %#04x: %v", err.Req.Destination, err.Unwrap())
+}
+
+// Unwrap returns ErrNegativeConfirmation or ErrMissingConfirmation.
+func (err *ConfirmationError) Unwrap() error {
+	if err.Con == nil {
+		return ErrMissingConfirmation
+	}
+
+	return ErrNegativeConfirmation
+}
+
+// confirmWaiter is a sender that awaits the L_Data.con for its request.
+type confirmWaiter struct {
+	req *cemi.LDataReq
+	con chan *cemi.LDataCon
+}
+
+// confirms determines if con is the confirmation of req. The source is not compared, because the
+// gateway replaces it with its own address if it has not been set.
+func confirms(req, con *cemi.LData) bool {
+	if req.Destination != con.Destination || req.Control2.IsGroupAddr() != con.Control2.IsGroupAddr() {
+		return false
+	}
+
+	if req.Data == nil || con.Data == nil {
+		return req.Data == nil && con.Data == nil
+	}
+
+	return bytes.Equal(util.AllocAndPack(req.Data), util.AllocAndPack(con.Data))
+}
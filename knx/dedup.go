@@ -0,0 +1,156 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knx
+
+import (
+	"sync"
+	"time"
+
+	"github.com/mobilarte/knx-exp/knx/cemi"
+)
+
+// A DedupConfig configures the suppression of duplicate incoming frames. Routers that repeat a
+// frame, and the multicast loopback, deliver several copies of the same telegram; only the first
+// one is passed on to Inbound.
+type DedupConfig struct {
+	// Window is the time for which a frame is remembered. Identical frames that arrive within this
+	// window are dropped. 0 disables the suppression.
+	Window time.Duration
+
+	// KeepOwn delivers the frames that the Router has sent itself when they come back. Otherwise
+	// they are dropped as well.
+	KeepOwn bool
+}
+
+// dedupKey identifies a telegram. The hop count is not part of it, because routers decrement it.
+type dedupKey struct {
+	source      cemi.IndividualAddr
+	destination uint16
+	group       bool
+	repeated    bool
+	apdu        string
+}
+
+// makeDedupKey creates the key of the frame. Messages other than L_Data have no key.
+func makeDedupKey(msg cemi.Message) (dedupKey, bool) {
+	var ldata *cemi.LData
+
+	switch msg := msg.(type) {
+	case *SystemBroadcast:
+		return makeDedupKey(msg.Message)
+	case *RoutedFrame:
+		return makeDedupKey(msg.Message)
+	case *cemi.LDataInd:
+		ldata = &msg.LData
+	case *cemi.LDataReq:
+		ldata = &msg.LData
+	default:
+		return dedupKey{}, false
+	}
+
+	var apdu []byte
+	if ldata.Data != nil {
+		apdu = make([]byte, ldata.Data.Size())
+		ldata.Data.Pack(apdu)
+	}
+
+	return dedupKey{
+		source:      ldata.Source,
+		destination: ldata.Destination,
+		group:       ldata.Control2.IsGroupAddr(),
+		repeated:    ldata.Control1&cemi.Control1NoRepeat == 0,
+		apdu:        string(apdu),
+	}, true
+}
+
+// dedupEntry records when a frame has been seen.
+type dedupEntry struct {
+	key  dedupKey
+	seen time.Time
+}
+
+// dedupFilter remembers the frames of the recent past.
+type dedupFilter struct {
+	config DedupConfig
+
+	mu      sync.Mutex
+	seen    map[dedupKey]time.Time
+	entries []dedupEntry
+}
+
+// newDedupFilter creates the filter, or returns nil if the suppression is disabled. A nil
+// *dedupFilter lets every frame pass.
+func newDedupFilter(config DedupConfig) *dedupFilter {
+	if config.Window <= 0 {
+		return nil
+	}
+
+	return &dedupFilter{config: config, seen: map[dedupKey]time.Time{}}
+}
+
+// expire forgets the frames that have left the window.
+func (filter *dedupFilter) expire(now time.Time) {
+	for len(filter.entries) > 0 && now.Sub(filter.entries[0].seen) >= filter.config.Window {
+		entry := filter.entries[0]
+		filter.entries = filter.entries[1:]
+
+		// The frame may have been seen again in the meantime.
+		if filter.seen[entry.key] == entry.seen {
+			delete(filter.seen, entry.key)
+		}
+	}
+}
+
+// remember records the frame.
+func (filter *dedupFilter) remember(key dedupKey, now time.Time) {
+	filter.seen[key] = now
+	filter.entries = append(filter.entries, dedupEntry{key, now})
+}
+
+// sent records a frame that the Router has sent, so that its echo is dropped.
+func (filter *dedupFilter) sent(msg cemi.Message, now time.Time) {
+	if filter == nil || filter.config.KeepOwn {
+		return
+	}
+
+	key, ok := makeDedupKey(msg)
+	if !ok {
+		return
+	}
+
+	filter.mu.Lock()
+	defer filter.mu.Unlock()
+
+	filter.expire(now)
+	filter.remember(key, now)
+}
+
+// duplicate determines whether an incoming frame has been seen within the window. A repeated frame
+// also counts as duplicate of its original.
+func (filter *dedupFilter) duplicate(msg cemi.Message, now time.Time) bool {
+	if filter == nil {
+		return false
+	}
+
+	key, ok := makeDedupKey(msg)
+	if !ok {
+		return false
+	}
+
+	filter.mu.Lock()
+	defer filter.mu.Unlock()
+
+	filter.expire(now)
+
+	_, dup := filter.seen[key]
+
+	if !dup && key.repeated {
+		original := key
+		original.repeated = false
+		_, dup = filter.seen[original]
+	}
+
+	filter.remember(key, now)
+
+	return dup
+}
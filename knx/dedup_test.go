@@ -0,0 +1,107 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knx
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mobilarte/knx-exp/knx/cemi"
+	"github.com/mobilarte/knx-exp/knx/knxnet"
+)
+
+func TestDedupFilter(t *testing.T) {
+	start := time.Now()
+	frame := func(control1 cemi.ControlField1, value byte) cemi.Message {
+		ldata := makeRoutedLData(control1)
+		ldata.Data = &cemi.AppData{Command: cemi.GroupValueWrite, Data: []byte{value}}
+
+		return &cemi.LDataInd{LData: ldata}
+	}
+
+	t.Run("Window", func(t *testing.T) {
+		filter := newDedupFilter(DedupConfig{Window: 100 * time.Millisecond})
+
+		steps := []struct {
+			msg cemi.Message
+			at  time.Duration
+			dup bool
+		}{
+			{frame(cemi.Control1NoRepeat, 1), 0, false},
+			{frame(cemi.Control1NoRepeat, 1), 10 * time.Millisecond, true},
+			{frame(cemi.Control1NoRepeat, 2), 20 * time.Millisecond, false},
+			{frame(0, 2), 30 * time.Millisecond, true},
+			{frame(0, 3), 40 * time.Millisecond, false},
+			{frame(cemi.Control1NoRepeat, 3), 50 * time.Millisecond, false},
+			{frame(cemi.Control1NoRepeat, 1), 200 * time.Millisecond, false},
+			{&cemi.UnsupportedMessage{}, 210 * time.Millisecond, false},
+			{&cemi.UnsupportedMessage{}, 220 * time.Millisecond, false},
+		}
+
+		for i, step := range steps {
+			if dup := filter.duplicate(step.msg, start.Add(step.at)); dup != step.dup {
+				t.Errorf("Step %d: expected duplicate %v, got %v", i, step.dup, dup)
+			}
+		}
+	})
+
+	t.Run("Own", func(t *testing.T) {
+		filter := newDedupFilter(DedupConfig{Window: 100 * time.Millisecond})
+		filter.sent(frame(cemi.Control1NoRepeat, 1), start)
+
+		if !filter.duplicate(frame(cemi.Control1NoRepeat, 1), start.Add(time.Millisecond)) {
+			t.Error("Own frame has not been dropped")
+		}
+	})
+
+	t.Run("KeepOwn", func(t *testing.T) {
+		filter := newDedupFilter(DedupConfig{Window: 100 * time.Millisecond, KeepOwn: true})
+		filter.sent(frame(cemi.Control1NoRepeat, 1), start)
+
+		if filter.duplicate(frame(cemi.Control1NoRepeat, 1), start.Add(time.Millisecond)) {
+			t.Error("Own frame has been dropped")
+		}
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		filter := newDedupFilter(DedupConfig{})
+
+		for range 2 {
+			if filter.duplicate(frame(cemi.Control1NoRepeat, 1), start) {
+				t.Error("Disabled filter dropped a frame")
+			}
+		}
+	})
+}
+
+func TestRouter_Dedup(t *testing.T) {
+	client, peer := newDummySockets()
+
+	router := makeRouter(client, RouterConfig{Dedup: DedupConfig{Window: time.Minute}})
+	t.Cleanup(func() { _ = router.Close() })
+
+	own := &cemi.LDataInd{LData: makeRoutedLData(cemi.Control1NoRepeat | cemi.Control1NoSysBroadcast)}
+	if err := router.Send(own); err != nil {
+		t.Fatal(err)
+	}
+
+	other := &cemi.LDataInd{LData: makeRoutedLData(cemi.Control1NoRepeat | cemi.Control1NoSysBroadcast)}
+	other.Source = 0x1102
+
+	// The echo of our own frame and the second copy of the other one are dropped.
+	for _, msg := range []cemi.Message{own, other, other} {
+		if err := peer.Send(&knxnet.RoutingInd{Payload: msg}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if msg := (<-router.Inbound()).(*cemi.LDataInd); msg.Source != other.Source {
+		t.Errorf("Unexpected source %v", msg.Source)
+	}
+
+	select {
+	case msg := <-router.Inbound():
+		t.Errorf("Unexpected %T", msg)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
@@ -4,7 +4,7 @@
 package knx
 
 import (
-	"log"
+	"context"
 	"time"
 
 	"github.com/mobilarte/knx-exp/knx/knxnet"
@@ -12,14 +12,21 @@ import (
 
 // DescribeTunnel describes a single KNXnet/IP server. Uses unicast UDP, address format is "ip:port".
 func DescribeTunnel(address string, searchTimeout time.Duration) (*knxnet.DescriptionRes, error) {
+	return DescribeTunnelContext(context.Background(), address, searchTimeout)
+}
+
+// DescribeTunnelContext is like DescribeTunnel, but gives up waiting for the description as soon
+// as the given context is done.
+func DescribeTunnelContext(ctx context.Context, address string,
+	searchTimeout time.Duration) (res *knxnet.DescriptionRes, err error) {
 	socket, err := knxnet.DialTunnelUDP(address)
 	if err != nil {
 		return nil, err
 	}
 	defer func() {
-		err := socket.Close()
-		if err != nil {
-			log.Fatal(err)
+		// Report a failure to close unless there is a more important error.
+		if closeErr := socket.Close(); closeErr != nil && err == nil {
+			err = closeErr
 		}
 	}()
 
@@ -42,6 +49,9 @@ func DescribeTunnel(address string, searchTimeout time.Duration) (*knxnet.Descri
 				return descriptionRes, nil
 			}
 
+		case <-ctx.Done():
+			return nil, ctx.Err()
+
 		case <-timeout:
 			return nil, nil
 		}
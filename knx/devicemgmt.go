@@ -0,0 +1,337 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mobilarte/knx-exp/knx/cemi"
+	"github.com/mobilarte/knx-exp/knx/knxnet"
+	"github.com/mobilarte/knx-exp/knx/util"
+)
+
+// friendlyNameLength is the fixed number of characters of PID_FRIENDLY_NAME.
+const friendlyNameLength = 30
+
+// A DeviceManagement is a device management connection to a KNXnet/IP interface. It reads and
+// writes the properties of the interface's own cEMI server using M_PropRead and M_PropWrite.
+type DeviceManagement struct {
+	tunnel *Tunnel
+
+	// Requests awaiting their confirmation
+	mu      sync.Mutex
+	waiters []*propertyWaiter
+}
+
+// propertyWaiter awaits the confirmation of a property service.
+type propertyWaiter struct {
+	code cemi.MessageCode
+	req  *cemi.PropertyData
+	con  chan *cemi.PropertyData
+}
+
+// NewDeviceManagement establishes a device management connection to a gateway. The
+// configuration is the same as for a Tunnel; settings specific to tunnelling are ignored.
+func NewDeviceManagement(gatewayAddr string, config TunnelConfig) (*DeviceManagement, error) {
+	return NewDeviceManagementContext(context.Background(), gatewayAddr, config)
+}
+
+// NewDeviceManagementContext is like NewDeviceManagement, but the connection request is aborted
+// as soon as the given context is done.
+func NewDeviceManagementContext(
+	ctx context.Context,
+	gatewayAddr string,
+	config TunnelConfig,
+) (*DeviceManagement, error) {
+	tunnel, err := newTunnel(ctx, gatewayAddr, knxnet.DeviceManagementConnection, 0, config)
+	if err != nil {
+		return nil, err
+	}
+
+	dm := &DeviceManagement{tunnel: tunnel}
+	go dm.serve()
+
+	return dm, nil
+}
+
+// Close terminates the connection.
+func (dm *DeviceManagement) Close() error {
+	return dm.tunnel.Close()
+}
+
+// State retrieves the channel which transmits changes of the connection state.
+func (dm *DeviceManagement) State() <-chan StateEvent {
+	return dm.tunnel.State()
+}
+
+// Errors retrieves the channel which transmits errors of the background goroutines.
+func (dm *DeviceManagement) Errors() <-chan error {
+	return dm.tunnel.Errors()
+}
+
+// ReadProperty reads count elements of a property, beginning at the element with the given start
+// index. Reading element 0 yields the current number of elements.
+func (dm *DeviceManagement) ReadProperty(
+	objType cemi.ObjectType,
+	instance uint8,
+	pid cemi.PropertyID,
+	start uint16,
+	count uint8,
+) ([]byte, error) {
+	return dm.ReadPropertyContext(context.Background(), objType, instance, pid, start, count)
+}
+
+// ReadPropertyContext is like ReadProperty, but gives up as soon as the given context is done.
+func (dm *DeviceManagement) ReadPropertyContext(
+	ctx context.Context,
+	objType cemi.ObjectType,
+	instance uint8,
+	pid cemi.PropertyID,
+	start uint16,
+	count uint8,
+) ([]byte, error) {
+	req := &cemi.MPropReadReq{PropertyData: cemi.PropertyData{
+		ObjectType:     objType,
+		ObjectInstance: instance,
+		PropertyID:     pid,
+		Count:          count,
+		StartIndex:     start,
+	}}
+
+	con, err := dm.requestProperty(ctx, req, &req.PropertyData, cemi.MPropReadConCode)
+	if err != nil {
+		return nil, err
+	}
+
+	return con.Data, nil
+}
+
+// WriteProperty writes count elements of a property, beginning at the element with the given
+// start index. The data must contain all elements.
+func (dm *DeviceManagement) WriteProperty(
+	objType cemi.ObjectType,
+	instance uint8,
+	pid cemi.PropertyID,
+	start uint16,
+	count uint8,
+	data []byte,
+) error {
+	return dm.WritePropertyContext(context.Background(), objType, instance, pid, start, count, data)
+}
+
+// WritePropertyContext is like WriteProperty, but gives up as soon as the given context is done.
+func (dm *DeviceManagement) WritePropertyContext(
+	ctx context.Context,
+	objType cemi.ObjectType,
+	instance uint8,
+	pid cemi.PropertyID,
+	start uint16,
+	count uint8,
+	data []byte,
+) error {
+	req := &cemi.MPropWriteReq{PropertyData: cemi.PropertyData{
+		ObjectType:     objType,
+		ObjectInstance: instance,
+		PropertyID:     pid,
+		Count:          count,
+		StartIndex:     start,
+		Data:           data,
+	}}
+
+	_, err := dm.requestProperty(ctx, req, &req.PropertyData, cemi.MPropWriteConCode)
+
+	return err
+}
+
+// IndividualAddr reads the individual address of the interface.
+func (dm *DeviceManagement) IndividualAddr() (cemi.IndividualAddr, error) {
+	data, err := dm.ReadProperty(cemi.KNXnetIPParameterObject, 1, cemi.PIDKNXIndividualAddress, 1, 1)
+	if err != nil {
+		return 0, err
+	}
+
+	var addr cemi.IndividualAddr
+
+	_, err = util.Unpack(data, (*uint16)(&addr))
+
+	return addr, err
+}
+
+// SetIndividualAddr changes the individual address of the interface.
+func (dm *DeviceManagement) SetIndividualAddr(addr cemi.IndividualAddr) error {
+	data := []byte{byte(addr >> 8), byte(addr)}
+
+	return dm.WriteProperty(cemi.KNXnetIPParameterObject, 1, cemi.PIDKNXIndividualAddress, 1, 1, data)
+}
+
+// ProgMode reads whether the interface is in programming mode.
+func (dm *DeviceManagement) ProgMode() (bool, error) {
+	data, err := dm.ReadProperty(cemi.DeviceObject, 1, cemi.PIDProgMode, 1, 1)
+	if err != nil {
+		return false, err
+	}
+
+	if len(data) < 1 {
+		return false, errors.New("programming mode property is empty")
+	}
+
+	return data[0]&1 != 0, nil
+}
+
+// SetProgMode switches the programming mode of the interface on or off.
+func (dm *DeviceManagement) SetProgMode(enabled bool) error {
+	var value byte
+	if enabled {
+		value = 1
+	}
+
+	return dm.WriteProperty(cemi.DeviceObject, 1, cemi.PIDProgMode, 1, 1, []byte{value})
+}
+
+// FriendlyName reads the friendly name of the interface.
+func (dm *DeviceManagement) FriendlyName() (string, error) {
+	name := make([]byte, 0, friendlyNameLength)
+
+	for start := 1; start <= friendlyNameLength; start += cemi.MaxPropertyCount {
+		count := min(cemi.MaxPropertyCount, friendlyNameLength+1-start)
+
+		data, err := dm.ReadProperty(
+			cemi.KNXnetIPParameterObject, 1, cemi.PIDFriendlyName, uint16(start), uint8(count),
+		)
+		if err != nil {
+			return "", err
+		}
+
+		name = append(name, data...)
+	}
+
+	// The name is padded with zeros.
+	for i, c := range name {
+		if c == 0 {
+			name = name[:i]
+			break
+		}
+	}
+
+	return string(name), nil
+}
+
+// SetFriendlyName changes the friendly name of the interface. It must not exceed 30 bytes in
+// ISO 8859-1 encoding.
+func (dm *DeviceManagement) SetFriendlyName(name string) error {
+	if len(name) > friendlyNameLength {
+		return fmt.Errorf("friendly name exceeds %d characters", friendlyNameLength)
+	}
+
+	padded := make([]byte, friendlyNameLength)
+	copy(padded, name)
+
+	for start := 1; start <= friendlyNameLength; start += cemi.MaxPropertyCount {
+		count := min(cemi.MaxPropertyCount, friendlyNameLength+1-start)
+
+		err := dm.WriteProperty(
+			cemi.KNXnetIPParameterObject, 1, cemi.PIDFriendlyName, uint16(start), uint8(count),
+			padded[start-1:start-1+count],
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// requestProperty sends the property service request and waits for its confirmation with the
+// given message code. A negative confirmation is returned as error.
+func (dm *DeviceManagement) requestProperty(
+	ctx context.Context,
+	req cemi.Message,
+	data *cemi.PropertyData,
+	code cemi.MessageCode,
+) (*cemi.PropertyData, error) {
+	if data.Count < 1 || data.Count > cemi.MaxPropertyCount {
+		return nil, fmt.Errorf("element count %d is out of range", data.Count)
+	}
+
+	// The waiter must be in place before sending, because the confirmation may arrive before the
+	// acknowledgement has been processed.
+	waiter := &propertyWaiter{code: code, req: data, con: make(chan *cemi.PropertyData, 1)}
+
+	dm.mu.Lock()
+	dm.waiters = append(dm.waiters, waiter)
+	dm.mu.Unlock()
+
+	defer dm.removeWaiter(waiter)
+
+	if err := dm.tunnel.SendContext(ctx, req); err != nil {
+		return nil, err
+	}
+
+	timeout := time.NewTimer(dm.tunnel.config.ResponseTimeout)
+	defer timeout.Stop()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+
+	case <-timeout.C:
+		return nil, ErrResponseTimeout
+
+	case con := <-waiter.con:
+		if err := con.Err(); err != nil {
+			return nil, err
+		}
+
+		return con, nil
+	}
+}
+
+// removeWaiter unregisters a request.
+func (dm *DeviceManagement) removeWaiter(waiter *propertyWaiter) {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+
+	for i, w := range dm.waiters {
+		if w == waiter {
+			dm.waiters = append(dm.waiters[:i], dm.waiters[i+1:]...)
+			return
+		}
+	}
+}
+
+// dispatch hands the confirmation to the oldest request that it answers.
+func (dm *DeviceManagement) dispatch(code cemi.MessageCode, con *cemi.PropertyData) {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+
+	for i, waiter := range dm.waiters {
+		if waiter.code == code && con.Answers(waiter.req) {
+			waiter.con <- con
+			dm.waiters = append(dm.waiters[:i], dm.waiters[i+1:]...)
+
+			return
+		}
+	}
+
+	util.Log(dm, "Dropped unexpected %v", code)
+}
+
+// serve relays the confirmations from the gateway to the waiting requests.
+func (dm *DeviceManagement) serve() {
+	for msg := range dm.tunnel.Inbound() {
+		switch msg := msg.(type) {
+		case *cemi.MPropReadCon:
+			dm.dispatch(msg.MessageCode(), &msg.PropertyData)
+
+		case *cemi.MPropWriteCon:
+			dm.dispatch(msg.MessageCode(), &msg.PropertyData)
+
+		default:
+			util.Log(dm, "Ignored management message %v", msg.MessageCode())
+		}
+	}
+}
@@ -0,0 +1,148 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knx
+
+import (
+	"errors"
+	"log"
+	"testing"
+
+	"github.com/mobilarte/knx-exp/knx/cemi"
+	"github.com/mobilarte/knx-exp/knx/knxnet"
+)
+
+func TestDeviceManagement_requestProperty(t *testing.T) {
+	const channel uint8 = 1
+
+	// The gateway acknowledges the request and answers with the given confirmation.
+	run := func(t *testing.T, answer func(req *cemi.PropertyData) cemi.Message, client func(*DeviceManagement)) {
+		clientSock, gateway := newDummySockets()
+		ack := make(chan *knxnet.TunnelRes)
+
+		conn := makeTunnelConn(clientSock, DefaultTunnelConfig, channel)
+		conn.connType = knxnet.DeviceManagementConnection
+		conn.ack = ack
+		startSendQueue(t, conn)
+
+		dm := &DeviceManagement{tunnel: conn}
+		go dm.serve()
+
+		// Stops dm.serve once both sides are done.
+		t.Cleanup(conn.inbound.close)
+
+		t.Run("Gateway", func(t *testing.T) {
+			t.Parallel()
+
+			defer func() {
+				err := gateway.Close()
+				if err != nil {
+					log.Fatal(err)
+				}
+			}()
+
+			msg := <-gateway.Inbound()
+
+			req, ok := msg.(*knxnet.DeviceConfigurationReq)
+			if !ok {
+				t.Fatalf("Unexpected type %T", msg)
+			}
+
+			ack <- &knxnet.TunnelRes{Channel: req.Channel, SeqNumber: req.SeqNumber, Status: 0}
+
+			var data *cemi.PropertyData
+
+			switch payload := req.Payload.(type) {
+			case *cemi.MPropReadReq:
+				data = &payload.PropertyData
+			case *cemi.MPropWriteReq:
+				data = &payload.PropertyData
+			default:
+				t.Fatalf("Unexpected payload %T", req.Payload)
+			}
+
+			var seqNumber uint8
+
+			err := conn.handleDeviceConfigurationReq(&knxnet.DeviceConfigurationReq{
+				Channel: channel,
+				Payload: answer(data),
+			}, &seqNumber)
+			if err != nil {
+				t.Error(err)
+			}
+
+			// The confirmation must be acknowledged.
+			msg = <-gateway.Inbound()
+			if res, ok := msg.(*knxnet.DeviceConfigurationAck); !ok || res.SeqNumber != 0 {
+				t.Errorf("Unexpected acknowledgement %+v", msg)
+			}
+		})
+
+		t.Run("Client", func(t *testing.T) {
+			t.Parallel()
+
+			defer func() {
+				err := clientSock.Close()
+				if err != nil {
+					log.Fatal(err)
+				}
+			}()
+
+			client(dm)
+		})
+	}
+
+	t.Run("ProgMode", func(t *testing.T) {
+		run(t, func(req *cemi.PropertyData) cemi.Message {
+			if req.ObjectType != cemi.DeviceObject || req.PropertyID != cemi.PIDProgMode {
+				t.Errorf("Unexpected request %+v", req)
+			}
+
+			con := &cemi.MPropReadCon{PropertyData: *req}
+			con.Data = []byte{1}
+
+			return con
+		}, func(dm *DeviceManagement) {
+			progMode, err := dm.ProgMode()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !progMode {
+				t.Error("Expected programming mode")
+			}
+		})
+	})
+
+	t.Run("IndividualAddr", func(t *testing.T) {
+		run(t, func(req *cemi.PropertyData) cemi.Message {
+			con := &cemi.MPropReadCon{PropertyData: *req}
+			con.Data = []byte{0x11, 0xfa}
+
+			return con
+		}, func(dm *DeviceManagement) {
+			addr, err := dm.IndividualAddr()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if expected := cemi.NewIndividualAddr3(1, 1, 250); addr != expected {
+				t.Errorf("Expected %v, got %v", expected, addr)
+			}
+		})
+	})
+
+	t.Run("WriteRejected", func(t *testing.T) {
+		run(t, func(req *cemi.PropertyData) cemi.Message {
+			con := &cemi.MPropWriteCon{PropertyData: *req}
+			con.Count = 0
+			con.Data = []byte{byte(cemi.PropErrReadOnly)}
+
+			return con
+		}, func(dm *DeviceManagement) {
+			err := dm.SetIndividualAddr(cemi.NewIndividualAddr3(1, 1, 250))
+			if !errors.Is(err, cemi.PropErrReadOnly) {
+				t.Fatalf("Expected %v, got %v", cemi.PropErrReadOnly, err)
+			}
+		})
+	})
+}
@@ -6,7 +6,7 @@
 package knx
 
 import (
-	"log"
+	"context"
 	"net"
 	"time"
 
@@ -29,14 +29,24 @@ func DiagnosticInProgMode(multicastDiscoveryAddress string,
 // interface is nil, the system-assigned multicast interface is used.
 func DiagnosticOnInterface(ifi *net.Interface, multicastDiscoveryAddress string,
 	macAddr net.HardwareAddr, progMode bool, searchTimeout time.Duration) ([]*knxnet.DiagnosticRes, error) {
+	return DiagnosticOnInterfaceContext(
+		context.Background(), ifi, multicastDiscoveryAddress, macAddr, progMode, searchTimeout,
+	)
+}
+
+// DiagnosticOnInterfaceContext is like DiagnosticOnInterface, but stops early when the given
+// context is done. The responses collected so far are then returned along with the context's error.
+func DiagnosticOnInterfaceContext(ctx context.Context, ifi *net.Interface, multicastDiscoveryAddress string,
+	macAddr net.HardwareAddr, progMode bool, searchTimeout time.Duration,
+) (results []*knxnet.DiagnosticRes, err error) {
 	socket, err := knxnet.ListenRouterOnInterface(ifi, multicastDiscoveryAddress, false)
 	if err != nil {
 		return nil, err
 	}
 	defer func() {
-		err := socket.Close()
-		if err != nil {
-			log.Fatal(err)
+		// Report a failure to close unless there is a more important error.
+		if closeErr := socket.Close(); closeErr != nil && err == nil {
+			err = closeErr
 		}
 	}()
 
@@ -51,10 +61,9 @@ func DiagnosticOnInterface(ifi *net.Interface, multicastDiscoveryAddress string,
 		return nil, err
 	}
 
-	results := []*knxnet.DiagnosticRes{}
+	results = []*knxnet.DiagnosticRes{}
 	timeout := time.After(searchTimeout)
 
-loop:
 	for {
 		select {
 		case msg := <-socket.Inbound():
@@ -65,10 +74,11 @@ loop:
 
 			results = append(results, diagnosticRes)
 
+		case <-ctx.Done():
+			return results, ctx.Err()
+
 		case <-timeout:
-			break loop
+			return results, nil
 		}
 	}
-
-	return results, nil
 }
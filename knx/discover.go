@@ -3,7 +3,7 @@
 package knx
 
 import (
-	"log"
+	"context"
 	"net"
 	"time"
 
@@ -12,21 +12,35 @@ import (
 
 // Discover all KNXnet/IP servers.
 func Discover(multicastDiscoveryAddress string, searchTimeout time.Duration) ([]*knxnet.SearchRes, error) {
-	return DiscoverOnInterface(nil, multicastDiscoveryAddress, searchTimeout)
+	return DiscoverOnInterfaceContext(context.Background(), nil, multicastDiscoveryAddress, searchTimeout)
+}
+
+// DiscoverContext discovers all KNXnet/IP servers. The search stops early when the given context
+// is done, in which case the responses collected so far are returned along with the context's error.
+func DiscoverContext(ctx context.Context, multicastDiscoveryAddress string,
+	searchTimeout time.Duration) ([]*knxnet.SearchRes, error) {
+	return DiscoverOnInterfaceContext(ctx, nil, multicastDiscoveryAddress, searchTimeout)
 }
 
 // DiscoverOnInterface discovers all KNXnet/IP servers on a specific interface. If the
 // interface is nil, the system-assigned multicast interface is used.
 func DiscoverOnInterface(ifi *net.Interface, multicastDiscoveryAddress string,
 	searchTimeout time.Duration) ([]*knxnet.SearchRes, error) {
+	return DiscoverOnInterfaceContext(context.Background(), ifi, multicastDiscoveryAddress, searchTimeout)
+}
+
+// DiscoverOnInterfaceContext is like DiscoverOnInterface, but stops early when the given context
+// is done. The responses collected so far are then returned along with the context's error.
+func DiscoverOnInterfaceContext(ctx context.Context, ifi *net.Interface, multicastDiscoveryAddress string,
+	searchTimeout time.Duration) (results []*knxnet.SearchRes, err error) {
 	socket, err := knxnet.ListenRouterOnInterface(ifi, multicastDiscoveryAddress, false)
 	if err != nil {
 		return nil, err
 	}
 	defer func() {
-		err := socket.Close()
-		if err != nil {
-			log.Fatal()
+		// Report a failure to close unless there is a more important error.
+		if closeErr := socket.Close(); closeErr != nil && err == nil {
+			err = closeErr
 		}
 	}()
 
@@ -39,10 +53,9 @@ func DiscoverOnInterface(ifi *net.Interface, multicastDiscoveryAddress string,
 		return nil, err
 	}
 
-	results := []*knxnet.SearchRes{}
+	results = []*knxnet.SearchRes{}
 	timeout := time.After(searchTimeout)
 
-loop:
 	for {
 		select {
 		case msg := <-socket.Inbound():
@@ -53,10 +66,11 @@ loop:
 
 			results = append(results, searchRes)
 
+		case <-ctx.Done():
+			return results, ctx.Err()
+
 		case <-timeout:
-			break loop
+			return results, nil
 		}
 	}
-
-	return results, nil
 }
@@ -0,0 +1,103 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knx
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/mobilarte/knx-exp/knx/util"
+)
+
+// ErrReconnectFailed is reported when a lost connection could not be re-established.
+var ErrReconnectFailed = errors.New("reconnect failed")
+
+// errorSource is implemented by sockets that report errors while receiving.
+type errorSource interface {
+	Errors() <-chan error
+}
+
+// asyncErrors delivers errors that occur in background goroutines to the client. Errors are
+// dropped when the client does not read them. A nil *asyncErrors discards everything.
+type asyncErrors struct {
+	mu     sync.Mutex
+	ch     chan error
+	closed bool
+}
+
+// newAsyncErrors creates the error channel.
+func newAsyncErrors() *asyncErrors {
+	return &asyncErrors{ch: make(chan error, eventBufferSize)}
+}
+
+// channel returns the channel from which the client reads.
+func (errs *asyncErrors) channel() <-chan error {
+	if errs == nil {
+		return nil
+	}
+
+	return errs.ch
+}
+
+// emit publishes the error without blocking.
+func (errs *asyncErrors) emit(owner any, err error) {
+	util.Log(owner, "%v", err)
+
+	if errs == nil {
+		return
+	}
+
+	errs.mu.Lock()
+	defer errs.mu.Unlock()
+
+	if errs.closed {
+		return
+	}
+
+	select {
+	case errs.ch <- err:
+
+	default:
+		util.Log(owner, "Dropped error: %v", err)
+	}
+}
+
+// forward publishes the errors that the socket reports, if it does so, until it closes its error
+// channel.
+func (errs *asyncErrors) forward(owner any, sock any) {
+	source, ok := sock.(errorSource)
+	if !ok {
+		return
+	}
+
+	go func() {
+		for err := range source.Errors() {
+			errs.emit(owner, err)
+		}
+	}()
+}
+
+// close closes the channel. Errors that are emitted afterwards are discarded.
+func (errs *asyncErrors) close() {
+	if errs == nil {
+		return
+	}
+
+	errs.mu.Lock()
+	defer errs.mu.Unlock()
+
+	if !errs.closed {
+		errs.closed = true
+		close(errs.ch)
+	}
+}
+
+// reportOverflow makes the inbound queue report discarded messages as asynchronous errors, unless
+// the client has its own callback.
+func (errs *asyncErrors) reportOverflow(owner any, config InboundConfig) InboundConfig {
+	if config.OnOverflow == nil {
+		config.OnOverflow = func(err error) { errs.emit(owner, err) }
+	}
+
+	return config
+}
@@ -0,0 +1,189 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knx
+
+import (
+	"context"
+	"errors"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/mobilarte/knx-exp/knx/knxnet"
+)
+
+type dummyErrorSource chan error
+
+func (source dummyErrorSource) Errors() <-chan error {
+	return source
+}
+
+func TestAsyncErrors(t *testing.T) {
+	t.Run("Forward", func(t *testing.T) {
+		errs := newAsyncErrors()
+		source := make(dummyErrorSource)
+		expected := errors.New("unpack failed")
+
+		errs.forward(t, source)
+		source <- expected
+
+		if err := <-errs.channel(); err != expected {
+			t.Errorf("Expected %v, got %v", expected, err)
+		}
+
+		close(source)
+		errs.close()
+		errs.close()
+
+		// Errors are discarded after closing.
+		errs.emit(t, expected)
+
+		if _, open := <-errs.channel(); open {
+			t.Error("Channel should be closed")
+		}
+	})
+
+	t.Run("Full", func(t *testing.T) {
+		errs := newAsyncErrors()
+
+		for range eventBufferSize + 1 {
+			errs.emit(t, ErrHeartbeatFailed)
+		}
+
+		if len(errs.channel()) != eventBufferSize {
+			t.Errorf("Expected %d buffered errors, got %d", eventBufferSize, len(errs.channel()))
+		}
+	})
+
+	t.Run("Nil", func(t *testing.T) {
+		var errs *asyncErrors
+
+		errs.emit(t, ErrHeartbeatFailed)
+		errs.close()
+
+		if errs.channel() != nil {
+			t.Error("Expected nil channel")
+		}
+	})
+}
+
+func TestTunnelConn_performHeartbeatError(t *testing.T) {
+	client, gateway := newDummySockets()
+
+	defer func() {
+		err := client.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}()
+
+	defer func() {
+		err := gateway.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}()
+
+	config := DefaultTunnelConfig
+	config.ResponseTimeout = 10 * time.Millisecond
+
+	conn := makeTunnelConn(client, config, 1)
+	conn.errors = newAsyncErrors()
+	conn.done = make(chan struct{})
+
+	heartbeat := make(chan knxnet.ErrCode)
+	timeout := make(chan struct{}, 1)
+
+	conn.performHeartbeat(context.Background(), heartbeat, timeout)
+
+	select {
+	case <-timeout:
+	default:
+		t.Error("Heartbeat failure has not been signalled")
+	}
+
+	select {
+	case err := <-conn.Errors():
+		if !errors.Is(err, ErrHeartbeatFailed) || !errors.Is(err, ErrResponseTimeout) {
+			t.Errorf("Unexpected error %v", err)
+		}
+
+	default:
+		t.Error("Heartbeat failure has not been reported")
+	}
+}
+
+func TestTunnelConn_performHeartbeatCanceled(t *testing.T) {
+	client, gateway := newDummySockets()
+	t.Cleanup(func() { _ = client.Close(); _ = gateway.Close() })
+
+	config := DefaultTunnelConfig
+	config.ResponseTimeout = 10 * time.Millisecond
+
+	conn := makeTunnelConn(client, config, 1)
+	conn.errors = newAsyncErrors()
+	conn.done = make(chan struct{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	closed := make(chan knxnet.ErrCode)
+	close(closed)
+
+	timeout := make(chan struct{}, 2)
+
+	conn.performHeartbeat(ctx, make(chan knxnet.ErrCode), timeout)
+	conn.performHeartbeat(context.Background(), closed, timeout)
+
+	if len(timeout) != 0 {
+		t.Error("Heartbeat failure has been signalled")
+	}
+
+	select {
+	case err := <-conn.Errors():
+		t.Errorf("Unexpected error %v", err)
+	default:
+	}
+}
+
+func TestTunnelConn_processStopsHeartbeat(t *testing.T) {
+	client, gateway := newDummySockets()
+	t.Cleanup(func() { _ = client.Close(); _ = gateway.Close() })
+
+	config := DefaultTunnelConfig
+	config.HeartbeatInterval = 10 * time.Millisecond
+	config.ResendInterval = time.Minute
+	config.ResponseTimeout = time.Minute
+
+	conn := makeTunnelConn(client, config, 1)
+	conn.errors = newAsyncErrors()
+	conn.done = make(chan struct{})
+
+	result := make(chan error, 1)
+
+	go func() { result <- conn.process() }()
+
+	// The gateway disconnects while the heartbeat waits for its answer.
+	if _, ok := (<-gateway.Inbound()).(*knxnet.ConnStateReq); !ok {
+		t.Fatal("Expected a connection state request")
+	}
+
+	if err := gateway.sendAny(&knxnet.DiscReq{Channel: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-result:
+		if !errors.Is(err, ErrDisconnected) {
+			t.Errorf("Unexpected error %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Processing has not stopped")
+	}
+
+	select {
+	case err := <-conn.Errors():
+		t.Errorf("Unexpected error %v", err)
+	default:
+	}
+}
@@ -0,0 +1,200 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knx
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/mobilarte/knx-exp/knx/knxnet"
+	"github.com/mobilarte/knx-exp/knx/util"
+)
+
+// A FeatureEvent notifies about a changed interface feature. It stems from a
+// TUNNELLING_FEATURE_INFO sent by the gateway.
+type FeatureEvent struct {
+	Feature knxnet.InterfaceFeature
+	Value   []byte
+}
+
+// featureWaiter is a sender that awaits the response to a feature get or set request.
+type featureWaiter struct {
+	feature knxnet.InterfaceFeature
+	res     chan *knxnet.TunnelFeatureRes
+}
+
+// FeatureEvents retrieves the channel which transmits feature info notifications. The channel is
+// buffered; events are dropped when it is full. The gateway only sends these notifications once
+// they have been enabled, see EnableFeatureInfo.
+func (conn *Tunnel) FeatureEvents() <-chan FeatureEvent {
+	return conn.featureInfo
+}
+
+// GetFeature reads the value of an interface feature. This requires a gateway that supports
+// Tunnelling v2.
+func (conn *Tunnel) GetFeature(feature knxnet.InterfaceFeature) ([]byte, error) {
+	return conn.GetFeatureContext(context.Background(), feature)
+}
+
+// GetFeatureContext is like GetFeature, but gives up as soon as the given context is done.
+func (conn *Tunnel) GetFeatureContext(ctx context.Context, feature knxnet.InterfaceFeature) ([]byte, error) {
+	res, err := conn.requestFeature(ctx, feature, func(channel, seqNumber uint8) knxnet.ServicePackable {
+		return &knxnet.TunnelFeatureGet{Channel: channel, SeqNumber: seqNumber, Feature: feature}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return res.Value, nil
+}
+
+// SetFeature changes the value of an interface feature. This requires a gateway that supports
+// Tunnelling v2.
+func (conn *Tunnel) SetFeature(feature knxnet.InterfaceFeature, value []byte) error {
+	return conn.SetFeatureContext(context.Background(), feature, value)
+}
+
+// SetFeatureContext is like SetFeature, but gives up as soon as the given context is done.
+func (conn *Tunnel) SetFeatureContext(ctx context.Context, feature knxnet.InterfaceFeature, value []byte) error {
+	_, err := conn.requestFeature(ctx, feature, func(channel, seqNumber uint8) knxnet.ServicePackable {
+		return &knxnet.TunnelFeatureSet{Channel: channel, SeqNumber: seqNumber, Feature: feature, Value: value}
+	})
+
+	return err
+}
+
+// MaxAPDULength reads the maximum APDU length supported by the interface.
+func (conn *Tunnel) MaxAPDULength() (uint16, error) {
+	return conn.getFeatureUint16(knxnet.FeatureMaxAPDULength)
+}
+
+// DeviceDescriptor reads the device descriptor type 0 (mask version) of the interface.
+func (conn *Tunnel) DeviceDescriptor() (uint16, error) {
+	return conn.getFeatureUint16(knxnet.FeatureDeviceDescriptor)
+}
+
+// BusConnected determines whether the interface is connected to the KNX bus.
+func (conn *Tunnel) BusConnected() (bool, error) {
+	value, err := conn.GetFeature(knxnet.FeatureBusConnectionStatus)
+	if err != nil {
+		return false, err
+	}
+
+	if len(value) < 1 {
+		return false, errFeatureValueLength
+	}
+
+	return value[0]&1 == 1, nil
+}
+
+// EnableFeatureInfo enables or disables the feature info notifications of the interface.
+func (conn *Tunnel) EnableFeatureInfo(enable bool) error {
+	var value uint8
+	if enable {
+		value = 1
+	}
+
+	return conn.SetFeature(knxnet.FeatureInfoServiceEnable, []byte{value})
+}
+
+var errFeatureValueLength = errors.New("feature value is too short")
+
+// getFeatureUint16 reads a 2-byte interface feature.
+func (conn *Tunnel) getFeatureUint16(feature knxnet.InterfaceFeature) (uint16, error) {
+	value, err := conn.GetFeature(feature)
+	if err != nil {
+		return 0, err
+	}
+
+	var result uint16
+	if _, err := util.Unpack(value, &result); err != nil {
+		return 0, errFeatureValueLength
+	}
+
+	return result, nil
+}
+
+// requestFeature sends a feature get or set request and waits for the matching response.
+func (conn *Tunnel) requestFeature(
+	ctx context.Context,
+	feature knxnet.InterfaceFeature,
+	build func(channel, seqNumber uint8) knxnet.ServicePackable,
+) (*knxnet.TunnelFeatureRes, error) {
+	// The waiter must be in place before sending, because the response may arrive before the
+	// acknowledgement has been processed.
+	waiter := &featureWaiter{feature: feature, res: make(chan *knxnet.TunnelFeatureRes, 1)}
+
+	conn.featureMu.Lock()
+	conn.featureWaiters = append(conn.featureWaiters, waiter)
+	conn.featureMu.Unlock()
+
+	defer conn.removeFeatureWaiter(waiter)
+
+	if err := conn.requestSequenced(ctx, build); err != nil {
+		return nil, err
+	}
+
+	timeout := time.NewTimer(conn.config.ResponseTimeout)
+	defer timeout.Stop()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+
+	case <-timeout.C:
+		return nil, ErrResponseTimeout
+
+	case res := <-waiter.res:
+		if !res.Status.IsSuccess() {
+			return res, res.Status
+		}
+
+		return res, nil
+	}
+}
+
+// removeFeatureWaiter unregisters a sender.
+func (conn *Tunnel) removeFeatureWaiter(waiter *featureWaiter) {
+	conn.featureMu.Lock()
+	defer conn.featureMu.Unlock()
+
+	for i, w := range conn.featureWaiters {
+		if w == waiter {
+			conn.featureWaiters = append(conn.featureWaiters[:i], conn.featureWaiters[i+1:]...)
+			return
+		}
+	}
+}
+
+// handleTunnelFeatureRes validates and acknowledges the response and hands it to the oldest
+// sender waiting for the feature.
+func (conn *Tunnel) handleTunnelFeatureRes(res *knxnet.TunnelFeatureRes, seqNumber *uint8) error {
+	return conn.handleSequenced(res.Channel, res.SeqNumber, seqNumber, func() {
+		conn.featureMu.Lock()
+		defer conn.featureMu.Unlock()
+
+		for i, waiter := range conn.featureWaiters {
+			if waiter.feature == res.Feature {
+				waiter.res <- res
+				conn.featureWaiters = append(conn.featureWaiters[:i], conn.featureWaiters[i+1:]...)
+
+				return
+			}
+		}
+
+		util.Log(conn, "Unsolicited feature response for %v", res.Feature)
+	})
+}
+
+// handleTunnelFeatureInfo validates and acknowledges the notification and publishes it.
+func (conn *Tunnel) handleTunnelFeatureInfo(info *knxnet.TunnelFeatureInfo, seqNumber *uint8) error {
+	return conn.handleSequenced(info.Channel, info.SeqNumber, seqNumber, func() {
+		select {
+		case conn.featureInfo <- FeatureEvent{Feature: info.Feature, Value: info.Value}:
+
+		default:
+			util.Log(conn, "Dropped feature info for %v", info.Feature)
+		}
+	})
+}
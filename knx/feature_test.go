@@ -0,0 +1,137 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knx
+
+import (
+	"errors"
+	"log"
+	"testing"
+
+	"github.com/mobilarte/knx-exp/knx/knxnet"
+)
+
+func TestTunnelConn_GetFeature(t *testing.T) {
+	run := func(t *testing.T, status knxnet.FeatureReturnCode, check func([]byte, error)) {
+		client, gateway := newDummySockets()
+		ack := make(chan *knxnet.TunnelRes)
+
+		const channel uint8 = 1
+
+		conn := makeTunnelConn(client, DefaultTunnelConfig, channel)
+		conn.ack = ack
+
+		t.Run("Gateway", func(t *testing.T) {
+			t.Parallel()
+
+			defer func() {
+				err := gateway.Close()
+				if err != nil {
+					log.Fatal(err)
+				}
+			}()
+
+			msg := <-gateway.Inbound()
+
+			req, ok := msg.(*knxnet.TunnelFeatureGet)
+			if !ok {
+				t.Fatalf("Unexpected type %T", msg)
+			}
+
+			if req.Channel != channel || req.Feature != knxnet.FeatureMaxAPDULength {
+				t.Errorf("Unexpected request %+v", req)
+			}
+
+			ack <- &knxnet.TunnelRes{Channel: req.Channel, SeqNumber: req.SeqNumber, Status: 0}
+
+			var seqNumber uint8
+
+			err := conn.handleTunnelFeatureRes(&knxnet.TunnelFeatureRes{
+				Channel: channel,
+				Feature: knxnet.FeatureMaxAPDULength,
+				Status:  status,
+				Value:   []byte{0, 254},
+			}, &seqNumber)
+			if err != nil {
+				t.Error(err)
+			}
+
+			// The response must be acknowledged.
+			msg = <-gateway.Inbound()
+			if res, ok := msg.(*knxnet.TunnelRes); !ok || res.SeqNumber != 0 {
+				t.Errorf("Expected acknowledgement, got %+v", msg)
+			}
+		})
+
+		t.Run("Client", func(t *testing.T) {
+			t.Parallel()
+
+			defer func() {
+				err := client.Close()
+				if err != nil {
+					log.Fatal(err)
+				}
+			}()
+
+			check(conn.GetFeature(knxnet.FeatureMaxAPDULength))
+		})
+	}
+
+	t.Run("Ok", func(t *testing.T) {
+		run(t, knxnet.FeatureSuccess, func(value []byte, err error) {
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(value) != 2 || value[1] != 254 {
+				t.Errorf("Unexpected value %v", value)
+			}
+		})
+	})
+
+	t.Run("Denied", func(t *testing.T) {
+		run(t, knxnet.FeatureAccessDenied, func(_ []byte, err error) {
+			if !errors.Is(err, knxnet.FeatureAccessDenied) {
+				t.Fatalf("Expected %v, got %v", knxnet.FeatureAccessDenied, err)
+			}
+		})
+	})
+}
+
+func TestTunnelConn_handleTunnelFeatureInfo(t *testing.T) {
+	client, gateway := newDummySockets()
+	defer func() {
+		err := client.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}()
+	defer func() {
+		err := gateway.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}()
+
+	conn := makeTunnelConn(client, DefaultTunnelConfig, 1)
+	conn.featureInfo = make(chan FeatureEvent, eventBufferSize)
+
+	var seqNumber uint8
+
+	err := conn.handleTunnelFeatureInfo(&knxnet.TunnelFeatureInfo{
+		Channel: 1,
+		Feature: knxnet.FeatureBusConnectionStatus,
+		Value:   []byte{0},
+	}, &seqNumber)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	event := <-conn.FeatureEvents()
+	if event.Feature != knxnet.FeatureBusConnectionStatus || len(event.Value) != 1 {
+		t.Errorf("Unexpected event %+v", event)
+	}
+
+	if seqNumber != 1 {
+		t.Error("Sequence number has not been increased")
+	}
+}
@@ -48,31 +48,43 @@ type GroupClient interface {
 	Inbound() <-chan GroupEvent
 }
 
+// GroupEventOf extracts the group communication from an inbound message. It reports false if the
+// message is not an L_Data.ind which carries a group command to a group address. Routed frames
+// are unwrapped.
+func GroupEventOf(msg cemi.Message) (GroupEvent, bool) {
+	// The interface on which the frame has been received does not matter here.
+	if frame, ok := msg.(*RoutedFrame); ok {
+		msg = frame.Message
+	}
+
+	ind, ok := msg.(*cemi.LDataInd)
+	if !ok || !ind.Control2.IsGroupAddr() {
+		return GroupEvent{}, false
+	}
+
+	app, ok := ind.Data.(*cemi.AppData)
+	if !ok || !app.Command.IsGroupCommand() {
+		return GroupEvent{}, false
+	}
+
+	return GroupEvent{
+		Command:     GroupCommand(app.Command),
+		Source:      ind.Source,
+		Destination: cemi.GroupAddr(ind.Destination),
+		Data:        app.Data,
+	}, true
+}
+
 // serveGroupInbound serves a group communication.
 func serveGroupInbound(inbound <-chan cemi.Message, outbound chan<- GroupEvent) {
 	util.Log(inbound, "Started worker")
 	defer util.Log(inbound, "Worker exited")
 
 	for msg := range inbound {
-		if ind, ok := msg.(*cemi.LDataInd); ok {
-			// Filter indications that do not target group addresses.
-			if !ind.Control2.IsGroupAddr() {
-				util.Log(inbound, "Received L_Data.ind does not target a group address")
-				continue
-			}
-
-			if app, ok := ind.Data.(*cemi.AppData); ok && app.Command.IsGroupCommand() {
-				outbound <- GroupEvent{
-					Command:     GroupCommand(app.Command),
-					Source:      ind.Source,
-					Destination: cemi.GroupAddr(ind.Destination),
-					Data:        app.Data,
-				}
-			} else {
-				util.Log(inbound, "Received L_Data.ind frame does not contain application data")
-			}
+		if event, ok := GroupEventOf(msg); ok {
+			outbound <- event
 		} else {
-			util.Log(inbound, "Received frame is not a L_Data.ind frame")
+			util.Log(inbound, "Received frame is not a group communication")
 		}
 	}
 
@@ -84,8 +96,9 @@ var defaultGroupLData = cemi.LData{
 	Control2: cemi.Control2GroupAddr | cemi.Control2Hops(6),
 }
 
-// buildGroupOutbound constructs the L_Data core frame for group communication.
-func buildGroupOutbound(event GroupEvent) cemi.LData {
+// GroupEventFrame constructs the L_Data core frame which carries the group communication. Frames
+// with up to 15 octets of data are marked as standard frames.
+func GroupEventFrame(event GroupEvent) cemi.LData {
 	ldata := defaultGroupLData
 	ldata.Data = &cemi.AppData{
 		Command: cemi.APCI(event.Command),
@@ -0,0 +1,46 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knx
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/mobilarte/knx-exp/knx/cemi"
+)
+
+func TestGroupEventOf(t *testing.T) {
+	ldata := makeRoutedLData(cemi.Control1NoSysBroadcast)
+
+	individual := ldata
+	individual.Control2 &^= cemi.Control2GroupAddr
+
+	control := ldata
+	control.Data = &cemi.ControlData{}
+
+	tests := []struct {
+		name string
+		msg  cemi.Message
+		ok   bool
+	}{
+		{"Indication", &cemi.LDataInd{LData: ldata}, true},
+		{"Routed", &RoutedFrame{Message: &cemi.LDataInd{LData: ldata}}, true},
+		{"Request", &cemi.LDataReq{LData: ldata}, false},
+		{"Individual", &cemi.LDataInd{LData: individual}, false},
+		{"Control", &cemi.LDataInd{LData: control}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			event, ok := GroupEventOf(test.msg)
+			if ok != test.ok {
+				t.Fatalf("Unexpected result %v", ok)
+			}
+
+			if ok && (event.Command != GroupWrite || event.Source != 0x1101 || event.Destination != 0x0901 ||
+				!bytes.Equal(event.Data, []byte{1})) {
+				t.Errorf("Unexpected event %+v", event)
+			}
+		})
+	}
+}
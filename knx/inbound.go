@@ -0,0 +1,251 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knx
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/mobilarte/knx-exp/knx/cemi"
+	"github.com/mobilarte/knx-exp/knx/util"
+)
+
+// OverflowPolicy determines what happens to an incoming message when the inbound queue is full.
+type OverflowPolicy uint8
+
+const (
+	// OverflowBlock stalls the receiving worker until the client has made room. Nothing is lost,
+	// but a client that does not keep up also delays the handling of acknowledgements and
+	// heartbeats, which may eventually cost the connection.
+	OverflowBlock OverflowPolicy = iota
+
+	// OverflowDropOldest discards the oldest queued message to make room for the new one.
+	OverflowDropOldest
+
+	// OverflowDropNewest discards the new message.
+	OverflowDropNewest
+
+	// OverflowError discards the new message and reports an *InboundOverflowError to
+	// InboundConfig.OnOverflow.
+	OverflowError
+)
+
+// String describes the overflow policy.
+func (policy OverflowPolicy) String() string {
+	switch policy {
+	case OverflowBlock:
+		return "block"
+	case OverflowDropOldest:
+		return "drop-oldest"
+	case OverflowDropNewest:
+		return "drop-newest"
+	case OverflowError:
+		return "error"
+	default:
+		return fmt.Sprintf("OverflowPolicy(%d)", uint8(policy))
+	}
+}
+
+// InboundConfig configures the queue which holds incoming messages until the client reads them
+// from the Inbound channel. Messages are always delivered in the order of their arrival.
+type InboundConfig struct {
+	// QueueSize is the maximum number of messages that wait for the client.
+	QueueSize int
+
+	// Overflow determines what happens when the queue is full.
+	Overflow OverflowPolicy
+
+	// OnOverflow is called with an *InboundOverflowError for each message that is discarded under
+	// the OverflowError policy. It is called from the receiving worker and must not block.
+	OnOverflow func(err error)
+}
+
+// DefaultInboundConfig is a good default configuration for the inbound queue.
+var DefaultInboundConfig = InboundConfig{
+	QueueSize: 1024,
+	Overflow:  OverflowBlock,
+}
+
+// checkInboundConfig makes sure that the configuration is actually usable.
+func checkInboundConfig(config InboundConfig) InboundConfig {
+	if config.QueueSize <= 0 {
+		config.QueueSize = DefaultInboundConfig.QueueSize
+	}
+
+	return config
+}
+
+// InboundOverflowError reports a message that has been discarded because the inbound queue was
+// full.
+type InboundOverflowError struct {
+	Message cemi.Message
+
+	// Dropped is the total number of messages that have been discarded so far.
+	Dropped uint64
+}
+
+// Error implements the error interface.
+func (err *InboundOverflowError) Error() string {
+	return fmt.Sprintf("inbound queue is full, discarded %v (%d in total)", err.Message.MessageCode(), err.Dropped)
+}
+
+// inboundQueue is a bounded FIFO queue between the receiving worker and the client. A single
+// goroutine forwards the queued messages to the outbound channel, which preserves their order.
+type inboundQueue struct {
+	config InboundConfig
+	out    chan cemi.Message
+
+	// done is closed when the queue is abandoned.
+	done        chan struct{}
+	abandonOnce sync.Once
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	items   []cemi.Message
+	closed  bool
+	dropped uint64
+}
+
+// newInboundQueue creates the queue and starts forwarding.
+func newInboundQueue(config InboundConfig) *inboundQueue {
+	queue := &inboundQueue{
+		config: checkInboundConfig(config),
+		out:    make(chan cemi.Message),
+		done:   make(chan struct{}),
+	}
+	queue.cond = sync.NewCond(&queue.mu)
+
+	go queue.serve()
+
+	return queue
+}
+
+// push appends the message to the queue, applying the overflow policy if the queue is full.
+func (queue *inboundQueue) push(msg cemi.Message) {
+	queue.mu.Lock()
+
+	if queue.config.Overflow == OverflowBlock {
+		for !queue.closed && len(queue.items) >= queue.config.QueueSize {
+			queue.cond.Wait()
+		}
+	}
+
+	if queue.closed {
+		queue.mu.Unlock()
+		return
+	}
+
+	var overflow *InboundOverflowError
+
+	if len(queue.items) >= queue.config.QueueSize {
+		queue.dropped++
+
+		switch queue.config.Overflow {
+		case OverflowDropOldest:
+			queue.items[0] = nil
+			queue.items = append(queue.items[1:], msg)
+
+		case OverflowError:
+			overflow = &InboundOverflowError{Message: msg, Dropped: queue.dropped}
+		}
+	} else {
+		queue.items = append(queue.items, msg)
+		queue.cond.Broadcast()
+	}
+
+	queue.mu.Unlock()
+
+	if overflow != nil {
+		if queue.config.OnOverflow != nil {
+			queue.config.OnOverflow(overflow)
+		} else {
+			util.Log(queue, "%v", overflow)
+		}
+	}
+}
+
+// len returns the number of messages that wait for the client.
+func (queue *inboundQueue) len() int {
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+
+	return len(queue.items)
+}
+
+// droppedCount returns the number of messages that have been discarded.
+func (queue *inboundQueue) droppedCount() uint64 {
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+
+	return queue.dropped
+}
+
+// close stops accepting messages. The messages that are already queued are still delivered
+// before the outbound channel is closed. It is safe to call close more than once.
+func (queue *inboundQueue) close() {
+	queue.mu.Lock()
+	queue.closed = true
+	queue.cond.Broadcast()
+	queue.mu.Unlock()
+}
+
+// abandon closes the queue like close, but the forwarder does not wait for the client to take the
+// queued messages: only a client which is already waiting still receives them, the others are
+// discarded. Owners call it when they are closed, because their client may have stopped reading.
+// It is safe to call abandon more than once.
+func (queue *inboundQueue) abandon() {
+	queue.abandonOnce.Do(func() { close(queue.done) })
+	queue.close()
+}
+
+// deliver hands the message to the client. Once the queue has been abandoned, it only succeeds if
+// the client is waiting for a message.
+func (queue *inboundQueue) deliver(msg cemi.Message) bool {
+	select {
+	case queue.out <- msg:
+		return true
+	case <-queue.done:
+	}
+
+	select {
+	case queue.out <- msg:
+		return true
+	default:
+		return false
+	}
+}
+
+// serve forwards the queued messages to the outbound channel.
+func (queue *inboundQueue) serve() {
+	defer close(queue.out)
+
+	for {
+		queue.mu.Lock()
+
+		for !queue.closed && len(queue.items) == 0 {
+			queue.cond.Wait()
+		}
+
+		if len(queue.items) == 0 {
+			queue.mu.Unlock()
+			return
+		}
+
+		msg := queue.items[0]
+		queue.items[0] = nil
+		queue.items = queue.items[1:]
+
+		// Wake a blocked push, there is room now.
+		queue.cond.Broadcast()
+		queue.mu.Unlock()
+
+		if !queue.deliver(msg) {
+			queue.mu.Lock()
+			clear(queue.items)
+			queue.items = nil
+			queue.mu.Unlock()
+
+			return
+		}
+	}
+}
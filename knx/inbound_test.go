@@ -0,0 +1,218 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knx
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mobilarte/knx-exp/knx/cemi"
+)
+
+func makeInboundMessages(n int) []cemi.Message {
+	messages := make([]cemi.Message, n)
+	for i := range messages {
+		messages[i] = &cemi.UnsupportedMessage{Code: cemi.MessageCode(i)}
+	}
+
+	return messages
+}
+
+// drainInbound closes the queue and collects all messages that are still delivered.
+func drainInbound(queue *inboundQueue) []cemi.Message {
+	queue.close()
+
+	var messages []cemi.Message
+	for msg := range queue.out {
+		messages = append(messages, msg)
+	}
+
+	return messages
+}
+
+func checkInboundCodes(t *testing.T, messages []cemi.Message, codes ...int) {
+	t.Helper()
+
+	if len(messages) != len(codes) {
+		t.Fatalf("Expected %d messages, got %d", len(codes), len(messages))
+	}
+
+	for i, msg := range messages {
+		if msg.MessageCode() != cemi.MessageCode(codes[i]) {
+			t.Errorf("Message %d: expected code %d, got %v", i, codes[i], msg.MessageCode())
+		}
+	}
+}
+
+func TestInboundQueue_Order(t *testing.T) {
+	queue := newInboundQueue(InboundConfig{QueueSize: 8})
+	messages := makeInboundMessages(100)
+
+	go func() {
+		for _, msg := range messages {
+			queue.push(msg)
+		}
+
+		queue.close()
+	}()
+
+	var received []cemi.Message
+	for msg := range queue.out {
+		received = append(received, msg)
+	}
+
+	for i, msg := range received {
+		if msg != messages[i] {
+			t.Fatalf("Message %d is out of order", i)
+		}
+	}
+
+	if len(received) != len(messages) || queue.droppedCount() != 0 {
+		t.Errorf("Received %d of %d, dropped %d", len(received), len(messages), queue.droppedCount())
+	}
+}
+
+func TestInboundQueue_Overflow(t *testing.T) {
+	// The forwarder holds one message while the queue is full, because nobody is reading.
+	fill := func(config InboundConfig) *inboundQueue {
+		queue := newInboundQueue(config)
+
+		for i, msg := range makeInboundMessages(5) {
+			queue.push(msg)
+
+			// Let the forwarder pick up the first message.
+			for i == 0 && queue.len() > 0 {
+				time.Sleep(time.Millisecond)
+			}
+		}
+
+		return queue
+	}
+
+	t.Run("DropOldest", func(t *testing.T) {
+		queue := fill(InboundConfig{QueueSize: 2, Overflow: OverflowDropOldest})
+
+		if queue.droppedCount() != 2 {
+			t.Errorf("Expected 2 dropped messages, got %d", queue.droppedCount())
+		}
+
+		checkInboundCodes(t, drainInbound(queue), 0, 3, 4)
+	})
+
+	t.Run("DropNewest", func(t *testing.T) {
+		queue := fill(InboundConfig{QueueSize: 2, Overflow: OverflowDropNewest})
+
+		if queue.droppedCount() != 2 {
+			t.Errorf("Expected 2 dropped messages, got %d", queue.droppedCount())
+		}
+
+		checkInboundCodes(t, drainInbound(queue), 0, 1, 2)
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		var errs []error
+
+		queue := fill(InboundConfig{
+			QueueSize:  2,
+			Overflow:   OverflowError,
+			OnOverflow: func(err error) { errs = append(errs, err) },
+		})
+
+		if len(errs) != 2 {
+			t.Fatalf("Expected 2 errors, got %d", len(errs))
+		}
+
+		var overflow *InboundOverflowError
+		if !errors.As(errs[1], &overflow) || overflow.Dropped != 2 || overflow.Message.MessageCode() != 4 {
+			t.Errorf("Unexpected error %v", errs[1])
+		}
+
+		checkInboundCodes(t, drainInbound(queue), 0, 1, 2)
+	})
+
+	t.Run("Block", func(t *testing.T) {
+		queue := newInboundQueue(InboundConfig{QueueSize: 1, Overflow: OverflowBlock})
+		done := make(chan struct{})
+
+		go func() {
+			defer close(done)
+
+			for _, msg := range makeInboundMessages(3) {
+				queue.push(msg)
+			}
+		}()
+
+		select {
+		case <-done:
+			t.Fatal("Push should block while the queue is full")
+		case <-time.After(10 * time.Millisecond):
+		}
+
+		// Reading makes room.
+		if msg := <-queue.out; msg.MessageCode() != 0 {
+			t.Errorf("Unexpected message %v", msg.MessageCode())
+		}
+
+		<-done
+
+		checkInboundCodes(t, drainInbound(queue), 1, 2)
+	})
+
+	t.Run("BlockClose", func(t *testing.T) {
+		queue := newInboundQueue(InboundConfig{QueueSize: 1, Overflow: OverflowBlock})
+		done := make(chan struct{})
+
+		go func() {
+			defer close(done)
+
+			for _, msg := range makeInboundMessages(3) {
+				queue.push(msg)
+			}
+		}()
+
+		time.Sleep(10 * time.Millisecond)
+
+		// Closing releases the blocked push, which discards its message.
+		queue.close()
+		<-done
+
+		checkInboundCodes(t, drainInbound(queue), 0, 1)
+	})
+}
+
+func TestInboundQueue_Abandon(t *testing.T) {
+	queue := newInboundQueue(InboundConfig{QueueSize: 8})
+
+	for i, msg := range makeInboundMessages(3) {
+		queue.push(msg)
+
+		// Let the forwarder pick up the first message.
+		for i == 0 && queue.len() > 0 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	// Nobody reads, yet the forwarder must give up the messages and exit.
+	queue.abandon()
+
+	deadline := time.Now().Add(time.Second)
+	for queue.len() > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Queued messages have not been discarded")
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+
+	select {
+	case msg, open := <-queue.out:
+		if open {
+			t.Errorf("Unexpected %v", msg)
+		}
+	case <-time.After(time.Second):
+		t.Error("Outbound channel has not been closed")
+	}
+
+	queue.abandon()
+}
@@ -6,6 +6,7 @@ package knxnet
 import (
 	"errors"
 
+	"github.com/mobilarte/knx-exp/knx/cemi"
 	"github.com/mobilarte/knx-exp/knx/util"
 )
 
@@ -23,11 +24,33 @@ const (
 	TunnelLayerBusmon TunnelLayer = 0x80
 )
 
+// ConnectionType identifies the type of connection that is requested by a ConnReq.
+type ConnectionType uint8
+
+const (
+	// DeviceManagementConnection establishes a device management connection, which gives access to
+	// the properties of the gateway's cEMI server.
+	DeviceManagementConnection ConnectionType = 0x03
+
+	// TunnelConnection establishes a tunnelling connection.
+	TunnelConnection ConnectionType = 0x04
+)
+
 // A ConnReq requests a connection to a gateway.
 type ConnReq struct {
 	Control HostInfo
 	Tunnel  HostInfo
-	Layer   TunnelLayer
+
+	// ConnType is the type of the requested connection. A zero value is packed as
+	// TunnelConnection.
+	ConnType ConnectionType
+
+	// Layer is the tunnelling layer. It is only relevant for tunnelling connections.
+	Layer TunnelLayer
+
+	// IndividualAddr requests a specific individual address for the tunnel using the extended
+	// connection request information of Tunnelling v2. Zero lets the gateway choose.
+	IndividualAddr cemi.IndividualAddr
 }
 
 // Service returns the service identifier for connection requests.
@@ -38,8 +61,8 @@ func (ConnReq) Service() ServiceID {
 var hostInfoSize = HostInfo{}.Size()
 
 // Size returns the packed size.
-func (ConnReq) Size() uint {
-	return 2*hostInfoSize + 4
+func (req *ConnReq) Size() uint {
+	return 2*hostInfoSize + req.criSize()
 }
 
 // Pack assembles the service payload in the given buffer.
@@ -47,39 +70,151 @@ func (req *ConnReq) Pack(buffer []byte) {
 	util.PackSome(buffer, &req.Control, &req.Tunnel)
 
 	buffer = buffer[2*hostInfoSize:]
-	buffer[0] = 4
-	buffer[1] = 4
+	buffer[0] = byte(req.criSize())
+	buffer[1] = byte(req.connType())
+
+	if req.connType() == DeviceManagementConnection {
+		return
+	}
+
 	buffer[2] = byte(req.Layer)
 	buffer[3] = 0
+
+	if req.IndividualAddr != 0 {
+		util.Pack(buffer[4:], uint16(req.IndividualAddr))
+	}
 }
 
 // Unpack parses the given service payload in order to initialize the structure.
 func (req *ConnReq) Unpack(data []byte) (n uint, err error) {
-	var length, connType, reserved uint8
+	var length, reserved uint8
 
-	n, err = util.UnpackSome(
-		data, &req.Control, &req.Tunnel, &length, &connType, (*uint8)(&req.Layer), &reserved,
-	)
+	n, err = util.UnpackSome(data, &req.Control, &req.Tunnel, &length, (*uint8)(&req.ConnType))
 	if err != nil {
 		return
 	}
 
-	if length != 4 {
-		return n, errors.New("invalid connection request info structure length")
-	}
+	req.Layer = 0
+	req.IndividualAddr = 0
+
+	switch req.ConnType {
+	case DeviceManagementConnection:
+		if length != 2 {
+			return n, errors.New("invalid connection request info structure length")
+		}
 
-	if connType != 4 {
+		return
+
+	case TunnelConnection:
+		if length != 4 && length != 6 {
+			return n, errors.New("invalid connection request info structure length")
+		}
+
+	default:
 		return n, errors.New("invalid connection type")
 	}
 
+	var m uint
+
+	m, err = util.UnpackSome(data[n:], (*uint8)(&req.Layer), &reserved)
+	n += m
+
+	if err == nil && length == 6 {
+		m, err = util.Unpack(data[n:], (*uint16)(&req.IndividualAddr))
+		n += m
+	}
+
 	return
 }
 
+// connType returns the type of the requested connection.
+func (req *ConnReq) connType() ConnectionType {
+	if req.ConnType == 0 {
+		return TunnelConnection
+	}
+
+	return req.ConnType
+}
+
+// criSize returns the size of the connection request information. A tunnelling connection
+// request is extended by the individual address if one has been requested.
+func (req *ConnReq) criSize() uint {
+	if req.connType() == DeviceManagementConnection {
+		return 2
+	}
+
+	if req.IndividualAddr != 0 {
+		return 6
+	}
+
+	return 4
+}
+
+// ConnResData is the connection response data block (CRD) of a successful ConnRes.
+type ConnResData struct {
+	// ConnType is the type of the established connection. A zero value is packed as
+	// TunnelConnection.
+	ConnType ConnectionType
+
+	// IndividualAddr is the individual address that the gateway assigned to the tunnel. Device
+	// management connections have none.
+	IndividualAddr cemi.IndividualAddr
+}
+
+// Size returns the packed size.
+func (crd *ConnResData) Size() uint {
+	if crd.ConnType == DeviceManagementConnection {
+		return 2
+	}
+
+	return 4
+}
+
+// Pack assembles the connection response data in the given buffer.
+func (crd *ConnResData) Pack(buffer []byte) {
+	connType := crd.ConnType
+	if connType == 0 {
+		connType = TunnelConnection
+	}
+
+	if connType == DeviceManagementConnection {
+		util.PackSome(buffer, uint8(crd.Size()), uint8(connType))
+	} else {
+		util.PackSome(buffer, uint8(crd.Size()), uint8(connType), uint16(crd.IndividualAddr))
+	}
+}
+
+// Unpack parses the given data in order to initialize the structure.
+func (crd *ConnResData) Unpack(data []byte) (n uint, err error) {
+	var length uint8
+
+	if n, err = util.UnpackSome(data, &length, (*uint8)(&crd.ConnType)); err != nil {
+		return
+	}
+
+	if length < 2 || uint(len(data)) < uint(length) {
+		return n, errors.New("invalid connection response data structure length")
+	}
+
+	crd.IndividualAddr = 0
+
+	if length >= 4 {
+		var m uint
+
+		m, err = util.Unpack(data[n:], (*uint16)(&crd.IndividualAddr))
+		n += m
+	}
+
+	// Skip whatever follows, we don't know about it.
+	return uint(length), err
+}
+
 // ConnRes is a response to a connection request.
 type ConnRes struct {
 	Channel uint8
 	Status  ErrCode
 	Control HostInfo
+	Data    ConnResData
 }
 
 // Service returns the service identifier for connection responses.
@@ -90,7 +225,7 @@ func (ConnRes) Service() ServiceID {
 // Size returns the packed size.
 func (res *ConnRes) Size() uint {
 	if res.Status == 0 {
-		return hostInfoSize + 6
+		return hostInfoSize + 2 + res.Data.Size()
 	}
 
 	return 2
@@ -99,7 +234,7 @@ func (res *ConnRes) Size() uint {
 // Pack assembles the service payload in the given buffer.
 func (res *ConnRes) Pack(buffer []byte) {
 	if res.Status == 0 {
-		util.PackSome(buffer, res.Channel, uint8(0), &res.Control, []byte{4, 4, 0, 0})
+		util.PackSome(buffer, res.Channel, uint8(0), &res.Control, &res.Data)
 	} else {
 		util.PackSome(buffer, res.Channel, uint8(res.Status))
 	}
@@ -109,11 +244,17 @@ func (res *ConnRes) Pack(buffer []byte) {
 func (res *ConnRes) Unpack(data []byte) (n uint, err error) {
 	n, err = util.UnpackSome(data, &res.Channel, (*uint8)(&res.Status))
 
-	if res.Status == 0 {
+	if err == nil && res.Status == 0 {
 		var m uint
 
-		m, err = res.Control.Unpack(data[2:])
+		m, err = res.Control.Unpack(data[n:])
 		n += m
+
+		// Tolerate gateways that omit the connection response data.
+		if err == nil && n < uint(len(data)) {
+			m, err = res.Data.Unpack(data[n:])
+			n += m
+		}
 	}
 
 	return
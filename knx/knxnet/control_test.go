@@ -0,0 +1,121 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knxnet
+
+import (
+	"testing"
+
+	"github.com/mobilarte/knx-exp/knx/cemi"
+)
+
+func TestConnReq_PackUnpack(t *testing.T) {
+	hostInfo := HostInfo{Protocol: TCP4}
+
+	for _, addr := range []cemi.IndividualAddr{0, cemi.NewIndividualAddr3(1, 1, 250)} {
+		req := &ConnReq{
+			Control:        hostInfo,
+			Tunnel:         hostInfo,
+			ConnType:       TunnelConnection,
+			Layer:          TunnelLayerData,
+			IndividualAddr: addr,
+		}
+
+		data := AllocAndPack(req)
+
+		criLength := data[6+2*hostInfoSize]
+		if (addr == 0 && criLength != 4) || (addr != 0 && criLength != 6) {
+			t.Errorf("Unexpected CRI length %d for address %v", criLength, addr)
+		}
+
+		var srv Service
+
+		n, err := Unpack(data, &srv)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if n != uint(len(data)) {
+			t.Errorf("Unexpected length: %d != %d", n, len(data))
+		}
+
+		result, ok := srv.(*ConnReq)
+		if !ok {
+			t.Fatalf("Unexpected type %T", srv)
+		}
+
+		if *result != *req {
+			t.Errorf("Unexpected result: %+v != %+v", result, req)
+		}
+	}
+}
+
+func TestConnReq_DeviceManagement(t *testing.T) {
+	req := &ConnReq{ConnType: DeviceManagementConnection, Layer: TunnelLayerData}
+
+	data := AllocAndPack(req)
+
+	cri := data[6+2*hostInfoSize:]
+	if len(cri) != 2 || cri[0] != 2 || cri[1] != byte(DeviceManagementConnection) {
+		t.Fatalf("Unexpected CRI %v", cri)
+	}
+
+	var srv Service
+	if _, err := Unpack(data, &srv); err != nil {
+		t.Fatal(err)
+	}
+
+	result, ok := srv.(*ConnReq)
+	if !ok {
+		t.Fatalf("Unexpected type %T", srv)
+	}
+
+	if result.ConnType != DeviceManagementConnection || result.Layer != 0 {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+}
+
+func TestConnReq_UnpackBadCRI(t *testing.T) {
+	data := AllocAndPack(&ConnReq{Layer: TunnelLayerData})
+
+	// Claim a CRI length of 5.
+	data[6+2*hostInfoSize] = 5
+
+	var srv Service
+	if _, err := Unpack(data, &srv); err == nil {
+		t.Fatal("Should not succeed")
+	}
+}
+
+func TestConnRes_PackUnpack(t *testing.T) {
+	for _, res := range []*ConnRes{
+		{Channel: 1, Status: NoError, Control: HostInfo{Protocol: UDP4}, Data: ConnResData{
+			ConnType:       TunnelConnection,
+			IndividualAddr: cemi.NewIndividualAddr3(1, 1, 251),
+		}},
+		{Channel: 2, Status: NoError, Data: ConnResData{ConnType: DeviceManagementConnection}},
+		{Channel: 0, Status: ErrNoMoreConnections},
+	} {
+		data := AllocAndPack(res)
+
+		var srv Service
+
+		n, err := Unpack(data, &srv)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if n != uint(len(data)) {
+			t.Errorf("Unexpected length: %d != %d", n, len(data))
+		}
+
+		result, ok := srv.(*ConnRes)
+		if !ok {
+			t.Fatalf("Unexpected type %T", srv)
+		}
+
+		if result.Channel != res.Channel || result.Status != res.Status ||
+			(res.Status == NoError && result.Data != res.Data) {
+			t.Errorf("Unexpected result: %+v != %+v", result, res)
+		}
+	}
+}
@@ -4,11 +4,7 @@
 
 package knxnet
 
-import (
-	"net"
-
-	"github.com/mobilarte/knx-exp/knx/util"
-)
+import "net"
 
 // A DescriptionReq requests a description from a particular KNXnet/IP server via unicast.
 type DescriptionReq struct {
@@ -43,12 +39,12 @@ func (DescriptionRes) Service() ServiceID {
 
 // Size returns the packed size of a Description Response.
 func (res DescriptionRes) Size() uint {
-	return res.DeviceHardware.Size() + res.SupportedServices.Size()
+	return (*DescriptionBlock)(&res).Size()
 }
 
 // Pack assembles the Description Response structure in the given buffer.
 func (res *DescriptionRes) Pack(buffer []byte) {
-	util.PackSome(buffer, res.DeviceHardware, res.SupportedServices)
+	(*DescriptionBlock)(res).Pack(buffer)
 }
 
 // Unpack parses the given service payload in order to initialize the Description Response.
@@ -0,0 +1,108 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knxnet
+
+import (
+	"errors"
+
+	"github.com/mobilarte/knx-exp/knx/cemi"
+	"github.com/mobilarte/knx-exp/knx/util"
+)
+
+// A DeviceConfigurationReq carries a cEMI management message over a device management
+// connection. It is sent in both directions.
+type DeviceConfigurationReq struct {
+	// Communication channel
+	Channel uint8
+
+	// Sequential number, used to track acknowledgements
+	SeqNumber uint8
+
+	// Management message, e.g. M_PropRead.req or M_PropRead.con
+	Payload cemi.Message
+}
+
+// Service returns the service identifier for device configuration requests.
+func (DeviceConfigurationReq) Service() ServiceID {
+	return DeviceConfReqService
+}
+
+// Size returns the packed size.
+func (req *DeviceConfigurationReq) Size() uint {
+	return 4 + cemi.Size(req.Payload)
+}
+
+// Pack assembles the service payload in the given buffer.
+func (req *DeviceConfigurationReq) Pack(buffer []byte) {
+	buffer[0] = 4
+	buffer[1] = req.Channel
+	buffer[2] = req.SeqNumber
+	buffer[3] = 0
+	cemi.Pack(buffer[4:], req.Payload)
+}
+
+// Unpack parses the given service payload in order to initialize the structure.
+func (req *DeviceConfigurationReq) Unpack(data []byte) (n uint, err error) {
+	var length, reserved uint8
+
+	if n, err = util.UnpackSome(
+		data, &length, &req.Channel, &req.SeqNumber, &reserved,
+	); err != nil {
+		return
+	}
+
+	if length != 4 {
+		return n, errors.New("length header is not 4")
+	}
+
+	m, err := cemi.Unpack(data[n:], &req.Payload)
+	n += m
+
+	return
+}
+
+// A DeviceConfigurationAck acknowledges a DeviceConfigurationReq.
+type DeviceConfigurationAck struct {
+	// Communication channel
+	Channel uint8
+
+	// Identifies the request that is being acknowledged
+	SeqNumber uint8
+
+	// Status code, determines whether the request has been accepted
+	Status ErrCode
+}
+
+// Service returns the service identifier for device configuration acknowledgements.
+func (DeviceConfigurationAck) Service() ServiceID {
+	return DeviceConfAckService
+}
+
+// Size returns the packed size.
+func (DeviceConfigurationAck) Size() uint {
+	return 4
+}
+
+// Pack assembles the service payload in the given buffer.
+func (ack *DeviceConfigurationAck) Pack(buffer []byte) {
+	buffer[0] = 4
+	buffer[1] = ack.Channel
+	buffer[2] = ack.SeqNumber
+	buffer[3] = uint8(ack.Status)
+}
+
+// Unpack parses the given service payload in order to initialize the structure.
+func (ack *DeviceConfigurationAck) Unpack(data []byte) (n uint, err error) {
+	var length uint8
+
+	n, err = util.UnpackSome(data, &length, &ack.Channel, &ack.SeqNumber, (*uint8)(&ack.Status))
+	if err != nil {
+		return
+	}
+
+	if length != 4 {
+		return n, errors.New("length header is not 4")
+	}
+
+	return
+}
@@ -0,0 +1,46 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knxnet
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/mobilarte/knx-exp/knx/cemi"
+)
+
+func TestDeviceConfiguration_PackUnpack(t *testing.T) {
+	services := []ServicePackable{
+		&DeviceConfigurationReq{Channel: 1, SeqNumber: 2, Payload: &cemi.MPropReadReq{PropertyData: cemi.PropertyData{
+			ObjectType:     cemi.DeviceObject,
+			ObjectInstance: 1,
+			PropertyID:     cemi.PIDProgMode,
+			Count:          1,
+			StartIndex:     1,
+		}}},
+		&DeviceConfigurationAck{Channel: 1, SeqNumber: 2, Status: NoError},
+	}
+
+	for _, srv := range services {
+		data := AllocAndPack(srv)
+
+		var result Service
+
+		n, err := Unpack(data, &result)
+		if err != nil {
+			t.Fatalf("Unpacking %T failed: %v", srv, err)
+		}
+
+		if n != uint(len(data)) {
+			t.Errorf("Unexpected length for %T: %d != %d", srv, n, len(data))
+		}
+
+		if result.Service() != srv.Service() {
+			t.Fatalf("Unexpected service %v, expected %v", result.Service(), srv.Service())
+		}
+
+		if repacked := AllocAndPack(result.(ServicePackable)); !bytes.Equal(repacked, data) {
+			t.Errorf("Repacked %T differs: %v != %v", srv, repacked, data)
+		}
+	}
+}
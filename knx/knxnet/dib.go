@@ -313,7 +313,6 @@ func (knxaddr *KnxAddrDIB) Pack(buffer []byte) {
 
 	offset := uint(2)
 	for _, e := range knxaddr.KNXAddresses {
-		fmt.Println(e)
 		util.PackSome(buffer[offset:], uint16(e))
 		offset += 2
 	}
@@ -403,6 +402,57 @@ type DescriptionBlock struct {
 	UnknownBlocks     []UnknownDescriptionBlock
 }
 
+// Size returns the packed size. Besides the device information and the supported services, only
+// the DIBs whose Type is set are included.
+func (di *DescriptionBlock) Size() uint {
+	size := di.DeviceHardware.Size() + di.SupportedServices.Size()
+
+	if di.IpConfig.Type != 0 {
+		size += di.IpConfig.Size()
+	}
+
+	if di.CurConfig.Type != 0 {
+		size += di.CurConfig.Size()
+	}
+
+	if di.KnxAddr.Type != 0 {
+		size += di.KnxAddr.Size()
+	}
+
+	for i := range di.UnknownBlocks {
+		size += di.UnknownBlocks[i].Size()
+	}
+
+	return size
+}
+
+// Pack assembles the DIBs in the given buffer.
+func (di *DescriptionBlock) Pack(buffer []byte) {
+	dibs := []util.Packable{&di.DeviceHardware, &di.SupportedServices}
+
+	if di.IpConfig.Type != 0 {
+		dibs = append(dibs, &di.IpConfig)
+	}
+
+	if di.CurConfig.Type != 0 {
+		dibs = append(dibs, &di.CurConfig)
+	}
+
+	if di.KnxAddr.Type != 0 {
+		dibs = append(dibs, &di.KnxAddr)
+	}
+
+	for i := range di.UnknownBlocks {
+		dibs = append(dibs, &di.UnknownBlocks[i])
+	}
+
+	var offset uint
+	for _, dib := range dibs {
+		dib.Pack(buffer[offset:])
+		offset += dib.Size()
+	}
+}
+
 // Unpack parses the given service payload in order to initialize the Description Block.
 // It can cope with not in sequence and unknown Device Information Blocks (DIB).
 func (di *DescriptionBlock) Unpack(data []byte) (n uint, err error) {
@@ -419,6 +469,11 @@ func (di *DescriptionBlock) Unpack(data []byte) (n uint, err error) {
 			return 0, err
 		}
 
+		// The length covers the length and type octets, and the DIB must not exceed the data.
+		if length < 2 || n+uint(length) > uint(len(data)) {
+			return 0, fmt.Errorf("invalid length %d of DIB 0x%02x", length, uint8(ty))
+		}
+
 		switch ty {
 		case DescriptionTypeDeviceInfo:
 			_, err = di.DeviceHardware.Unpack(data[n : n+uint(length)])
@@ -465,7 +520,7 @@ func (di *DescriptionBlock) Unpack(data []byte) (n uint, err error) {
 
 			// known DIBs without data will be silently ignored.
 			if length > 2 {
-				_, err = u.Unpack(data[n+2 : n+uint(length)-2])
+				_, err = u.Unpack(data[n+2 : n+uint(length)])
 				if err != nil {
 					return 0, err
 				}
@@ -492,6 +547,16 @@ type UnknownDescriptionBlock struct {
 	Data []byte
 }
 
+// Size returns the packed size.
+func (u *UnknownDescriptionBlock) Size() uint {
+	return uint(2 + len(u.Data))
+}
+
+// Pack assembles the DIB in the given buffer.
+func (u *UnknownDescriptionBlock) Pack(buffer []byte) {
+	util.PackSome(buffer, uint8(u.Size()), uint8(u.Type), u.Data)
+}
+
 // Unpack Unknown Description Blocks into a buffer.
 func (u *UnknownDescriptionBlock) Unpack(data []byte) (n uint, err error) {
 	u.Data = make([]byte, len(data))
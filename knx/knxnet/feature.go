@@ -0,0 +1,311 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+// Tunnelling v2 feature services, described in 03_08_04 Tunnelling AS.
+
+package knxnet
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/mobilarte/knx-exp/knx/util"
+)
+
+// InterfaceFeature identifies a feature of a tunnelling interface that can be queried or
+// modified with the Tunnelling v2 feature services.
+type InterfaceFeature uint8
+
+// These are the interface features defined by the standard.
+const (
+	// FeatureSupportedEMITypes is a bitset of the supported EMI types (2 bytes).
+	FeatureSupportedEMITypes InterfaceFeature = 0x01
+
+	// FeatureDeviceDescriptor is the device descriptor type 0, i.e. the mask version (2 bytes).
+	FeatureDeviceDescriptor InterfaceFeature = 0x02
+
+	// FeatureBusConnectionStatus is 1 if the interface is connected to the KNX bus (1 byte).
+	FeatureBusConnectionStatus InterfaceFeature = 0x03
+
+	// FeatureManufacturerCode is the KNX manufacturer code (2 bytes).
+	FeatureManufacturerCode InterfaceFeature = 0x04
+
+	// FeatureActiveEMIType is the active EMI type (1 byte).
+	FeatureActiveEMIType InterfaceFeature = 0x05
+
+	// FeatureIndividualAddress is the individual address used by the tunnel (2 bytes).
+	FeatureIndividualAddress InterfaceFeature = 0x06
+
+	// FeatureMaxAPDULength is the maximum APDU length supported by the interface (2 bytes).
+	FeatureMaxAPDULength InterfaceFeature = 0x07
+
+	// FeatureInfoServiceEnable enables (1) or disables (0) TUNNELLING_FEATURE_INFO (1 byte).
+	FeatureInfoServiceEnable InterfaceFeature = 0x08
+)
+
+// String generates a string representation of the feature.
+func (feature InterfaceFeature) String() string {
+	switch feature {
+	case FeatureSupportedEMITypes:
+		return "Supported EMI types"
+
+	case FeatureDeviceDescriptor:
+		return "Device descriptor"
+
+	case FeatureBusConnectionStatus:
+		return "Bus connection status"
+
+	case FeatureManufacturerCode:
+		return "Manufacturer code"
+
+	case FeatureActiveEMIType:
+		return "Active EMI type"
+
+	case FeatureIndividualAddress:
+		return "Individual address"
+
+	case FeatureMaxAPDULength:
+		return "Max APDU length"
+
+	case FeatureInfoServiceEnable:
+		return "Feature info service enable"
+
+	default:
+		return fmt.Sprintf("Unknown feature %#x", uint8(feature))
+	}
+}
+
+// FeatureReturnCode is the result of a feature service.
+type FeatureReturnCode uint8
+
+// These are the return codes of the feature services.
+const (
+	FeatureSuccess                 FeatureReturnCode = 0x00
+	FeatureSuccessWithCRC          FeatureReturnCode = 0x01
+	FeatureMemoryError             FeatureReturnCode = 0xf1
+	FeatureInvalidCommand          FeatureReturnCode = 0xf2
+	FeatureImpossibleCommand       FeatureReturnCode = 0xf3
+	FeatureExceedsMaxAPDULength    FeatureReturnCode = 0xf4
+	FeatureDataOverflow            FeatureReturnCode = 0xf5
+	FeatureOutOfMinRange           FeatureReturnCode = 0xf6
+	FeatureOutOfMaxRange           FeatureReturnCode = 0xf7
+	FeatureDataVoid                FeatureReturnCode = 0xf8
+	FeatureTemporarilyNotAvailable FeatureReturnCode = 0xf9
+	FeatureAccessWriteOnly         FeatureReturnCode = 0xfa
+	FeatureAccessReadOnly          FeatureReturnCode = 0xfb
+	FeatureAccessDenied            FeatureReturnCode = 0xfc
+	FeatureAddressVoid             FeatureReturnCode = 0xfd
+	FeatureDataTypeConflict        FeatureReturnCode = 0xfe
+	FeatureError                   FeatureReturnCode = 0xff
+)
+
+// String returns a string representation of the return code.
+func (code FeatureReturnCode) String() string {
+	switch code {
+	case FeatureSuccess:
+		return "Success"
+
+	case FeatureSuccessWithCRC:
+		return "Success with CRC"
+
+	case FeatureMemoryError:
+		return "Memory error"
+
+	case FeatureInvalidCommand:
+		return "Invalid command"
+
+	case FeatureImpossibleCommand:
+		return "Impossible command"
+
+	case FeatureExceedsMaxAPDULength:
+		return "Exceeds max APDU length"
+
+	case FeatureDataOverflow:
+		return "Data overflow"
+
+	case FeatureOutOfMinRange:
+		return "Out of min range"
+
+	case FeatureOutOfMaxRange:
+		return "Out of max range"
+
+	case FeatureDataVoid:
+		return "Data void"
+
+	case FeatureTemporarilyNotAvailable:
+		return "Temporarily not available"
+
+	case FeatureAccessWriteOnly:
+		return "Access write only"
+
+	case FeatureAccessReadOnly:
+		return "Access read only"
+
+	case FeatureAccessDenied:
+		return "Access denied"
+
+	case FeatureAddressVoid:
+		return "Address void"
+
+	case FeatureDataTypeConflict:
+		return "Data type conflict"
+
+	case FeatureError:
+		return "Error"
+
+	default:
+		return fmt.Sprintf("Unknown return code %#x", uint8(code))
+	}
+}
+
+// Error implements the error interface.
+func (code FeatureReturnCode) Error() string {
+	return code.String()
+}
+
+// IsSuccess determines whether the return code indicates a successful operation.
+func (code FeatureReturnCode) IsSuccess() bool {
+	return code == FeatureSuccess || code == FeatureSuccessWithCRC
+}
+
+// packFeature assembles the common layout of all feature services.
+func packFeature(buffer []byte, channel, seqNumber uint8, feature InterfaceFeature, status uint8, value []byte) {
+	util.PackSome(buffer, uint8(4), channel, seqNumber, uint8(0), uint8(feature), status, value)
+}
+
+// unpackFeature parses the common layout of all feature services.
+func unpackFeature(
+	data []byte, channel, seqNumber *uint8, feature *InterfaceFeature, status *uint8, value *[]byte,
+) (n uint, err error) {
+	var length, reserved uint8
+
+	if n, err = util.UnpackSome(
+		data, &length, channel, seqNumber, &reserved, (*uint8)(feature), status,
+	); err != nil {
+		return
+	}
+
+	if length != 4 {
+		return n, errors.New("length header is not 4")
+	}
+
+	if value != nil {
+		*value = make([]byte, len(data[n:]))
+		n += uint(copy(*value, data[n:]))
+	}
+
+	return
+}
+
+// A TunnelFeatureGet asks a tunnelling interface for the value of a feature.
+type TunnelFeatureGet struct {
+	Channel   uint8
+	SeqNumber uint8
+	Feature   InterfaceFeature
+}
+
+// Service returns the service identifier for feature get requests.
+func (TunnelFeatureGet) Service() ServiceID {
+	return TunnelFeatureGetService
+}
+
+// Size returns the packed size.
+func (TunnelFeatureGet) Size() uint {
+	return 6
+}
+
+// Pack assembles the service payload in the given buffer.
+func (req *TunnelFeatureGet) Pack(buffer []byte) {
+	packFeature(buffer, req.Channel, req.SeqNumber, req.Feature, 0, nil)
+}
+
+// Unpack parses the given service payload in order to initialize the structure.
+func (req *TunnelFeatureGet) Unpack(data []byte) (uint, error) {
+	var reserved uint8
+	return unpackFeature(data, &req.Channel, &req.SeqNumber, &req.Feature, &reserved, nil)
+}
+
+// A TunnelFeatureRes is the response of a tunnelling interface to a feature get or set request.
+type TunnelFeatureRes struct {
+	Channel   uint8
+	SeqNumber uint8
+	Feature   InterfaceFeature
+	Status    FeatureReturnCode
+	Value     []byte
+}
+
+// Service returns the service identifier for feature responses.
+func (TunnelFeatureRes) Service() ServiceID {
+	return TunnelFeatureResService
+}
+
+// Size returns the packed size.
+func (res *TunnelFeatureRes) Size() uint {
+	return 6 + uint(len(res.Value))
+}
+
+// Pack assembles the service payload in the given buffer.
+func (res *TunnelFeatureRes) Pack(buffer []byte) {
+	packFeature(buffer, res.Channel, res.SeqNumber, res.Feature, uint8(res.Status), res.Value)
+}
+
+// Unpack parses the given service payload in order to initialize the structure.
+func (res *TunnelFeatureRes) Unpack(data []byte) (uint, error) {
+	return unpackFeature(data, &res.Channel, &res.SeqNumber, &res.Feature, (*uint8)(&res.Status), &res.Value)
+}
+
+// A TunnelFeatureSet asks a tunnelling interface to change the value of a feature.
+type TunnelFeatureSet struct {
+	Channel   uint8
+	SeqNumber uint8
+	Feature   InterfaceFeature
+	Value     []byte
+}
+
+// Service returns the service identifier for feature set requests.
+func (TunnelFeatureSet) Service() ServiceID {
+	return TunnelFeatureSetService
+}
+
+// Size returns the packed size.
+func (req *TunnelFeatureSet) Size() uint {
+	return 6 + uint(len(req.Value))
+}
+
+// Pack assembles the service payload in the given buffer.
+func (req *TunnelFeatureSet) Pack(buffer []byte) {
+	packFeature(buffer, req.Channel, req.SeqNumber, req.Feature, 0, req.Value)
+}
+
+// Unpack parses the given service payload in order to initialize the structure.
+func (req *TunnelFeatureSet) Unpack(data []byte) (uint, error) {
+	var reserved uint8
+	return unpackFeature(data, &req.Channel, &req.SeqNumber, &req.Feature, &reserved, &req.Value)
+}
+
+// A TunnelFeatureInfo is sent by a tunnelling interface to notify about a changed feature value.
+type TunnelFeatureInfo struct {
+	Channel   uint8
+	SeqNumber uint8
+	Feature   InterfaceFeature
+	Value     []byte
+}
+
+// Service returns the service identifier for feature info notifications.
+func (TunnelFeatureInfo) Service() ServiceID {
+	return TunnelFeatureInfoService
+}
+
+// Size returns the packed size.
+func (info *TunnelFeatureInfo) Size() uint {
+	return 6 + uint(len(info.Value))
+}
+
+// Pack assembles the service payload in the given buffer.
+func (info *TunnelFeatureInfo) Pack(buffer []byte) {
+	packFeature(buffer, info.Channel, info.SeqNumber, info.Feature, 0, info.Value)
+}
+
+// Unpack parses the given service payload in order to initialize the structure.
+func (info *TunnelFeatureInfo) Unpack(data []byte) (uint, error) {
+	var reserved uint8
+	return unpackFeature(data, &info.Channel, &info.SeqNumber, &info.Feature, &reserved, &info.Value)
+}
@@ -0,0 +1,66 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knxnet
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestTunnelFeature_PackUnpack(t *testing.T) {
+	services := []ServicePackable{
+		&TunnelFeatureGet{Channel: 1, SeqNumber: 2, Feature: FeatureMaxAPDULength},
+		&TunnelFeatureRes{
+			Channel: 1, SeqNumber: 3, Feature: FeatureMaxAPDULength, Status: FeatureSuccess, Value: []byte{0, 254},
+		},
+		&TunnelFeatureSet{Channel: 1, SeqNumber: 4, Feature: FeatureInfoServiceEnable, Value: []byte{1}},
+		&TunnelFeatureInfo{Channel: 1, SeqNumber: 5, Feature: FeatureBusConnectionStatus, Value: []byte{1}},
+	}
+
+	for _, srv := range services {
+		data := AllocAndPack(srv)
+
+		var result Service
+
+		n, err := Unpack(data, &result)
+		if err != nil {
+			t.Fatalf("Unpacking %T failed: %v", srv, err)
+		}
+
+		if n != uint(len(data)) {
+			t.Errorf("Unexpected length for %T: %d != %d", srv, n, len(data))
+		}
+
+		if result.Service() != srv.Service() {
+			t.Fatalf("Unexpected service %v, expected %v", result.Service(), srv.Service())
+		}
+
+		if repacked := AllocAndPack(result.(ServicePackable)); !bytes.Equal(repacked, data) {
+			t.Errorf("Repacked %T differs: %v != %v", srv, repacked, data)
+		}
+	}
+}
+
+func TestTunnelFeatureRes_Unpack(t *testing.T) {
+	data := []byte{4, 7, 9, 0, byte(FeatureDeviceDescriptor), byte(FeatureAccessDenied)}
+
+	var res TunnelFeatureRes
+
+	_, err := res.Unpack(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Channel != 7 || res.SeqNumber != 9 || res.Feature != FeatureDeviceDescriptor {
+		t.Errorf("Unexpected header: %+v", res)
+	}
+
+	if res.Status.IsSuccess() || len(res.Value) != 0 {
+		t.Errorf("Unexpected status or value: %+v", res)
+	}
+
+	_, err = res.Unpack([]byte{5, 7, 9, 0, 1, 0})
+	if err == nil {
+		t.Error("Should not succeed with bad length")
+	}
+}
@@ -22,25 +22,34 @@ func (srv ServiceID) String() string {
 
 // Currently supported services.
 const (
-	SearchReqService      ServiceID = 0x0201
-	SearchResService      ServiceID = 0x0202
-	DescrReqService       ServiceID = 0x0203
-	DescrResService       ServiceID = 0x0204
-	ConnReqService        ServiceID = 0x0205
-	ConnResService        ServiceID = 0x0206
-	ConnStateReqService   ServiceID = 0x0207
-	ConnStateResService   ServiceID = 0x0208
-	DiscReqService        ServiceID = 0x0209
-	DiscResService        ServiceID = 0x020a
-	TunnelReqService      ServiceID = 0x0420
-	TunnelResService      ServiceID = 0x0421
-	RoutingIndService     ServiceID = 0x0530
-	RoutingLostService    ServiceID = 0x0531
-	RoutingBusyService    ServiceID = 0x0532
-	DiagnosticReqService  ServiceID = 0x0740
-	DiagnosticResService  ServiceID = 0x0741
-	BasicConfReqService   ServiceID = 0x0742
-	BasicConfResetService ServiceID = 0x0743
+	SearchReqService           ServiceID = 0x0201
+	SearchResService           ServiceID = 0x0202
+	DescrReqService            ServiceID = 0x0203
+	DescrResService            ServiceID = 0x0204
+	ConnReqService             ServiceID = 0x0205
+	ConnResService             ServiceID = 0x0206
+	ConnStateReqService        ServiceID = 0x0207
+	ConnStateResService        ServiceID = 0x0208
+	DiscReqService             ServiceID = 0x0209
+	DiscResService             ServiceID = 0x020a
+	SearchReqExtService        ServiceID = 0x020b
+	SearchResExtService        ServiceID = 0x020c
+	DeviceConfReqService       ServiceID = 0x0310
+	DeviceConfAckService       ServiceID = 0x0311
+	TunnelReqService           ServiceID = 0x0420
+	TunnelResService           ServiceID = 0x0421
+	TunnelFeatureGetService    ServiceID = 0x0422
+	TunnelFeatureResService    ServiceID = 0x0423
+	TunnelFeatureSetService    ServiceID = 0x0424
+	TunnelFeatureInfoService   ServiceID = 0x0425
+	RoutingIndService          ServiceID = 0x0530
+	RoutingLostService         ServiceID = 0x0531
+	RoutingBusyService         ServiceID = 0x0532
+	RoutingSysBroadcastService ServiceID = 0x0533
+	DiagnosticReqService       ServiceID = 0x0740
+	DiagnosticResService       ServiceID = 0x0741
+	BasicConfReqService        ServiceID = 0x0742
+	BasicConfResetService      ServiceID = 0x0743
 )
 
 // Service describes a KNXnet/IP service.
@@ -175,6 +184,12 @@ func Unpack(data []byte, srv *Service) (uint, error) {
 	case SearchResService:
 		body = &SearchRes{}
 
+	case SearchReqExtService:
+		body = &SearchReqExt{}
+
+	case SearchResExtService:
+		body = &SearchResExt{}
+
 	case DescrReqService:
 		body = &DescriptionReq{}
 
@@ -199,12 +214,30 @@ func Unpack(data []byte, srv *Service) (uint, error) {
 	case DiscResService:
 		body = &DiscRes{}
 
+	case DeviceConfReqService:
+		body = &DeviceConfigurationReq{}
+
+	case DeviceConfAckService:
+		body = &DeviceConfigurationAck{}
+
 	case TunnelReqService:
 		body = &TunnelReq{}
 
 	case TunnelResService:
 		body = &TunnelRes{}
 
+	case TunnelFeatureGetService:
+		body = &TunnelFeatureGet{}
+
+	case TunnelFeatureResService:
+		body = &TunnelFeatureRes{}
+
+	case TunnelFeatureSetService:
+		body = &TunnelFeatureSet{}
+
+	case TunnelFeatureInfoService:
+		body = &TunnelFeatureInfo{}
+
 	case RoutingIndService:
 		body = &RoutingInd{}
 
@@ -214,6 +247,9 @@ func Unpack(data []byte, srv *Service) (uint, error) {
 	case RoutingBusyService:
 		body = &RoutingBusy{}
 
+	case RoutingSysBroadcastService:
+		body = &RoutingSystemBroadcast{}
+
 	case DiagnosticReqService:
 		body = &DiagnosticReq{}
 
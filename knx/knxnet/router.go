@@ -36,6 +36,32 @@ func (ind *RoutingInd) Unpack(data []byte) (uint, error) {
 	return cemi.Unpack(data, &ind.Payload)
 }
 
+// A RoutingSystemBroadcast carries a cEMI frame in system broadcast mode, i.e. with
+// cemi.Control1NoSysBroadcast cleared, between routers.
+type RoutingSystemBroadcast struct {
+	Payload cemi.Message
+}
+
+// Service returns the service identifier for routing system broadcasts.
+func (RoutingSystemBroadcast) Service() ServiceID {
+	return RoutingSysBroadcastService
+}
+
+// Size returns the packed size.
+func (bc *RoutingSystemBroadcast) Size() uint {
+	return cemi.Size(bc.Payload)
+}
+
+// Pack assembles the service payload in the given buffer.
+func (bc *RoutingSystemBroadcast) Pack(buffer []byte) {
+	cemi.Pack(buffer, bc.Payload)
+}
+
+// Unpack parses the given service payload in order to initialize the structure.
+func (bc *RoutingSystemBroadcast) Unpack(data []byte) (uint, error) {
+	return cemi.Unpack(data, &bc.Payload)
+}
+
 // DeviceState indicates the state of a device.
 type DeviceState uint8
 
@@ -106,6 +132,21 @@ func (RoutingBusy) Service() ServiceID {
 	return RoutingBusyService
 }
 
+// routingBusyLength is the length of the busy information, including the length byte itself.
+const routingBusyLength = 6
+
+// Size returns the packed size.
+func (RoutingBusy) Size() uint {
+	return routingBusyLength
+}
+
+// Pack assembles the service payload in the given buffer.
+func (rl *RoutingBusy) Pack(buffer []byte) {
+	util.PackSome(
+		buffer, uint8(routingBusyLength), uint8(rl.Status), uint16(rl.WaitTime/time.Millisecond), rl.Control,
+	)
+}
+
 // Unpack parses the given service payload in order to initialize the structure.
 func (rl *RoutingBusy) Unpack(data []byte) (n uint, err error) {
 	var (
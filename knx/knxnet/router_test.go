@@ -0,0 +1,63 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knxnet
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/mobilarte/knx-exp/knx/cemi"
+)
+
+func TestRoutingSystemBroadcast_PackUnpack(t *testing.T) {
+	srv := &RoutingSystemBroadcast{Payload: &cemi.LDataInd{LData: cemi.LData{
+		Control1:    cemi.Control1StdFrame | cemi.Control1Prio(cemi.PrioSystem),
+		Control2:    cemi.Control2Hops(6),
+		Source:      0x1101,
+		Destination: 0,
+		Data:        &cemi.AppData{Command: cemi.IndividualAddrWrite, Data: []byte{0, 0x11, 0x05}},
+	}}}
+
+	data := AllocAndPack(srv)
+
+	var result Service
+
+	n, err := Unpack(data, &result)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n != uint(len(data)) {
+		t.Errorf("Unexpected length: %d != %d", n, len(data))
+	}
+
+	bc, ok := result.(*RoutingSystemBroadcast)
+	if !ok {
+		t.Fatalf("Unexpected service %T", result)
+	}
+
+	if _, ok := bc.Payload.(*cemi.LDataInd); !ok {
+		t.Errorf("Unexpected payload %T", bc.Payload)
+	}
+
+	if repacked := AllocAndPack(bc); !bytes.Equal(repacked, data) {
+		t.Errorf("Repacked service differs: %v != %v", repacked, data)
+	}
+}
+
+func TestRoutingBusy_PackUnpack(t *testing.T) {
+	busy := &RoutingBusy{Status: DeviceStateOk, WaitTime: 100 * time.Millisecond, Control: 0}
+
+	data := AllocAndPack(busy)
+
+	var result Service
+
+	if _, err := Unpack(data, &result); err != nil {
+		t.Fatal(err)
+	}
+
+	if unpacked, ok := result.(*RoutingBusy); !ok || *unpacked != *busy {
+		t.Errorf("Unexpected result %+v", result)
+	}
+}
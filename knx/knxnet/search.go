@@ -3,6 +3,7 @@
 package knxnet
 
 import (
+	"errors"
 	"net"
 
 	"github.com/mobilarte/knx-exp/knx/util"
@@ -51,10 +52,166 @@ func (res SearchRes) Size() uint {
 
 // Pack assembles the Search Response structure in the given buffer.
 func (res *SearchRes) Pack(buffer []byte) {
-	util.PackSome(buffer, res.Control, res.DescriptionB.DeviceHardware, res.DescriptionB.SupportedServices)
+	util.PackSome(buffer, &res.Control, &res.DescriptionB.DeviceHardware, &res.DescriptionB.SupportedServices)
 }
 
 // Unpack parses the given service payload in order to initialize the Search Response structure.
 func (res *SearchRes) Unpack(data []byte) (n uint, err error) {
 	return util.UnpackSome(data, &res.Control, &res.DescriptionB.DeviceHardware, &res.DescriptionB.SupportedServices)
 }
+
+// SearchParamType identifies a search request parameter of an extended search.
+type SearchParamType uint8
+
+// These are the known search request parameters.
+const (
+	// SearchParamProgMode selects the devices that are in programming mode.
+	SearchParamProgMode SearchParamType = 0x02
+
+	// SearchParamMACAddress selects the device with the given MAC address.
+	SearchParamMACAddress SearchParamType = 0x03
+
+	// SearchParamService selects the devices that support the given service family in at least
+	// the given version.
+	SearchParamService SearchParamType = 0x04
+
+	// SearchParamDIBs requests the listed DIBs in the response.
+	SearchParamDIBs SearchParamType = 0x05
+)
+
+// searchParamMandatory marks a search request parameter that a device must understand in order to
+// respond.
+const searchParamMandatory = 0x80
+
+// A SearchParam is a search request parameter (SRP) of an extended search.
+type SearchParam struct {
+	Type SearchParamType
+
+	// Mandatory parameters must be understood. Devices that do not know the type do not respond.
+	Mandatory bool
+
+	Data []byte
+}
+
+// Size returns the packed size.
+func (srp *SearchParam) Size() uint {
+	return uint(2 + len(srp.Data))
+}
+
+// Pack assembles the search request parameter in the given buffer.
+func (srp *SearchParam) Pack(buffer []byte) {
+	ty := uint8(srp.Type)
+	if srp.Mandatory {
+		ty |= searchParamMandatory
+	}
+
+	util.PackSome(buffer, uint8(srp.Size()), ty, srp.Data)
+}
+
+// Unpack parses the given data in order to initialize the structure.
+func (srp *SearchParam) Unpack(data []byte) (n uint, err error) {
+	var length, ty uint8
+
+	if n, err = util.UnpackSome(data, &length, &ty); err != nil {
+		return
+	}
+
+	if length < 2 || uint(len(data)) < uint(length) {
+		return n, errors.New("invalid search request parameter length")
+	}
+
+	srp.Type = SearchParamType(ty &^ searchParamMandatory)
+	srp.Mandatory = ty&searchParamMandatory != 0
+	srp.Data = make([]byte, length-2)
+	copy(srp.Data, data[n:length])
+
+	return uint(length), nil
+}
+
+// A SearchReqExt is an extended search request, which may narrow down the responding devices and
+// request additional DIBs.
+type SearchReqExt struct {
+	Control HostInfo
+	Params  []SearchParam
+}
+
+// Service returns the service identifier for the extended Search Request.
+func (SearchReqExt) Service() ServiceID {
+	return SearchReqExtService
+}
+
+// Size returns the packed size.
+func (req *SearchReqExt) Size() uint {
+	size := req.Control.Size()
+	for i := range req.Params {
+		size += req.Params[i].Size()
+	}
+
+	return size
+}
+
+// Pack assembles the extended Search Request in the given buffer.
+func (req *SearchReqExt) Pack(buffer []byte) {
+	req.Control.Pack(buffer)
+
+	offset := req.Control.Size()
+	for i := range req.Params {
+		req.Params[i].Pack(buffer[offset:])
+		offset += req.Params[i].Size()
+	}
+}
+
+// Unpack parses the given service payload in order to initialize the structure.
+func (req *SearchReqExt) Unpack(data []byte) (n uint, err error) {
+	if n, err = req.Control.Unpack(data); err != nil {
+		return
+	}
+
+	req.Params = nil
+
+	for n < uint(len(data)) {
+		var srp SearchParam
+
+		m, err := srp.Unpack(data[n:])
+		if err != nil {
+			return n, err
+		}
+
+		n += m
+		req.Params = append(req.Params, srp)
+	}
+
+	return n, nil
+}
+
+// A SearchResExt is the response to an extended search request. It carries the requested DIBs.
+type SearchResExt struct {
+	Control      HostInfo
+	DescriptionB DescriptionBlock
+}
+
+// Service returns the service identifier for the extended Search Response.
+func (SearchResExt) Service() ServiceID {
+	return SearchResExtService
+}
+
+// Size returns the packed size.
+func (res *SearchResExt) Size() uint {
+	return res.Control.Size() + res.DescriptionB.Size()
+}
+
+// Pack assembles the extended Search Response in the given buffer.
+func (res *SearchResExt) Pack(buffer []byte) {
+	util.PackSome(buffer, &res.Control, &res.DescriptionB)
+}
+
+// Unpack parses the given service payload in order to initialize the structure.
+func (res *SearchResExt) Unpack(data []byte) (n uint, err error) {
+	if n, err = res.Control.Unpack(data); err != nil {
+		return
+	}
+
+	m, err := res.DescriptionB.Unpack(data[n:])
+
+	return n + m, err
+}
@@ -0,0 +1,161 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knxnet
+
+import (
+	"bytes"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/mobilarte/knx-exp/knx/cemi"
+)
+
+func makeDescriptionBlock() DescriptionBlock {
+	return DescriptionBlock{
+		DeviceHardware: DeviceInformationBlock{
+			Type:                    DescriptionTypeDeviceInfo,
+			Medium:                  KNXMediumTP1,
+			Source:                  0x1101,
+			SerialNumber:            DeviceSerialNumber{0, 1, 2, 3, 4, 5},
+			RoutingMulticastAddress: Address{224, 0, 23, 12},
+			HardwareAddr:            net.HardwareAddr{0x02, 0, 0, 0, 0, 1},
+			FriendlyName:            "knx-exp",
+		},
+		SupportedServices: SupportedServicesDIB{
+			Type: DescriptionTypeSupportedServiceFamilies,
+			Families: []ServiceFamily{
+				{Type: ServiceFamilyTypeIPCore, Version: 2},
+				{Type: ServiceFamilyTypeIPTunnelling, Version: 2},
+			},
+		},
+		IpConfig: IpConfigDIB{
+			Type:       DescriptionTypeIPConfig,
+			IpAddress:  Address{192, 168, 1, 10},
+			SubnetMask: Address{255, 255, 255, 0},
+		},
+		KnxAddr: KnxAddrDIB{
+			Type:         DescriptionTypeKNXAddresses,
+			KNXAddresses: []cemi.IndividualAddr{0x1101, 0x11f1},
+		},
+	}
+}
+
+// roundTrip packs the service, unpacks it again and makes sure that it repacks identically.
+func roundTrip(t *testing.T, srv ServicePackable) Service {
+	t.Helper()
+
+	data := AllocAndPack(srv)
+
+	var result Service
+
+	n, err := Unpack(data, &result)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n != uint(len(data)) {
+		t.Errorf("Unexpected length: %d != %d", n, len(data))
+	}
+
+	packable, ok := result.(ServicePackable)
+	if !ok {
+		t.Fatalf("Unexpected service %T", result)
+	}
+
+	if repacked := AllocAndPack(packable); !bytes.Equal(repacked, data) {
+		t.Errorf("Repacked service differs: %v != %v", repacked, data)
+	}
+
+	return result
+}
+
+func TestSearchRes_PackUnpack(t *testing.T) {
+	res := &SearchRes{
+		Control:      HostInfo{Protocol: UDP4, Address: Address{192, 168, 1, 10}, Port: 3671},
+		DescriptionB: makeDescriptionBlock(),
+	}
+
+	unpacked, ok := roundTrip(t, res).(*SearchRes)
+	if !ok {
+		t.Fatal("Unexpected service")
+	}
+
+	// Search responses only carry the device information and the supported services.
+	if unpacked.DescriptionB.DeviceHardware.FriendlyName != "knx-exp" || unpacked.DescriptionB.IpConfig.Type != 0 {
+		t.Errorf("Unexpected description %+v", unpacked.DescriptionB)
+	}
+}
+
+func TestDescriptionRes_PackUnpack(t *testing.T) {
+	res := DescriptionRes(makeDescriptionBlock())
+
+	unpacked, ok := roundTrip(t, &res).(*DescriptionRes)
+	if !ok {
+		t.Fatal("Unexpected service")
+	}
+
+	if !reflect.DeepEqual(DescriptionBlock(*unpacked), DescriptionBlock(res)) {
+		t.Errorf("Unexpected description %+v", *unpacked)
+	}
+}
+
+func TestDescriptionRes_InvalidLength(t *testing.T) {
+	for _, packet := range [][]byte{
+		// A DIB with zero length must not stall the parser.
+		{0x06, 0x10, 0x02, 0x04, 0x00, 0x0a, 0x00, 0x07, 0x00, 0x00},
+		{0x06, 0x10, 0x02, 0x0c, 0x00, 0x12, 0x08, 0x01, 0, 0, 0, 0, 0, 0, 0x00, 0x07, 0x00, 0x00},
+		// A DIB must not exceed the packet.
+		{0x06, 0x10, 0x02, 0x04, 0x00, 0x0a, 0xff, 0x01, 0x00, 0x00},
+		{0x06, 0x10, 0x02, 0x04, 0x00, 0x0a, 0x01, 0x07, 0x00, 0x00},
+	} {
+		var srv Service
+		if _, err := Unpack(packet, &srv); err == nil {
+			t.Errorf("Invalid packet % x has been accepted", packet)
+		}
+	}
+}
+
+func TestSearchReqExt_PackUnpack(t *testing.T) {
+	req := &SearchReqExt{
+		Control: HostInfo{Protocol: UDP4, Address: Address{192, 168, 1, 20}, Port: 50000},
+		Params: []SearchParam{
+			{Type: SearchParamProgMode},
+			{Type: SearchParamService, Data: []byte{byte(ServiceFamilyTypeIPTunnelling), 2}},
+			{Type: SearchParamDIBs, Mandatory: true, Data: []byte{byte(DescriptionTypeIPConfig), 0}},
+		},
+	}
+
+	unpacked, ok := roundTrip(t, req).(*SearchReqExt)
+	if !ok {
+		t.Fatal("Unexpected service")
+	}
+
+	if len(unpacked.Params) != 3 || !unpacked.Params[2].Mandatory || unpacked.Params[2].Type != SearchParamDIBs {
+		t.Errorf("Unexpected parameters %+v", unpacked.Params)
+	}
+
+	t.Run("BadLength", func(t *testing.T) {
+		var srp SearchParam
+
+		if _, err := srp.Unpack([]byte{4, byte(SearchParamService), 4}); err == nil {
+			t.Error("Truncated parameter has been accepted")
+		}
+	})
+}
+
+func TestSearchResExt_PackUnpack(t *testing.T) {
+	res := &SearchResExt{
+		Control:      HostInfo{Protocol: UDP4, Address: Address{192, 168, 1, 10}, Port: 3671},
+		DescriptionB: makeDescriptionBlock(),
+	}
+
+	unpacked, ok := roundTrip(t, res).(*SearchResExt)
+	if !ok {
+		t.Fatal("Unexpected service")
+	}
+
+	if !reflect.DeepEqual(unpacked.DescriptionB.KnxAddr, res.DescriptionB.KnxAddr) {
+		t.Errorf("Unexpected KNX addresses %+v", unpacked.DescriptionB.KnxAddr)
+	}
+}
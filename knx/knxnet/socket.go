@@ -5,6 +5,7 @@ package knxnet
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"io"
 	"net"
@@ -22,10 +23,14 @@ type Socket interface {
 	LocalAddr() net.Addr
 }
 
+// errorBufferSize is the number of receive errors that are kept for a slow reader.
+const errorBufferSize = 16
+
 // TunnelSocket is a UDP socket for KNXnet/IP packet exchange.
 type TunnelSocket struct {
 	conn    net.Conn
 	inbound <-chan Service
+	errors  <-chan error
 }
 
 // DialTunnelUDP creates a new Socket which can used to exchange KNXnet/IP packets with a single
@@ -51,9 +56,11 @@ func DialTunnelUDP(address string) (*TunnelSocket, error) {
 	}
 
 	inbound := make(chan Service)
-	go serveUDPSocket(conn, addr, inbound)
+	errs := make(chan error, errorBufferSize)
+
+	go serveUDPSocket(conn, addr, inbound, errs)
 
-	return &TunnelSocket{conn, inbound}, nil
+	return &TunnelSocket{conn, inbound, errs}, nil
 }
 
 // DialTunnelTCP creates a new Socket which can used to exchange KNXnet/IP packets with a single
@@ -79,9 +86,11 @@ func DialTunnelTCP(address string) (*TunnelSocket, error) {
 	}
 
 	inbound := make(chan Service)
-	go serveTCPSocket(conn, addr, inbound)
+	errs := make(chan error, errorBufferSize)
 
-	return &TunnelSocket{conn, inbound}, nil
+	go serveTCPSocket(conn, addr, inbound, errs)
+
+	return &TunnelSocket{conn, inbound, errs}, nil
 }
 
 // Send transmits a KNXnet/IP packet.
@@ -100,6 +109,13 @@ func (sock *TunnelSocket) Inbound() <-chan Service {
 	return sock.inbound
 }
 
+// Errors provides a channel from which you can retrieve errors that occurred while receiving,
+// e.g. packets that could not be parsed. Errors are dropped when nobody reads them. The channel is
+// closed together with the inbound channel.
+func (sock *TunnelSocket) Errors() <-chan error {
+	return sock.errors
+}
+
 // Close shuts the socket down. This will indirectly terminate the associated workers.
 func (sock *TunnelSocket) Close() error {
 	return sock.conn.Close()
@@ -115,6 +131,23 @@ type RouterSocket struct {
 	conn    *net.UDPConn
 	addr    *net.UDPAddr
 	inbound <-chan Service
+	errors  <-chan error
+
+	// Only set if the socket listens on several interfaces
+	pc   *ipv4.PacketConn
+	ifis []*net.Interface
+}
+
+// An InterfaceService is a service that a RouterSocket on several interfaces has received,
+// together with the interface it came in on.
+type InterfaceService struct {
+	Payload   Service
+	Interface *net.Interface
+}
+
+// Service returns the service identifier of the payload.
+func (srv *InterfaceService) Service() ServiceID {
+	return srv.Payload.Service()
 }
 
 // ListenRouter creates a new Socket which can be used to exchange KNXnet/IP packets with
@@ -144,23 +177,93 @@ func ListenRouterOnInterface(ifi *net.Interface, multicastAddress string,
 		return nil, err
 	}
 
+	setupMulticastLoopback(conn, pc, multicastLoopbackEnabled)
+
+	_ = conn.SetDeadline(time.Time{})
+
+	inbound := make(chan Service)
+	errs := make(chan error, errorBufferSize)
+
+	go serveUDPSocket(conn, nil, inbound, errs)
+
+	return &RouterSocket{conn: conn, addr: addr, inbound: inbound, errors: errs}, nil
+}
+
+// ListenRouterOnInterfaces is like ListenRouterOnInterface, but joins the multicast group on each
+// of the given interfaces. Incoming packets are delivered as *InterfaceService.
+func ListenRouterOnInterfaces(ifis []*net.Interface, multicastAddress string,
+	multicastLoopbackEnabled bool) (*RouterSocket, error) {
+	if len(ifis) == 0 {
+		return nil, errors.New("no interface to listen on")
+	}
+
+	addr, err := net.ResolveUDPAddr("udp4", multicastAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.ListenUDP("udp4", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	pc := ipv4.NewPacketConn(conn)
+
+	for _, ifi := range ifis {
+		if err := pc.JoinGroup(ifi, addr); err != nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("joining multicast group on %s: %w", ifi.Name, err)
+		}
+	}
+
+	// Tells on which interface a packet has been received.
+	if err := pc.SetControlMessage(ipv4.FlagInterface, true); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	setupMulticastLoopback(conn, pc, multicastLoopbackEnabled)
+
+	_ = conn.SetDeadline(time.Time{})
+
+	inbound := make(chan Service)
+	errs := make(chan error, errorBufferSize)
+
+	go serveRouterSocket(pc, ifis, inbound, errs)
+
+	return &RouterSocket{conn: conn, addr: addr, inbound: inbound, errors: errs, pc: pc, ifis: ifis}, nil
+}
+
+// MulticastInterfaces returns the interfaces that are up and capable of multicast.
+func MulticastInterfaces() ([]*net.Interface, error) {
+	all, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	var ifis []*net.Interface
+
+	for i := range all {
+		if all[i].Flags&net.FlagUp != 0 && all[i].Flags&net.FlagMulticast != 0 {
+			ifis = append(ifis, &all[i])
+		}
+	}
+
+	return ifis, nil
+}
+
+// setupMulticastLoopback enables or disables the multicast loopback.
+func setupMulticastLoopback(conn *net.UDPConn, pc *ipv4.PacketConn, enabled bool) {
 	// Just for logging purposes.
 	if loopOn, err := pc.MulticastLoopback(); err == nil {
 		util.Log(conn, "MulticastLoopback status: %v", loopOn)
 	}
 	// Setup interface with Multicast Loopback enabled if desired.
-	if err := pc.SetMulticastLoopback(multicastLoopbackEnabled); err != nil {
+	if err := pc.SetMulticastLoopback(enabled); err != nil {
 		util.Log(conn, "SetMulticastLoopback error: %v", err)
 	} else {
-		util.Log(conn, "MulticastLoopbackEnabled: %t", multicastLoopbackEnabled)
+		util.Log(conn, "MulticastLoopbackEnabled: %t", enabled)
 	}
-
-	_ = conn.SetDeadline(time.Time{})
-
-	inbound := make(chan Service)
-	go serveUDPSocket(conn, nil, inbound)
-
-	return &RouterSocket{conn, addr, inbound}, nil
 }
 
 // Addr returns the multicast destination address.
@@ -168,8 +271,20 @@ func (sock *RouterSocket) Addr() *net.UDPAddr {
 	return sock.addr
 }
 
-// Send transmits a KNXnet/IP packet.
+// Send transmits a KNXnet/IP packet. A socket on several interfaces sends it on each of them.
 func (sock *RouterSocket) Send(payload ServicePackable) error {
+	if sock.pc != nil {
+		var errs []error
+
+		for _, ifi := range sock.ifis {
+			if err := sock.SendOn(ifi, payload); err != nil {
+				errs = append(errs, err)
+			}
+		}
+
+		return errors.Join(errs...)
+	}
+
 	buffer := make([]byte, Size(payload))
 	Pack(buffer, payload)
 
@@ -179,11 +294,41 @@ func (sock *RouterSocket) Send(payload ServicePackable) error {
 	return err
 }
 
+// SendOn transmits a KNXnet/IP packet on the given interface. It is only supported by sockets on
+// several interfaces.
+func (sock *RouterSocket) SendOn(ifi *net.Interface, payload ServicePackable) error {
+	if sock.pc == nil {
+		return errors.New("socket does not support choosing the interface")
+	}
+
+	buffer := make([]byte, Size(payload))
+	Pack(buffer, payload)
+
+	if _, err := sock.pc.WriteTo(buffer, &ipv4.ControlMessage{IfIndex: ifi.Index}, sock.addr); err != nil {
+		return fmt.Errorf("sending on %s: %w", ifi.Name, err)
+	}
+
+	return nil
+}
+
+// Interfaces returns the interfaces on which the socket listens, if it has been created for
+// several interfaces.
+func (sock *RouterSocket) Interfaces() []*net.Interface {
+	return sock.ifis
+}
+
 // Inbound provides a channel from which you can retrieve incoming packets.
 func (sock *RouterSocket) Inbound() <-chan Service {
 	return sock.inbound
 }
 
+// Errors provides a channel from which you can retrieve errors that occurred while receiving,
+// e.g. packets that could not be parsed. Errors are dropped when nobody reads them. The channel is
+// closed together with the inbound channel.
+func (sock *RouterSocket) Errors() <-chan error {
+	return sock.errors
+}
+
 // Close shuts the socket down. This will indirectly terminate the associated workers.
 func (sock *RouterSocket) Close() error {
 	return sock.conn.Close()
@@ -194,13 +339,22 @@ func (sock *RouterSocket) LocalAddr() net.Addr {
 	return sock.conn.LocalAddr()
 }
 
+// reportError passes the error on to the reader of the error channel, if there is one.
+func reportError(errs chan<- error, err error) {
+	select {
+	case errs <- err:
+	default:
+	}
+}
+
 // serveUDPSocket is the receiver worker for a UDP socket.
-func serveUDPSocket(conn *net.UDPConn, addr *net.UDPAddr, inbound chan<- Service) {
+func serveUDPSocket(conn *net.UDPConn, addr *net.UDPAddr, inbound chan<- Service, errs chan<- error) {
 	util.Log(conn, "Started worker")
 	defer util.Log(conn, "Worker exited")
 
 	// A closed inbound channel indicates to its readers that the worker has terminated.
 	defer close(inbound)
+	defer close(errs)
 
 	buffer := [1024]byte{}
 
@@ -208,6 +362,12 @@ func serveUDPSocket(conn *net.UDPConn, addr *net.UDPAddr, inbound chan<- Service
 		len, sender, err := conn.ReadFromUDP(buffer[:])
 		if err != nil {
 			util.Log(conn, "Error during ReadFromUDP: %v", err)
+
+			// Closing the socket is not an error.
+			if !errors.Is(err, net.ErrClosed) {
+				reportError(errs, err)
+			}
+
 			return
 		}
 
@@ -228,6 +388,8 @@ func serveUDPSocket(conn *net.UDPConn, addr *net.UDPAddr, inbound chan<- Service
 		_, err = Unpack(buffer[:len], &payload)
 		if err != nil {
 			util.Log(conn, "Error during Unpack: %v", err)
+			reportError(errs, fmt.Errorf("unpacking packet from %v: %w", sender, err))
+
 			continue
 		}
 
@@ -235,13 +397,74 @@ func serveUDPSocket(conn *net.UDPConn, addr *net.UDPAddr, inbound chan<- Service
 	}
 }
 
+// serveRouterSocket is the receiver worker for a router socket on several interfaces.
+func serveRouterSocket(pc *ipv4.PacketConn, ifis []*net.Interface, inbound chan<- Service, errs chan<- error) {
+	util.Log(pc, "Started worker")
+	defer util.Log(pc, "Worker exited")
+
+	// A closed inbound channel indicates to its readers that the worker has terminated.
+	defer close(inbound)
+	defer close(errs)
+
+	byIndex := make(map[int]*net.Interface, len(ifis))
+	for _, ifi := range ifis {
+		byIndex[ifi.Index] = ifi
+	}
+
+	buffer := [1024]byte{}
+
+	for {
+		len, cm, sender, err := pc.ReadFrom(buffer[:])
+		if err != nil {
+			util.Log(pc, "Error during ReadFrom: %v", err)
+
+			// Closing the socket is not an error.
+			if !errors.Is(err, net.ErrClosed) {
+				reportError(errs, err)
+			}
+
+			return
+		}
+
+		// Discard empty frames, but log.
+		if len == 0 {
+			util.Log(pc, "Empty frame discarded")
+			continue
+		}
+
+		var ifi *net.Interface
+		if cm != nil {
+			ifi = byIndex[cm.IfIndex]
+		}
+
+		// Packets may arrive on interfaces that have not joined the group, e.g. through loopback.
+		if ifi == nil {
+			util.Log(pc, "Packet from %v on unknown interface discarded", sender)
+			continue
+		}
+
+		var payload Service
+
+		_, err = Unpack(buffer[:len], &payload)
+		if err != nil {
+			util.Log(pc, "Error during Unpack: %v", err)
+			reportError(errs, fmt.Errorf("unpacking packet from %v on %s: %w", sender, ifi.Name, err))
+
+			continue
+		}
+
+		inbound <- &InterfaceService{Payload: payload, Interface: ifi}
+	}
+}
+
 // serveTCPSocket is the receiver worker for a TCP socket.
-func serveTCPSocket(conn *net.TCPConn, _ *net.TCPAddr, inbound chan<- Service) {
+func serveTCPSocket(conn *net.TCPConn, _ *net.TCPAddr, inbound chan<- Service, errs chan<- error) {
 	util.Log(conn, "Started worker")
 	defer util.Log(conn, "Worker exited")
 
 	// A closed inbound channel indicates to its readers that the worker has terminated.
 	defer close(inbound)
+	defer close(errs)
 
 	connBuffer := bufio.NewReader(conn)
 
@@ -249,6 +472,12 @@ func serveTCPSocket(conn *net.TCPConn, _ *net.TCPAddr, inbound chan<- Service) {
 		header, err := connBuffer.Peek(6) // KNXnet/IP headers are 6 bytes long
 		if err != nil {
 			util.Log(conn, "Error during peeking header: %v", err)
+
+			// Closing the socket is not an error.
+			if !errors.Is(err, net.ErrClosed) {
+				reportError(errs, err)
+			}
+
 			return
 		}
 
@@ -260,6 +489,8 @@ func serveTCPSocket(conn *net.TCPConn, _ *net.TCPAddr, inbound chan<- Service) {
 		_, err = UnpackHeader(header, &serviceID, &totalLen)
 		if err != nil {
 			util.Log(conn, "Error during header inspection: %v", err)
+			reportError(errs, err)
+
 			return
 		}
 
@@ -268,6 +499,8 @@ func serveTCPSocket(conn *net.TCPConn, _ *net.TCPAddr, inbound chan<- Service) {
 		len, err := io.ReadFull(connBuffer, buffer)
 		if err != nil {
 			util.Log(conn, "Error during ReadFull: %v", err)
+			reportError(errs, err)
+
 			return
 		}
 
@@ -282,6 +515,8 @@ func serveTCPSocket(conn *net.TCPConn, _ *net.TCPAddr, inbound chan<- Service) {
 		_, err = Unpack(buffer[:len], &payload)
 		if err != nil {
 			util.Log(conn, "Error during Unpack: %v", err)
+			reportError(errs, fmt.Errorf("unpacking packet: %w", err))
+
 			continue
 		}
 
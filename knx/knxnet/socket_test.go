@@ -0,0 +1,64 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knxnet
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestTunnelSocket_Errors(t *testing.T) {
+	gateway, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Skipf("Cannot listen on loopback: %v", err)
+	}
+
+	defer func() {
+		_ = gateway.Close()
+	}()
+
+	sock, err := DialTunnelUDP(gateway.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Tell the gateway where the client is.
+	if err := sock.Send(&ConnStateReq{Channel: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	buffer := make([]byte, 64)
+
+	_, client, err := gateway.ReadFromUDP(buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A header with an invalid length.
+	if _, err := gateway.WriteToUDP([]byte{0x06, 0x10, 0x02, 0x08, 0x00, 0x02}, client); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-sock.Errors():
+		if err == nil {
+			t.Error("Expected an error")
+		}
+
+	case <-time.After(time.Second):
+		t.Fatal("No error has been reported")
+	}
+
+	if err := sock.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Closing is not an error.
+	for range sock.Inbound() {
+	}
+
+	if err, open := <-sock.Errors(); open {
+		t.Errorf("Unexpected error %v", err)
+	}
+}
@@ -0,0 +1,276 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package pcap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mobilarte/knx-exp/knx/cemi"
+	"github.com/mobilarte/knx-exp/knx/knxnet"
+)
+
+var (
+	client  = &net.UDPAddr{IP: net.IPv4(192, 168, 1, 20), Port: 50000}
+	gateway = &net.UDPAddr{IP: net.IPv4(192, 168, 1, 10), Port: 3671}
+)
+
+func makeTunnelReq(seqNumber uint8) *knxnet.TunnelReq {
+	return &knxnet.TunnelReq{Channel: 1, SeqNumber: seqNumber, Payload: &cemi.LDataReq{LData: cemi.LData{
+		Control1:    cemi.Control1StdFrame | cemi.Control1NoRepeat | cemi.Control1NoSysBroadcast,
+		Control2:    cemi.Control2GroupAddr | cemi.Control2Hops(6),
+		Destination: 0x0901,
+		Data:        &cemi.AppData{Command: cemi.GroupValueWrite, Data: []byte{1}},
+	}}}
+}
+
+// readAll returns all packets of the capture.
+func readAll(t *testing.T, r io.Reader) []Packet {
+	t.Helper()
+
+	reader, err := NewReader(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var packets []Packet
+
+	for {
+		packet, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return packets
+		} else if err != nil {
+			t.Fatal(err)
+		}
+
+		packets = append(packets, packet)
+	}
+}
+
+func TestWriter(t *testing.T) {
+	var buffer bytes.Buffer
+
+	writer, err := NewWriter(&buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2024, 5, 3, 3, 12, 0, 123456000, time.UTC)
+
+	if err := writer.WriteService(start, client, gateway, makeTunnelReq(7)); err != nil {
+		t.Fatal(err)
+	}
+
+	ack := &knxnet.TunnelRes{Channel: 1, SeqNumber: 7}
+	if err := writer.WriteService(start.Add(time.Millisecond), gateway, client, ack); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writer.WritePacket(start, client, gateway, []byte("not KNX")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writer.WritePacket(start, &net.UDPAddr{IP: net.IPv6loopback}, gateway, nil); err == nil {
+		t.Error("IPv6 has been accepted")
+	}
+
+	packets := readAll(t, &buffer)
+	if len(packets) != 2 {
+		t.Fatalf("Unexpected packets %+v", packets)
+	}
+
+	req, ok := packets[0].Service.(*knxnet.TunnelReq)
+	if !ok || req.SeqNumber != 7 || packets[0].Err != nil {
+		t.Errorf("Unexpected service %+v", packets[0].Service)
+	}
+
+	if !packets[0].Time.Equal(start) || packets[0].Source.String() != client.String() ||
+		packets[0].Destination.String() != gateway.String() || packets[0].TCP {
+		t.Errorf("Unexpected packet %+v", packets[0])
+	}
+
+	if _, ok := packets[1].Service.(*knxnet.TunnelRes); !ok || !packets[1].Time.Equal(start.Add(time.Millisecond)) {
+		t.Errorf("Unexpected packet %+v", packets[1])
+	}
+}
+
+func TestReader_MalformedPayload(t *testing.T) {
+	var buffer bytes.Buffer
+
+	writer, err := NewWriter(&buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Description responses with a zero and an oversized DIB length.
+	payloads := [][]byte{
+		{0x06, 0x10, 0x02, 0x04, 0x00, 0x0a, 0x00, 0x07, 0x00, 0x00},
+		{0x06, 0x10, 0x02, 0x04, 0x00, 0x0a, 0xff, 0x01, 0x00, 0x00},
+	}
+
+	for _, payload := range payloads {
+		if err := writer.WritePacket(time.Now(), gateway, client, payload); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	packets := readAll(t, &buffer)
+	if len(packets) != len(payloads) {
+		t.Fatalf("Unexpected packets %+v", packets)
+	}
+
+	for i, packet := range packets {
+		if packet.Err == nil || packet.Service != nil || !bytes.Equal(packet.Data, payloads[i]) {
+			t.Errorf("Unexpected packet %+v", packet)
+		}
+	}
+}
+
+// ipv4Packet builds an IPv4 packet with the given protocol and transport header.
+func ipv4Packet(protocol byte, transport []byte) []byte {
+	packet := make([]byte, ipv4HeaderSize, ipv4HeaderSize+len(transport))
+	packet[0] = 0x45
+	binary.BigEndian.PutUint16(packet[2:], uint16(ipv4HeaderSize+len(transport)))
+	packet[8] = 64
+	packet[9] = protocol
+	copy(packet[12:], gateway.IP.To4())
+	copy(packet[16:], client.IP.To4())
+
+	return append(packet, transport...)
+}
+
+func TestReader_Pcap(t *testing.T) {
+	first := knxnet.AllocAndPack(makeTunnelReq(1))
+	second := knxnet.AllocAndPack(makeTunnelReq(2))
+
+	udp := make([]byte, udpHeaderSize)
+	binary.BigEndian.PutUint16(udp[0:], 3671)
+	binary.BigEndian.PutUint16(udp[2:], 50000)
+	binary.BigEndian.PutUint16(udp[4:], uint16(udpHeaderSize+len(first)))
+
+	tcp := make([]byte, 20)
+	binary.BigEndian.PutUint16(tcp[0:], 3671)
+	binary.BigEndian.PutUint16(tcp[2:], 50000)
+	tcp[12] = 5 << 4
+
+	// Ethernet with a VLAN tag.
+	ethernet := []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 0x81, 0x00, 0, 1, 0x08, 0x00}
+
+	frames := [][]byte{
+		append(append([]byte(nil), ethernet...), ipv4Packet(17, append(udp, first...))...),
+		append(append([]byte(nil), ethernet...), ipv4Packet(17, append(udp[:udpHeaderSize:udpHeaderSize], "DNS"...))...),
+		append(append([]byte(nil), ethernet...), ipv4Packet(6, append(append(tcp, first...), second...))...),
+	}
+
+	var buffer bytes.Buffer
+
+	header := make([]byte, 24)
+	binary.BigEndian.PutUint32(header[0:], pcapMagicNanos)
+	binary.BigEndian.PutUint16(header[4:], 2)
+	binary.BigEndian.PutUint16(header[6:], 4)
+	binary.BigEndian.PutUint32(header[16:], 0xffff)
+	binary.BigEndian.PutUint32(header[20:], linkTypeEthernet)
+	buffer.Write(header)
+
+	for i, frame := range frames {
+		record := make([]byte, 16)
+		binary.BigEndian.PutUint32(record[0:], 1714706000)
+		binary.BigEndian.PutUint32(record[4:], uint32(i))
+		binary.BigEndian.PutUint32(record[8:], uint32(len(frame)))
+		binary.BigEndian.PutUint32(record[12:], uint32(len(frame)))
+		buffer.Write(record)
+		buffer.Write(frame)
+	}
+
+	packets := readAll(t, &buffer)
+	if len(packets) != 3 {
+		t.Fatalf("Unexpected packets %+v", packets)
+	}
+
+	if !packets[0].Time.Equal(time.Unix(1714706000, 0)) || packets[0].TCP || packets[0].Source.Port != 3671 {
+		t.Errorf("Unexpected packet %+v", packets[0])
+	}
+
+	for i, packet := range packets[1:] {
+		req, ok := packet.Service.(*knxnet.TunnelReq)
+		if !ok || !packet.TCP || req.SeqNumber != uint8(i+1) || packet.Time.Nanosecond() != 2 {
+			t.Errorf("Unexpected packet %+v", packet)
+		}
+	}
+
+	t.Run("Invalid", func(t *testing.T) {
+		if _, err := NewReader(strings.NewReader("hello world")); !errors.Is(err, ErrFormat) {
+			t.Errorf("Unexpected error %v", err)
+		}
+	})
+}
+
+// fakeSocket is a knxnet.Socket which hands the packets over to the test.
+type fakeSocket struct {
+	sent    []knxnet.ServicePackable
+	inbound chan knxnet.Service
+}
+
+func (sock *fakeSocket) Send(payload knxnet.ServicePackable) error {
+	sock.sent = append(sock.sent, payload)
+	return nil
+}
+
+func (sock *fakeSocket) Inbound() <-chan knxnet.Service {
+	return sock.inbound
+}
+
+func (sock *fakeSocket) Close() error {
+	close(sock.inbound)
+	return nil
+}
+
+func (sock *fakeSocket) LocalAddr() net.Addr {
+	return client
+}
+
+func TestSocket(t *testing.T) {
+	var buffer bytes.Buffer
+
+	writer, err := NewWriter(&buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fake := &fakeSocket{inbound: make(chan knxnet.Service)}
+	sock := NewSocket(fake, writer, gateway)
+
+	if err := sock.Send(makeTunnelReq(3)); err != nil {
+		t.Fatal(err)
+	}
+
+	fake.inbound <- &knxnet.TunnelRes{Channel: 1, SeqNumber: 3}
+
+	if _, ok := (<-sock.Inbound()).(*knxnet.TunnelRes); !ok {
+		t.Error("Unexpected inbound service")
+	}
+
+	if err := sock.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, open := <-sock.Inbound(); open {
+		t.Error("Inbound channel is still open")
+	}
+
+	if len(fake.sent) != 1 || sock.Err() != nil {
+		t.Fatalf("Unexpected state %v %v", fake.sent, sock.Err())
+	}
+
+	packets := readAll(t, &buffer)
+	if len(packets) != 2 || packets[0].Destination.String() != gateway.String() ||
+		packets[1].Source.String() != gateway.String() {
+		t.Errorf("Unexpected packets %+v", packets)
+	}
+}
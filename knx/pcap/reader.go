@@ -0,0 +1,452 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package pcap
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+	"net"
+	"time"
+
+	"github.com/mobilarte/knx-exp/knx/knxnet"
+)
+
+// ErrFormat is returned when the capture is neither pcap nor pcapng.
+var ErrFormat = errors.New("not a pcap or pcapng capture")
+
+// These are the magic numbers of classic pcap files.
+const (
+	pcapMagicMicros = 0xa1b2c3d4
+	pcapMagicNanos  = 0xa1b23c4d
+)
+
+// A Packet is a KNXnet/IP packet of a capture.
+type Packet struct {
+	Time        time.Time
+	Source      *net.UDPAddr
+	Destination *net.UDPAddr
+
+	// TCP indicates that the packet has been carried by TCP rather than UDP.
+	TCP bool
+
+	// Data is the entire KNXnet/IP packet.
+	Data []byte
+
+	// Service is the unpacked packet. It is nil if the packet is malformed, in which case Err
+	// tells why.
+	Service knxnet.Service
+	Err     error
+}
+
+// pcapInterface is an interface of a capture.
+type pcapInterface struct {
+	linkType uint16
+
+	// Number of timestamp units per second
+	units uint64
+}
+
+// A Reader extracts the KNXnet/IP packets from a pcap or pcapng capture. Packets on all ports are
+// considered; those that carry a valid KNXnet/IP header are returned.
+type Reader struct {
+	r  io.Reader
+	ng bool
+
+	// Byte order of the current section or file
+	order binary.ByteOrder
+
+	interfaces []pcapInterface
+
+	// KNXnet/IP packets of the current frame that have not been returned yet
+	pending []Packet
+}
+
+// NewReader detects the format of the capture and reads its header.
+func NewReader(r io.Reader) (*Reader, error) {
+	reader := &Reader{r: r}
+
+	var magic [4]byte
+	if _, err := io.ReadFull(r, magic[:]); err != nil {
+		return nil, ErrFormat
+	}
+
+	switch {
+	case binary.LittleEndian.Uint32(magic[:]) == blockSectionHeader:
+		reader.ng = true
+
+		var head [8]byte
+		if _, err := io.ReadFull(r, head[:]); err != nil {
+			return nil, fmt.Errorf("reading section header: %w", err)
+		}
+
+		if err := reader.readSectionHeader(head); err != nil {
+			return nil, err
+		}
+
+	case binary.LittleEndian.Uint32(magic[:]) == pcapMagicMicros,
+		binary.LittleEndian.Uint32(magic[:]) == pcapMagicNanos:
+		reader.order = binary.LittleEndian
+
+	case binary.BigEndian.Uint32(magic[:]) == pcapMagicMicros,
+		binary.BigEndian.Uint32(magic[:]) == pcapMagicNanos:
+		reader.order = binary.BigEndian
+
+	default:
+		return nil, ErrFormat
+	}
+
+	if !reader.ng {
+		var header [20]byte
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			return nil, fmt.Errorf("reading pcap header: %w", err)
+		}
+
+		units := uint64(1e6)
+		if reader.order.Uint32(magic[:]) == pcapMagicNanos {
+			units = 1e9
+		}
+
+		reader.interfaces = []pcapInterface{{
+			linkType: uint16(reader.order.Uint32(header[16:])),
+			units:    units,
+		}}
+	}
+
+	return reader, nil
+}
+
+// Next returns the next KNXnet/IP packet, or io.EOF at the end of the capture.
+func (reader *Reader) Next() (Packet, error) {
+	for len(reader.pending) == 0 {
+		var err error
+
+		if reader.ng {
+			err = reader.nextBlock()
+		} else {
+			err = reader.nextRecord()
+		}
+
+		if err != nil {
+			return Packet{}, err
+		}
+	}
+
+	packet := reader.pending[0]
+	reader.pending = reader.pending[1:]
+
+	return packet, nil
+}
+
+// readSectionHeader reads the rest of a section header block. The head contains the block length
+// and the byte-order magic, which determines how to interpret the length.
+func (reader *Reader) readSectionHeader(head [8]byte) error {
+	switch {
+	case binary.LittleEndian.Uint32(head[4:]) == byteOrderMagic:
+		reader.order = binary.LittleEndian
+	case binary.BigEndian.Uint32(head[4:]) == byteOrderMagic:
+		reader.order = binary.BigEndian
+	default:
+		return ErrFormat
+	}
+
+	total := reader.order.Uint32(head[0:])
+	if total < 28 || total%4 != 0 {
+		return fmt.Errorf("invalid section header length %d", total)
+	}
+
+	// Interfaces are numbered per section.
+	reader.interfaces = nil
+
+	_, err := io.CopyN(io.Discard, reader.r, int64(total)-12)
+
+	return err
+}
+
+// nextBlock reads the next pcapng block.
+func (reader *Reader) nextBlock() error {
+	var head [8]byte
+	if _, err := io.ReadFull(reader.r, head[:]); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return fmt.Errorf("truncated block: %w", err)
+		}
+
+		return err
+	}
+
+	// The byte order may change with a new section.
+	if binary.LittleEndian.Uint32(head[:]) == blockSectionHeader {
+		var shb [8]byte
+
+		copy(shb[:], head[4:])
+
+		if _, err := io.ReadFull(reader.r, shb[4:]); err != nil {
+			return fmt.Errorf("reading section header: %w", err)
+		}
+
+		return reader.readSectionHeader(shb)
+	}
+
+	blockType := reader.order.Uint32(head[0:])
+	total := reader.order.Uint32(head[4:])
+
+	if total < 12 || total%4 != 0 {
+		return fmt.Errorf("invalid block length %d", total)
+	}
+
+	block := make([]byte, total-8)
+	if _, err := io.ReadFull(reader.r, block); err != nil {
+		return fmt.Errorf("reading block: %w", err)
+	}
+
+	body := block[:len(block)-4]
+
+	switch blockType {
+	case blockInterfaceDesc:
+		if len(body) < 8 {
+			return errors.New("interface description is too short")
+		}
+
+		reader.interfaces = append(reader.interfaces, pcapInterface{
+			linkType: reader.order.Uint16(body[0:]),
+			units:    reader.units(body[8:]),
+		})
+
+	case blockEnhancedPacket:
+		if len(body) < 20 {
+			return errors.New("packet block is too short")
+		}
+
+		id := reader.order.Uint32(body[0:])
+		if id >= uint32(len(reader.interfaces)) {
+			return fmt.Errorf("packet refers to unknown interface %d", id)
+		}
+
+		ifc := reader.interfaces[id]
+		ts := uint64(reader.order.Uint32(body[4:]))<<32 | uint64(reader.order.Uint32(body[8:]))
+		captured := reader.order.Uint32(body[12:])
+
+		if captured > uint32(len(body)-20) {
+			return errors.New("packet exceeds its block")
+		}
+
+		reader.decode(ifc.linkType, timestamp(ts, ifc.units), body[20:20+captured])
+
+	case blockSimplePacket:
+		if len(reader.interfaces) == 0 || len(body) < 4 {
+			return errors.New("simple packet without interface")
+		}
+
+		// Simple packets have no timestamp.
+		reader.decode(reader.interfaces[0].linkType, time.Time{}, body[4:])
+	}
+
+	return nil
+}
+
+// units determines the timestamp resolution from the options of an interface description.
+func (reader *Reader) units(options []byte) uint64 {
+	for len(options) >= 4 {
+		code := reader.order.Uint16(options[0:])
+		length := int(reader.order.Uint16(options[2:]))
+
+		if code == optionEndOfOpt || 4+pad4(length) > len(options) {
+			break
+		}
+
+		if code == optionInterfaceTimestamp && length >= 1 {
+			value := options[4]
+
+			// Resolutions beyond uint64 are not supported.
+			if value&0x80 != 0 && value&0x7f < 64 {
+				return 1 << (value & 0x7f)
+			} else if value&0x80 == 0 && value < 20 {
+				units := uint64(1)
+				for range value {
+					units *= 10
+				}
+
+				return units
+			}
+		}
+
+		options = options[4+pad4(length):]
+	}
+
+	return 1e6
+}
+
+// nextRecord reads the next record of a classic pcap file.
+func (reader *Reader) nextRecord() error {
+	var head [16]byte
+	if _, err := io.ReadFull(reader.r, head[:]); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return fmt.Errorf("truncated record: %w", err)
+		}
+
+		return err
+	}
+
+	captured := reader.order.Uint32(head[8:])
+	if captured > 0x40000 {
+		return fmt.Errorf("invalid record length %d", captured)
+	}
+
+	data := make([]byte, captured)
+	if _, err := io.ReadFull(reader.r, data); err != nil {
+		return fmt.Errorf("reading record: %w", err)
+	}
+
+	ifc := reader.interfaces[0]
+	fraction := uint64(reader.order.Uint32(head[4:]))
+	ts := time.Unix(int64(reader.order.Uint32(head[0:])), int64(fraction*uint64(time.Second)/ifc.units))
+
+	reader.decode(ifc.linkType, ts, data)
+
+	return nil
+}
+
+// timestamp converts a pcapng timestamp.
+func timestamp(ts, units uint64) time.Time {
+	// The fraction times 1e9 may exceed 64 bits.
+	hi, lo := bits.Mul64(ts%units, uint64(time.Second))
+	nanos, _ := bits.Div64(hi, lo, units)
+
+	return time.Unix(int64(ts/units), int64(nanos))
+}
+
+// decode extracts the KNXnet/IP packets from a captured frame.
+func (reader *Reader) decode(linkType uint16, ts time.Time, frame []byte) {
+	ip := linkPayload(linkType, frame)
+	if len(ip) < ipv4HeaderSize || ip[0]>>4 != 4 {
+		return
+	}
+
+	headerSize := int(ip[0]&0x0f) * 4
+	totalSize := int(binary.BigEndian.Uint16(ip[2:]))
+
+	// Fragments other than the first cannot be decoded on their own.
+	if headerSize < ipv4HeaderSize || totalSize < headerSize || totalSize > len(ip) ||
+		binary.BigEndian.Uint16(ip[6:])&0x1fff != 0 {
+		return
+	}
+
+	src, dst := net.IP(ip[12:16]), net.IP(ip[16:20])
+	transport := ip[headerSize:totalSize]
+
+	var (
+		srcPort, dstPort int
+		payload          []byte
+		tcp              bool
+	)
+
+	switch ip[9] {
+	case 17:
+		if len(transport) < udpHeaderSize {
+			return
+		}
+
+		srcPort = int(binary.BigEndian.Uint16(transport[0:]))
+		dstPort = int(binary.BigEndian.Uint16(transport[2:]))
+		payload = transport[udpHeaderSize:]
+
+	case 6:
+		if len(transport) < 20 || int(transport[12]>>4)*4 > len(transport) {
+			return
+		}
+
+		srcPort = int(binary.BigEndian.Uint16(transport[0:]))
+		dstPort = int(binary.BigEndian.Uint16(transport[2:]))
+		payload = transport[int(transport[12]>>4)*4:]
+		tcp = true
+
+	default:
+		return
+	}
+
+	// A TCP segment may carry several packets. Packets that span segments are not reassembled.
+	for len(payload) >= 6 {
+		var (
+			service  knxnet.ServiceID
+			totalLen uint16
+		)
+
+		if _, err := knxnet.UnpackHeader(payload, &service, &totalLen); err != nil ||
+			int(totalLen) < 6 || int(totalLen) > len(payload) {
+			return
+		}
+
+		packet := Packet{
+			Time:        ts,
+			Source:      &net.UDPAddr{IP: append(net.IP(nil), src...), Port: srcPort},
+			Destination: &net.UDPAddr{IP: append(net.IP(nil), dst...), Port: dstPort},
+			TCP:         tcp,
+			Data:        append([]byte(nil), payload[:totalLen]...),
+		}
+
+		if _, err := knxnet.Unpack(packet.Data, &packet.Service); err != nil {
+			packet.Service = nil
+			packet.Err = err
+		}
+
+		reader.pending = append(reader.pending, packet)
+		payload = payload[totalLen:]
+
+		if !tcp {
+			return
+		}
+	}
+}
+
+// linkPayload strips the link layer header, if the frame carries IPv4.
+func linkPayload(linkType uint16, frame []byte) []byte {
+	switch linkType {
+	case linkTypeRaw, linkTypeIPv4:
+		return frame
+
+	case linkTypeNull:
+		// The address family is in host byte order.
+		if len(frame) < 4 || (binary.LittleEndian.Uint32(frame) != 2 && binary.BigEndian.Uint32(frame) != 2) {
+			return nil
+		}
+
+		return frame[4:]
+
+	case linkTypeEthernet:
+		if len(frame) < 14 {
+			return nil
+		}
+
+		etherType, offset := binary.BigEndian.Uint16(frame[12:]), 14
+
+		// Skip VLAN tags.
+		for (etherType == 0x8100 || etherType == 0x88a8) && len(frame) >= offset+4 {
+			etherType = binary.BigEndian.Uint16(frame[offset+2:])
+			offset += 4
+		}
+
+		if etherType != 0x0800 {
+			return nil
+		}
+
+		return frame[offset:]
+
+	case linkTypeLinuxSLL:
+		if len(frame) < 16 || binary.BigEndian.Uint16(frame[14:]) != 0x0800 {
+			return nil
+		}
+
+		return frame[16:]
+
+	case linkTypeLinuxSL2:
+		if len(frame) < 20 || binary.BigEndian.Uint16(frame[0:]) != 0x0800 {
+			return nil
+		}
+
+		return frame[20:]
+	}
+
+	return nil
+}
@@ -0,0 +1,94 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package pcap
+
+import (
+	"net"
+	"time"
+
+	"github.com/mobilarte/knx-exp/knx/knxnet"
+	"github.com/mobilarte/knx-exp/knx/util"
+)
+
+// A Socket is a knxnet.Socket which captures the packets of the socket that it wraps. Use it
+// wherever the original socket would be used; close it to close the original socket.
+type Socket struct {
+	knxnet.Socket
+
+	writer  *Writer
+	local   *net.UDPAddr
+	remote  *net.UDPAddr
+	inbound chan knxnet.Service
+}
+
+// NewSocket starts capturing the packets of the socket. The remote address is the peer of the
+// socket, e.g. the gateway or the routing multicast group, since the socket does not tell. The
+// Socket consumes the Inbound channel of the socket.
+func NewSocket(sock knxnet.Socket, writer *Writer, remote *net.UDPAddr) *Socket {
+	local, _ := net.ResolveUDPAddr("udp4", sock.LocalAddr().String())
+	if local == nil || local.IP.To4() == nil || local.IP.IsUnspecified() {
+		// The capture needs some IPv4 address.
+		port := 0
+		if local != nil {
+			port = local.Port
+		}
+
+		local = &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port}
+	}
+
+	capture := &Socket{
+		Socket:  sock,
+		writer:  writer,
+		local:   local,
+		remote:  remote,
+		inbound: make(chan knxnet.Service),
+	}
+
+	go capture.serve()
+
+	return capture
+}
+
+// Send captures the packet and transmits it.
+func (sock *Socket) Send(payload knxnet.ServicePackable) error {
+	sock.capture(sock.local, sock.remote, payload)
+
+	return sock.Socket.Send(payload)
+}
+
+// Inbound provides a channel from which you can retrieve the captured incoming packets.
+func (sock *Socket) Inbound() <-chan knxnet.Service {
+	return sock.inbound
+}
+
+// Err returns the error that has stopped the capture, if any.
+func (sock *Socket) Err() error {
+	return sock.writer.Err()
+}
+
+// capture writes the packet. Failures are remembered by the writer.
+func (sock *Socket) capture(src, dst *net.UDPAddr, srv knxnet.Service) {
+	// Packets from several interfaces are captured as if they came from one.
+	if ifs, ok := srv.(*knxnet.InterfaceService); ok {
+		srv = ifs.Payload
+	}
+
+	packable, ok := srv.(knxnet.ServicePackable)
+	if !ok {
+		util.Log(sock, "Cannot capture service %v", srv.Service())
+		return
+	}
+
+	_ = sock.writer.WriteService(time.Now(), src, dst, packable)
+}
+
+// serve captures the incoming packets.
+func (sock *Socket) serve() {
+	defer close(sock.inbound)
+
+	for msg := range sock.Socket.Inbound() {
+		sock.capture(sock.remote, sock.local, msg)
+
+		sock.inbound <- msg
+	}
+}
@@ -0,0 +1,183 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+// Package pcap exports KNXnet/IP traffic to pcapng captures, which Wireshark's KNXnet/IP dissector
+// decodes, and imports KNXnet/IP services from pcap and pcapng captures.
+package pcap
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/mobilarte/knx-exp/knx/knxnet"
+)
+
+// These are the pcapng block types.
+const (
+	blockSectionHeader       = 0x0a0d0d0a
+	blockInterfaceDesc       = 0x00000001
+	blockSimplePacket        = 0x00000003
+	blockEnhancedPacket      = 0x00000006
+	byteOrderMagic           = 0x1a2b3c4d
+	optionEndOfOpt           = 0
+	optionInterfaceTimestamp = 9
+)
+
+// These are the link types that the reader understands.
+const (
+	linkTypeNull     = 0
+	linkTypeEthernet = 1
+	linkTypeRaw      = 101
+	linkTypeLinuxSLL = 113
+	linkTypeIPv4     = 228
+	linkTypeLinuxSL2 = 276
+)
+
+// These are the sizes of the synthetic headers.
+const (
+	ipv4HeaderSize = 20
+	udpHeaderSize  = 8
+	maxPacketSize  = 0xffff - ipv4HeaderSize - udpHeaderSize
+)
+
+// A Writer writes a pcapng capture with a single interface of raw IPv4 packets. Each KNXnet/IP
+// packet gets synthetic IPv4 and UDP headers. It is safe for concurrent use.
+type Writer struct {
+	mu  sync.Mutex
+	w   io.Writer
+	id  uint16
+	err error
+}
+
+// NewWriter starts a capture by writing the section header and the interface description.
+func NewWriter(w io.Writer) (*Writer, error) {
+	writer := &Writer{w: w}
+
+	shb := make([]byte, 16)
+	binary.LittleEndian.PutUint32(shb[0:], byteOrderMagic)
+	binary.LittleEndian.PutUint16(shb[4:], 1)
+	binary.LittleEndian.PutUint16(shb[6:], 0)
+	// The length of the section is not known.
+	binary.LittleEndian.PutUint64(shb[8:], ^uint64(0))
+
+	idb := make([]byte, 8)
+	binary.LittleEndian.PutUint16(idb[0:], linkTypeRaw)
+	binary.LittleEndian.PutUint32(idb[4:], 0)
+
+	if err := writer.block(blockSectionHeader, shb); err != nil {
+		return nil, err
+	}
+
+	if err := writer.block(blockInterfaceDesc, idb); err != nil {
+		return nil, err
+	}
+
+	return writer, nil
+}
+
+// WritePacket appends a UDP packet with the given payload. Once writing has failed, all further
+// writes fail with the same error.
+func (writer *Writer) WritePacket(ts time.Time, src, dst *net.UDPAddr, payload []byte) error {
+	srcIP, dstIP := src.IP.To4(), dst.IP.To4()
+	if srcIP == nil || dstIP == nil {
+		return fmt.Errorf("only IPv4 can be captured: %v -> %v", src, dst)
+	}
+
+	if len(payload) > maxPacketSize {
+		return fmt.Errorf("payload of %d bytes is too large", len(payload))
+	}
+
+	writer.mu.Lock()
+	defer writer.mu.Unlock()
+
+	if writer.err != nil {
+		return writer.err
+	}
+
+	writer.id++
+
+	packet := make([]byte, ipv4HeaderSize+udpHeaderSize+len(payload))
+
+	ip := packet[:ipv4HeaderSize]
+	ip[0] = 0x45
+	binary.BigEndian.PutUint16(ip[2:], uint16(len(packet)))
+	binary.BigEndian.PutUint16(ip[4:], writer.id)
+	ip[8] = 64
+	ip[9] = 17
+	copy(ip[12:], srcIP)
+	copy(ip[16:], dstIP)
+	binary.BigEndian.PutUint16(ip[10:], checksum(ip))
+
+	// The UDP checksum is optional over IPv4.
+	udp := packet[ipv4HeaderSize:]
+	binary.BigEndian.PutUint16(udp[0:], uint16(src.Port))
+	binary.BigEndian.PutUint16(udp[2:], uint16(dst.Port))
+	binary.BigEndian.PutUint16(udp[4:], uint16(udpHeaderSize+len(payload)))
+	copy(udp[udpHeaderSize:], payload)
+
+	// Timestamps are in microseconds, the default resolution.
+	micros := uint64(ts.UnixMicro())
+
+	epb := make([]byte, 20+pad4(len(packet)))
+	binary.LittleEndian.PutUint32(epb[0:], 0)
+	binary.LittleEndian.PutUint32(epb[4:], uint32(micros>>32))
+	binary.LittleEndian.PutUint32(epb[8:], uint32(micros))
+	binary.LittleEndian.PutUint32(epb[12:], uint32(len(packet)))
+	binary.LittleEndian.PutUint32(epb[16:], uint32(len(packet)))
+	copy(epb[20:], packet)
+
+	writer.err = writer.block(blockEnhancedPacket, epb)
+
+	return writer.err
+}
+
+// WriteService appends a KNXnet/IP packet that carries the service.
+func (writer *Writer) WriteService(ts time.Time, src, dst *net.UDPAddr, srv knxnet.ServicePackable) error {
+	return writer.WritePacket(ts, src, dst, knxnet.AllocAndPack(srv))
+}
+
+// Err returns the error that has stopped the capture, if any.
+func (writer *Writer) Err() error {
+	writer.mu.Lock()
+	defer writer.mu.Unlock()
+
+	return writer.err
+}
+
+// block writes a pcapng block. The body must be padded to 32 bits.
+func (writer *Writer) block(blockType uint32, body []byte) error {
+	total := uint32(12 + len(body))
+
+	buffer := make([]byte, total)
+	binary.LittleEndian.PutUint32(buffer[0:], blockType)
+	binary.LittleEndian.PutUint32(buffer[4:], total)
+	copy(buffer[8:], body)
+	binary.LittleEndian.PutUint32(buffer[total-4:], total)
+
+	_, err := writer.w.Write(buffer)
+
+	return err
+}
+
+// pad4 rounds up to a multiple of 4.
+func pad4(n int) int {
+	return (n + 3) &^ 3
+}
+
+// checksum computes the Internet checksum of the header.
+func checksum(header []byte) uint16 {
+	var sum uint32
+
+	for i := 0; i+1 < len(header); i += 2 {
+		sum += uint32(binary.BigEndian.Uint16(header[i:]))
+	}
+
+	for sum > 0xffff {
+		sum = sum&0xffff + sum>>16
+	}
+
+	return ^uint16(sum)
+}
@@ -0,0 +1,143 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knx
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// UnlimitedReconnectAttempts can be used as ReconnectPolicy.MaxAttempts in order to never give up
+// reconnecting.
+const UnlimitedReconnectAttempts = -1
+
+// A ReconnectPolicy determines how a Tunnel tries to re-establish a connection that has been
+// lost, either because the heartbeat failed or because the gateway disconnected. The first attempt
+// is made immediately, every following attempt is delayed with an exponential backoff.
+type ReconnectPolicy struct {
+	// MaxAttempts is the number of attempts before the Tunnel gives up and closes. Use
+	// UnlimitedReconnectAttempts to never give up.
+	MaxAttempts int
+
+	// InitialDelay is the delay before the second attempt.
+	InitialDelay time.Duration
+
+	// MaxDelay caps the delay between two attempts.
+	MaxDelay time.Duration
+
+	// Multiplier is the factor by which the delay grows after each failed attempt.
+	Multiplier float64
+
+	// Jitter randomizes each delay by up to the given fraction in both directions, e.g. 0.2
+	// yields delays between 80% and 120% of the nominal delay.
+	Jitter float64
+}
+
+// DefaultReconnectPolicy tries once to reconnect.
+var DefaultReconnectPolicy = ReconnectPolicy{
+	MaxAttempts:  1,
+	InitialDelay: 1 * time.Second,
+	MaxDelay:     30 * time.Second,
+	Multiplier:   2,
+	Jitter:       0.2,
+}
+
+// checkReconnectPolicy makes sure that the policy is actually usable.
+func checkReconnectPolicy(policy ReconnectPolicy) ReconnectPolicy {
+	if policy.MaxAttempts == 0 {
+		policy.MaxAttempts = DefaultReconnectPolicy.MaxAttempts
+	}
+
+	if policy.InitialDelay <= 0 {
+		policy.InitialDelay = DefaultReconnectPolicy.InitialDelay
+	}
+
+	if policy.MaxDelay < policy.InitialDelay {
+		policy.MaxDelay = max(DefaultReconnectPolicy.MaxDelay, policy.InitialDelay)
+	}
+
+	if policy.Multiplier < 1 {
+		policy.Multiplier = DefaultReconnectPolicy.Multiplier
+	}
+
+	policy.Jitter = min(max(policy.Jitter, 0), 1)
+
+	return policy
+}
+
+// allows determines whether the given attempt (starting at 1) may be made.
+func (policy ReconnectPolicy) allows(attempt int) bool {
+	return policy.MaxAttempts < 0 || attempt <= policy.MaxAttempts
+}
+
+// delay computes how long to wait before the given attempt (starting at 1).
+func (policy ReconnectPolicy) delay(attempt int) time.Duration {
+	if attempt <= 1 {
+		return 0
+	}
+
+	delay := float64(policy.InitialDelay)
+	for i := 2; i < attempt && delay < float64(policy.MaxDelay); i++ {
+		delay *= policy.Multiplier
+	}
+
+	delay = min(delay, float64(policy.MaxDelay))
+
+	if policy.Jitter > 0 {
+		delay *= 1 + policy.Jitter*(2*rand.Float64()-1)
+	}
+
+	return time.Duration(delay)
+}
+
+// ConnState is the state of a Tunnel's connection to the gateway.
+type ConnState uint8
+
+// These are the states that a Tunnel's connection goes through.
+const (
+	// StateConnecting is emitted before the initial connection request.
+	StateConnecting ConnState = iota
+
+	// StateConnected is emitted once a connection has been established.
+	StateConnected
+
+	// StateReconnecting is emitted before each reconnect attempt.
+	StateReconnecting
+
+	// StateClosed is emitted once the Tunnel has terminated for good.
+	StateClosed
+)
+
+// String generates a string representation of the state.
+func (state ConnState) String() string {
+	switch state {
+	case StateConnecting:
+		return "Connecting"
+
+	case StateConnected:
+		return "Connected"
+
+	case StateReconnecting:
+		return "Reconnecting"
+
+	case StateClosed:
+		return "Closed"
+	}
+
+	return fmt.Sprintf("Unknown state %d", uint8(state))
+}
+
+// A StateEvent describes a change of a Tunnel's connection state.
+type StateEvent struct {
+	State ConnState
+
+	// Attempt is the number of the reconnect attempt, starting at 1. It is 0 for events that do
+	// not belong to a reconnect.
+	Attempt int
+
+	// Err is the cause of the event. For StateReconnecting, it is the error that lost the
+	// connection or made the previous attempt fail. For StateClosed, it is nil if the Tunnel
+	// has been closed by its user.
+	Err error
+}
@@ -0,0 +1,63 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knx
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReconnectPolicy_delay(t *testing.T) {
+	t.Run("Backoff", func(t *testing.T) {
+		policy := ReconnectPolicy{
+			MaxAttempts:  UnlimitedReconnectAttempts,
+			InitialDelay: 100 * time.Millisecond,
+			MaxDelay:     time.Second,
+			Multiplier:   2,
+		}
+
+		expected := []time.Duration{
+			0,
+			100 * time.Millisecond,
+			200 * time.Millisecond,
+			400 * time.Millisecond,
+			800 * time.Millisecond,
+			time.Second,
+			time.Second,
+		}
+
+		for i, want := range expected {
+			if got := policy.delay(i + 1); got != want {
+				t.Errorf("Attempt %d: expected delay %v, got %v", i+1, want, got)
+			}
+		}
+	})
+
+	t.Run("Jitter", func(t *testing.T) {
+		policy := ReconnectPolicy{
+			InitialDelay: 100 * time.Millisecond,
+			MaxDelay:     time.Second,
+			Multiplier:   2,
+			Jitter:       0.5,
+		}
+
+		for range 100 {
+			got := policy.delay(3)
+			if got < 100*time.Millisecond || got > 300*time.Millisecond {
+				t.Fatalf("Delay %v is out of the jitter range", got)
+			}
+		}
+	})
+}
+
+func TestReconnectPolicy_allows(t *testing.T) {
+	policy := checkReconnectPolicy(ReconnectPolicy{})
+	if !policy.allows(1) || policy.allows(2) {
+		t.Error("Default policy should allow exactly one attempt")
+	}
+
+	policy = checkReconnectPolicy(ReconnectPolicy{MaxAttempts: UnlimitedReconnectAttempts})
+	if !policy.allows(1000) {
+		t.Error("Unlimited policy should allow any attempt")
+	}
+}
@@ -0,0 +1,217 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+// Package record captures the cEMI traffic of a KNX client into a file and replays it later.
+//
+// A recording is line-delimited JSON. The first line is a header which names the format and its
+// version; each further line is one frame with its timestamp and direction:
+//
+//	{"format":"knx-exp/telegrams","version":1}
+//	{"time":"2024-05-03T03:12:00.123456789Z","dir":"in","cemi":"2900bce01101090101008001"}
+//
+// The frame is the hex encoded cEMI message, including the message code.
+package record
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/mobilarte/knx-exp/knx/cemi"
+)
+
+// These identify the format of a recording.
+const (
+	Format  = "knx-exp/telegrams"
+	Version = 1
+)
+
+// ErrFormat is returned when reading something that is not a recording of a supported version.
+var ErrFormat = errors.New("not a supported telegram recording")
+
+// Direction tells whether a frame has been received or sent by the client.
+type Direction uint8
+
+// These are the directions of a frame.
+const (
+	Inbound Direction = iota
+	Outbound
+)
+
+// String generates a string representation of the direction.
+func (dir Direction) String() string {
+	switch dir {
+	case Inbound:
+		return "in"
+
+	case Outbound:
+		return "out"
+	}
+
+	return "unknown"
+}
+
+// MarshalText encodes the direction as "in" or "out".
+func (dir Direction) MarshalText() ([]byte, error) {
+	if dir != Inbound && dir != Outbound {
+		return nil, fmt.Errorf("invalid direction %d", dir)
+	}
+
+	return []byte(dir.String()), nil
+}
+
+// UnmarshalText decodes "in" or "out".
+func (dir *Direction) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "in":
+		*dir = Inbound
+
+	case "out":
+		*dir = Outbound
+
+	default:
+		return fmt.Errorf("invalid direction %q", text)
+	}
+
+	return nil
+}
+
+// A Record is a frame of a recording.
+type Record struct {
+	Time      time.Time
+	Direction Direction
+	Message   cemi.Message
+}
+
+// header is the first line of a recording.
+type header struct {
+	Format  string `json:"format"`
+	Version int    `json:"version"`
+}
+
+// line is the encoding of a Record.
+type line struct {
+	Time      time.Time `json:"time"`
+	Direction Direction `json:"dir"`
+	CEMI      string    `json:"cemi"`
+}
+
+// A Writer writes a recording. It is safe for concurrent use.
+type Writer struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+	err error
+}
+
+// NewWriter starts a recording by writing the header.
+func NewWriter(w io.Writer) (*Writer, error) {
+	enc := json.NewEncoder(w)
+	if err := enc.Encode(header{Format: Format, Version: Version}); err != nil {
+		return nil, err
+	}
+
+	return &Writer{enc: enc}, nil
+}
+
+// Write appends the record. Once writing has failed, all further writes fail with the same error.
+func (writer *Writer) Write(rec Record) error {
+	buffer := make([]byte, cemi.Size(rec.Message))
+	cemi.Pack(buffer, rec.Message)
+
+	writer.mu.Lock()
+	defer writer.mu.Unlock()
+
+	if writer.err != nil {
+		return writer.err
+	}
+
+	writer.err = writer.enc.Encode(line{Time: rec.Time, Direction: rec.Direction, CEMI: hex.EncodeToString(buffer)})
+
+	return writer.err
+}
+
+// Err returns the error that has stopped the recording, if any.
+func (writer *Writer) Err() error {
+	writer.mu.Lock()
+	defer writer.mu.Unlock()
+
+	return writer.err
+}
+
+// A Reader reads a recording.
+type Reader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+// NewReader checks the header of the recording.
+func NewReader(r io.Reader) (*Reader, error) {
+	reader := &Reader{scanner: bufio.NewScanner(r)}
+
+	data, err := reader.next()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, ErrFormat
+		}
+
+		return nil, err
+	}
+
+	var hdr header
+	if err := json.Unmarshal(data, &hdr); err != nil || hdr.Format != Format {
+		return nil, ErrFormat
+	}
+
+	if hdr.Version < 1 || hdr.Version > Version {
+		return nil, fmt.Errorf("%w: version %d", ErrFormat, hdr.Version)
+	}
+
+	return reader, nil
+}
+
+// Read returns the next record, or io.EOF at the end of the recording.
+func (reader *Reader) Read() (Record, error) {
+	data, err := reader.next()
+	if err != nil {
+		return Record{}, err
+	}
+
+	var ln line
+	if err := json.Unmarshal(data, &ln); err != nil {
+		return Record{}, fmt.Errorf("line %d: %w", reader.line, err)
+	}
+
+	frame, err := hex.DecodeString(ln.CEMI)
+	if err != nil {
+		return Record{}, fmt.Errorf("line %d: %w", reader.line, err)
+	}
+
+	rec := Record{Time: ln.Time, Direction: ln.Direction}
+
+	if _, err := cemi.Unpack(frame, &rec.Message); err != nil {
+		return Record{}, fmt.Errorf("line %d: %w", reader.line, err)
+	}
+
+	return rec, nil
+}
+
+// next returns the next non-empty line.
+func (reader *Reader) next() ([]byte, error) {
+	for reader.scanner.Scan() {
+		reader.line++
+
+		if data := reader.scanner.Bytes(); len(data) > 0 {
+			return data, nil
+		}
+	}
+
+	if err := reader.scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return nil, io.EOF
+}
@@ -0,0 +1,251 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package record
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mobilarte/knx-exp/knx"
+	"github.com/mobilarte/knx-exp/knx/cemi"
+	"github.com/mobilarte/knx-exp/knx/sim"
+)
+
+func makeGroupWrite(source cemi.IndividualAddr, dest cemi.GroupAddr, data ...byte) cemi.LData {
+	return cemi.LData{
+		Control1:    cemi.Control1StdFrame | cemi.Control1NoRepeat | cemi.Control1NoSysBroadcast,
+		Control2:    cemi.Control2GroupAddr | cemi.Control2Hops(6),
+		Source:      source,
+		Destination: uint16(dest),
+		Data:        &cemi.AppData{Command: cemi.GroupValueWrite, Data: data},
+	}
+}
+
+// makeRecording creates a recording of a few frames, 10ms apart.
+func makeRecording(t *testing.T) *bytes.Buffer {
+	t.Helper()
+
+	var buffer bytes.Buffer
+
+	writer, err := NewWriter(&buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2024, 5, 3, 3, 12, 0, 0, time.UTC)
+
+	for i, rec := range []Record{
+		{Direction: Inbound, Message: &cemi.LDataInd{LData: makeGroupWrite(0x1101, 0x0901, 1)}},
+		{Direction: Outbound, Message: &cemi.LDataReq{LData: makeGroupWrite(0, 0x0902, 2)}},
+		{Direction: Inbound, Message: &cemi.LDataCon{LData: makeGroupWrite(0x11f1, 0x0902, 2)}},
+		{Direction: Inbound, Message: &cemi.LDataInd{LData: makeGroupWrite(0x1102, 0x0903, 3)}},
+	} {
+		rec.Time = start.Add(time.Duration(i) * 10 * time.Millisecond)
+
+		if err := writer.Write(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return &buffer
+}
+
+func TestReader(t *testing.T) {
+	recording := makeRecording(t)
+
+	if header, _, _ := strings.Cut(recording.String(), "\n"); header != `{"format":"knx-exp/telegrams","version":1}` {
+		t.Errorf("Unexpected header %s", header)
+	}
+
+	reader, err := NewReader(recording)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var recs []Record
+
+	for {
+		rec, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+
+		recs = append(recs, rec)
+	}
+
+	if len(recs) != 4 || recs[1].Direction != Outbound || recs[3].Time.Sub(recs[0].Time) != 30*time.Millisecond {
+		t.Fatalf("Unexpected records %v", recs)
+	}
+
+	if con, ok := recs[2].Message.(*cemi.LDataCon); !ok || con.Source != 0x11f1 {
+		t.Errorf("Unexpected message %+v", recs[2].Message)
+	}
+
+	t.Run("Invalid", func(t *testing.T) {
+		for _, data := range []string{
+			"",
+			"hello\n",
+			`{"format":"knx-exp/telegrams","version":2}`,
+		} {
+			if _, err := NewReader(strings.NewReader(data)); !errors.Is(err, ErrFormat) {
+				t.Errorf("Unexpected error %v for %q", err, data)
+			}
+		}
+
+		reader, err := NewReader(strings.NewReader(`{"format":"knx-exp/telegrams","version":1}
+{"time":"2024-05-03T03:12:00Z","dir":"sideways","cemi":"2900"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := reader.Read(); err == nil || !strings.Contains(err.Error(), "line 2") {
+			t.Errorf("Unexpected error %v", err)
+		}
+	})
+}
+
+func TestRecorder(t *testing.T) {
+	bus := sim.NewBus(sim.DefaultConfig)
+	t.Cleanup(func() { _ = bus.Close() })
+
+	client, err := bus.Attach(0x11f1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := bus.Attach(0x1101)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buffer bytes.Buffer
+
+	writer, err := NewWriter(&buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := NewRecorder(client, writer)
+
+	if err := recorder.Send(&cemi.LDataReq{LData: makeGroupWrite(0, 0x0901, 1)}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := other.Send(&cemi.LDataReq{LData: makeGroupWrite(0, 0x0902, 2)}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The confirmation and the indication of the other client.
+	for range 2 {
+		select {
+		case <-recorder.Inbound():
+		case <-time.After(time.Second):
+			t.Fatal("No frame has been received")
+		}
+	}
+
+	if err := recorder.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := NewReader(&buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var directions []Direction
+
+	for {
+		rec, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+
+		directions = append(directions, rec.Direction)
+	}
+
+	if len(directions) != 3 || directions[0] != Outbound {
+		t.Errorf("Unexpected directions %v", directions)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	bus := sim.NewBus(sim.DefaultConfig)
+	t.Cleanup(func() { _ = bus.Close() })
+
+	player, err := bus.Attach(0x11ff)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := NewReader(makeRecording(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+
+	// The recording spans 30ms, which takes 15ms at double speed.
+	if err := Replay(context.Background(), reader, player, ReplayConfig{Speed: 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	if elapsed := time.Since(start); elapsed < 15*time.Millisecond {
+		t.Errorf("Replay took %v", elapsed)
+	}
+
+	// Only the received L_Data.ind are replayed, with their original source.
+	telegrams := bus.Telegrams()
+	if len(telegrams) != 2 || telegrams[0].Source != 0x1101 || telegrams[1].Source != 0x1102 {
+		t.Errorf("Unexpected telegrams %v", telegrams)
+	}
+
+	t.Run("Group", func(t *testing.T) {
+		bus.ClearHistory()
+
+		group, err := bus.AttachGroup(0x11fe)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		reader, err := NewReader(makeRecording(t))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		config := ReplayConfig{Outbound: true}
+
+		if err := ReplayGroup(context.Background(), reader, group, config); err != nil {
+			t.Fatal(err)
+		}
+
+		bus.Expect(t, time.Second, sim.All(sim.From(0x11fe), sim.Group(knx.GroupWrite, 0x0902, []byte{2})))
+
+		if n := len(bus.Telegrams()); n != 3 {
+			t.Errorf("Unexpected number of telegrams %d", n)
+		}
+	})
+
+	t.Run("Cancel", func(t *testing.T) {
+		reader, err := NewReader(makeRecording(t))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		if err := Replay(ctx, reader, player, DefaultReplayConfig); !errors.Is(err, context.Canceled) {
+			t.Errorf("Unexpected error %v", err)
+		}
+	})
+}
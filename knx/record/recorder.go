@@ -0,0 +1,69 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package record
+
+import (
+	"time"
+
+	"github.com/mobilarte/knx-exp/knx/cemi"
+)
+
+// A Client exchanges cEMI frames, like knx.Tunnel, knx.Router or sim.Client.
+type Client interface {
+	Send(data cemi.Message) error
+	Inbound() <-chan cemi.Message
+}
+
+// A Recorder is a Client which records all frames of the client that it wraps. Use the Recorder
+// instead of the client for sending and receiving; close the client itself when done.
+type Recorder struct {
+	client  Client
+	writer  *Writer
+	inbound chan cemi.Message
+}
+
+// NewRecorder starts recording the frames of the client. The Recorder consumes the Inbound channel
+// of the client.
+func NewRecorder(client Client, writer *Writer) *Recorder {
+	rec := &Recorder{client: client, writer: writer, inbound: make(chan cemi.Message)}
+
+	go rec.serve()
+
+	return rec
+}
+
+// Send transmits the frame through the client and records it if it has been sent.
+func (rec *Recorder) Send(data cemi.Message) error {
+	now := time.Now()
+
+	if err := rec.client.Send(data); err != nil {
+		return err
+	}
+
+	// Failures are remembered by the writer and can be retrieved with Err.
+	_ = rec.writer.Write(Record{Time: now, Direction: Outbound, Message: data})
+
+	return nil
+}
+
+// Inbound returns the channel on which the frames of the client are received after they have been
+// recorded. It is closed when the channel of the client is closed.
+func (rec *Recorder) Inbound() <-chan cemi.Message {
+	return rec.inbound
+}
+
+// Err returns the error that has stopped the recording, if any.
+func (rec *Recorder) Err() error {
+	return rec.writer.Err()
+}
+
+// serve records the incoming frames.
+func (rec *Recorder) serve() {
+	defer close(rec.inbound)
+
+	for msg := range rec.client.Inbound() {
+		_ = rec.writer.Write(Record{Time: time.Now(), Direction: Inbound, Message: msg})
+
+		rec.inbound <- msg
+	}
+}
@@ -0,0 +1,120 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package record
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/mobilarte/knx-exp/knx"
+	"github.com/mobilarte/knx-exp/knx/cemi"
+)
+
+// ReplayConfig configures the replay of a recording.
+type ReplayConfig struct {
+	// Speed is the factor by which the replay is faster than the recording: 1 replays in real
+	// time, 10 ten times as fast. 0 sends the frames without pauses.
+	Speed float64
+
+	// Outbound also replays the frames that the recording client has sent. By default, only the
+	// frames that it has received are replayed.
+	Outbound bool
+}
+
+// DefaultReplayConfig replays the received frames in real time.
+var DefaultReplayConfig = ReplayConfig{Speed: 1}
+
+// A Sender transmits cEMI frames, like knx.Tunnel, knx.Router or sim.Client.
+type Sender interface {
+	Send(data cemi.Message) error
+}
+
+// Replay sends the L_Data frames of the recording with their original timing. Indications are
+// sent as requests with their original source address; confirmations are skipped. It returns when
+// the recording is exhausted or the context is done.
+func Replay(ctx context.Context, reader *Reader, sender Sender, config ReplayConfig) error {
+	return replay(ctx, reader, config, func(ldata *cemi.LData) error {
+		return sender.Send(&cemi.LDataReq{LData: *ldata})
+	})
+}
+
+// ReplayGroup sends the group communication of the recording to the client with its original
+// timing. Other frames are skipped.
+func ReplayGroup(ctx context.Context, reader *Reader, client knx.GroupClient, config ReplayConfig) error {
+	return replay(ctx, reader, config, func(ldata *cemi.LData) error {
+		app, ok := ldata.Data.(*cemi.AppData)
+		if !ldata.Control2.IsGroupAddr() || !ok || !app.Command.IsGroupCommand() {
+			return nil
+		}
+
+		return client.Send(knx.GroupEvent{
+			Command:     knx.GroupCommand(app.Command),
+			Source:      ldata.Source,
+			Destination: cemi.GroupAddr(ldata.Destination),
+			Data:        app.Data,
+		})
+	})
+}
+
+// replay waits for each frame of the recording to be due and passes it on.
+func replay(ctx context.Context, reader *Reader, config ReplayConfig, send func(ldata *cemi.LData) error) error {
+	var first time.Time
+
+	start := time.Now()
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		rec, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if rec.Direction == Outbound && !config.Outbound {
+			continue
+		}
+
+		var ldata *cemi.LData
+
+		switch msg := rec.Message.(type) {
+		case *cemi.LDataInd:
+			ldata = &msg.LData
+		case *cemi.LDataReq:
+			ldata = &msg.LData
+		default:
+			continue
+		}
+
+		if first.IsZero() {
+			first = rec.Time
+		}
+
+		if config.Speed > 0 {
+			due := start.Add(time.Duration(float64(rec.Time.Sub(first)) / config.Speed))
+
+			if wait := time.Until(due); wait > 0 {
+				timer.Reset(wait)
+
+				select {
+				case <-timer.C:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if err := send(ldata); err != nil {
+			return fmt.Errorf("replaying frame of %v: %w", rec.Time, err)
+		}
+	}
+}
@@ -0,0 +1,378 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knx
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/mobilarte/knx-exp/knx/knxnet"
+	"golang.org/x/net/ipv4"
+)
+
+// deviceStatusProgMode is the bit of the device status which indicates the programming mode.
+const deviceStatusProgMode knxnet.DeviceStatus = 0x01
+
+// DiscoveryResponderConfig configures a DiscoveryResponder.
+type DiscoveryResponderConfig struct {
+	// Description is the self-description of the device. The types of the device information and
+	// supported services DIBs are filled in if they are missing. The other DIBs are only announced
+	// if their type is set, or for the KNX addresses, if the list is not empty.
+	Description knxnet.DescriptionBlock
+
+	// Interface is the interface on which the multicast group is joined. If it is nil, the
+	// system-assigned multicast interface is used.
+	Interface *net.Interface
+
+	// Address is the local unicast address from which responses are sent. Description requests
+	// are also accepted on it. It defaults to an ephemeral port.
+	Address string
+
+	// Control is the control endpoint that search responses announce, typically the address of
+	// a TunnelServer. A zero address is replaced with the local address that faces the client,
+	// a zero port with the port of the responder.
+	Control knxnet.HostInfo
+
+	// ProgMode reports whether the device is in programming mode. If it is nil, the status of the
+	// device information DIB is announced as is.
+	ProgMode func() bool
+}
+
+// completeDescription fills in the DIB types that the description leaves out.
+func completeDescription(desc knxnet.DescriptionBlock) knxnet.DescriptionBlock {
+	if desc.DeviceHardware.Type == 0 {
+		desc.DeviceHardware.Type = knxnet.DescriptionTypeDeviceInfo
+	}
+
+	if len(desc.DeviceHardware.HardwareAddr) != 6 {
+		// The DIB has a fixed size.
+		hw := make(net.HardwareAddr, 6)
+		copy(hw, desc.DeviceHardware.HardwareAddr)
+		desc.DeviceHardware.HardwareAddr = hw
+	}
+
+	if desc.SupportedServices.Type == 0 {
+		desc.SupportedServices.Type = knxnet.DescriptionTypeSupportedServiceFamilies
+	}
+
+	if desc.KnxAddr.Type == 0 && len(desc.KnxAddr.KNXAddresses) > 0 {
+		desc.KnxAddr.Type = knxnet.DescriptionTypeKNXAddresses
+	}
+
+	return desc
+}
+
+// A DiscoveryResponder answers search and description requests on behalf of a KNXnet/IP server,
+// so that it can be found by ETS or Discover. It joins the discovery multicast group and sends
+// its responses via unicast.
+type DiscoveryResponder struct {
+	config    DiscoveryResponderConfig
+	multicast *net.UDPConn
+	unicast   *net.UDPConn
+	errors    *asyncErrors
+
+	mu          sync.Mutex
+	description knxnet.DescriptionBlock
+
+	wait sync.WaitGroup
+}
+
+// NewDiscoveryResponder starts a responder which listens on the given multicast address,
+// usually "224.0.23.12:3671".
+func NewDiscoveryResponder(multicastDiscoveryAddress string,
+	config DiscoveryResponderConfig) (*DiscoveryResponder, error) {
+	group, err := net.ResolveUDPAddr("udp4", multicastDiscoveryAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.Address == "" {
+		config.Address = ":0"
+	}
+
+	local, err := net.ResolveUDPAddr("udp4", config.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	multicast, err := net.ListenMulticastUDP("udp4", config.Interface, group)
+	if err != nil {
+		return nil, err
+	}
+
+	unicast, err := net.ListenUDP("udp4", local)
+	if err != nil {
+		_ = multicast.Close()
+		return nil, err
+	}
+
+	// Responses to the multicast group leave on the same interface.
+	if config.Interface != nil {
+		if err := ipv4.NewPacketConn(unicast).SetMulticastInterface(config.Interface); err != nil {
+			_ = multicast.Close()
+			_ = unicast.Close()
+
+			return nil, err
+		}
+	}
+
+	responder := &DiscoveryResponder{
+		config:      config,
+		multicast:   multicast,
+		unicast:     unicast,
+		errors:      newAsyncErrors(),
+		description: completeDescription(config.Description),
+	}
+
+	responder.wait.Add(2)
+
+	go responder.serve(multicast)
+	go responder.serve(unicast)
+
+	return responder, nil
+}
+
+// LocalAddr returns the unicast address from which the responses are sent.
+func (responder *DiscoveryResponder) LocalAddr() *net.UDPAddr {
+	return responder.unicast.LocalAddr().(*net.UDPAddr)
+}
+
+// SetDescription replaces the self-description of the device.
+func (responder *DiscoveryResponder) SetDescription(desc knxnet.DescriptionBlock) {
+	desc = completeDescription(desc)
+
+	responder.mu.Lock()
+	defer responder.mu.Unlock()
+
+	responder.description = desc
+}
+
+// Errors returns the channel on which errors are reported that occur while answering requests.
+// The channel is closed when the responder is closed.
+func (responder *DiscoveryResponder) Errors() <-chan error {
+	return responder.errors.channel()
+}
+
+// Close stops the responder.
+func (responder *DiscoveryResponder) Close() error {
+	err := errors.Join(responder.multicast.Close(), responder.unicast.Close())
+
+	responder.wait.Wait()
+	responder.errors.close()
+
+	return err
+}
+
+// currentDescription returns the description with the current programming mode.
+func (responder *DiscoveryResponder) currentDescription() knxnet.DescriptionBlock {
+	responder.mu.Lock()
+	desc := responder.description
+	responder.mu.Unlock()
+
+	if responder.config.ProgMode != nil {
+		if responder.config.ProgMode() {
+			desc.DeviceHardware.Status |= deviceStatusProgMode
+		} else {
+			desc.DeviceHardware.Status &^= deviceStatusProgMode
+		}
+	}
+
+	return desc
+}
+
+// control determines the control endpoint which is announced to the client.
+func (responder *DiscoveryResponder) control(client *net.UDPAddr) knxnet.HostInfo {
+	control := responder.config.Control
+	control.Protocol = knxnet.UDP4
+
+	if control.Port == 0 {
+		control.Port = knxnet.Port(responder.LocalAddr().Port)
+	}
+
+	if control.Address == (knxnet.Address{}) {
+		if ip := localIPFor(client); ip != nil {
+			copy(control.Address[:], ip)
+		}
+	}
+
+	return control
+}
+
+// localIPFor determines the local address that the system would use to reach the client.
+func localIPFor(client *net.UDPAddr) net.IP {
+	// Connecting a UDP socket does not send anything.
+	conn, err := net.DialUDP("udp4", nil, client)
+	if err != nil {
+		return nil
+	}
+
+	defer func() { _ = conn.Close() }()
+
+	return conn.LocalAddr().(*net.UDPAddr).IP.To4()
+}
+
+// matchSearch determines whether the device satisfies the parameters of an extended search. The
+// DIBs to be included in the response are returned as well. Unknown mandatory parameters make
+// the device ignore the request.
+func matchSearch(desc *knxnet.DescriptionBlock, params []knxnet.SearchParam) (bool, []knxnet.DescriptionType) {
+	var dibs []knxnet.DescriptionType
+
+	for _, param := range params {
+		switch param.Type {
+		case knxnet.SearchParamProgMode:
+			if desc.DeviceHardware.Status&deviceStatusProgMode == 0 {
+				return false, nil
+			}
+
+		case knxnet.SearchParamMACAddress:
+			if !bytes.Equal(param.Data, desc.DeviceHardware.HardwareAddr) {
+				return false, nil
+			}
+
+		case knxnet.SearchParamService:
+			if len(param.Data) < 2 || !supportsService(desc, knxnet.ServiceFamily{
+				Type:    knxnet.ServiceFamilyType(param.Data[0]),
+				Version: param.Data[1],
+			}) {
+				return false, nil
+			}
+
+		case knxnet.SearchParamDIBs:
+			for _, ty := range param.Data {
+				// The list may be padded with zeros.
+				if ty != 0 {
+					dibs = append(dibs, knxnet.DescriptionType(ty))
+				}
+			}
+
+		default:
+			if param.Mandatory {
+				return false, nil
+			}
+		}
+	}
+
+	return true, dibs
+}
+
+// supportsService determines whether the device supports the service family in at least the
+// given version.
+func supportsService(desc *knxnet.DescriptionBlock, family knxnet.ServiceFamily) bool {
+	for _, supported := range desc.SupportedServices.Families {
+		if supported.Type == family.Type && supported.Version >= family.Version {
+			return true
+		}
+	}
+
+	return false
+}
+
+// selectDIBs reduces the description to the requested DIBs. The device information and the
+// supported services are always included.
+func selectDIBs(desc knxnet.DescriptionBlock, dibs []knxnet.DescriptionType) knxnet.DescriptionBlock {
+	selected := knxnet.DescriptionBlock{
+		DeviceHardware:    desc.DeviceHardware,
+		SupportedServices: desc.SupportedServices,
+	}
+
+	for _, ty := range dibs {
+		switch ty {
+		case knxnet.DescriptionTypeIPConfig:
+			selected.IpConfig = desc.IpConfig
+
+		case knxnet.DescriptionTypeIPCurrentConfig:
+			selected.CurConfig = desc.CurConfig
+
+		case knxnet.DescriptionTypeKNXAddresses:
+			selected.KnxAddr = desc.KnxAddr
+
+		default:
+			for _, block := range desc.UnknownBlocks {
+				if block.Type == ty {
+					selected.UnknownBlocks = append(selected.UnknownBlocks, block)
+				}
+			}
+		}
+	}
+
+	return selected
+}
+
+// respond sends the response to the endpoint that the client has given. A zero endpoint means
+// that the client is behind a NAT, so the response goes to the sender instead.
+func (responder *DiscoveryResponder) respond(info knxnet.HostInfo, sender *net.UDPAddr,
+	res knxnet.ServicePackable) error {
+	dest := sender
+	if info.Address != (knxnet.Address{}) && info.Port != 0 {
+		dest = &net.UDPAddr{IP: info.Address[:], Port: int(info.Port)}
+	}
+
+	_, err := responder.unicast.WriteToUDP(knxnet.AllocAndPack(res), dest)
+
+	return err
+}
+
+// handle answers a request.
+func (responder *DiscoveryResponder) handle(msg knxnet.Service, sender *net.UDPAddr) error {
+	switch msg := msg.(type) {
+	case *knxnet.SearchReq:
+		return responder.respond(msg.HostInfo, sender, &knxnet.SearchRes{
+			Control:      responder.control(sender),
+			DescriptionB: responder.currentDescription(),
+		})
+
+	case *knxnet.SearchReqExt:
+		desc := responder.currentDescription()
+
+		match, dibs := matchSearch(&desc, msg.Params)
+		if !match {
+			return nil
+		}
+
+		return responder.respond(msg.Control, sender, &knxnet.SearchResExt{
+			Control:      responder.control(sender),
+			DescriptionB: selectDIBs(desc, dibs),
+		})
+
+	case *knxnet.DescriptionReq:
+		res := knxnet.DescriptionRes(responder.currentDescription())
+		return responder.respond(msg.HostInfo, sender, &res)
+
+	default:
+		// Routing and other multicast traffic is none of our business.
+		return nil
+	}
+}
+
+// serve is the receiving worker of a socket.
+func (responder *DiscoveryResponder) serve(conn *net.UDPConn) {
+	defer responder.wait.Done()
+
+	buffer := [1024]byte{}
+
+	for {
+		n, sender, err := conn.ReadFromUDP(buffer[:])
+		if err != nil {
+			// Closing the socket is not an error.
+			if !errors.Is(err, net.ErrClosed) {
+				responder.errors.emit(responder, err)
+			}
+
+			return
+		}
+
+		var msg knxnet.Service
+
+		if _, err := knxnet.Unpack(buffer[:n], &msg); err != nil {
+			responder.errors.emit(responder, fmt.Errorf("unpacking packet from %v: %w", sender, err))
+			continue
+		}
+
+		if err := responder.handle(msg, sender); err != nil {
+			responder.errors.emit(responder, fmt.Errorf("responding to %v: %w", sender, err))
+		}
+	}
+}
@@ -0,0 +1,215 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knx
+
+import (
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mobilarte/knx-exp/knx/cemi"
+	"github.com/mobilarte/knx-exp/knx/knxnet"
+)
+
+func makeDescription() knxnet.DescriptionBlock {
+	return knxnet.DescriptionBlock{
+		DeviceHardware: knxnet.DeviceInformationBlock{
+			Medium:       knxnet.KNXMediumTP1,
+			Source:       0x1101,
+			HardwareAddr: net.HardwareAddr{0x02, 0, 0, 0, 0, 1},
+			FriendlyName: "knx-exp",
+		},
+		SupportedServices: knxnet.SupportedServicesDIB{
+			Families: []knxnet.ServiceFamily{
+				{Type: knxnet.ServiceFamilyTypeIPCore, Version: 2},
+				{Type: knxnet.ServiceFamilyTypeIPTunnelling, Version: 2},
+			},
+		},
+		IpConfig: knxnet.IpConfigDIB{
+			Type:      knxnet.DescriptionTypeIPConfig,
+			IpAddress: knxnet.Address{127, 0, 0, 1},
+		},
+		KnxAddr: knxnet.KnxAddrDIB{KNXAddresses: []cemi.IndividualAddr{0x1101, 0x11f1}},
+	}
+}
+
+func TestDiscoveryResponder(t *testing.T) {
+	var progMode atomic.Bool
+
+	responder, err := NewDiscoveryResponder("224.0.23.12:0", DiscoveryResponderConfig{
+		Description: makeDescription(),
+		Address:     "127.0.0.1:0",
+		Control:     knxnet.HostInfo{Port: 3671},
+		ProgMode:    progMode.Load,
+	})
+	if err != nil {
+		t.Skipf("Cannot listen for discovery requests: %v", err)
+	}
+
+	t.Cleanup(func() {
+		if err := responder.Close(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("Description", func(t *testing.T) {
+		res, err := DescribeTunnel(responder.LocalAddr().String(), time.Second)
+		if err != nil || res == nil {
+			t.Fatalf("No description: %v", err)
+		}
+
+		if res.DeviceHardware.FriendlyName != "knx-exp" || res.IpConfig.IpAddress != (knxnet.Address{127, 0, 0, 1}) {
+			t.Errorf("Unexpected description %+v", res)
+		}
+
+		if len(res.KnxAddr.KNXAddresses) != 2 || res.KnxAddr.KNXAddresses[1] != 0x11f1 {
+			t.Errorf("Unexpected KNX addresses %v", res.KnxAddr.KNXAddresses)
+		}
+	})
+
+	sock, err := knxnet.DialTunnelUDP(responder.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { _ = sock.Close() })
+
+	control, err := knxnet.HostInfoFromAddress(sock.LocalAddr())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// search sends an extended search request and waits for the response, if any.
+	search := func(t *testing.T, params ...knxnet.SearchParam) *knxnet.SearchResExt {
+		t.Helper()
+
+		if err := sock.Send(&knxnet.SearchReqExt{Control: control, Params: params}); err != nil {
+			t.Fatal(err)
+		}
+
+		select {
+		case msg := <-sock.Inbound():
+			res, ok := msg.(*knxnet.SearchResExt)
+			if !ok {
+				t.Fatalf("Unexpected %T", msg)
+			}
+
+			return res
+
+		case <-time.After(200 * time.Millisecond):
+			return nil
+		}
+	}
+
+	t.Run("Search", func(t *testing.T) {
+		if err := sock.Send(&knxnet.SearchReq{HostInfo: control}); err != nil {
+			t.Fatal(err)
+		}
+
+		select {
+		case msg := <-sock.Inbound():
+			res, ok := msg.(*knxnet.SearchRes)
+			if !ok {
+				t.Fatalf("Unexpected %T", msg)
+			}
+
+			// The local address facing the client is announced.
+			expected := knxnet.HostInfo{Protocol: knxnet.UDP4, Address: knxnet.Address{127, 0, 0, 1}, Port: 3671}
+			if !res.Control.Equals(expected) {
+				t.Errorf("Unexpected control endpoint %+v", res.Control)
+			}
+
+		case <-time.After(time.Second):
+			t.Fatal("No search response")
+		}
+	})
+
+	t.Run("Filters", func(t *testing.T) {
+		mac := knxnet.SearchParam{Type: knxnet.SearchParamMACAddress, Data: []byte{0x02, 0, 0, 0, 0, 1}}
+		tunnelling := knxnet.SearchParam{
+			Type: knxnet.SearchParamService,
+			Data: []byte{byte(knxnet.ServiceFamilyTypeIPTunnelling), 2},
+		}
+
+		if search(t, mac, tunnelling) == nil {
+			t.Error("Matching device has not responded")
+		}
+
+		progModeParam := knxnet.SearchParam{Type: knxnet.SearchParamProgMode}
+		if search(t, progModeParam) != nil {
+			t.Error("Device has responded although it is not in programming mode")
+		}
+
+		progMode.Store(true)
+
+		res := search(t, progModeParam)
+		if res == nil || res.DescriptionB.DeviceHardware.Status&0x01 == 0 {
+			t.Errorf("Unexpected response to search in programming mode %+v", res)
+		}
+
+		routing := knxnet.SearchParam{
+			Type: knxnet.SearchParamService,
+			Data: []byte{byte(knxnet.ServiceFamilyTypeIPRouting), 1},
+		}
+		if search(t, routing) != nil {
+			t.Error("Device has responded although it does not support routing")
+		}
+
+		unknown := knxnet.SearchParam{Type: 0x7f, Mandatory: true}
+		if search(t, unknown) != nil {
+			t.Error("Device has responded to an unknown mandatory parameter")
+		}
+
+		unknown.Mandatory = false
+		if search(t, unknown) == nil {
+			t.Error("Device has not responded to an unknown optional parameter")
+		}
+	})
+
+	t.Run("DIBs", func(t *testing.T) {
+		res := search(t)
+		if res == nil {
+			t.Fatal("No search response")
+		}
+
+		if res.DescriptionB.IpConfig.Type != 0 || res.DescriptionB.KnxAddr.Type != 0 {
+			t.Errorf("Unrequested DIBs have been included %+v", res.DescriptionB)
+		}
+
+		res = search(t, knxnet.SearchParam{
+			Type: knxnet.SearchParamDIBs,
+			Data: []byte{byte(knxnet.DescriptionTypeKNXAddresses), 0},
+		})
+		if res == nil {
+			t.Fatal("No search response")
+		}
+
+		if res.DescriptionB.IpConfig.Type != 0 || len(res.DescriptionB.KnxAddr.KNXAddresses) != 2 {
+			t.Errorf("Unexpected DIBs %+v", res.DescriptionB)
+		}
+	})
+}
+
+func TestTunnelServer_Description(t *testing.T) {
+	desc := makeDescription()
+
+	srv, err := NewTunnelServer(newChanBackend(), TunnelServerConfig{
+		Address:     "127.0.0.1:0",
+		Description: &desc,
+	})
+	if err != nil {
+		t.Skipf("Cannot listen on loopback: %v", err)
+	}
+
+	t.Cleanup(func() { _ = srv.Close() })
+
+	res, err := DescribeTunnel(srv.UDPAddr().String(), time.Second)
+	if err != nil || res == nil {
+		t.Fatalf("No description: %v", err)
+	}
+
+	if res.DeviceHardware.Type != knxnet.DescriptionTypeDeviceInfo || res.DeviceHardware.FriendlyName != "knx-exp" {
+		t.Errorf("Unexpected description %+v", res.DeviceHardware)
+	}
+}
@@ -5,8 +5,9 @@ package knx
 
 import (
 	"container/list"
+	"context"
 	"errors"
-	"log"
+	"fmt"
 	"math/rand"
 	"net"
 	"sync"
@@ -25,12 +26,36 @@ type RouterConfig struct {
 	// Specifies the interface used to send and receive KNXNet/IP packets. If the interface
 	// is nil, the system-assigned multicast interface is used.
 	Interface *net.Interface
+	// Lists the interfaces used to send and receive KNXnet/IP packets, instead of Interface.
+	// Incoming frames are then delivered as *RoutedFrame.
+	Interfaces []RouterInterface
+	// Use all interfaces that are up and capable of multicast, instead of Interface. Entries in
+	// Interfaces with the same name override the settings of an interface.
+	AllInterfaces bool
 	// Specifies if Multicast Loopback should be enabled.
 	MulticastLoopbackEnabled bool
 	// Pause duration after sending. 0 means disabled.
 	// According to the specification, we may choose to always pause for 20 ms after transmitting,
 	// but we should always pause for at least 5 ms on a multicast address.
 	PostSendPauseDuration time.Duration
+	// Configures the queue of incoming messages.
+	Inbound InboundConfig
+	// Maximum number of telegrams sent per second. The specification permits at most 50.
+	RateLimit uint
+	// Number of messages waiting in the inbound queue at which the other routers are asked to
+	// pause by a ROUTING_BUSY. 0 means three quarters of the queue size.
+	BusyThreshold int
+	// Time for which the other routers are asked to pause.
+	BusyWaitTime time.Duration
+	// Configures the suppression of duplicate incoming frames. It is disabled by default.
+	Dedup DedupConfig
+}
+
+// A RouterInterface is a network interface on which a Router joins the multicast group.
+type RouterInterface struct {
+	Interface *net.Interface
+	// Prevents the Router from sending on this interface.
+	ReceiveOnly bool
 }
 
 // DefaultRouterConfig is a good default configuration for a Router client.
@@ -38,6 +63,9 @@ var DefaultRouterConfig = RouterConfig{
 	RetainCount:              32,
 	MulticastLoopbackEnabled: false,
 	PostSendPauseDuration:    20 * time.Millisecond,
+	Inbound:                  DefaultInboundConfig,
+	RateLimit:                50,
+	BusyWaitTime:             50 * time.Millisecond,
 }
 
 // checkRouterConfig validates the given RouterConfig.
@@ -46,6 +74,20 @@ func checkRouterConfig(config RouterConfig) RouterConfig {
 		config.RetainCount = DefaultRouterConfig.RetainCount
 	}
 
+	config.Inbound = checkInboundConfig(config.Inbound)
+
+	if config.RateLimit == 0 {
+		config.RateLimit = DefaultRouterConfig.RateLimit
+	}
+
+	if config.BusyThreshold <= 0 {
+		config.BusyThreshold = max(config.Inbound.QueueSize*3/4, 1)
+	}
+
+	if config.BusyWaitTime <= 0 {
+		config.BusyWaitTime = DefaultRouterConfig.BusyWaitTime
+	}
+
 	return config
 }
 
@@ -54,45 +96,170 @@ func checkRouterConfig(config RouterConfig) RouterConfig {
 type Router struct {
 	sock          knxnet.Socket
 	config        RouterConfig
-	inbound       chan cemi.Message
-	sendMu        sync.Mutex
+	inbound       *inboundQueue
+	errors        *asyncErrors
+	sendLock      chan struct{}
 	retainer      *list.List
 	postSendPause time.Duration
+	flow          *routingFlow
+	dedup         *dedupFilter
+
+	// Time at which the last ROUTING_BUSY has been sent
+	busySent time.Time
+}
+
+// A SystemBroadcast is a frame that has been received in system broadcast mode, i.e. through a
+// ROUTING_SYSTEM_BROADCAST. Router.Inbound delivers it in place of the plain frame so that clients
+// can tell both kinds apart.
+type SystemBroadcast struct {
+	cemi.Message
+}
+
+// A RoutedFrame is a frame that a Router on several interfaces has received, together with the
+// interface it came in on. Router.Inbound delivers it in place of the plain frame.
+type RoutedFrame struct {
+	cemi.Message
+	Interface *net.Interface
+}
+
+// interfaceSender is implemented by sockets that can send on a chosen interface.
+type interfaceSender interface {
+	SendOn(ifi *net.Interface, payload knxnet.ServicePackable) error
+}
+
+// isSystemBroadcast determines whether the frame must be sent in system broadcast mode.
+func isSystemBroadcast(data cemi.Message) bool {
+	switch msg := data.(type) {
+	case *SystemBroadcast:
+		return true
+	case *cemi.LDataInd:
+		return msg.Control1&cemi.Control1NoSysBroadcast == 0
+	case *cemi.LDataReq:
+		return msg.Control1&cemi.Control1NoSysBroadcast == 0
+	default:
+		return false
+	}
 }
 
-const maxWaitTime = 50 * time.Millisecond
+// routingService wraps the frame in the routing service that matches its broadcast mode.
+func routingService(data cemi.Message) knxnet.ServicePackable {
+	if !isSystemBroadcast(data) {
+		return &knxnet.RoutingInd{Payload: data}
+	}
+
+	if bc, ok := data.(*SystemBroadcast); ok {
+		data = bc.Message
+	}
+
+	return &knxnet.RoutingSystemBroadcast{Payload: data}
+}
 
 // NewRouter creates a new Router that joins the given multicast group. You may pass a
 // zero-initialized value as parameter config, the default values will be set up.
 func NewRouter(multicastAddress string, config RouterConfig) (*Router, error) {
 	config = checkRouterConfig(config)
 
-	sock, err := knxnet.ListenRouterOnInterface(config.Interface, multicastAddress, config.MulticastLoopbackEnabled)
+	if config.AllInterfaces {
+		ifis, err := knxnet.MulticastInterfaces()
+		if err != nil {
+			return nil, err
+		}
+
+		config.Interfaces = mergeRouterInterfaces(ifis, config.Interfaces)
+	}
+
+	var (
+		sock *knxnet.RouterSocket
+		err  error
+	)
+
+	if config.AllInterfaces || len(config.Interfaces) > 0 {
+		ifis := make([]*net.Interface, len(config.Interfaces))
+		for i, ifi := range config.Interfaces {
+			ifis[i] = ifi.Interface
+		}
+
+		sock, err = knxnet.ListenRouterOnInterfaces(ifis, multicastAddress, config.MulticastLoopbackEnabled)
+	} else {
+		sock, err = knxnet.ListenRouterOnInterface(config.Interface, multicastAddress, config.MulticastLoopbackEnabled)
+	}
+
 	if err != nil {
 		return nil, err
 	}
 
+	return newRouter(sock, config), nil
+}
+
+// mergeRouterInterfaces creates the settings for the given interfaces, taking over the settings
+// that have been configured for interfaces of the same name.
+func mergeRouterInterfaces(ifis []*net.Interface, configured []RouterInterface) []RouterInterface {
+	merged := make([]RouterInterface, len(ifis))
+
+	for i, ifi := range ifis {
+		merged[i] = RouterInterface{Interface: ifi}
+
+		for _, conf := range configured {
+			if conf.Interface != nil && conf.Interface.Name == ifi.Name {
+				merged[i].ReceiveOnly = conf.ReceiveOnly
+			}
+		}
+	}
+
+	return merged
+}
+
+// newRouter creates a Router on top of the given socket and starts its worker. The configuration
+// must have been checked.
+func newRouter(sock knxnet.Socket, config RouterConfig) *Router {
 	r := &Router{
 		sock:          sock,
 		config:        config,
-		inbound:       make(chan cemi.Message),
+		errors:        newAsyncErrors(),
+		sendLock:      make(chan struct{}, 1),
 		retainer:      list.New(),
 		postSendPause: config.PostSendPauseDuration,
+		flow:          newRoutingFlow(config.RateLimit),
+		dedup:         newDedupFilter(config.Dedup),
 	}
 
+	r.inbound = newInboundQueue(r.errors.reportOverflow(r, config.Inbound))
+	r.errors.forward(r, sock)
+
 	go r.serve()
 
-	return r, nil
+	return r
+}
+
+// Send transmits a packet. Frames whose Control1NoSysBroadcast flag is cleared are sent as
+// ROUTING_SYSTEM_BROADCAST, all others as ROUTING_INDICATION. A Router on several interfaces sends
+// on each interface that is not receive-only.
+func (router *Router) Send(data cemi.Message) error {
+	return router.SendContext(context.Background(), data)
 }
 
-// Send transmits a packet.
-func (router *Router) Send(data cemi.Message) (err error) {
+// SendContext transmits a packet. If sending is currently inhibited by flow control, it waits
+// until it may send or until the given context is done.
+func (router *Router) SendContext(ctx context.Context, data cemi.Message) (err error) {
 	if data == nil {
 		return errors.New("nil-pointers are not sendable")
 	}
 
+	// Frames that have been received on another interface may be forwarded.
+	if frame, ok := data.(*RoutedFrame); ok {
+		data = frame.Message
+	}
+
+	// The retained copy must not change when the caller modifies the frame later on.
+	retained, err := copyMessage(data)
+	if err != nil {
+		return err
+	}
+
 	// We lock this before doing any sending so the server goroutine can adjust the flow control.
-	router.sendMu.Lock()
+	if err := router.lockSend(ctx); err != nil {
+		return err
+	}
 
 	defer func() {
 		// This is called as a goroutine in order to not block the return of Send.
@@ -101,16 +268,21 @@ func (router *Router) Send(data cemi.Message) (err error) {
 				time.Sleep(router.postSendPause)
 			}
 
-			router.sendMu.Unlock()
+			router.unlockSend()
 		}()
 	}()
 
-	err = router.sock.Send(&knxnet.RoutingInd{Payload: data})
+	if err = router.flow.wait(ctx); err != nil {
+		return err
+	}
+
+	// Remember the frame beforehand, its echo may arrive before sending has returned.
+	router.dedup.sent(data, time.Now())
+
+	err = router.transmit(routingService(data))
 	if err == nil {
 		// Store this for potential resending.
-		// TODO: Ensure that the retained value is independent from the parameter, i.e. not modified
-		//       when the user changes a member of data.
-		router.retainer.PushBack(data)
+		router.retainer.PushBack(retained)
 
 		// We don't want to keep more messages than necessary. The overhead needs to be removed.
 		for uint(router.retainer.Len()) > router.config.RetainCount {
@@ -121,73 +293,134 @@ func (router *Router) Send(data cemi.Message) (err error) {
 	return err
 }
 
-// Inbound returns the channel which transmits incoming data. The channel will be closed when the
-// underlying Socket closes its inbound channel (which happens on read errors or upon closing it).
+// Inbound returns the channel which transmits incoming data in the order of arrival. Frames that
+// were received in system broadcast mode are wrapped in a *SystemBroadcast. A Router on several
+// interfaces wraps every frame in a *RoutedFrame. The channel
+// will be closed when the underlying Socket closes its inbound channel (which happens on read
+// errors or upon closing it), after the messages that were still queued have been delivered. After
+// Close, queued messages are only delivered to a reader that is already waiting; the rest is
+// discarded.
 func (router *Router) Inbound() <-chan cemi.Message {
-	return router.inbound
+	return router.inbound.out
+}
+
+// DroppedInbound returns the number of incoming messages that have been discarded because the
+// inbound queue was full.
+func (router *Router) DroppedInbound() uint64 {
+	return router.inbound.droppedCount()
+}
+
+// Errors returns the channel which transmits errors of the background goroutines, e.g. incoming
+// packets that could not be parsed or failed resends. The channel is buffered; errors are dropped
+// when it is full. It is closed together with the inbound channel.
+func (router *Router) Errors() <-chan error {
+	return router.errors.channel()
 }
 
 // Close closes the underlying socket and terminates the Router thereby.
-func (router *Router) Close() {
-	err := router.sock.Close()
-	if err != nil {
-		log.Fatal(err)
+func (router *Router) Close() error {
+	// Release the server goroutine if it is blocked on a full inbound queue, and the forwarder if
+	// nobody reads the inbound channel anymore.
+	router.inbound.abandon()
+
+	return router.sock.Close()
+}
+
+// transmit sends the packet on the interfaces that are not receive-only, or on the only one.
+func (router *Router) transmit(payload knxnet.ServicePackable) error {
+	if len(router.config.Interfaces) == 0 {
+		return router.sock.Send(payload)
+	}
+
+	sender, ok := router.sock.(interfaceSender)
+	if !ok {
+		return errors.New("socket does not support choosing the interface")
 	}
+
+	var errs []error
+
+	for _, ifi := range router.config.Interfaces {
+		if ifi.ReceiveOnly {
+			continue
+		}
+
+		if err := sender.SendOn(ifi.Interface, payload); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
 }
 
-// pushInbound sends the message through the inbound channel. If the sending blocks, it will launch
-// a goroutine which will do the sending.
-func (router *Router) pushInbound(msg cemi.Message) {
+// lockSend acquires the permission to send. It gives up when the context is done.
+func (router *Router) lockSend(ctx context.Context) error {
 	select {
-	case router.inbound <- msg:
+	case router.sendLock <- struct{}{}:
+		return nil
 
-	default:
-		go func() {
-			// Since this goroutine decouples from the server goroutine, it might try to send when
-			// the server closed the inbound channel. Sending to a closed channel will panic. But we
-			// don't care, because cool guys don't look at explosions.
-			defer func() {
-				if r := recover(); r != nil {
-					log.Fatal(r)
-				}
-			}()
-
-			router.inbound <- msg
-		}()
+	case <-ctx.Done():
+		return ctx.Err()
 	}
 }
 
-// getLastMessages returns the last count messages from the retainer in FIFO order.
+// unlockSend releases the permission to send.
+func (router *Router) unlockSend() {
+	<-router.sendLock
+}
+
+// pushInbound queues the message for the client, as permitted by the overflow policy. Duplicates
+// are dropped. When the queue fills up, the other routers are asked to pause.
+func (router *Router) pushInbound(msg cemi.Message) {
+	if router.dedup.duplicate(msg, time.Now()) {
+		util.Log(router, "Dropped duplicate %v", msg.MessageCode())
+		return
+	}
+
+	if router.inbound.len() >= router.config.BusyThreshold {
+		router.signalBusy()
+	}
+
+	router.inbound.push(msg)
+}
+
+// signalBusy sends a ROUTING_BUSY, unless the other routers are still pausing because of the
+// previous one.
+func (router *Router) signalBusy() {
+	now := time.Now()
+	if now.Sub(router.busySent) < router.config.BusyWaitTime {
+		return
+	}
+
+	router.busySent = now
+
+	err := router.transmit(&knxnet.RoutingBusy{
+		Status:   knxnet.DeviceStateOk,
+		WaitTime: router.config.BusyWaitTime,
+	})
+	if err != nil {
+		router.errors.emit(router, fmt.Errorf("sending routing busy: %w", err))
+	}
+}
+
+// getLastMessages returns the last count messages from the retainer in FIFO order. The messages
+// are copies of the frames that have been sent.
 func (router *Router) getLastMessages(count uint16) []cemi.Message {
-	if int(count) > router.retainer.Len() {
-		count = uint16(router.retainer.Len())
-	}
-	messages := make([]cemi.Message, 0, count)
-	// Start from the front if we need all messages, or skip to the (len-count)th element.
-	start := router.retainer.Len() - int(count)
-	i := 0
-	for elem := router.retainer.Front(); elem != nil; elem = elem.Next() {
-		if i >= start {
-			messages = append(messages, elem.Value.(cemi.Message))
-		}
-		i++
-		elem := router.retainer.Back()
-		for i := len(messages) - 1; i >= 0 && elem != nil; i-- {
-			if msg, ok := elem.Value.(cemi.Message); ok {
-				messages[i] = msg
-			} else {
-				util.Log(router, "Warning: retainer contains non-cemi.Message type")
-			}
-			elem = elem.Prev()
-		}
+	count = min(count, uint16(router.retainer.Len()))
+	messages := make([]cemi.Message, count)
+
+	elem := router.retainer.Back()
+	for i := len(messages) - 1; i >= 0; i-- {
+		messages[i] = elem.Value.(cemi.Message)
+		elem = elem.Prev()
 	}
+
 	return messages
 }
 
 // resendLost resends the last count messages.
 func (router *Router) resendLost(count uint16) {
-	router.sendMu.Lock()
-	defer router.sendMu.Unlock()
+	_ = router.lockSend(context.Background())
+	defer router.unlockSend()
 
 	messages := router.getLastMessages(count)
 
@@ -199,29 +432,25 @@ func (router *Router) serve() {
 	util.Log(router, "Started worker")
 	defer util.Log(router, "Worker exited")
 
-	defer close(router.inbound)
+	defer router.errors.close()
+	defer router.inbound.close()
 
 	for msg := range router.sock.Inbound() {
+		var ifi *net.Interface
+		if packet, ok := msg.(*knxnet.InterfaceService); ok {
+			msg, ifi = packet.Payload, packet.Interface
+		}
+
 		switch msg := msg.(type) {
 		case *knxnet.RoutingInd:
-			// Try to push it to the client without blocking this goroutine too long.
-			router.pushInbound(msg.Payload)
+			router.pushInbound(routedFrame(msg.Payload, ifi))
 
-		case *knxnet.RoutingBusy:
-			var trandom time.Duration
-			// If Control is 0, we should add a specified random amount of time
-			// to the WaitTime. Otherwise, it is not specified, we just wait WaitTime.
-			if msg.Control == 0 {
-				trandom = time.Duration(rand.Float64()*50) * time.Millisecond
-			}
+		case *knxnet.RoutingSystemBroadcast:
+			router.pushInbound(routedFrame(&SystemBroadcast{Message: msg.Payload}, ifi))
 
+		case *knxnet.RoutingBusy:
 			// Inhibit sending for the given time.
-			router.sendMu.Lock()
-
-			// Cap wait time.
-			waitTime := min(msg.WaitTime+trandom, maxWaitTime)
-
-			time.AfterFunc(waitTime, router.sendMu.Unlock)
+			router.flow.busy(msg, time.Now())
 
 		case *knxnet.RoutingLost:
 			// Resend the last msg.Count messages.
@@ -235,7 +464,178 @@ func (router *Router) sendMultiple(messages []cemi.Message) {
 	for _, message := range messages {
 		err := router.Send(message)
 		if err != nil {
-			util.Log(router, "Error sendMultiple: %v", err)
+			router.errors.emit(router, fmt.Errorf("resending lost message: %w", err))
+		}
+	}
+}
+
+// routedFrame attaches the interface on which the frame has been received, if it is known.
+func routedFrame(msg cemi.Message, ifi *net.Interface) cemi.Message {
+	if ifi == nil {
+		return msg
+	}
+
+	return &RoutedFrame{Message: msg, Interface: ifi}
+}
+
+// copyMessage creates a deep copy of the frame by packing and parsing it.
+func copyMessage(data cemi.Message) (cemi.Message, error) {
+	if bc, ok := data.(*SystemBroadcast); ok {
+		msg, err := copyMessage(bc.Message)
+		if err != nil {
+			return nil, err
+		}
+
+		return &SystemBroadcast{Message: msg}, nil
+	}
+
+	buffer := make([]byte, cemi.Size(data))
+	cemi.Pack(buffer, data)
+
+	var msg cemi.Message
+	if _, err := cemi.Unpack(buffer, &msg); err != nil {
+		return nil, fmt.Errorf("copying frame: %w", err)
+	}
+
+	return msg, nil
+}
+
+// These parameters of the routing flow control are given by the specification.
+const (
+	// Busy indications that arrive within this interval are counted once.
+	busyCountInterval = 10 * time.Millisecond
+
+	// Each counted busy indication widens the random backoff by one slot.
+	busyRandomSlot = 50 * time.Millisecond
+
+	// After the last busy indication, the counter is kept for this duration per count and then
+	// decremented by one per decay interval.
+	busySlowDuration  = 100 * time.Millisecond
+	busyDecayInterval = 5 * time.Millisecond
+
+	// RouterConfig.RateLimit applies to this window.
+	rateLimitWindow = time.Second
+)
+
+// maxBusyWaitTime caps the wait time demanded by other routers.
+const maxBusyWaitTime = time.Second
+
+// routingFlow decides when the Router may send. It combines the pauses that other routers demand
+// by ROUTING_BUSY with the limit on the number of telegrams per second.
+type routingFlow struct {
+	mu sync.Mutex
+
+	// Times of the most recent transmissions, used as ring buffer
+	sent  []time.Time
+	next  int
+	limit int
+
+	// Sending is inhibited until this point in time.
+	pausedUntil time.Time
+
+	// Number of busy indications that have been counted recently
+	busyCount uint
+	lastBusy  time.Time
+	decayFrom time.Time
+
+	random func() float64
+}
+
+// newRoutingFlow creates the flow control for at most limit telegrams per second.
+func newRoutingFlow(limit uint) *routingFlow {
+	return &routingFlow{
+		sent:   make([]time.Time, 0, limit),
+		limit:  int(limit),
+		random: rand.Float64,
+	}
+}
+
+// busy inhibits sending as demanded by the busy indication. Busy indications for all routers
+// extend the pause by a random backoff that grows with the number of recent busy indications.
+func (flow *routingFlow) busy(msg *knxnet.RoutingBusy, now time.Time) {
+	flow.mu.Lock()
+	defer flow.mu.Unlock()
+
+	flow.decay(now)
+
+	wait := min(msg.WaitTime, maxBusyWaitTime)
+
+	if msg.Control == 0 {
+		if flow.lastBusy.IsZero() || now.Sub(flow.lastBusy) > busyCountInterval {
+			flow.busyCount++
+		}
+
+		flow.lastBusy = now
+		flow.decayFrom = now.Add(time.Duration(flow.busyCount) * busySlowDuration)
+
+		wait += time.Duration(flow.random() * float64(time.Duration(flow.busyCount)*busyRandomSlot))
+	}
+
+	if until := now.Add(wait); until.After(flow.pausedUntil) {
+		flow.pausedUntil = until
+	}
+}
+
+// decay decrements the busy counter for the time that has passed since the last busy indication.
+func (flow *routingFlow) decay(now time.Time) {
+	if flow.busyCount == 0 || now.Before(flow.decayFrom) {
+		return
+	}
+
+	steps := now.Sub(flow.decayFrom) / busyDecayInterval
+	flow.busyCount -= min(flow.busyCount, uint(steps))
+	flow.decayFrom = flow.decayFrom.Add(steps * busyDecayInterval)
+}
+
+// delay returns how long sending has to wait.
+func (flow *routingFlow) delay(now time.Time) time.Duration {
+	delay := flow.pausedUntil.Sub(now)
+
+	if len(flow.sent) >= flow.limit {
+		delay = max(delay, flow.sent[flow.next].Add(rateLimitWindow).Sub(now))
+	}
+
+	return delay
+}
+
+// record registers a transmission for the rate limit.
+func (flow *routingFlow) record(now time.Time) {
+	if len(flow.sent) < flow.limit {
+		flow.sent = append(flow.sent, now)
+		return
+	}
+
+	flow.sent[flow.next] = now
+	flow.next = (flow.next + 1) % flow.limit
+}
+
+// wait blocks until sending is permitted and registers the transmission. It gives up when the
+// given context is done.
+func (flow *routingFlow) wait(ctx context.Context) error {
+	for {
+		flow.mu.Lock()
+
+		now := time.Now()
+
+		delay := flow.delay(now)
+		if delay <= 0 {
+			flow.record(now)
+			flow.mu.Unlock()
+
+			return nil
+		}
+
+		flow.mu.Unlock()
+
+		// The pause may have been extended in the meantime, hence check again afterwards.
+		timer := time.NewTimer(delay)
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+
+		case <-timer.C:
 		}
 	}
 }
@@ -259,7 +659,12 @@ func NewGroupRouter(multicastAddress string, config RouterConfig) (gr GroupRoute
 
 // Send a group communication.
 func (gr *GroupRouter) Send(event GroupEvent) error {
-	return gr.Router.Send(&cemi.LDataInd{LData: buildGroupOutbound(event)})
+	return gr.Router.Send(&cemi.LDataInd{LData: GroupEventFrame(event)})
+}
+
+// SendContext sends a group communication, giving up when the given context is done.
+func (gr *GroupRouter) SendContext(ctx context.Context, event GroupEvent) error {
+	return gr.Router.SendContext(ctx, &cemi.LDataInd{LData: GroupEventFrame(event)})
 }
 
 // Inbound returns the channel on which group communication can be received.
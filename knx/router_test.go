@@ -0,0 +1,303 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knx
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/mobilarte/knx-exp/knx/cemi"
+	"github.com/mobilarte/knx-exp/knx/knxnet"
+)
+
+// makeRouter creates a Router on top of the given socket.
+func makeRouter(sock knxnet.Socket, config RouterConfig) *Router {
+	return newRouter(sock, checkRouterConfig(config))
+}
+
+func makeRoutedLData(control1 cemi.ControlField1) cemi.LData {
+	return cemi.LData{
+		Control1:    control1 | cemi.Control1StdFrame,
+		Control2:    cemi.Control2GroupAddr | cemi.Control2Hops(6),
+		Source:      0x1101,
+		Destination: 0x0901,
+		Data:        &cemi.AppData{Command: cemi.GroupValueWrite, Data: []byte{1}},
+	}
+}
+
+func TestRouter_SystemBroadcast(t *testing.T) {
+	client, peer := newDummySockets()
+
+	router := makeRouter(client, DefaultRouterConfig)
+	t.Cleanup(func() { _ = router.Close() })
+
+	t.Run("Send", func(t *testing.T) {
+		messages := []cemi.Message{
+			&cemi.LDataInd{LData: makeRoutedLData(cemi.Control1NoSysBroadcast)},
+			&cemi.LDataInd{LData: makeRoutedLData(0)},
+			&SystemBroadcast{Message: &cemi.LDataInd{LData: makeRoutedLData(cemi.Control1NoSysBroadcast)}},
+		}
+
+		for _, msg := range messages {
+			if err := router.Send(msg); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if _, ok := (<-peer.Inbound()).(*knxnet.RoutingInd); !ok {
+			t.Error("Expected a routing indication")
+		}
+
+		for range 2 {
+			msg, ok := (<-peer.Inbound()).(*knxnet.RoutingSystemBroadcast)
+			if !ok {
+				t.Fatal("Expected a routing system broadcast")
+			}
+
+			if _, ok := msg.Payload.(*cemi.LDataInd); !ok {
+				t.Errorf("Unexpected payload %T", msg.Payload)
+			}
+		}
+	})
+
+	t.Run("Receive", func(t *testing.T) {
+		payload := &cemi.LDataInd{LData: makeRoutedLData(0)}
+
+		if err := peer.Send(&knxnet.RoutingInd{Payload: payload}); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := peer.Send(&knxnet.RoutingSystemBroadcast{Payload: payload}); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, ok := (<-router.Inbound()).(*cemi.LDataInd); !ok {
+			t.Error("Expected a plain frame")
+		}
+
+		bc, ok := (<-router.Inbound()).(*SystemBroadcast)
+		if !ok {
+			t.Fatal("Expected a system broadcast")
+		}
+
+		if bc.MessageCode() != cemi.LDataIndCode {
+			t.Errorf("Unexpected message code %v", bc.MessageCode())
+		}
+	})
+}
+
+func TestRoutingFlow_Busy(t *testing.T) {
+	flow := newRoutingFlow(50)
+	flow.random = func() float64 { return 1 }
+
+	start := time.Now()
+	busy := &knxnet.RoutingBusy{WaitTime: 20 * time.Millisecond}
+
+	// Busy indications within 10 ms are counted once.
+	flow.busy(busy, start)
+	flow.busy(busy, start.Add(5*time.Millisecond))
+
+	if flow.busyCount != 1 {
+		t.Errorf("Expected busy count 1, got %d", flow.busyCount)
+	}
+
+	// The random backoff grows with each counted busy indication.
+	second := start.Add(30 * time.Millisecond)
+	flow.busy(busy, second)
+
+	if flow.busyCount != 2 {
+		t.Errorf("Expected busy count 2, got %d", flow.busyCount)
+	}
+
+	if delay := flow.delay(second); delay != 20*time.Millisecond+2*busyRandomSlot {
+		t.Errorf("Unexpected delay %v", delay)
+	}
+
+	// The counter is kept for 200 ms and then decays by one every 5 ms.
+	flow.decay(second.Add(200 * time.Millisecond))
+
+	if flow.busyCount != 2 {
+		t.Errorf("Expected busy count 2, got %d", flow.busyCount)
+	}
+
+	flow.decay(second.Add(205 * time.Millisecond))
+
+	if flow.busyCount != 1 {
+		t.Errorf("Expected busy count 1, got %d", flow.busyCount)
+	}
+
+	flow.decay(second.Add(time.Second))
+
+	if flow.busyCount != 0 {
+		t.Errorf("Expected busy count 0, got %d", flow.busyCount)
+	}
+
+	// Busy indications for specific devices do not count.
+	flow.busy(&knxnet.RoutingBusy{WaitTime: 20 * time.Millisecond, Control: 1}, second.Add(time.Second))
+
+	if flow.busyCount != 0 {
+		t.Errorf("Expected busy count 0, got %d", flow.busyCount)
+	}
+}
+
+func TestRoutingFlow_RateLimit(t *testing.T) {
+	flow := newRoutingFlow(5)
+	start := time.Now()
+
+	for i := range 5 {
+		if delay := flow.delay(start); delay > 0 {
+			t.Fatalf("Telegram %d delayed by %v", i, delay)
+		}
+
+		flow.record(start.Add(time.Duration(i) * time.Millisecond))
+	}
+
+	if delay := flow.delay(start.Add(10 * time.Millisecond)); delay != 990*time.Millisecond {
+		t.Errorf("Unexpected delay %v", delay)
+	}
+
+	// The window slides with the oldest transmission.
+	flow.record(start.Add(time.Second))
+
+	if delay := flow.delay(start.Add(time.Second)); delay != time.Millisecond {
+		t.Errorf("Unexpected delay %v", delay)
+	}
+}
+
+func TestRouter_RetainCopies(t *testing.T) {
+	client, peer := newDummySockets()
+	defer peer.closeOut()
+
+	router := makeRouter(client, RouterConfig{RetainCount: 2})
+	t.Cleanup(func() { _ = router.Close() })
+
+	for i := range 3 {
+		msg := &cemi.LDataInd{LData: makeRoutedLData(cemi.Control1NoSysBroadcast)}
+		msg.Destination = uint16(i)
+
+		if err := router.Send(msg); err != nil {
+			t.Fatal(err)
+		}
+
+		// Modifying the frame after sending must not affect the retained copy.
+		msg.Destination = 0xffff
+	}
+
+	messages := router.getLastMessages(5)
+	if len(messages) != 2 {
+		t.Fatalf("Expected 2 messages, got %d", len(messages))
+	}
+
+	for i, msg := range messages {
+		if dest := msg.(*cemi.LDataInd).Destination; dest != uint16(i+1) {
+			t.Errorf("Message %d: unexpected destination %v", i, dest)
+		}
+	}
+}
+
+func TestRouter_SignalBusy(t *testing.T) {
+	client, peer := newDummySockets()
+
+	router := makeRouter(client, RouterConfig{
+		Inbound:       InboundConfig{QueueSize: 4, Overflow: OverflowDropNewest},
+		BusyThreshold: 2,
+		BusyWaitTime:  time.Second,
+	})
+	t.Cleanup(func() { _ = router.Close() })
+
+	// Nobody reads from the router, so its inbound queue fills up.
+	for range 5 {
+		ind := &knxnet.RoutingInd{Payload: &cemi.LDataInd{LData: makeRoutedLData(cemi.Control1NoSysBroadcast)}}
+		if err := peer.Send(ind); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	busy, ok := (<-peer.Inbound()).(*knxnet.RoutingBusy)
+	if !ok {
+		t.Fatal("Expected a routing busy")
+	}
+
+	if busy.WaitTime != time.Second || busy.Control != 0 {
+		t.Errorf("Unexpected routing busy %+v", busy)
+	}
+
+	// The busy indication is not repeated within the wait time.
+	select {
+	case msg := <-peer.Inbound():
+		t.Errorf("Unexpected %T", msg)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+// interfaceSocket is a dummy socket that pretends to be on several interfaces.
+type interfaceSocket struct {
+	*dummySocket
+	sentOn []string
+}
+
+func (sock *interfaceSocket) SendOn(ifi *net.Interface, payload knxnet.ServicePackable) error {
+	sock.sentOn = append(sock.sentOn, ifi.Name)
+	return sock.sendAny(payload)
+}
+
+func TestRouter_Interfaces(t *testing.T) {
+	client, peer := newDummySockets()
+	building := &net.Interface{Index: 2, Name: "building"}
+	management := &net.Interface{Index: 3, Name: "management"}
+
+	sock := &interfaceSocket{dummySocket: client}
+	router := makeRouter(sock, RouterConfig{Interfaces: []RouterInterface{
+		{Interface: building},
+		{Interface: management, ReceiveOnly: true},
+	}})
+	t.Cleanup(func() { _ = router.Close() })
+
+	t.Run("Send", func(t *testing.T) {
+		if err := router.Send(&cemi.LDataInd{LData: makeRoutedLData(cemi.Control1NoSysBroadcast)}); err != nil {
+			t.Fatal(err)
+		}
+
+		<-peer.Inbound()
+
+		if len(sock.sentOn) != 1 || sock.sentOn[0] != building.Name {
+			t.Errorf("Unexpected interfaces %v", sock.sentOn)
+		}
+	})
+
+	t.Run("Receive", func(t *testing.T) {
+		err := peer.sendAny(&knxnet.InterfaceService{
+			Payload:   &knxnet.RoutingInd{Payload: &cemi.LDataInd{LData: makeRoutedLData(0)}},
+			Interface: management,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		frame, ok := (<-router.Inbound()).(*RoutedFrame)
+		if !ok {
+			t.Fatal("Expected a routed frame")
+		}
+
+		if frame.Interface != management {
+			t.Errorf("Unexpected interface %v", frame.Interface.Name)
+		}
+
+		if _, ok := frame.Message.(*cemi.LDataInd); !ok {
+			t.Errorf("Unexpected message %T", frame.Message)
+		}
+	})
+}
+
+func TestMergeRouterInterfaces(t *testing.T) {
+	ifis := []*net.Interface{{Index: 2, Name: "eth0"}, {Index: 3, Name: "eth1"}}
+
+	merged := mergeRouterInterfaces(ifis, []RouterInterface{
+		{Interface: &net.Interface{Name: "eth1"}, ReceiveOnly: true},
+	})
+
+	if len(merged) != 2 || merged[0].ReceiveOnly || !merged[1].ReceiveOnly || merged[1].Interface != ifis[1] {
+		t.Errorf("Unexpected interfaces %+v", merged)
+	}
+}
@@ -0,0 +1,290 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knx
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/mobilarte/knx-exp/knx/cemi"
+)
+
+// These errors are the result of telegrams that never made it to the gateway.
+var (
+	ErrSendQueueFull = errors.New("send queue is full")
+	ErrTunnelClosed  = errors.New("tunnel has been closed")
+)
+
+// A SendFuture is the handle of a telegram in the send queue. It resolves once the gateway has
+// acknowledged the telegram, or confirmed it if TunnelConfig.WaitForConfirmation is set, or once
+// sending has failed.
+type SendFuture struct {
+	done chan struct{}
+	err  error
+}
+
+// newSendFuture creates an unresolved future.
+func newSendFuture() *SendFuture {
+	return &SendFuture{done: make(chan struct{})}
+}
+
+// Done returns a channel that is closed when the future has been resolved.
+func (future *SendFuture) Done() <-chan struct{} {
+	return future.done
+}
+
+// Err returns the result of sending. It must only be called after Done has been closed.
+func (future *SendFuture) Err() error {
+	return future.err
+}
+
+// Wait blocks until the future has been resolved and returns the result of sending. It gives up
+// when the given context is done, but the telegram may still be sent.
+func (future *SendFuture) Wait(ctx context.Context) error {
+	select {
+	case <-future.done:
+		return future.err
+
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// resolve sets the result. It must be called exactly once.
+func (future *SendFuture) resolve(err error) {
+	future.err = err
+	close(future.done)
+}
+
+// sendRequest is a telegram in the send queue.
+type sendRequest struct {
+	ctx    context.Context
+	data   cemi.Message
+	future *SendFuture
+}
+
+// sendLanes is the number of priority lanes of the send queue.
+const sendLanes = 4
+
+// sendLane maps the priority of a message to its lane in the send queue. Lower lanes are served
+// first: system, urgent, normal, low. Messages without priority are treated as normal.
+func sendLane(data cemi.Message) int {
+	prio := cemi.PrioNormal
+
+	switch msg := data.(type) {
+	case *cemi.LDataReq:
+		prio = msg.Control1.Priority()
+	case *cemi.LDataInd:
+		prio = msg.Control1.Priority()
+	}
+
+	switch prio {
+	case cemi.PrioSystem:
+		return 0
+	case cemi.PrioUrgent:
+		return 1
+	case cemi.PrioNormal:
+		return 2
+	default:
+		return 3
+	}
+}
+
+// sendQueue holds the telegrams that wait to be sent, in one FIFO lane per priority.
+type sendQueue struct {
+	mu       sync.Mutex
+	lanes    [sendLanes][]*sendRequest
+	depth    int
+	capacity int
+	closed   bool
+
+	// Signals the worker that a request has been queued.
+	ready chan struct{}
+}
+
+// newSendQueue creates a queue that holds at most capacity telegrams.
+func newSendQueue(capacity int) *sendQueue {
+	return &sendQueue{capacity: capacity, ready: make(chan struct{}, 1)}
+}
+
+// push queues the request.
+func (queue *sendQueue) push(req *sendRequest) error {
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+
+	if queue.closed {
+		return ErrTunnelClosed
+	}
+
+	if queue.depth >= queue.capacity {
+		return ErrSendQueueFull
+	}
+
+	lane := sendLane(req.data)
+	queue.lanes[lane] = append(queue.lanes[lane], req)
+	queue.depth++
+
+	select {
+	case queue.ready <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+// pop removes the oldest request of the highest priority, or returns nil if the queue is empty.
+func (queue *sendQueue) pop() *sendRequest {
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+
+	for i, lane := range queue.lanes {
+		if len(lane) > 0 {
+			req := lane[0]
+			lane[0] = nil
+			queue.lanes[i] = lane[1:]
+			queue.depth--
+
+			return req
+		}
+	}
+
+	return nil
+}
+
+// len returns the number of queued telegrams.
+func (queue *sendQueue) len() int {
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+
+	return queue.depth
+}
+
+// close rejects further requests and fails the queued ones.
+func (queue *sendQueue) close() {
+	queue.mu.Lock()
+	queue.closed = true
+	queue.mu.Unlock()
+
+	for req := queue.pop(); req != nil; req = queue.pop() {
+		req.future.resolve(ErrTunnelClosed)
+	}
+}
+
+// Enqueue places the telegram in the send queue and returns immediately. Telegrams of higher KNX
+// priority are sent first, telegrams of the same priority in order.
+func (conn *Tunnel) Enqueue(data cemi.Message) *SendFuture {
+	return conn.EnqueueContext(context.Background(), data)
+}
+
+// EnqueueContext is like Enqueue. If the given context is done before the telegram has been
+// acknowledged, sending is aborted and the future resolves with the context's error.
+func (conn *Tunnel) EnqueueContext(ctx context.Context, data cemi.Message) *SendFuture {
+	future := newSendFuture()
+
+	if err := conn.sendQueue.push(&sendRequest{ctx: ctx, data: data, future: future}); err != nil {
+		future.resolve(err)
+	}
+
+	return future
+}
+
+// SendQueueDepth returns the number of telegrams that wait in the send queue. The telegram that is
+// currently being sent is not included.
+func (conn *Tunnel) SendQueueDepth() int {
+	return conn.sendQueue.len()
+}
+
+// serveSendQueue sends the queued telegrams one after another, as fast as the rate limit permits.
+// Only the transmission is sequential; awaiting the confirmation of a telegram overlaps with
+// sending the next ones.
+func (conn *Tunnel) serveSendQueue() {
+	defer conn.wait.Done()
+	defer conn.sendQueue.close()
+
+	// Abort transmissions when the tunnel is closed.
+	base, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		select {
+		case <-conn.done:
+			cancel()
+		case <-base.Done():
+		}
+	}()
+
+	var interval time.Duration
+	if conn.config.SendRate > 0 {
+		interval = time.Duration(float64(time.Second) / conn.config.SendRate)
+	}
+
+	var next time.Time
+
+	for {
+		req := conn.sendQueue.pop()
+		if req == nil {
+			select {
+			case <-conn.done:
+				return
+
+			case <-conn.sendQueue.ready:
+				continue
+			}
+		}
+
+		// Respect the rate limit.
+		if wait := time.Until(next); wait > 0 {
+			timer := time.NewTimer(wait)
+
+			select {
+			case <-conn.done:
+				timer.Stop()
+				req.future.resolve(ErrTunnelClosed)
+
+				return
+
+			case <-timer.C:
+			}
+		}
+
+		next = time.Now().Add(interval)
+
+		conn.sendQueued(base, req)
+	}
+}
+
+// sendQueued transmits a queued telegram and resolves its future.
+func (conn *Tunnel) sendQueued(base context.Context, req *sendRequest) {
+	ctx, cancel := context.WithCancel(req.ctx)
+	stop := context.AfterFunc(base, cancel)
+
+	resolve := func(err error) {
+		stop()
+		cancel()
+
+		// Tell the caller why sending has been aborted.
+		if err != nil && base.Err() != nil && req.ctx.Err() == nil {
+			err = ErrTunnelClosed
+		}
+
+		req.future.resolve(err)
+	}
+
+	// The caller may have given up while the telegram was waiting.
+	if err := ctx.Err(); err != nil {
+		resolve(err)
+		return
+	}
+
+	waiter, err := conn.transmitTunnel(ctx, req.data)
+	if err != nil || waiter == nil {
+		resolve(err)
+		return
+	}
+
+	go func() {
+		resolve(conn.awaitConfirmation(ctx, waiter))
+	}()
+}
@@ -0,0 +1,178 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knx
+
+import (
+	"context"
+	"errors"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/mobilarte/knx-exp/knx/cemi"
+	"github.com/mobilarte/knx-exp/knx/knxnet"
+)
+
+// startSendQueue runs the send queue of a tunnel that has been created by makeTunnelConn until the
+// test has finished.
+func startSendQueue(t *testing.T, conn *Tunnel) {
+	conn.sendQueue = newSendQueue(DefaultTunnelConfig.SendQueueSize)
+	conn.done = make(chan struct{})
+
+	conn.wait.Add(1)
+
+	go conn.serveSendQueue()
+
+	t.Cleanup(func() {
+		close(conn.done)
+		conn.wait.Wait()
+	})
+}
+
+func makePrioLDataReq(prio cemi.Priority, dest uint16) *cemi.LDataReq {
+	req := &cemi.LDataReq{LData: GroupEventFrame(GroupEvent{
+		Command:     GroupWrite,
+		Destination: cemi.GroupAddr(dest),
+		Data:        []byte{1},
+	})}
+	req.Control1 = req.Control1&^cemi.Control1Prio(3) | cemi.Control1Prio(prio)
+
+	return req
+}
+
+func TestSendQueue_Priority(t *testing.T) {
+	queue := newSendQueue(10)
+
+	for i, prio := range []cemi.Priority{cemi.PrioLow, cemi.PrioNormal, cemi.PrioLow, cemi.PrioUrgent, cemi.PrioSystem} {
+		err := queue.push(&sendRequest{data: makePrioLDataReq(prio, uint16(i)), future: newSendFuture()})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if queue.len() != 5 {
+		t.Errorf("Expected depth 5, got %d", queue.len())
+	}
+
+	for _, expected := range []uint16{4, 3, 1, 0, 2} {
+		req := queue.pop()
+		if dest := req.data.(*cemi.LDataReq).Destination; dest != expected {
+			t.Errorf("Expected destination %d, got %d", expected, dest)
+		}
+	}
+
+	if queue.pop() != nil {
+		t.Error("Queue should be empty")
+	}
+}
+
+func TestSendQueue_Full(t *testing.T) {
+	queue := newSendQueue(1)
+	first := &sendRequest{data: &cemi.UnsupportedMessage{}, future: newSendFuture()}
+
+	if err := queue.push(first); err != nil {
+		t.Fatal(err)
+	}
+
+	err := queue.push(&sendRequest{data: &cemi.UnsupportedMessage{}, future: newSendFuture()})
+	if !errors.Is(err, ErrSendQueueFull) {
+		t.Fatalf("Expected %v, got %v", ErrSendQueueFull, err)
+	}
+
+	queue.close()
+
+	if err := first.future.Wait(context.Background()); !errors.Is(err, ErrTunnelClosed) {
+		t.Errorf("Expected %v, got %v", ErrTunnelClosed, err)
+	}
+
+	err = queue.push(&sendRequest{data: &cemi.UnsupportedMessage{}, future: newSendFuture()})
+	if !errors.Is(err, ErrTunnelClosed) {
+		t.Errorf("Expected %v, got %v", ErrTunnelClosed, err)
+	}
+}
+
+func TestTunnelConn_Enqueue(t *testing.T) {
+	client, gateway := newDummySockets()
+	ack := make(chan *knxnet.TunnelRes)
+
+	config := DefaultTunnelConfig
+	config.SendRate = 100
+
+	conn := makeTunnelConn(client, config, 1)
+	conn.ack = ack
+
+	// Hold the worker back until all telegrams have been queued.
+	conn.seqMu.Lock()
+
+	startSendQueue(t, conn)
+
+	t.Run("Gateway", func(t *testing.T) {
+		t.Parallel()
+
+		defer func() {
+			err := gateway.Close()
+			if err != nil {
+				log.Fatal(err)
+			}
+		}()
+
+		var last time.Time
+
+		// The first telegram was already taken by the worker, the rest is sorted by priority.
+		for _, expected := range []uint16{1, 3, 2} {
+			msg := <-gateway.Inbound()
+
+			req, ok := msg.(*knxnet.TunnelReq)
+			if !ok {
+				t.Fatalf("Unexpected type %T", msg)
+			}
+
+			if dest := req.Payload.(*cemi.LDataReq).Destination; dest != expected {
+				t.Errorf("Expected destination %d, got %d", expected, dest)
+			}
+
+			if !last.IsZero() && time.Since(last) < 9*time.Millisecond {
+				t.Errorf("Rate limit exceeded: %v", time.Since(last))
+			}
+
+			last = time.Now()
+
+			ack <- &knxnet.TunnelRes{Channel: req.Channel, SeqNumber: req.SeqNumber, Status: 0}
+		}
+	})
+
+	t.Run("Client", func(t *testing.T) {
+		t.Parallel()
+
+		defer func() {
+			err := client.Close()
+			if err != nil {
+				log.Fatal(err)
+			}
+		}()
+
+		futures := []*SendFuture{conn.Enqueue(makePrioLDataReq(cemi.PrioLow, 1))}
+
+		// Wait until the worker has taken the first telegram.
+		for conn.SendQueueDepth() != 0 {
+			time.Sleep(time.Millisecond)
+		}
+
+		futures = append(futures,
+			conn.Enqueue(makePrioLDataReq(cemi.PrioLow, 2)),
+			conn.Enqueue(makePrioLDataReq(cemi.PrioSystem, 3)),
+		)
+
+		if conn.SendQueueDepth() != 2 {
+			t.Errorf("Expected depth 2, got %d", conn.SendQueueDepth())
+		}
+
+		conn.seqMu.Unlock()
+
+		for _, future := range futures {
+			if err := future.Wait(context.Background()); err != nil {
+				t.Error(err)
+			}
+		}
+	})
+}
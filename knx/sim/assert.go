@@ -0,0 +1,84 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package sim
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	"github.com/mobilarte/knx-exp/knx"
+	"github.com/mobilarte/knx-exp/knx/cemi"
+)
+
+// A Matcher selects telegrams.
+type Matcher func(tg Telegram) bool
+
+// GroupEvent extracts the group communication from the telegram.
+func (tg Telegram) GroupEvent() (knx.GroupEvent, bool) {
+	return knx.GroupEventOf(&cemi.LDataInd{LData: tg.LData})
+}
+
+// Group matches group communication with the given command and destination. If data is not nil,
+// the payload must be equal as well.
+func Group(command knx.GroupCommand, dest cemi.GroupAddr, data []byte) Matcher {
+	return func(tg Telegram) bool {
+		event, ok := tg.GroupEvent()
+
+		return ok && event.Command == command && event.Destination == dest &&
+			(data == nil || bytes.Equal(event.Data, data))
+	}
+}
+
+// From matches the telegrams of the given sender.
+func From(source cemi.IndividualAddr) Matcher {
+	return func(tg Telegram) bool {
+		return tg.Source == source
+	}
+}
+
+// All matches the telegrams that satisfy all of the given matchers.
+func All(matchers ...Matcher) Matcher {
+	return func(tg Telegram) bool {
+		for _, match := range matchers {
+			if !match(tg) {
+				return false
+			}
+		}
+
+		return true
+	}
+}
+
+// T is the part of testing.TB that the assertions use.
+type T interface {
+	Helper()
+	Fatalf(format string, args ...any)
+}
+
+// Expect waits up to the timeout for a matching telegram and fails the test if there is none.
+func (bus *Bus) Expect(t T, timeout time.Duration, match Matcher) Telegram {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	tg, err := bus.Await(ctx, match)
+	if err != nil {
+		t.Fatalf("No matching telegram within %v; seen: %v", timeout, bus.Telegrams())
+	}
+
+	return tg
+}
+
+// ExpectNone waits for the given duration and fails the test if a matching telegram is seen.
+func (bus *Bus) ExpectNone(t T, duration time.Duration, match Matcher) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), duration)
+	defer cancel()
+
+	if tg, err := bus.Await(ctx, match); err == nil {
+		t.Fatalf("Unexpected telegram %v", tg)
+	}
+}
@@ -0,0 +1,295 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+// Package sim provides an in-memory KNX bus. Clients attach to it either through the raw cEMI
+// interface or as knx.GroupClient, which allows to test KNX applications without hardware.
+package sim
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/mobilarte/knx-exp/knx/cemi"
+)
+
+// ErrClosed is returned when sending on a closed bus or client.
+var ErrClosed = errors.New("bus or client has been closed")
+
+// Config configures a Bus.
+type Config struct {
+	// Latency delays the delivery of every telegram and its confirmation.
+	Latency time.Duration
+
+	// Jitter adds a random delay between 0 and Jitter to the latency.
+	Jitter time.Duration
+
+	// Loss is the probability between 0 and 1 with which a receiver misses a telegram. The
+	// sender is not affected and still receives a positive confirmation.
+	Loss float64
+
+	// QueueSize is the number of telegrams that wait to be delivered to a client. Further
+	// telegrams are dropped until the client has caught up.
+	QueueSize int
+
+	// HistorySize is the number of telegrams that the bus remembers for the assertions.
+	HistorySize int
+
+	// Random returns random numbers in [0, 1) for the loss and jitter. It defaults to
+	// math/rand/v2.Float64; tests can make it deterministic.
+	Random func() float64
+}
+
+// DefaultConfig is the configuration of an ideal bus.
+var DefaultConfig = Config{
+	QueueSize:   256,
+	HistorySize: 4096,
+}
+
+// checkConfig makes sure that the configuration is actually usable.
+func checkConfig(config Config) Config {
+	if config.QueueSize <= 0 {
+		config.QueueSize = DefaultConfig.QueueSize
+	}
+
+	if config.HistorySize <= 0 {
+		config.HistorySize = DefaultConfig.HistorySize
+	}
+
+	if config.Random == nil {
+		config.Random = rand.Float64
+	}
+
+	return config
+}
+
+// A Telegram is a frame that has been sent on the bus.
+type Telegram struct {
+	// Time is the moment at which the telegram has been sent.
+	Time time.Time
+
+	// LData is the frame. The source is the address of the sender if it has not set one.
+	cemi.LData
+
+	// Rejected indicates that the bus was busy. Nobody has received the telegram and the sender
+	// has got a negative confirmation.
+	Rejected bool
+}
+
+// String generates a string representation of the telegram.
+func (tg Telegram) String() string {
+	state := ""
+	if tg.Rejected {
+		state = " (rejected)"
+	}
+
+	return fmt.Sprintf("%v -> %#04x: %v%s", tg.Source, tg.Destination, tg.Data, state)
+}
+
+// A Bus is an in-memory KNX line. Every telegram that a client sends is delivered as L_Data.ind
+// to all other clients, and confirmed to the sender with L_Data.con.
+type Bus struct {
+	config Config
+
+	mu        sync.Mutex
+	clients   map[*Client]struct{}
+	history   []Telegram
+	changed   chan struct{}
+	busyUntil time.Time
+	closed    bool
+}
+
+// NewBus creates an empty bus.
+func NewBus(config Config) *Bus {
+	return &Bus{
+		config:  checkConfig(config),
+		clients: map[*Client]struct{}{},
+		changed: make(chan struct{}),
+	}
+}
+
+// Attach connects a client with the given individual address, which exchanges raw cEMI frames.
+func (bus *Bus) Attach(addr cemi.IndividualAddr) (*Client, error) {
+	client := newClient(bus, addr)
+
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+
+	if bus.closed {
+		return nil, ErrClosed
+	}
+
+	bus.clients[client] = struct{}{}
+
+	go client.serve()
+
+	return client, nil
+}
+
+// AttachGroup connects a client with the given individual address for group communication.
+func (bus *Bus) AttachGroup(addr cemi.IndividualAddr) (*GroupClient, error) {
+	client, err := bus.Attach(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	return newGroupClient(client), nil
+}
+
+// SetBusy makes the bus reject all telegrams for the given duration. Their senders receive a
+// negative confirmation, as if the repetitions had been exhausted.
+func (bus *Bus) SetBusy(duration time.Duration) {
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+
+	bus.busyUntil = time.Now().Add(duration)
+}
+
+// Telegrams returns the telegrams in the history, oldest first.
+func (bus *Bus) Telegrams() []Telegram {
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+
+	return append([]Telegram(nil), bus.history...)
+}
+
+// ClearHistory forgets all telegrams that have been sent so far.
+func (bus *Bus) ClearHistory() {
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+
+	bus.history = nil
+}
+
+// Count returns the number of telegrams in the history that match.
+func (bus *Bus) Count(match Matcher) int {
+	count := 0
+
+	for _, tg := range bus.Telegrams() {
+		if match(tg) {
+			count++
+		}
+	}
+
+	return count
+}
+
+// Await waits until a matching telegram has been sent. Telegrams in the history are considered
+// as well. It returns the first matching telegram, or the context's error.
+func (bus *Bus) Await(ctx context.Context, match Matcher) (Telegram, error) {
+	for {
+		bus.mu.Lock()
+		changed := bus.changed
+		history := bus.history
+		bus.mu.Unlock()
+
+		for _, tg := range history {
+			if match(tg) {
+				return tg, nil
+			}
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return Telegram{}, ctx.Err()
+		}
+	}
+}
+
+// Close detaches all clients. Their inbound channels are closed. It is safe to call Close more
+// than once.
+func (bus *Bus) Close() error {
+	bus.mu.Lock()
+
+	if bus.closed {
+		bus.mu.Unlock()
+		return nil
+	}
+
+	bus.closed = true
+	clients := bus.clients
+	bus.clients = map[*Client]struct{}{}
+	bus.mu.Unlock()
+
+	for client := range clients {
+		client.stop()
+	}
+
+	return nil
+}
+
+// detach removes the client from the bus.
+func (bus *Bus) detach(client *Client) {
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+
+	delete(bus.clients, client)
+}
+
+// delay determines when a telegram that is sent now will arrive.
+func (bus *Bus) delay(now time.Time) time.Time {
+	delay := bus.config.Latency
+	if bus.config.Jitter > 0 {
+		delay += time.Duration(bus.config.Random() * float64(bus.config.Jitter))
+	}
+
+	return now.Add(delay)
+}
+
+// transmit puts the frame of the sender on the bus.
+func (bus *Bus) transmit(sender *Client, ldata cemi.LData) error {
+	now := time.Now()
+
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+
+	if bus.closed {
+		return ErrClosed
+	}
+
+	tg := Telegram{Time: now, LData: copyLData(ldata), Rejected: now.Before(bus.busyUntil)}
+
+	bus.history = append(bus.history, tg)
+	if len(bus.history) > bus.config.HistorySize {
+		bus.history = bus.history[len(bus.history)-bus.config.HistorySize:]
+	}
+
+	close(bus.changed)
+	bus.changed = make(chan struct{})
+
+	if !tg.Rejected {
+		for client := range bus.clients {
+			if client == sender || bus.config.Random() < bus.config.Loss {
+				continue
+			}
+
+			client.enqueue(bus.delay(now), &cemi.LDataInd{LData: copyLData(ldata)})
+		}
+	}
+
+	con := &cemi.LDataCon{LData: copyLData(ldata)}
+	if tg.Rejected {
+		con.Control1 |= cemi.Control1HasError
+	}
+
+	sender.enqueue(bus.delay(now), con)
+
+	return nil
+}
+
+// copyLData makes a deep copy of the frame, so that the receivers do not share its data.
+func copyLData(ldata cemi.LData) cemi.LData {
+	buffer := make([]byte, ldata.Size())
+	ldata.Pack(buffer)
+
+	var dup cemi.LData
+	if _, err := dup.Unpack(buffer); err != nil {
+		// Frames which cannot be parsed again are passed on as they are.
+		return ldata
+	}
+
+	return dup
+}
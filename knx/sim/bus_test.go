@@ -0,0 +1,268 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package sim
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mobilarte/knx-exp/knx"
+	"github.com/mobilarte/knx-exp/knx/cemi"
+	"github.com/mobilarte/knx-exp/knx/dpt"
+)
+
+func makeBus(t *testing.T, config Config) *Bus {
+	t.Helper()
+
+	bus := NewBus(config)
+	t.Cleanup(func() { _ = bus.Close() })
+
+	return bus
+}
+
+func attach(t *testing.T, bus *Bus, addr cemi.IndividualAddr) *Client {
+	t.Helper()
+
+	client, err := bus.Attach(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return client
+}
+
+func attachGroup(t *testing.T, bus *Bus, addr cemi.IndividualAddr) *GroupClient {
+	t.Helper()
+
+	client, err := bus.AttachGroup(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return client
+}
+
+// receive waits for a frame of the given type.
+func receive[T cemi.Message](t *testing.T, client *Client) T {
+	t.Helper()
+
+	select {
+	case msg := <-client.Inbound():
+		typed, ok := msg.(T)
+		if !ok {
+			t.Fatalf("Unexpected %T", msg)
+		}
+
+		return typed
+
+	case <-time.After(time.Second):
+		var zero T
+
+		t.Fatalf("No %T has been received", zero)
+
+		return zero
+	}
+}
+
+// expectSilence makes sure that the client receives nothing for a while.
+func expectSilence(t *testing.T, client *Client) {
+	t.Helper()
+
+	select {
+	case msg := <-client.Inbound():
+		t.Errorf("Unexpected %T", msg)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func makeGroupWrite(dest cemi.GroupAddr, data ...byte) *cemi.LDataReq {
+	return &cemi.LDataReq{LData: cemi.LData{
+		Control1:    cemi.Control1StdFrame | cemi.Control1NoRepeat | cemi.Control1NoSysBroadcast,
+		Control2:    cemi.Control2GroupAddr | cemi.Control2Hops(6),
+		Destination: uint16(dest),
+		Data:        &cemi.AppData{Command: cemi.GroupValueWrite, Data: data},
+	}}
+}
+
+func TestBus(t *testing.T) {
+	bus := makeBus(t, DefaultConfig)
+	sender := attach(t, bus, 0x1101)
+	receiver := attach(t, bus, 0x1102)
+
+	if err := sender.Send(makeGroupWrite(0x0901, 1)); err != nil {
+		t.Fatal(err)
+	}
+
+	if ind := receive[*cemi.LDataInd](t, receiver); ind.Source != 0x1101 || ind.Destination != 0x0901 {
+		t.Errorf("Unexpected indication %+v", ind.LData)
+	}
+
+	if con := receive[*cemi.LDataCon](t, sender); con.Control1&cemi.Control1HasError != 0 {
+		t.Error("Negative confirmation")
+	}
+
+	bus.Expect(t, time.Second, All(From(0x1101), Group(knx.GroupWrite, 0x0901, []byte{1})))
+
+	if n := bus.Count(Group(knx.GroupWrite, 0x0901, nil)); n != 1 {
+		t.Errorf("Unexpected count %d", n)
+	}
+
+	if err := sender.Send(&cemi.LDataCon{}); err == nil {
+		t.Error("Confirmation has been sent")
+	}
+
+	for range 2 {
+		if err := receiver.Close(); err != nil {
+			t.Error(err)
+		}
+	}
+
+	if err := receiver.Send(makeGroupWrite(0x0901, 1)); !errors.Is(err, ErrClosed) {
+		t.Errorf("Unexpected error %v", err)
+	}
+
+	if _, open := <-receiver.Inbound(); open {
+		t.Error("Inbound channel is still open")
+	}
+
+	for range 2 {
+		if err := bus.Close(); err != nil {
+			t.Error(err)
+		}
+	}
+
+	if err := sender.Send(makeGroupWrite(0x0901, 1)); !errors.Is(err, ErrClosed) {
+		t.Errorf("Unexpected error %v", err)
+	}
+
+	for range sender.Inbound() {
+	}
+}
+
+func TestBus_Faults(t *testing.T) {
+	t.Run("Busy", func(t *testing.T) {
+		bus := makeBus(t, DefaultConfig)
+		sender := attach(t, bus, 0x1101)
+		receiver := attach(t, bus, 0x1102)
+
+		bus.SetBusy(time.Minute)
+
+		if err := sender.Send(makeGroupWrite(0x0901, 1)); err != nil {
+			t.Fatal(err)
+		}
+
+		if con := receive[*cemi.LDataCon](t, sender); con.Control1&cemi.Control1HasError == 0 {
+			t.Error("Positive confirmation on a busy bus")
+		}
+
+		expectSilence(t, receiver)
+
+		if tg := bus.Telegrams(); len(tg) != 1 || !tg[0].Rejected {
+			t.Errorf("Unexpected history %v", tg)
+		}
+	})
+
+	t.Run("Loss", func(t *testing.T) {
+		config := DefaultConfig
+		config.Loss = 1
+
+		bus := makeBus(t, config)
+		sender := attach(t, bus, 0x1101)
+		receiver := attach(t, bus, 0x1102)
+
+		if err := sender.Send(makeGroupWrite(0x0901, 1)); err != nil {
+			t.Fatal(err)
+		}
+
+		if con := receive[*cemi.LDataCon](t, sender); con.Control1&cemi.Control1HasError != 0 {
+			t.Error("Negative confirmation")
+		}
+
+		expectSilence(t, receiver)
+	})
+
+	t.Run("Latency", func(t *testing.T) {
+		config := DefaultConfig
+		config.Latency = 50 * time.Millisecond
+		config.Jitter = 50 * time.Millisecond
+		config.Random = func() float64 { return 0.5 }
+
+		bus := makeBus(t, config)
+		sender := attach(t, bus, 0x1101)
+		receiver := attach(t, bus, 0x1102)
+
+		start := time.Now()
+
+		for i := range byte(3) {
+			if err := sender.Send(makeGroupWrite(0x0901, i)); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		for i := range byte(3) {
+			ind := receive[*cemi.LDataInd](t, receiver)
+			if app, ok := ind.Data.(*cemi.AppData); !ok || app.Data[0] != i {
+				t.Errorf("Unexpected order %+v", ind.Data)
+			}
+		}
+
+		if elapsed := time.Since(start); elapsed < 75*time.Millisecond {
+			t.Errorf("Delivered after %v", elapsed)
+		}
+	})
+}
+
+func TestGroupClient(t *testing.T) {
+	bus := makeBus(t, DefaultConfig)
+	sensor := attachGroup(t, bus, 0x1101)
+	actuator := attachGroup(t, bus, 0x1102)
+
+	var _ knx.GroupClient = sensor
+
+	if err := sensor.Send(knx.GroupEvent{Command: knx.GroupWrite, Destination: 0x0901, Data: []byte{1}}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case event := <-actuator.Inbound():
+		if event.Command != knx.GroupWrite || event.Source != 0x1101 || event.Data[0] != 1 {
+			t.Errorf("Unexpected event %+v", event)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("No event")
+	}
+
+	// The sender only gets the confirmation, which is not passed on.
+	select {
+	case event := <-sensor.Inbound():
+		t.Errorf("Unexpected event %+v", event)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestVirtualDevice(t *testing.T) {
+	bus := makeBus(t, DefaultConfig)
+	tester := attachGroup(t, bus, 0x1101)
+
+	temperature := dpt.DPT_9001(21.5)
+
+	dev, err := knx.NewVirtualDevice(attachGroup(t, bus, 0x1105), 0, knx.GroupObjectConfig{
+		Name:      "temperature",
+		Address:   0x0801,
+		Datapoint: &temperature,
+		Flags:     knx.FlagCommunication | knx.FlagRead,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { _ = dev.Close() })
+
+	if err := tester.Send(knx.GroupEvent{Command: knx.GroupRead, Destination: 0x0801}); err != nil {
+		t.Fatal(err)
+	}
+
+	bus.Expect(t, time.Second, All(From(0x1105), Group(knx.GroupResponse, 0x0801, temperature.Pack())))
+	bus.ExpectNone(t, 50*time.Millisecond, Group(knx.GroupWrite, 0x0801, nil))
+}
@@ -0,0 +1,190 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package sim
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mobilarte/knx-exp/knx"
+	"github.com/mobilarte/knx-exp/knx/cemi"
+	"github.com/mobilarte/knx-exp/knx/util"
+)
+
+// Clients can be used as backends of a knx.TunnelServer.
+var _ knx.TunnelBackend = (*Client)(nil)
+
+// delivery is a frame that waits to be delivered to a client.
+type delivery struct {
+	due time.Time
+	msg cemi.Message
+}
+
+// A Client is attached to a Bus and exchanges raw cEMI frames. It sends L_Data.req and receives
+// L_Data.ind of the other clients as well as L_Data.con for its own frames.
+type Client struct {
+	bus  *Bus
+	addr cemi.IndividualAddr
+
+	pending  chan delivery
+	inbound  chan cemi.Message
+	done     chan struct{}
+	stopOnce sync.Once
+	wait     sync.WaitGroup
+}
+
+// newClient creates the client. Its worker is started once it is attached.
+func newClient(bus *Bus, addr cemi.IndividualAddr) *Client {
+	client := &Client{
+		bus:     bus,
+		addr:    addr,
+		pending: make(chan delivery, bus.config.QueueSize),
+		inbound: make(chan cemi.Message),
+		done:    make(chan struct{}),
+	}
+
+	client.wait.Add(1)
+
+	return client
+}
+
+// IndividualAddr returns the address of the client.
+func (client *Client) IndividualAddr() cemi.IndividualAddr {
+	return client.addr
+}
+
+// Send puts a frame on the bus. L_Data.req and L_Data.ind are accepted. A missing source address
+// is replaced with the address of the client.
+func (client *Client) Send(data cemi.Message) error {
+	var ldata cemi.LData
+
+	switch msg := data.(type) {
+	case *cemi.LDataReq:
+		ldata = msg.LData
+	case *cemi.LDataInd:
+		ldata = msg.LData
+	default:
+		return fmt.Errorf("cannot send %T on the bus", data)
+	}
+
+	select {
+	case <-client.done:
+		return ErrClosed
+	default:
+	}
+
+	if ldata.Source == 0 {
+		ldata.Source = client.addr
+	}
+
+	return client.bus.transmit(client, ldata)
+}
+
+// Inbound returns the channel on which the frames of the bus are received. It is closed when the
+// client or the bus is closed.
+func (client *Client) Inbound() <-chan cemi.Message {
+	return client.inbound
+}
+
+// Close detaches the client from the bus. It is safe to call Close more than once.
+func (client *Client) Close() error {
+	client.bus.detach(client)
+	client.stop()
+
+	return nil
+}
+
+// stop terminates the worker.
+func (client *Client) stop() {
+	client.stopOnce.Do(func() { close(client.done) })
+	client.wait.Wait()
+}
+
+// enqueue schedules the delivery of a frame. It must not block, because the bus is locked.
+func (client *Client) enqueue(due time.Time, msg cemi.Message) {
+	select {
+	case client.pending <- delivery{due, msg}:
+	default:
+		util.Log(client, "Dropped %T, because the client does not keep up", msg)
+	}
+}
+
+// serve is the worker which delivers the frames in order once they are due.
+func (client *Client) serve() {
+	defer client.wait.Done()
+	defer close(client.inbound)
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		var next delivery
+
+		select {
+		case next = <-client.pending:
+		case <-client.done:
+			return
+		}
+
+		if wait := time.Until(next.due); wait > 0 {
+			timer.Reset(wait)
+
+			select {
+			case <-timer.C:
+			case <-client.done:
+				return
+			}
+		}
+
+		select {
+		case client.inbound <- next.msg:
+		case <-client.done:
+			return
+		}
+	}
+}
+
+// A GroupClient is attached to a Bus and implements knx.GroupClient.
+type GroupClient struct {
+	*Client
+	inbound chan knx.GroupEvent
+}
+
+// newGroupClient wraps the raw client.
+func newGroupClient(client *Client) *GroupClient {
+	gc := &GroupClient{Client: client, inbound: make(chan knx.GroupEvent)}
+
+	go gc.serve()
+
+	return gc
+}
+
+// Send a group communication.
+func (gc *GroupClient) Send(event knx.GroupEvent) error {
+	return gc.Client.Send(&cemi.LDataReq{LData: knx.GroupEventFrame(event)})
+}
+
+// Inbound returns the channel on which group communication can be received. Confirmations and
+// frames which are not group communication are skipped.
+func (gc *GroupClient) Inbound() <-chan knx.GroupEvent {
+	return gc.inbound
+}
+
+// serve translates the frames of the bus into group events.
+func (gc *GroupClient) serve() {
+	defer close(gc.inbound)
+
+	for msg := range gc.Client.Inbound() {
+		event, ok := knx.GroupEventOf(msg)
+		if !ok {
+			continue
+		}
+
+		select {
+		case gc.inbound <- event:
+		case <-gc.done:
+			// Drain the raw channel until it is closed.
+		}
+	}
+}
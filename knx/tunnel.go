@@ -4,9 +4,9 @@
 package knx
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"log"
 	"sync"
 	"time"
 
@@ -31,15 +31,46 @@ type TunnelConfig struct {
 
 	// UseTCP configures whether to connect to the gateway using TCP.
 	UseTCP bool
+
+	// WaitForConfirmation makes Send wait for the L_Data.con which the gateway emits once an
+	// L_Data.req has been transmitted on the bus (or failed to be). Other messages are unaffected.
+	WaitForConfirmation bool
+
+	// ConfirmationTimeout specifies how long to wait for an L_Data.con after the gateway has
+	// acknowledged the tunnel request. Only relevant if WaitForConfirmation is set.
+	ConfirmationTimeout time.Duration
+
+	// Reconnect determines how to re-establish a lost connection.
+	Reconnect ReconnectPolicy
+
+	// IndividualAddress requests a specific individual address, i.e. a specific tunnel slot, from
+	// the gateway. This uses the extended connection request of Tunnelling v2, which gateways
+	// usually only accept on TCP connections. Zero lets the gateway choose.
+	IndividualAddress cemi.IndividualAddr
+
+	// Inbound configures the queue of incoming messages.
+	Inbound InboundConfig
+
+	// SendQueueSize is the maximum number of telegrams that wait in the send queue.
+	SendQueueSize int
+
+	// SendRate limits the number of telegrams that are sent per second. Zero means no limit.
+	SendRate float64
 }
 
 // DefaultTunnelConfig is a good default configuration for a Tunnel client.
 var DefaultTunnelConfig = TunnelConfig{
-	ResendInterval:    500 * time.Millisecond,
-	HeartbeatInterval: 10 * time.Second,
-	ResponseTimeout:   10 * time.Second,
-	SendLocalAddress:  false,
-	UseTCP:            false,
+	ResendInterval:      500 * time.Millisecond,
+	HeartbeatInterval:   10 * time.Second,
+	ResponseTimeout:     10 * time.Second,
+	SendLocalAddress:    false,
+	UseTCP:              false,
+	WaitForConfirmation: false,
+	ConfirmationTimeout: 3 * time.Second,
+	Reconnect:           DefaultReconnectPolicy,
+	Inbound:             DefaultInboundConfig,
+	SendQueueSize:       256,
+	SendRate:            0,
 }
 
 // A Tunnel provides methods to communicate with a KNXnet/IP gateway.
@@ -49,28 +80,70 @@ type Tunnel struct {
 	config TunnelConfig
 
 	// Connection information
-	connMu  sync.Mutex
-	layer   knxnet.TunnelLayer
-	channel uint8
-	control knxnet.HostInfo
+	connMu   sync.Mutex
+	connType knxnet.ConnectionType
+	layer    knxnet.TunnelLayer
+	channel  uint8
+	control  knxnet.HostInfo
+	addr     cemi.IndividualAddr
 
 	// For outgoing requests
+	sendQueue *sendQueue
 	seqMu     sync.Mutex
 	seqNumber uint8
 	ack       chan *knxnet.TunnelRes
 
+	// Senders awaiting an L_Data.con
+	confMu      sync.Mutex
+	confWaiters []*confirmWaiter
+
 	// Incoming requests
-	inbound chan cemi.Message
+	inbound *inboundQueue
+
+	// Connection state changes
+	state chan StateEvent
+
+	// Errors of the background goroutines
+	errors *asyncErrors
+
+	// Senders awaiting a feature response and feature info notifications
+	featureMu      sync.Mutex
+	featureWaiters []*featureWaiter
+	featureInfo    chan FeatureEvent
 
 	// Goroutine controller
-	done chan struct{}
-	once sync.Once
-	wait sync.WaitGroup
+	done     chan struct{}
+	once     sync.Once
+	wait     sync.WaitGroup
+	closeErr error
 }
 
 // NewTunnel establishes a connection to a gateway. You can pass a zero initialized ClientConfig;
 // the function will take care of filling in the default values.
-func NewTunnel(gatewayAddr string, layer knxnet.TunnelLayer, config TunnelConfig) (tunnel *Tunnel, err error) {
+func NewTunnel(gatewayAddr string, layer knxnet.TunnelLayer, config TunnelConfig) (*Tunnel, error) {
+	return NewTunnelContext(context.Background(), gatewayAddr, layer, config)
+}
+
+// NewTunnelContext is like NewTunnel, but the connection request is aborted as soon as the given
+// context is done. The context only governs the establishment of the connection, not the
+// lifetime of the returned Tunnel.
+func NewTunnelContext(
+	ctx context.Context,
+	gatewayAddr string,
+	layer knxnet.TunnelLayer,
+	config TunnelConfig,
+) (*Tunnel, error) {
+	return newTunnel(ctx, gatewayAddr, knxnet.TunnelConnection, layer, config)
+}
+
+// newTunnel establishes a connection of the given type to a gateway.
+func newTunnel(
+	ctx context.Context,
+	gatewayAddr string,
+	connType knxnet.ConnectionType,
+	layer knxnet.TunnelLayer,
+	config TunnelConfig,
+) (tunnel *Tunnel, err error) {
 	var sock knxnet.Socket
 
 	// Create socket which will be used for communication.
@@ -84,52 +157,114 @@ func NewTunnel(gatewayAddr string, layer knxnet.TunnelLayer, config TunnelConfig
 		return nil, err
 	}
 
+	config = checkTunnelConfig(config)
+
 	// Initialize the Client structure.
 	client := &Tunnel{
-		sock:    sock,
-		config:  checkTunnelConfig(config),
-		layer:   layer,
-		ack:     make(chan *knxnet.TunnelRes),
-		inbound: make(chan cemi.Message),
-		done:    make(chan struct{}),
+		sock:        sock,
+		config:      config,
+		connType:    connType,
+		layer:       layer,
+		sendQueue:   newSendQueue(config.SendQueueSize),
+		ack:         make(chan *knxnet.TunnelRes),
+		state:       make(chan StateEvent, eventBufferSize),
+		errors:      newAsyncErrors(),
+		featureInfo: make(chan FeatureEvent, eventBufferSize),
+		done:        make(chan struct{}),
 	}
 
+	client.inbound = newInboundQueue(client.errors.reportOverflow(client, config.Inbound))
+	client.errors.forward(client, sock)
+
 	// Connect to the gateway.
-	err = client.requestConn()
+	client.emitState(StateEvent{State: StateConnecting})
+
+	err = client.requestConn(ctx)
 	if err != nil {
+		client.inbound.abandon()
+		client.errors.close()
 		_ = sock.Close()
+
 		return nil, err
 	}
 
-	client.wait.Add(1)
+	client.emitState(StateEvent{State: StateConnected})
+
+	client.wait.Add(2)
 
 	go client.serve()
+	go client.serveSendQueue()
 
 	return client, nil
 }
 
 // Close will terminate the connection and wait for the server routine to exit. Although a
-// disconnect request is sent, it does not wait for a disconnect response.
-func (conn *Tunnel) Close() {
+// disconnect request is sent, it does not wait for a disconnect response. The error stems from
+// closing the socket; it is returned on every call.
+func (conn *Tunnel) Close() error {
 	conn.once.Do(func() {
 		_ = conn.requestDisc()
 
 		close(conn.done)
+
+		// Release the server routine if it is blocked on a full inbound queue, and the forwarder if
+		// nobody reads the inbound channel anymore.
+		conn.inbound.abandon()
 		conn.wait.Wait()
 
-		_ = conn.sock.Close()
+		conn.closeErr = conn.sock.Close()
 	})
+
+	return conn.closeErr
 }
 
-// Inbound retrieves the channel which transmits incoming data. The channel is closed when the
-// underlying Socket closes its inbound channel or when the connection is terminated.
+// Inbound retrieves the channel which transmits incoming data in the order of arrival. The
+// channel is closed when the underlying Socket closes its inbound channel or when the connection
+// is terminated, after the messages that were still queued have been delivered. After Close,
+// queued messages are only delivered to a reader that is already waiting; the rest is discarded.
 func (conn *Tunnel) Inbound() <-chan cemi.Message {
-	return conn.inbound
+	return conn.inbound.out
+}
+
+// DroppedInbound returns the number of incoming messages that have been discarded because the
+// inbound queue was full.
+func (conn *Tunnel) DroppedInbound() uint64 {
+	return conn.inbound.droppedCount()
 }
 
-// Send relays a tunnel request to the gateway with the given contents.
+// State retrieves the channel which transmits changes of the connection state. The channel is
+// buffered; events are dropped when it is full. It is closed after the StateClosed event.
+func (conn *Tunnel) State() <-chan StateEvent {
+	return conn.state
+}
+
+// Errors retrieves the channel which transmits errors of the background goroutines, e.g. a failed
+// heartbeat, a failed reconnect or an incoming packet that could not be parsed. The channel is
+// buffered; errors are dropped when it is full. It is closed when the connection is terminated.
+func (conn *Tunnel) Errors() <-chan error {
+	return conn.errors.channel()
+}
+
+// IndividualAddr returns the individual address that the gateway assigned to the tunnel. It may
+// change after a reconnect. Zero means that the gateway did not tell.
+func (conn *Tunnel) IndividualAddr() cemi.IndividualAddr {
+	conn.connMu.Lock()
+	defer conn.connMu.Unlock()
+
+	return conn.addr
+}
+
+// Send relays a tunnel request to the gateway with the given contents. An L_Data.req without a
+// source address is sent with the tunnel's individual address as source. The request passes the
+// send queue; Send waits until it has been sent.
 func (conn *Tunnel) Send(data cemi.Message) error {
-	return conn.requestTunnel(data)
+	return conn.Enqueue(data).Wait(context.Background())
+}
+
+// SendContext is like Send, but gives up waiting for the gateway's acknowledgement as soon as the
+// given context is done.
+func (conn *Tunnel) SendContext(ctx context.Context, data cemi.Message) error {
+	return conn.EnqueueContext(ctx, data).Wait(ctx)
 }
 
 // GroupTunnel is a Tunnel that provides only a group communication interface.
@@ -139,8 +274,14 @@ type GroupTunnel struct {
 }
 
 // NewGroupTunnel creates a new Tunnel for group communication.
-func NewGroupTunnel(gatewayAddr string, config TunnelConfig) (gt GroupTunnel, err error) {
-	gt.Tunnel, err = NewTunnel(gatewayAddr, knxnet.TunnelLayerData, config)
+func NewGroupTunnel(gatewayAddr string, config TunnelConfig) (GroupTunnel, error) {
+	return NewGroupTunnelContext(context.Background(), gatewayAddr, config)
+}
+
+// NewGroupTunnelContext creates a new Tunnel for group communication. Establishing the connection
+// is aborted when the given context is done.
+func NewGroupTunnelContext(ctx context.Context, gatewayAddr string, config TunnelConfig) (gt GroupTunnel, err error) {
+	gt.Tunnel, err = NewTunnelContext(ctx, gatewayAddr, knxnet.TunnelLayerData, config)
 	if err == nil {
 		gt.inbound = make(chan GroupEvent)
 		go serveGroupInbound(gt.Tunnel.Inbound(), gt.inbound)
@@ -151,7 +292,12 @@ func NewGroupTunnel(gatewayAddr string, config TunnelConfig) (gt GroupTunnel, er
 
 // Send a group communication.
 func (gt *GroupTunnel) Send(event GroupEvent) error {
-	return gt.Tunnel.Send(&cemi.LDataReq{LData: buildGroupOutbound(event)})
+	return gt.Tunnel.Send(&cemi.LDataReq{LData: GroupEventFrame(event)})
+}
+
+// SendContext sends a group communication, giving up when the given context is done.
+func (gt *GroupTunnel) SendContext(ctx context.Context, event GroupEvent) error {
+	return gt.Tunnel.SendContext(ctx, &cemi.LDataReq{LData: GroupEventFrame(event)})
 }
 
 // Inbound returns the channel on which group communication can be received.
@@ -173,12 +319,29 @@ func checkTunnelConfig(config TunnelConfig) TunnelConfig {
 		config.ResponseTimeout = DefaultTunnelConfig.ResponseTimeout
 	}
 
+	if config.ConfirmationTimeout <= 0 {
+		config.ConfirmationTimeout = DefaultTunnelConfig.ConfirmationTimeout
+	}
+
+	if config.SendQueueSize <= 0 {
+		config.SendQueueSize = DefaultTunnelConfig.SendQueueSize
+	}
+
+	if config.SendRate < 0 {
+		config.SendRate = 0
+	}
+
+	config.Reconnect = checkReconnectPolicy(config.Reconnect)
+	config.Inbound = checkInboundConfig(config.Inbound)
+
 	return config
 }
 
-var (
-	errResponseTimeout = errors.New("response timeout reached")
-)
+// eventBufferSize is the number of state or feature events that are kept for a slow reader.
+const eventBufferSize = 16
+
+// ErrResponseTimeout is returned when the gateway does not respond in time.
+var ErrResponseTimeout = errors.New("response timeout reached")
 
 func (conn *Tunnel) hostInfo() (knxnet.HostInfo, error) {
 	addr := conn.sock.LocalAddr()
@@ -197,9 +360,11 @@ func (conn *Tunnel) hostInfo() (knxnet.HostInfo, error) {
 }
 
 // requestConn repeatedly sends a connection request through the socket until the configured
-// response timeout is reached or a response is received. A response that renders the gateway as busy
-// will not stop requestConn.
-func (conn *Tunnel) requestConn() (err error) {
+// response timeout is reached, the context is done or a response is received. A response that
+// renders the gateway as busy will not stop requestConn, but its status is returned as error if
+// the timeout is reached afterwards. A requested individual address that is in use is reported
+// immediately.
+func (conn *Tunnel) requestConn(ctx context.Context) (err error) {
 	hostInfo, err := conn.hostInfo()
 	if err != nil {
 		return err
@@ -210,11 +375,16 @@ func (conn *Tunnel) requestConn() (err error) {
 	conn.connMu.Unlock()
 
 	req := &knxnet.ConnReq{
-		Layer:   conn.layer,
-		Control: conn.control,
-		Tunnel:  conn.control,
+		ConnType:       conn.connType,
+		Layer:          conn.layer,
+		Control:        conn.control,
+		Tunnel:         conn.control,
+		IndividualAddr: conn.config.IndividualAddress,
 	}
 
+	// The most recent reason why the gateway could not accept the connection yet.
+	var busy error
+
 	// Send the initial request.
 	err = conn.sock.Send(req)
 	if err != nil {
@@ -231,9 +401,17 @@ func (conn *Tunnel) requestConn() (err error) {
 	// Cycle until a request gets a response.
 	for {
 		select {
-		// Timeout reached.
+		// The caller is no longer interested.
+		case <-ctx.Done():
+			return ctx.Err()
+
+		// Timeout reached. If the gateway has been busy, tell the caller why.
 		case <-timeout:
-			return errResponseTimeout
+			if busy != nil {
+				return busy
+			}
+
+			return ErrResponseTimeout
 
 		// Resend timer triggered.
 		case <-ticker.C:
@@ -255,6 +433,7 @@ func (conn *Tunnel) requestConn() (err error) {
 				case knxnet.NoError:
 					conn.connMu.Lock()
 					conn.channel = res.Channel
+					conn.addr = res.Data.IndividualAddr
 					conn.connMu.Unlock()
 
 					conn.seqMu.Lock()
@@ -264,9 +443,15 @@ func (conn *Tunnel) requestConn() (err error) {
 					return nil
 
 				// The gateway is busy, but we don't stop yet.
-				case knxnet.ErrNoMoreConnections, knxnet.ErrNoMoreUniqueConnections:
+				case knxnet.ErrNoMoreConnections:
+					busy = res.Status
 					continue
 
+				// The requested individual address is in use. Retrying right away does not help,
+				// reconnects try again after their delay.
+				case knxnet.ErrNoMoreUniqueConnections:
+					return res.Status
+
 				// Connection request has been denied.
 				default:
 					return res.Status
@@ -277,8 +462,10 @@ func (conn *Tunnel) requestConn() (err error) {
 }
 
 // requestConnState periodically sends a connection state request to the gateway until it has
-// received a response or the response timeout is reached.
+// received a response, the response timeout is reached or the context is done. A closed heartbeat
+// channel means that the connection is going away, which is treated like a cancellation.
 func (conn *Tunnel) requestConnState(
+	ctx context.Context,
 	heartbeat <-chan knxnet.ErrCode,
 ) (knxnet.ErrCode, error) {
 	conn.connMu.Lock()
@@ -305,7 +492,11 @@ func (conn *Tunnel) requestConnState(
 		select {
 		// Reached timeout
 		case <-timeout:
-			return knxnet.ErrConnectionID, errResponseTimeout
+			return knxnet.ErrConnectionID, ErrResponseTimeout
+
+		// The connection is being torn down.
+		case <-ctx.Done():
+			return knxnet.ErrConnectionID, ctx.Err()
 
 		// Resend timer fired.
 		case <-ticker.C:
@@ -317,7 +508,7 @@ func (conn *Tunnel) requestConnState(
 		// Received a connection state response.
 		case res, open := <-heartbeat:
 			if !open {
-				return knxnet.ErrConnectionID, errors.New("connection server has terminated")
+				return knxnet.ErrConnectionID, context.Canceled
 			}
 
 			return res, nil
@@ -340,7 +531,70 @@ func (conn *Tunnel) requestDisc() error {
 }
 
 // requestTunnel sends a tunnel request to the gateway and waits for an appropriate acknowledgement.
-func (conn *Tunnel) requestTunnel(data cemi.Message) error {
+// Waiting is aborted when the context is done.
+func (conn *Tunnel) requestTunnel(ctx context.Context, data cemi.Message) error {
+	waiter, err := conn.transmitTunnel(ctx, data)
+	if err != nil {
+		return err
+	}
+
+	return conn.awaitConfirmation(ctx, waiter)
+}
+
+// transmitTunnel sends a tunnel request to the gateway and waits for its acknowledgement. If the
+// request needs to be confirmed, it returns the waiter which must be passed to awaitConfirmation.
+func (conn *Tunnel) transmitTunnel(ctx context.Context, data cemi.Message) (*confirmWaiter, error) {
+	ldataReq, isLDataReq := data.(*cemi.LDataReq)
+
+	// Default to the tunnel's address as source. The caller's frame must not be modified.
+	if isLDataReq && ldataReq.Source == 0 {
+		if addr := conn.IndividualAddr(); addr != 0 {
+			ldataReq = &cemi.LDataReq{LData: ldataReq.LData}
+			ldataReq.Source = addr
+			data = ldataReq
+		}
+	}
+
+	// The waiter must be in place before sending, because the confirmation may arrive before the
+	// acknowledgement has been processed.
+	var waiter *confirmWaiter
+
+	if isLDataReq && conn.config.WaitForConfirmation {
+		waiter = conn.addConfirmWaiter(ldataReq)
+	}
+
+	err := conn.requestSequenced(ctx, func(channel, seqNumber uint8) knxnet.ServicePackable {
+		if conn.connType == knxnet.DeviceManagementConnection {
+			return &knxnet.DeviceConfigurationReq{
+				Channel:   channel,
+				SeqNumber: seqNumber,
+				Payload:   data,
+			}
+		}
+
+		return &knxnet.TunnelReq{
+			Channel:   channel,
+			SeqNumber: seqNumber,
+			Payload:   data,
+		}
+	})
+	if err != nil {
+		if waiter != nil {
+			conn.removeConfirmWaiter(waiter)
+		}
+
+		return nil, err
+	}
+
+	return waiter, nil
+}
+
+// requestSequenced sends the request created by build, which carries the tunnel's sequence
+// number, and waits for its acknowledgement. Waiting is aborted when the context is done.
+func (conn *Tunnel) requestSequenced(
+	ctx context.Context,
+	build func(channel, seqNumber uint8) knxnet.ServicePackable,
+) error {
 	// Sequence numbers cannot be reused, therefore we must protect against that.
 	conn.seqMu.Lock()
 	defer conn.seqMu.Unlock()
@@ -356,11 +610,7 @@ func (conn *Tunnel) requestTunnel(data cemi.Message) error {
 		seqNumber = conn.seqNumber
 	}
 
-	req := &knxnet.TunnelReq{
-		Channel:   channel,
-		SeqNumber: seqNumber,
-		Payload:   data,
-	}
+	req := build(channel, seqNumber)
 
 	// Send initial request.
 	err := conn.sock.Send(req)
@@ -383,9 +633,13 @@ func (conn *Tunnel) requestTunnel(data cemi.Message) error {
 
 	for {
 		select {
+		// The caller is no longer interested.
+		case <-ctx.Done():
+			return ctx.Err()
+
 		// Timeout reached.
 		case <-timeout:
-			return errResponseTimeout
+			return ErrResponseTimeout
 
 		// Resend timer fired.
 		case <-ticker.C:
@@ -418,23 +672,96 @@ func (conn *Tunnel) requestTunnel(data cemi.Message) error {
 	}
 }
 
-// performHeartbeat uses requestConnState to determine if the gateway is still alive.
+// addConfirmWaiter registers a sender that awaits the L_Data.con for the given request.
+func (conn *Tunnel) addConfirmWaiter(req *cemi.LDataReq) *confirmWaiter {
+	waiter := &confirmWaiter{req: req, con: make(chan *cemi.LDataCon, 1)}
+
+	conn.confMu.Lock()
+	conn.confWaiters = append(conn.confWaiters, waiter)
+	conn.confMu.Unlock()
+
+	return waiter
+}
+
+// removeConfirmWaiter unregisters a sender.
+func (conn *Tunnel) removeConfirmWaiter(waiter *confirmWaiter) {
+	conn.confMu.Lock()
+	defer conn.confMu.Unlock()
+
+	for i, w := range conn.confWaiters {
+		if w == waiter {
+			conn.confWaiters = append(conn.confWaiters[:i], conn.confWaiters[i+1:]...)
+			return
+		}
+	}
+}
+
+// awaitConfirmation waits until the waiter has received its L_Data.con. A nil waiter is
+// considered confirmed.
+func (conn *Tunnel) awaitConfirmation(ctx context.Context, waiter *confirmWaiter) error {
+	if waiter == nil {
+		return nil
+	}
+
+	defer conn.removeConfirmWaiter(waiter)
+
+	timeout := time.NewTimer(conn.config.ConfirmationTimeout)
+	defer timeout.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+
+	case <-timeout.C:
+		return &ConfirmationError{Req: waiter.req}
+
+	case con := <-waiter.con:
+		if con.Control1&cemi.Control1HasError != 0 {
+			return &ConfirmationError{Req: waiter.req, Con: con}
+		}
+
+		return nil
+	}
+}
+
+// dispatchConfirmation hands the L_Data.con to the oldest sender whose request it confirms.
+func (conn *Tunnel) dispatchConfirmation(con *cemi.LDataCon) {
+	conn.confMu.Lock()
+	defer conn.confMu.Unlock()
+
+	for i, waiter := range conn.confWaiters {
+		if confirms(&waiter.req.LData, &con.LData) {
+			waiter.con <- con
+			conn.confWaiters = append(conn.confWaiters[:i], conn.confWaiters[i+1:]...)
+
+			return
+		}
+	}
+}
+
+// performHeartbeat uses requestConnState to determine if the gateway is still alive. Nothing is
+// reported if the context is canceled before the heartbeat has completed.
 func (conn *Tunnel) performHeartbeat(
+	ctx context.Context,
 	heartbeat <-chan knxnet.ErrCode,
 	timeout chan<- struct{},
 ) {
 	// Request the connction state.
-	state, err := conn.requestConnState(heartbeat)
+	state, err := conn.requestConnState(ctx, heartbeat)
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+
 	if err != nil || state != knxnet.NoError {
 		if err != nil {
-			util.Log(conn, "Error while requesting connection state: %v", err)
+			conn.errors.emit(conn, fmt.Errorf("%w: requesting connection state: %w", ErrHeartbeatFailed, err))
 		} else {
-			util.Log(conn, "Bad connection state: %v", state)
+			conn.errors.emit(conn, fmt.Errorf("%w: bad connection state: %w", ErrHeartbeatFailed, state))
 		}
 
 		// Write to timeout as an indication that the heartbeat has failed.
 		select {
-		case <-conn.done:
+		case <-ctx.Done():
 		case timeout <- struct{}{}:
 		}
 	}
@@ -471,47 +798,55 @@ func (conn *Tunnel) handleDiscRes(res *knxnet.DiscRes) error {
 	return nil
 }
 
-// pushInbound sends the message through the inbound channel. If the sending blocks, it will launch
-// a goroutine which will do the sending.
+// pushInbound queues the message for the client, as permitted by the overflow policy.
 func (conn *Tunnel) pushInbound(msg cemi.Message) {
-	select {
-	case conn.inbound <- msg:
-
-	default:
-		go func() {
-			// Since this goroutine decouples from the server goroutine, it might try to send when
-			// the server closed the inbound channel. Sending to a closed channel will panic. But we
-			// don't care, because cool guys don't look at explosions.
-			defer func() {
-				if r := recover(); r != nil {
-					log.Fatal(r)
-				}
-			}()
+	conn.inbound.push(msg)
+}
 
-			conn.inbound <- msg
-		}()
+// deliverInbound forwards an incoming message to a sender awaiting its confirmation, if any, and
+// to the client.
+func (conn *Tunnel) deliverInbound(msg cemi.Message) {
+	if con, ok := msg.(*cemi.LDataCon); ok {
+		conn.dispatchConfirmation(con)
 	}
+
+	conn.pushInbound(msg)
 }
 
 // handleTunnelReq validates the request, pushes the data to the client and acknowledges the
 // request for the gateway.
+func (conn *Tunnel) handleTunnelReq(req *knxnet.TunnelReq, seqNumber *uint8) error {
+	return conn.handleSequenced(req.Channel, req.SeqNumber, seqNumber, func() {
+		conn.deliverInbound(req.Payload)
+	})
+}
+
+// handleDeviceConfigurationReq validates the request, pushes the management message to the client
+// and acknowledges the request for the gateway.
+func (conn *Tunnel) handleDeviceConfigurationReq(req *knxnet.DeviceConfigurationReq, seqNumber *uint8) error {
+	return conn.handleSequenced(req.Channel, req.SeqNumber, seqNumber, func() {
+		conn.deliverInbound(req.Payload)
+	})
+}
+
+// handleSequenced validates a request from the gateway which carries a sequence number, runs
+// deliver if it is not a repetition and acknowledges the request for the gateway.
 // 03_08_04 Tunnelling v01.05.03 AS.pdf
 // 2.6 Frame confirmation
-func (conn *Tunnel) handleTunnelReq(req *knxnet.TunnelReq, seqNumber *uint8) error {
+func (conn *Tunnel) handleSequenced(reqChannel, reqSeqNumber uint8, seqNumber *uint8, deliver func()) error {
 	conn.connMu.Lock()
 	channel := conn.channel
 	conn.connMu.Unlock()
 
 	// Validate the request channel.
-	if req.Channel != channel {
+	if reqChannel != channel {
 		return errors.New("invalid communication channel in tunnel request")
 	}
 
 	// In TCP connections, we don't need to check the sequence number and we don't need to acknowledge the
 	// tunnelling request.
 	if conn.config.UseTCP {
-		// Send tunnel data to the client without blocking this goroutine for too long.
-		conn.pushInbound(req.Payload)
+		deliver()
 
 		return nil
 	}
@@ -519,20 +854,27 @@ func (conn *Tunnel) handleTunnelReq(req *knxnet.TunnelReq, seqNumber *uint8) err
 	expected := *seqNumber
 
 	// Is the sequence number what we expected?
-	if req.SeqNumber == expected {
+	if reqSeqNumber == expected {
 		*seqNumber++
 
-		// Send tunnel data to the client without blocking this goroutine for too long.
-		conn.pushInbound(req.Payload)
-	} else if req.SeqNumber != expected-1 {
+		deliver()
+	} else if reqSeqNumber != expected-1 {
 		// The sequence number is out of the range which we would have to acknowledge.
 		return errors.New("out of sequence tunnel acknowledgement")
 	}
 
 	// Send the acknowledgement.
+	if conn.connType == knxnet.DeviceManagementConnection {
+		return conn.sock.Send(&knxnet.DeviceConfigurationAck{
+			Channel:   channel,
+			SeqNumber: reqSeqNumber,
+			Status:    0,
+		})
+	}
+
 	return conn.sock.Send(&knxnet.TunnelRes{
-		Channel:   conn.channel,
-		SeqNumber: req.SeqNumber,
+		Channel:   channel,
+		SeqNumber: reqSeqNumber,
 		Status:    0,
 	})
 }
@@ -555,7 +897,7 @@ func (conn *Tunnel) handleTunnelRes(res *knxnet.TunnelRes) error {
 		// writing to a closed channel here, and be done with it.
 		defer func() {
 			if r := recover(); r != nil {
-				log.Fatal(r)
+				util.Log(conn, "Dropped tunnel response: %v", r)
 			}
 		}()
 
@@ -569,6 +911,16 @@ func (conn *Tunnel) handleTunnelRes(res *knxnet.TunnelRes) error {
 	return nil
 }
 
+// handleDeviceConfigurationAck relays the acknowledgement like a tunnel response, since both
+// acknowledge a sequenced request in the same way.
+func (conn *Tunnel) handleDeviceConfigurationAck(ack *knxnet.DeviceConfigurationAck) error {
+	return conn.handleTunnelRes(&knxnet.TunnelRes{
+		Channel:   ack.Channel,
+		SeqNumber: ack.SeqNumber,
+		Status:    ack.Status,
+	})
+}
+
 // handleConnStateRes validates the response and sends it to the heartbeat routine, if there is a
 // waiting one.
 func (conn *Tunnel) handleConnStateRes(
@@ -590,7 +942,7 @@ func (conn *Tunnel) handleConnStateRes(
 		// when writing to a closed channel here, and be done with it.
 		defer func() {
 			if r := recover(); r != nil {
-				log.Fatal(r)
+				util.Log(conn, "Dropped connection state response: %v", r)
 			}
 		}()
 
@@ -604,19 +956,30 @@ func (conn *Tunnel) handleConnStateRes(
 	return nil
 }
 
+// These errors describe why a connection has been lost. They are reported through StateEvent.
 var (
-	errHeartbeatFailed = errors.New("heartbeat did not succeed")
-	errInboundClosed   = errors.New("socket's inbound channel is closed")
-	errDisconnected    = errors.New("gateway terminated the connection")
+	ErrHeartbeatFailed = errors.New("heartbeat did not succeed")
+	ErrInboundClosed   = errors.New("socket's inbound channel is closed")
+	ErrDisconnected    = errors.New("gateway terminated the connection")
 )
 
 // process incoming packets.
 func (conn *Tunnel) process() error {
 	heartbeat := make(chan knxnet.ErrCode)
-	defer close(heartbeat)
-
 	timeout := make(chan struct{})
 
+	// Heartbeats must not outlive this run, otherwise they report the end of the connection as a
+	// failure and wait for the next run to take their result.
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var heartbeats sync.WaitGroup
+
+	defer func() {
+		cancel()
+		heartbeats.Wait()
+		close(heartbeat)
+	}()
+
 	var seqNumber uint8
 
 	heartbeatInterval := time.NewTicker(conn.config.HeartbeatInterval)
@@ -630,16 +993,21 @@ func (conn *Tunnel) process() error {
 
 		// Heartbeat worker signals a result.
 		case <-timeout:
-			return errHeartbeatFailed
+			return ErrHeartbeatFailed
 
 		// Heartbeat check is due.
 		case <-heartbeatInterval.C:
-			go conn.performHeartbeat(heartbeat, timeout)
+			heartbeats.Add(1)
+
+			go func() {
+				defer heartbeats.Done()
+				conn.performHeartbeat(ctx, heartbeat, timeout)
+			}()
 
 		// A message has been received or the channel is closed.
 		case msg, open := <-conn.sock.Inbound():
 			if !open {
-				return errInboundClosed
+				return ErrInboundClosed
 			}
 
 			// Determine what to do with the message.
@@ -647,10 +1015,10 @@ func (conn *Tunnel) process() error {
 			case *knxnet.DiscReq:
 				err := conn.handleDiscReq(msg)
 				if err == nil {
-					return errDisconnected
+					return ErrDisconnected
 				}
 
-				util.Log(conn, "Error while handling disconnect request %v: %v", msg, err)
+				conn.errors.emit(conn, fmt.Errorf("handling disconnect request %v: %w", msg, err))
 
 			case *knxnet.DiscRes:
 				err := conn.handleDiscRes(msg)
@@ -658,27 +1026,48 @@ func (conn *Tunnel) process() error {
 					return nil
 				}
 
-				util.Log(conn, "Error while handling disconnect response %v: %v", msg, err)
+				conn.errors.emit(conn, fmt.Errorf("handling disconnect response %v: %w", msg, err))
 
 			case *knxnet.TunnelReq:
 				err := conn.handleTunnelReq(msg, &seqNumber)
 				if err != nil {
-					util.Log(conn, "Error while handling tunnel request %v: %v", msg, err)
+					conn.errors.emit(conn, fmt.Errorf("handling tunnel request %v: %w", msg, err))
 				}
 
 			case *knxnet.TunnelRes:
 				err := conn.handleTunnelRes(msg)
 				if err != nil {
-					util.Log(conn, "Error while handling tunnel response %v: %v", msg, err)
+					conn.errors.emit(conn, fmt.Errorf("handling tunnel response %v: %w", msg, err))
+				}
+
+			case *knxnet.DeviceConfigurationReq:
+				err := conn.handleDeviceConfigurationReq(msg, &seqNumber)
+				if err != nil {
+					conn.errors.emit(conn, fmt.Errorf("handling device configuration request %v: %w", msg, err))
+				}
+
+			case *knxnet.DeviceConfigurationAck:
+				err := conn.handleDeviceConfigurationAck(msg)
+				if err != nil {
+					conn.errors.emit(conn, fmt.Errorf("handling device configuration ack %v: %w", msg, err))
+				}
+
+			case *knxnet.TunnelFeatureRes:
+				err := conn.handleTunnelFeatureRes(msg, &seqNumber)
+				if err != nil {
+					conn.errors.emit(conn, fmt.Errorf("handling feature response %v: %w", msg, err))
+				}
+
+			case *knxnet.TunnelFeatureInfo:
+				err := conn.handleTunnelFeatureInfo(msg, &seqNumber)
+				if err != nil {
+					conn.errors.emit(conn, fmt.Errorf("handling feature info %v: %w", msg, err))
 				}
 
 			case *knxnet.ConnStateRes:
 				err := conn.handleConnStateRes(msg, heartbeat)
 				if err != nil {
-					util.Log(
-						conn,
-						"Error while handling connection state response: %v", err,
-					)
+					conn.errors.emit(conn, fmt.Errorf("handling connection state response: %w", err))
 				}
 			}
 		}
@@ -686,15 +1075,30 @@ func (conn *Tunnel) process() error {
 }
 
 // serve serves the tunnel connection. It can sustain certain failures. This method will try to
-// reconnect in case of a heartbeat failure or disconnect.
+// reconnect in case of a heartbeat failure or disconnect, as permitted by the reconnect policy.
 func (conn *Tunnel) serve() {
 	util.Log(conn, "Started worker")
 	defer util.Log(conn, "Worker exited")
 
+	defer conn.errors.close()
+	defer close(conn.featureInfo)
+	defer close(conn.state)
 	defer close(conn.ack)
-	defer close(conn.inbound)
+	defer conn.inbound.close()
 	defer conn.wait.Done()
 
+	// Reconnect attempts must not outlive the tunnel.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		select {
+		case <-conn.done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
 	for {
 		err := conn.process()
 		if err != nil {
@@ -702,18 +1106,69 @@ func (conn *Tunnel) serve() {
 		}
 
 		// Check if we can try again.
-		if err == errDisconnected || err == errHeartbeatFailed {
-			util.Log(conn, "Attempting reconnect")
-
-			reconnErr := conn.requestConn()
-			if reconnErr == nil {
-				util.Log(conn, "Reconnect succeeded")
+		if err == ErrDisconnected || err == ErrHeartbeatFailed {
+			err = conn.reconnect(ctx, err)
+			if err == nil {
 				continue
 			}
 
-			util.Log(conn, "Reconnect failed: %v", reconnErr)
+			// The tunnel has been closed while reconnecting.
+			if ctx.Err() != nil {
+				err = nil
+			} else {
+				conn.errors.emit(conn, fmt.Errorf("%w: %w", ErrReconnectFailed, err))
+			}
 		}
 
+		conn.emitState(StateEvent{State: StateClosed, Err: err})
+
 		return
 	}
 }
+
+// reconnect tries to re-establish the connection according to the reconnect policy. It returns
+// the error of the last attempt if it gives up.
+func (conn *Tunnel) reconnect(ctx context.Context, cause error) error {
+	policy := conn.config.Reconnect
+
+	for attempt := 1; policy.allows(attempt); attempt++ {
+		if delay := policy.delay(attempt); delay > 0 {
+			timer := time.NewTimer(delay)
+
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+
+			case <-timer.C:
+			}
+		}
+
+		conn.emitState(StateEvent{State: StateReconnecting, Attempt: attempt, Err: cause})
+		util.Log(conn, "Attempting reconnect #%d", attempt)
+
+		cause = conn.requestConn(ctx)
+		if cause == nil {
+			util.Log(conn, "Reconnect succeeded")
+			conn.emitState(StateEvent{State: StateConnected, Attempt: attempt})
+
+			return nil
+		}
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+
+	return cause
+}
+
+// emitState publishes a state event without blocking.
+func (conn *Tunnel) emitState(event StateEvent) {
+	select {
+	case conn.state <- event:
+
+	default:
+		util.Log(conn, "Dropped state event %v", event.State)
+	}
+}
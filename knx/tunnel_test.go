@@ -4,6 +4,8 @@
 package knx
 
 import (
+	"context"
+	"errors"
 	"log"
 	"testing"
 	"time"
@@ -22,7 +24,7 @@ func makeTunnelConn(
 		config:  config,
 		channel: channel,
 		ack:     make(chan *knxnet.TunnelRes),
-		inbound: make(chan cemi.Message, 100),
+		inbound: newInboundQueue(InboundConfig{QueueSize: 100}),
 	}
 }
 
@@ -47,7 +49,7 @@ func TestTunnelConn_requestConn(t *testing.T) {
 			config: DefaultTunnelConfig,
 		}
 
-		err = conn.requestConn()
+		err = conn.requestConn(context.Background())
 		if err == nil {
 			t.Fatal("Should not succeed")
 		}
@@ -78,9 +80,40 @@ func TestTunnelConn_requestConn(t *testing.T) {
 			config: config,
 		}
 
-		err := conn.requestConn()
-		if err != errResponseTimeout {
-			t.Fatalf("Expected error %v, got %v", errResponseTimeout, err)
+		err := conn.requestConn(context.Background())
+		if err != ErrResponseTimeout {
+			t.Fatalf("Expected error %v, got %v", ErrResponseTimeout, err)
+		}
+	})
+
+	// Context is cancelled before a response arrives.
+	t.Run("CancelledContext", func(t *testing.T) {
+		client, gateway := newDummySockets()
+		defer func() {
+			err := client.Close()
+			if err != nil {
+				log.Fatal(err)
+			}
+		}()
+
+		defer func() {
+			err := gateway.Close()
+			if err != nil {
+				log.Fatal(err)
+			}
+		}()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		conn := Tunnel{
+			sock:   client,
+			config: DefaultTunnelConfig,
+		}
+
+		err := conn.requestConn(ctx)
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("Expected error %v, got %v", context.Canceled, err)
 		}
 	})
 
@@ -108,7 +141,7 @@ func TestTunnelConn_requestConn(t *testing.T) {
 				config: config,
 			}
 
-			err := conn.requestConn()
+			err := conn.requestConn(context.Background())
 			if err == nil {
 				t.Fatal("Should not succeed")
 			}
@@ -161,7 +194,7 @@ func TestTunnelConn_requestConn(t *testing.T) {
 				config: config,
 			}
 
-			err := conn.requestConn()
+			err := conn.requestConn(context.Background())
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -193,7 +226,7 @@ func TestTunnelConn_requestConn(t *testing.T) {
 			config: DefaultTunnelConfig,
 		}
 
-		err := conn.requestConn()
+		err := conn.requestConn(context.Background())
 		if err == nil {
 			t.Fatal("Should not succeed")
 		}
@@ -248,7 +281,7 @@ func TestTunnelConn_requestConn(t *testing.T) {
 				config: DefaultTunnelConfig,
 			}
 
-			err := conn.requestConn()
+			err := conn.requestConn(context.Background())
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -311,7 +344,7 @@ func TestTunnelConn_requestConn(t *testing.T) {
 				},
 			}
 
-			err := conn.requestConn()
+			err := conn.requestConn(context.Background())
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -373,13 +406,133 @@ func TestTunnelConn_requestConn(t *testing.T) {
 				config: config,
 			}
 
-			err := conn.requestConn()
+			err := conn.requestConn(context.Background())
 			if err != nil {
 				t.Fatal(err)
 			}
 		})
 	})
 
+	// The requested individual address stays in use.
+	t.Run("AddressInUse", func(t *testing.T) {
+		client, gateway := newDummySockets()
+
+		addr := cemi.NewIndividualAddr3(1, 1, 250)
+
+		t.Run("Gateway", func(t *testing.T) {
+			t.Parallel()
+
+			defer func() {
+				err := gateway.Close()
+				if err != nil {
+					log.Fatal(err)
+				}
+			}()
+
+			msg := <-gateway.Inbound()
+			if req, ok := msg.(*knxnet.ConnReq); ok {
+				if req.IndividualAddr != addr {
+					t.Errorf("Expected individual address %v, got %v", addr, req.IndividualAddr)
+				}
+
+				_ = gateway.sendAny(&knxnet.ConnRes{
+					Channel: 0,
+					Status:  knxnet.ErrNoMoreUniqueConnections,
+					Control: req.Control,
+				})
+			} else {
+				t.Fatalf("Unexpected incoming message type: %T", msg)
+			}
+		})
+
+		t.Run("Client", func(t *testing.T) {
+			t.Parallel()
+
+			defer func() {
+				err := client.Close()
+				if err != nil {
+					log.Fatal(err)
+				}
+			}()
+
+			config := DefaultTunnelConfig
+			config.ResponseTimeout = 5 * time.Second
+			config.IndividualAddress = addr
+
+			conn := Tunnel{
+				sock:   client,
+				config: config,
+			}
+
+			start := time.Now()
+
+			err := conn.requestConn(context.Background())
+			if !errors.Is(err, knxnet.ErrCode(knxnet.ErrNoMoreUniqueConnections)) {
+				t.Fatalf("Expected error %v, got %v", knxnet.ErrCode(knxnet.ErrNoMoreUniqueConnections), err)
+			}
+
+			// The error must not wait for the response timeout.
+			if elapsed := time.Since(start); elapsed >= config.ResponseTimeout {
+				t.Errorf("Error has been returned after %v", elapsed)
+			}
+		})
+	})
+
+	// The gateway assigns an individual address to the tunnel.
+	t.Run("AssignedAddress", func(t *testing.T) {
+		client, gateway := newDummySockets()
+
+		addr := cemi.NewIndividualAddr3(1, 1, 251)
+
+		t.Run("Gateway", func(t *testing.T) {
+			t.Parallel()
+
+			defer func() {
+				err := gateway.Close()
+				if err != nil {
+					log.Fatal(err)
+				}
+			}()
+
+			msg := <-gateway.Inbound()
+			if req, ok := msg.(*knxnet.ConnReq); ok {
+				_ = gateway.sendAny(&knxnet.ConnRes{
+					Channel: 1,
+					Status:  knxnet.NoError,
+					Control: req.Control,
+					Data:    knxnet.ConnResData{ConnType: knxnet.TunnelConnection, IndividualAddr: addr},
+				})
+			} else {
+				t.Fatalf("Unexpected incoming message type: %T", msg)
+			}
+		})
+
+		t.Run("Client", func(t *testing.T) {
+			t.Parallel()
+
+			defer func() {
+				err := client.Close()
+				if err != nil {
+					log.Fatal(err)
+				}
+			}()
+
+			conn := Tunnel{
+				sock:   client,
+				config: DefaultTunnelConfig,
+			}
+
+			err := conn.requestConn(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if conn.IndividualAddr() != addr {
+				t.Fatalf("Expected individual address %v, got %v", addr, conn.IndividualAddr())
+			}
+		})
+	})
+
 	// The gateway doesn't supported the requested connection type.
 	t.Run("Unsupported", func(t *testing.T) {
 		client, gateway := newDummySockets()
@@ -421,7 +574,7 @@ func TestTunnelConn_requestConn(t *testing.T) {
 				config: DefaultTunnelConfig,
 			}
 
-			err := conn.requestConn()
+			err := conn.requestConn(context.Background())
 			if err != knxnet.ErrCode(knxnet.ErrConnectionType) {
 				t.Fatalf("Expected error %v, got %v", knxnet.ErrConnectionType, err)
 			}
@@ -443,7 +596,7 @@ func TestTunnelConn_requestState(t *testing.T) {
 
 		conn := makeTunnelConn(client, DefaultTunnelConfig, 1)
 
-		_, err := conn.requestConnState(make(chan knxnet.ErrCode))
+		_, err := conn.requestConnState(context.Background(), make(chan knxnet.ErrCode))
 		if err == nil {
 			t.Fatal("Should not succeed")
 		}
@@ -470,9 +623,9 @@ func TestTunnelConn_requestState(t *testing.T) {
 
 		conn := makeTunnelConn(client, config, 1)
 
-		_, err := conn.requestConnState(make(chan knxnet.ErrCode))
-		if err != errResponseTimeout {
-			t.Fatalf("Expected error %v, got %v", errResponseTimeout, err)
+		_, err := conn.requestConnState(context.Background(), make(chan knxnet.ErrCode))
+		if err != ErrResponseTimeout {
+			t.Fatalf("Expected error %v, got %v", ErrResponseTimeout, err)
 		}
 	})
 
@@ -508,7 +661,7 @@ func TestTunnelConn_requestState(t *testing.T) {
 
 			conn := makeTunnelConn(client, config, 1)
 
-			_, err := conn.requestConnState(make(chan knxnet.ErrCode))
+			_, err := conn.requestConnState(context.Background(), make(chan knxnet.ErrCode))
 			if err == nil {
 				t.Fatal("Should not succeed")
 			}
@@ -565,7 +718,7 @@ func TestTunnelConn_requestState(t *testing.T) {
 
 			conn := makeTunnelConn(client, config, channel)
 
-			state, err := conn.requestConnState(heartbeat)
+			state, err := conn.requestConnState(context.Background(), heartbeat)
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -596,7 +749,7 @@ func TestTunnelConn_requestState(t *testing.T) {
 
 		conn := makeTunnelConn(client, DefaultTunnelConfig, 1)
 
-		_, err := conn.requestConnState(heartbeat)
+		_, err := conn.requestConnState(context.Background(), heartbeat)
 		if err == nil {
 			t.Fatal("Should not succeed")
 		}
@@ -647,7 +800,7 @@ func TestTunnelConn_requestState(t *testing.T) {
 
 			conn := makeTunnelConn(client, DefaultTunnelConfig, channel)
 
-			state, err := conn.requestConnState(heartbeat)
+			state, err := conn.requestConnState(context.Background(), heartbeat)
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -703,7 +856,7 @@ func TestTunnelConn_requestState(t *testing.T) {
 
 			conn := makeTunnelConn(client, DefaultTunnelConfig, channel)
 
-			state, err := conn.requestConnState(heartbeat)
+			state, err := conn.requestConnState(context.Background(), heartbeat)
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -729,7 +882,7 @@ func TestTunnelConn_requestTunnel(t *testing.T) {
 
 		conn := makeTunnelConn(client, DefaultTunnelConfig, 1)
 
-		err := conn.requestTunnel(&cemi.UnsupportedMessage{})
+		err := conn.requestTunnel(context.Background(), &cemi.UnsupportedMessage{})
 		if err == nil {
 			t.Fatal("Should not succeed")
 		}
@@ -755,9 +908,35 @@ func TestTunnelConn_requestTunnel(t *testing.T) {
 
 		conn := makeTunnelConn(client, config, 1)
 
-		err := conn.requestTunnel(&cemi.UnsupportedMessage{})
-		if err != errResponseTimeout {
-			t.Fatalf("Expected %v, got %v", errResponseTimeout, err)
+		err := conn.requestTunnel(context.Background(), &cemi.UnsupportedMessage{})
+		if err != ErrResponseTimeout {
+			t.Fatalf("Expected %v, got %v", ErrResponseTimeout, err)
+		}
+	})
+
+	t.Run("CancelledContext", func(t *testing.T) {
+		client, gateway := newDummySockets()
+		defer func() {
+			err := client.Close()
+			if err != nil {
+				log.Fatal(err)
+			}
+		}()
+		defer func() {
+			err := gateway.Close()
+			if err != nil {
+				log.Fatal(err)
+			}
+		}()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		conn := makeTunnelConn(client, DefaultTunnelConfig, 1)
+
+		err := conn.requestTunnel(ctx, &cemi.UnsupportedMessage{})
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("Expected %v, got %v", context.Canceled, err)
 		}
 	})
 
@@ -793,7 +972,7 @@ func TestTunnelConn_requestTunnel(t *testing.T) {
 
 			conn := makeTunnelConn(client, config, 1)
 
-			err := conn.requestTunnel(&cemi.UnsupportedMessage{})
+			err := conn.requestTunnel(context.Background(), &cemi.UnsupportedMessage{})
 			if err == nil {
 				t.Fatal("Should not succeed")
 			}
@@ -850,7 +1029,7 @@ func TestTunnelConn_requestTunnel(t *testing.T) {
 			conn := makeTunnelConn(client, config, channel)
 			conn.ack = ack
 
-			err := conn.requestTunnel(&cemi.UnsupportedMessage{})
+			err := conn.requestTunnel(context.Background(), &cemi.UnsupportedMessage{})
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -875,7 +1054,7 @@ func TestTunnelConn_requestTunnel(t *testing.T) {
 		conn := makeTunnelConn(client, DefaultTunnelConfig, 1)
 		close(conn.ack)
 
-		err := conn.requestTunnel(&cemi.UnsupportedMessage{})
+		err := conn.requestTunnel(context.Background(), &cemi.UnsupportedMessage{})
 		if err == nil {
 			t.Fatal("Should not succeed")
 		}
@@ -932,7 +1111,7 @@ func TestTunnelConn_requestTunnel(t *testing.T) {
 			conn := makeTunnelConn(client, DefaultTunnelConfig, channel)
 			conn.ack = ack
 
-			err := conn.requestTunnel(&cemi.UnsupportedMessage{})
+			err := conn.requestTunnel(context.Background(), &cemi.UnsupportedMessage{})
 			if err == nil {
 				t.Fatal("Should not succeed")
 			}
@@ -984,7 +1163,7 @@ func TestTunnelConn_requestTunnel(t *testing.T) {
 			conn := makeTunnelConn(client, DefaultTunnelConfig, channel)
 			conn.ack = ack
 
-			err := conn.requestTunnel(&cemi.UnsupportedMessage{})
+			err := conn.requestTunnel(context.Background(), &cemi.UnsupportedMessage{})
 			if err == nil {
 				t.Fatal("Should not succeed")
 			}
@@ -1036,7 +1215,7 @@ func TestTunnelConn_requestTunnel(t *testing.T) {
 			conn := makeTunnelConn(client, DefaultTunnelConfig, channel)
 			conn.ack = ack
 
-			err := conn.requestTunnel(&cemi.UnsupportedMessage{})
+			err := conn.requestTunnel(context.Background(), &cemi.UnsupportedMessage{})
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -1257,3 +1436,245 @@ func TestTunnelConn_handleTunnelRes(t *testing.T) {
 		})
 	})
 }
+
+func TestTunnelConn_requestTunnelConfirmation(t *testing.T) {
+	const channel uint8 = 1
+
+	ldata := GroupEventFrame(GroupEvent{
+		Command:     GroupWrite,
+		Destination: cemi.NewGroupAddr3(1, 2, 3),
+		Data:        []byte{1},
+	})
+
+	run := func(t *testing.T, con *cemi.LDataCon, check func(error)) {
+		client, gateway := newDummySockets()
+		ack := make(chan *knxnet.TunnelRes)
+
+		config := DefaultTunnelConfig
+		config.WaitForConfirmation = true
+		config.ConfirmationTimeout = 50 * time.Millisecond
+
+		conn := makeTunnelConn(client, config, channel)
+		conn.ack = ack
+
+		t.Run("Gateway", func(t *testing.T) {
+			t.Parallel()
+
+			defer func() {
+				err := gateway.Close()
+				if err != nil {
+					log.Fatal(err)
+				}
+			}()
+
+			msg := <-gateway.Inbound()
+			if req, ok := msg.(*knxnet.TunnelReq); ok {
+				ack <- &knxnet.TunnelRes{Channel: req.Channel, SeqNumber: req.SeqNumber, Status: 0}
+
+				if con != nil {
+					conn.deliverInbound(con)
+				}
+			} else {
+				t.Fatalf("Unexpected type %T", msg)
+			}
+		})
+
+		t.Run("Client", func(t *testing.T) {
+			t.Parallel()
+
+			defer func() {
+				err := client.Close()
+				if err != nil {
+					log.Fatal(err)
+				}
+			}()
+
+			check(conn.requestTunnel(context.Background(), &cemi.LDataReq{LData: ldata}))
+		})
+	}
+
+	t.Run("Positive", func(t *testing.T) {
+		con := &cemi.LDataCon{LData: ldata}
+		con.Source = cemi.NewIndividualAddr3(1, 1, 250)
+
+		run(t, con, func(err error) {
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	})
+
+	t.Run("Negative", func(t *testing.T) {
+		con := &cemi.LDataCon{LData: ldata}
+		con.Control1 |= cemi.Control1HasError
+
+		run(t, con, func(err error) {
+			if !errors.Is(err, ErrNegativeConfirmation) {
+				t.Fatalf("Expected %v, got %v", ErrNegativeConfirmation, err)
+			}
+		})
+	})
+
+	t.Run("Mismatching", func(t *testing.T) {
+		con := &cemi.LDataCon{LData: ldata}
+		con.Destination++
+
+		run(t, con, func(err error) {
+			if !errors.Is(err, ErrMissingConfirmation) {
+				t.Fatalf("Expected %v, got %v", ErrMissingConfirmation, err)
+			}
+		})
+	})
+
+	t.Run("Missing", func(t *testing.T) {
+		run(t, nil, func(err error) {
+			var confErr *ConfirmationError
+			if !errors.As(err, &confErr) || confErr.Con != nil {
+				t.Fatalf("Expected missing confirmation, got %v", err)
+			}
+		})
+	})
+}
+
+func TestTunnelConn_requestTunnelSource(t *testing.T) {
+	addr := cemi.NewIndividualAddr3(1, 1, 251)
+	other := cemi.NewIndividualAddr3(1, 1, 10)
+
+	run := func(t *testing.T, source, expected cemi.IndividualAddr) {
+		client, gateway := newDummySockets()
+		ack := make(chan *knxnet.TunnelRes)
+
+		conn := makeTunnelConn(client, DefaultTunnelConfig, 1)
+		conn.ack = ack
+		conn.addr = addr
+
+		req := &cemi.LDataReq{LData: GroupEventFrame(GroupEvent{
+			Command:     GroupWrite,
+			Destination: cemi.NewGroupAddr3(1, 2, 3),
+			Data:        []byte{1},
+		})}
+		req.Source = source
+
+		t.Run("Gateway", func(t *testing.T) {
+			t.Parallel()
+
+			defer func() {
+				err := gateway.Close()
+				if err != nil {
+					log.Fatal(err)
+				}
+			}()
+
+			msg := <-gateway.Inbound()
+			if tunnelReq, ok := msg.(*knxnet.TunnelReq); ok {
+				if ldata, ok := tunnelReq.Payload.(*cemi.LDataReq); !ok || ldata.Source != expected {
+					t.Errorf("Expected source %v, got %+v", expected, tunnelReq.Payload)
+				}
+
+				ack <- &knxnet.TunnelRes{Channel: tunnelReq.Channel, SeqNumber: tunnelReq.SeqNumber, Status: 0}
+			} else {
+				t.Fatalf("Unexpected type %T", msg)
+			}
+		})
+
+		t.Run("Client", func(t *testing.T) {
+			t.Parallel()
+
+			defer func() {
+				err := client.Close()
+				if err != nil {
+					log.Fatal(err)
+				}
+			}()
+
+			if err := conn.requestTunnel(context.Background(), req); err != nil {
+				t.Fatal(err)
+			}
+
+			if req.Source != source {
+				t.Errorf("Request was modified: source %v != %v", req.Source, source)
+			}
+		})
+	}
+
+	t.Run("Default", func(t *testing.T) {
+		run(t, 0, addr)
+	})
+
+	t.Run("Explicit", func(t *testing.T) {
+		run(t, other, other)
+	})
+}
+
+func TestTunnelConn_reconnect(t *testing.T) {
+	client, gateway := newDummySockets()
+
+	config := DefaultTunnelConfig
+	config.ResponseTimeout = 20 * time.Millisecond
+	config.ResendInterval = time.Second
+	config.Reconnect = checkReconnectPolicy(ReconnectPolicy{
+		MaxAttempts:  3,
+		InitialDelay: time.Millisecond,
+	})
+
+	conn := makeTunnelConn(client, config, 1)
+	conn.state = make(chan StateEvent, eventBufferSize)
+
+	t.Run("Gateway", func(t *testing.T) {
+		t.Parallel()
+
+		defer func() {
+			err := gateway.Close()
+			if err != nil {
+				log.Fatal(err)
+			}
+		}()
+
+		// Ignore the first attempt.
+		<-gateway.Inbound()
+
+		msg := <-gateway.Inbound()
+		if req, ok := msg.(*knxnet.ConnReq); ok {
+			_ = gateway.sendAny(&knxnet.ConnRes{
+				Channel: 2,
+				Status:  knxnet.NoError,
+				Control: req.Control,
+			})
+		} else {
+			t.Fatalf("Unexpected incoming message type: %T", msg)
+		}
+	})
+
+	t.Run("Client", func(t *testing.T) {
+		t.Parallel()
+
+		defer func() {
+			err := client.Close()
+			if err != nil {
+				log.Fatal(err)
+			}
+		}()
+
+		err := conn.reconnect(context.Background(), ErrHeartbeatFailed)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := []StateEvent{
+			{State: StateReconnecting, Attempt: 1, Err: ErrHeartbeatFailed},
+			{State: StateReconnecting, Attempt: 2, Err: ErrResponseTimeout},
+			{State: StateConnected, Attempt: 2},
+		}
+
+		for _, want := range expected {
+			got := <-conn.State()
+			if got != want {
+				t.Errorf("Expected state event %+v, got %+v", want, got)
+			}
+		}
+
+		if conn.channel != 2 {
+			t.Errorf("Expected channel 2, got %d", conn.channel)
+		}
+	})
+}
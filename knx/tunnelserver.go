@@ -0,0 +1,907 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knx
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/mobilarte/knx-exp/knx/cemi"
+	"github.com/mobilarte/knx-exp/knx/knxnet"
+	"github.com/mobilarte/knx-exp/knx/util"
+)
+
+// A TunnelBackend is the bus to which a TunnelServer forwards the frames of its clients. Router
+// and Tunnel are backends. The server consumes the Inbound channel of its backend.
+type TunnelBackend interface {
+	// Send transmits a frame that a client has sent. Frames are passed on as L_Data.req, except
+	// to a Router, which exchanges L_Data.ind with other routers.
+	Send(data cemi.Message) error
+
+	// Inbound delivers the frames from the bus, which are relayed to all clients.
+	Inbound() <-chan cemi.Message
+}
+
+// TunnelServerConfig configures a TunnelServer.
+type TunnelServerConfig struct {
+	// Address is the local address on which the server listens for UDP and TCP connections.
+	Address string
+
+	// AddressPool contains the individual addresses that are assigned to the connections. Its
+	// size limits the number of simultaneous connections.
+	AddressPool []cemi.IndividualAddr
+
+	// ConnectionTimeout is the time after which a connection is dropped if its client has not sent
+	// a heartbeat.
+	ConnectionTimeout time.Duration
+
+	// ResponseTimeout specifies how long to wait for a client to acknowledge a tunnel request. The
+	// request is repeated once before the connection is dropped.
+	ResponseTimeout time.Duration
+
+	// OutboundQueueSize is the number of frames that wait to be sent to a client. Further frames
+	// are dropped until the client has caught up. It also limits the frames of all clients that
+	// wait to be sent to the backend; further frames are confirmed negatively.
+	OutboundQueueSize int
+
+	// Description is sent in response to description requests. If it is nil, such requests are
+	// ignored. A DiscoveryResponder with the same description makes the server discoverable.
+	Description *knxnet.DescriptionBlock
+}
+
+// DefaultTunnelServerConfig is a good default configuration for a TunnelServer.
+var DefaultTunnelServerConfig = TunnelServerConfig{
+	Address: ":3671",
+	AddressPool: []cemi.IndividualAddr{
+		0x11f1, 0x11f2, 0x11f3, 0x11f4, 0x11f5, 0x11f6, 0x11f7, 0x11f8,
+	},
+	ConnectionTimeout: 120 * time.Second,
+	ResponseTimeout:   time.Second,
+	OutboundQueueSize: 256,
+}
+
+// maxServerConnections is the number of available channel identifiers.
+const maxServerConnections = 255
+
+// checkTunnelServerConfig makes sure that the configuration is actually usable.
+func checkTunnelServerConfig(config TunnelServerConfig) (TunnelServerConfig, error) {
+	if config.Address == "" {
+		config.Address = DefaultTunnelServerConfig.Address
+	}
+
+	if len(config.AddressPool) == 0 {
+		config.AddressPool = DefaultTunnelServerConfig.AddressPool
+	}
+
+	if len(config.AddressPool) > maxServerConnections {
+		return config, fmt.Errorf("address pool exceeds %d addresses", maxServerConnections)
+	}
+
+	if config.ConnectionTimeout <= 0 {
+		config.ConnectionTimeout = DefaultTunnelServerConfig.ConnectionTimeout
+	}
+
+	if config.ResponseTimeout <= 0 {
+		config.ResponseTimeout = DefaultTunnelServerConfig.ResponseTimeout
+	}
+
+	if config.OutboundQueueSize <= 0 {
+		config.OutboundQueueSize = DefaultTunnelServerConfig.OutboundQueueSize
+	}
+
+	return config, nil
+}
+
+// A TunnelServer is a KNXnet/IP tunnelling server. It accepts tunnelling connections on UDP and
+// TCP and shares one backend among them. Frames of one client are also relayed to the others, as
+// if they were connected to the same line.
+type TunnelServer struct {
+	backend TunnelBackend
+	config  TunnelServerConfig
+	udp     *net.UDPConn
+	tcp     *net.TCPListener
+	errors  *asyncErrors
+
+	mu          sync.Mutex
+	conns       map[uint8]*serverConn
+	links       map[*tcpEndpoint]struct{}
+	nextChannel uint8
+	closed      bool
+
+	forward chan forwardRequest
+	done    chan struct{}
+	wait    sync.WaitGroup
+}
+
+// NewTunnelServer starts a server which forwards the frames of its clients to the backend.
+func NewTunnelServer(backend TunnelBackend, config TunnelServerConfig) (*TunnelServer, error) {
+	config, err := checkTunnelServerConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	udpAddr, err := net.ResolveUDPAddr("udp4", config.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	udp, err := net.ListenUDP("udp4", udpAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	tcpAddr, err := net.ResolveTCPAddr("tcp4", config.Address)
+	if err != nil {
+		_ = udp.Close()
+		return nil, err
+	}
+
+	tcp, err := net.ListenTCP("tcp4", tcpAddr)
+	if err != nil {
+		_ = udp.Close()
+		return nil, err
+	}
+
+	srv := &TunnelServer{
+		backend: backend,
+		config:  config,
+		udp:     udp,
+		tcp:     tcp,
+		errors:  newAsyncErrors(),
+		conns:   map[uint8]*serverConn{},
+		links:   map[*tcpEndpoint]struct{}{},
+		forward: make(chan forwardRequest, config.OutboundQueueSize),
+		done:    make(chan struct{}),
+	}
+
+	srv.wait.Add(4)
+
+	go srv.serveUDP()
+	go srv.serveTCP()
+	go srv.serveForward()
+	go srv.serveBackend()
+
+	return srv, nil
+}
+
+// UDPAddr returns the local address on which the server accepts UDP connections.
+func (srv *TunnelServer) UDPAddr() net.Addr {
+	return srv.udp.LocalAddr()
+}
+
+// TCPAddr returns the local address on which the server accepts TCP connections.
+func (srv *TunnelServer) TCPAddr() net.Addr {
+	return srv.tcp.Addr()
+}
+
+// Errors returns the channel which transmits errors of the background goroutines, e.g. frames
+// that the backend failed to send. The channel is buffered; errors are dropped when it is full.
+// It is closed once the server has been closed.
+func (srv *TunnelServer) Errors() <-chan error {
+	return srv.errors.channel()
+}
+
+// Close disconnects all clients and shuts the server down.
+func (srv *TunnelServer) Close() error {
+	srv.mu.Lock()
+
+	if srv.closed {
+		srv.mu.Unlock()
+		return nil
+	}
+
+	srv.closed = true
+	conns := make([]*serverConn, 0, len(srv.conns))
+
+	for _, sc := range srv.conns {
+		conns = append(conns, sc)
+	}
+
+	srv.mu.Unlock()
+
+	for _, sc := range conns {
+		srv.disconnect(sc)
+	}
+
+	close(srv.done)
+
+	err := errors.Join(srv.udp.Close(), srv.tcp.Close())
+
+	srv.mu.Lock()
+	for link := range srv.links {
+		_ = link.conn.Close()
+	}
+	srv.mu.Unlock()
+
+	srv.wait.Wait()
+	srv.errors.close()
+
+	return err
+}
+
+// serverEndpoint transmits packets to a client.
+type serverEndpoint interface {
+	send(payload knxnet.ServicePackable) error
+}
+
+// udpEndpoint is a client endpoint which is reached through the server's UDP socket.
+type udpEndpoint struct {
+	conn *net.UDPConn
+	addr *net.UDPAddr
+}
+
+// send transmits the packet.
+func (ep *udpEndpoint) send(payload knxnet.ServicePackable) error {
+	_, err := ep.conn.WriteToUDP(knxnet.AllocAndPack(payload), ep.addr)
+	return err
+}
+
+// tcpEndpoint is a TCP connection of a client. It carries the control and data packets.
+type tcpEndpoint struct {
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// send transmits the packet.
+func (ep *tcpEndpoint) send(payload knxnet.ServicePackable) error {
+	ep.mu.Lock()
+	defer ep.mu.Unlock()
+
+	_, err := ep.conn.Write(knxnet.AllocAndPack(payload))
+
+	return err
+}
+
+// serverConn is a tunnelling connection of a client.
+type serverConn struct {
+	channel uint8
+	addr    cemi.IndividualAddr
+	control serverEndpoint
+	data    serverEndpoint
+
+	// Only set for connections over TCP, which need neither sequence numbers nor acknowledgements
+	link *tcpEndpoint
+
+	// Next expected sequence number from the client, only used by the receiving worker
+	seqIn uint8
+
+	outbound  chan cemi.Message
+	acks      chan uint8
+	heartbeat *time.Timer
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// forwardRequest is a frame of a client which waits to be sent to the backend.
+type forwardRequest struct {
+	conn *serverConn
+	req  *cemi.LDataReq
+}
+
+// enqueue queues the frame for the client. It is dropped if the client does not keep up.
+func (sc *serverConn) enqueue(msg cemi.Message) {
+	select {
+	case sc.outbound <- msg:
+
+	case <-sc.done:
+
+	default:
+		util.Log(sc, "Outbound queue of channel %d is full, dropped %v", sc.channel, msg.MessageCode())
+	}
+}
+
+// close terminates the connection's worker. It is safe to call close more than once.
+func (sc *serverConn) close() {
+	sc.closeOnce.Do(func() {
+		sc.heartbeat.Stop()
+		close(sc.done)
+	})
+}
+
+// awaitAck waits for the client to acknowledge the tunnel request with the given sequence number.
+func (sc *serverConn) awaitAck(seqNumber uint8, timeout time.Duration) bool {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-sc.done:
+			return false
+
+		case <-timer.C:
+			return false
+
+		case ack := <-sc.acks:
+			if ack == seqNumber {
+				return true
+			}
+		}
+	}
+}
+
+// serveOutbound sends the queued frames to the client, one after another.
+func (srv *TunnelServer) serveOutbound(sc *serverConn) {
+	defer srv.wait.Done()
+
+	var seqNumber uint8
+
+	for {
+		var msg cemi.Message
+
+		select {
+		case <-sc.done:
+			return
+
+		case msg = <-sc.outbound:
+		}
+
+		req := &knxnet.TunnelReq{Channel: sc.channel, SeqNumber: seqNumber, Payload: msg}
+
+		// Over TCP there are no acknowledgements.
+		if sc.link != nil {
+			if err := sc.data.send(req); err != nil {
+				util.Log(srv, "Sending to channel %d failed: %v", sc.channel, err)
+			}
+
+			continue
+		}
+
+		// The request is repeated once if the client does not acknowledge it.
+		acked := false
+
+		for attempt := 0; attempt < 2 && !acked; attempt++ {
+			if err := sc.data.send(req); err != nil {
+				util.Log(srv, "Sending to channel %d failed: %v", sc.channel, err)
+			}
+
+			acked = sc.awaitAck(seqNumber, srv.config.ResponseTimeout)
+		}
+
+		select {
+		case <-sc.done:
+			return
+		default:
+		}
+
+		if !acked {
+			util.Log(srv, "Channel %d did not acknowledge tunnel request %d", sc.channel, seqNumber)
+			srv.disconnect(sc)
+
+			return
+		}
+
+		seqNumber++
+	}
+}
+
+// remove unregisters the connection and terminates its worker. It reports whether the connection
+// was still registered.
+func (srv *TunnelServer) remove(sc *serverConn) bool {
+	srv.mu.Lock()
+	registered := srv.conns[sc.channel] == sc
+
+	if registered {
+		delete(srv.conns, sc.channel)
+	}
+	srv.mu.Unlock()
+
+	sc.close()
+
+	return registered
+}
+
+// disconnect drops the connection and tells the client about it.
+func (srv *TunnelServer) disconnect(sc *serverConn) {
+	if !srv.remove(sc) {
+		return
+	}
+
+	util.Log(srv, "Disconnecting channel %d", sc.channel)
+
+	if err := sc.control.send(&knxnet.DiscReq{Channel: sc.channel}); err != nil {
+		util.Log(srv, "Sending disconnect request to channel %d failed: %v", sc.channel, err)
+	}
+}
+
+// connection looks up the connection with the given channel that has been established through
+// the given TCP connection, or through UDP if link is nil.
+func (srv *TunnelServer) connection(channel uint8, link *tcpEndpoint) *serverConn {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	if sc, ok := srv.conns[channel]; ok && sc.link == link {
+		return sc
+	}
+
+	return nil
+}
+
+// allocate registers a connection with a free channel and individual address. A specific address
+// may be requested.
+func (srv *TunnelServer) allocate(sc *serverConn, requested cemi.IndividualAddr) knxnet.ErrCode {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	if srv.closed {
+		return knxnet.ErrNoMoreConnections
+	}
+
+	inUse := make(map[cemi.IndividualAddr]bool, len(srv.conns))
+	for _, other := range srv.conns {
+		inUse[other.addr] = true
+	}
+
+	sc.addr = 0
+
+	for _, addr := range srv.config.AddressPool {
+		if (requested == 0 || addr == requested) && !inUse[addr] {
+			sc.addr = addr
+			break
+		}
+	}
+
+	if sc.addr == 0 {
+		switch {
+		case requested == 0:
+			return knxnet.ErrNoMoreConnections
+
+		case inUse[requested]:
+			return knxnet.ErrNoMoreUniqueConnections
+
+		default:
+			return knxnet.ErrConnectionOption
+		}
+	}
+
+	// Channels are handed out in turn, so that a stale client does not hit a new connection.
+	for range maxServerConnections {
+		srv.nextChannel++
+		if srv.nextChannel == 0 {
+			srv.nextChannel = 1
+		}
+
+		if _, taken := srv.conns[srv.nextChannel]; !taken {
+			sc.channel = srv.nextChannel
+			srv.conns[sc.channel] = sc
+
+			return knxnet.NoError
+		}
+	}
+
+	return knxnet.ErrNoMoreConnections
+}
+
+// broadcast queues the frame for all connections except the given one.
+func (srv *TunnelServer) broadcast(msg cemi.Message, except *serverConn) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	for _, sc := range srv.conns {
+		if sc != except {
+			sc.enqueue(msg)
+		}
+	}
+}
+
+// handleConnReq establishes a connection if the request can be served.
+func (srv *TunnelServer) handleConnReq(
+	req *knxnet.ConnReq,
+	control, data serverEndpoint,
+	link *tcpEndpoint,
+	protocol knxnet.Protocol,
+) error {
+	res := &knxnet.ConnRes{Status: knxnet.NoError}
+
+	switch {
+	case req.Control.Protocol != protocol || req.Tunnel.Protocol != protocol:
+		res.Status = knxnet.ErrHostProtocolType
+
+	case req.ConnType != knxnet.TunnelConnection:
+		res.Status = knxnet.ErrConnectionType
+
+	case req.Layer != knxnet.TunnelLayerData:
+		res.Status = knxnet.ErrTunnellingLayer
+	}
+
+	if res.Status != knxnet.NoError {
+		return control.send(res)
+	}
+
+	sc := &serverConn{
+		control:  control,
+		data:     data,
+		link:     link,
+		outbound: make(chan cemi.Message, srv.config.OutboundQueueSize),
+		acks:     make(chan uint8, 8),
+		done:     make(chan struct{}),
+	}
+
+	sc.heartbeat = time.AfterFunc(srv.config.ConnectionTimeout, func() {
+		util.Log(srv, "Channel %d timed out", sc.channel)
+		srv.disconnect(sc)
+	})
+
+	if res.Status = srv.allocate(sc, req.IndividualAddr); res.Status != knxnet.NoError {
+		sc.heartbeat.Stop()
+		return control.send(res)
+	}
+
+	srv.wait.Add(1)
+
+	go srv.serveOutbound(sc)
+
+	util.Log(srv, "Channel %d connected with address %v", sc.channel, sc.addr)
+
+	res.Channel = sc.channel
+	res.Control = srv.hostInfo(protocol)
+	res.Data = knxnet.ConnResData{ConnType: knxnet.TunnelConnection, IndividualAddr: sc.addr}
+
+	return control.send(res)
+}
+
+// hostInfo describes the server's data endpoint.
+func (srv *TunnelServer) hostInfo(protocol knxnet.Protocol) knxnet.HostInfo {
+	// Over TCP, the data endpoint is the connection itself.
+	if protocol == knxnet.TCP4 {
+		return knxnet.HostInfo{Protocol: knxnet.TCP4}
+	}
+
+	info, err := knxnet.HostInfoFromAddress(srv.udp.LocalAddr())
+	if err != nil {
+		return knxnet.HostInfo{Protocol: knxnet.UDP4}
+	}
+
+	return info
+}
+
+// handleConnStateReq answers a heartbeat.
+func (srv *TunnelServer) handleConnStateReq(
+	req *knxnet.ConnStateReq,
+	control serverEndpoint,
+	link *tcpEndpoint,
+) error {
+	res := &knxnet.ConnStateRes{Channel: req.Channel, Status: knxnet.NoError}
+
+	if sc := srv.connection(req.Channel, link); sc != nil {
+		sc.heartbeat.Reset(srv.config.ConnectionTimeout)
+	} else {
+		res.Status = knxnet.ErrConnectionID
+	}
+
+	return control.send(res)
+}
+
+// handleDiscReq terminates a connection on behalf of the client.
+func (srv *TunnelServer) handleDiscReq(req *knxnet.DiscReq, control serverEndpoint, link *tcpEndpoint) error {
+	res := &knxnet.DiscRes{Channel: req.Channel}
+
+	if sc := srv.connection(req.Channel, link); sc != nil {
+		srv.remove(sc)
+		util.Log(srv, "Channel %d disconnected", sc.channel)
+	} else {
+		res.Status = knxnet.ErrConnectionID
+	}
+
+	return control.send(res)
+}
+
+// handleTunnelReq acknowledges the request of a client and forwards its frame.
+// 03_08_04 Tunnelling v01.05.03 AS.pdf
+// 2.6 Frame confirmation
+func (srv *TunnelServer) handleTunnelReq(req *knxnet.TunnelReq, link *tcpEndpoint) error {
+	sc := srv.connection(req.Channel, link)
+	if sc == nil {
+		return fmt.Errorf("tunnel request for unknown channel %d", req.Channel)
+	}
+
+	// Over TCP there are neither sequence numbers nor acknowledgements.
+	if link == nil {
+		switch req.SeqNumber {
+		case sc.seqIn:
+			sc.seqIn++
+
+		case sc.seqIn - 1:
+			// The acknowledgement got lost, the frame has already been forwarded.
+			return sc.data.send(&knxnet.TunnelRes{Channel: sc.channel, SeqNumber: req.SeqNumber})
+
+		default:
+			return fmt.Errorf("out of sequence tunnel request %d on channel %d", req.SeqNumber, sc.channel)
+		}
+
+		if err := sc.data.send(&knxnet.TunnelRes{Channel: sc.channel, SeqNumber: req.SeqNumber}); err != nil {
+			return err
+		}
+	}
+
+	ldataReq, ok := req.Payload.(*cemi.LDataReq)
+	if !ok {
+		util.Log(srv, "Ignored %v on channel %d", req.Payload.MessageCode(), sc.channel)
+		return nil
+	}
+
+	// Frames without source are sent with the address of the connection.
+	frame := &cemi.LDataReq{LData: ldataReq.LData}
+	if frame.Source == 0 {
+		frame.Source = sc.addr
+	}
+
+	// The other clients see the frame as if it had been sent on their line.
+	srv.broadcast(&cemi.LDataInd{LData: frame.LData}, sc)
+
+	// The receiving worker must not wait for a slow backend, otherwise heartbeats and acknowledgements
+	// of all clients go unanswered. A frame that does not fit into the queue is confirmed negatively.
+	select {
+	case srv.forward <- forwardRequest{conn: sc, req: frame}:
+
+	case <-srv.done:
+
+	default:
+		util.Log(srv, "Forward queue is full, rejected frame of channel %d", sc.channel)
+
+		con := &cemi.LDataCon{LData: frame.LData}
+		con.Control1 |= cemi.Control1HasError
+		sc.enqueue(con)
+	}
+
+	return nil
+}
+
+// handleTunnelRes relays the acknowledgement of a client to the connection's worker.
+func (srv *TunnelServer) handleTunnelRes(res *knxnet.TunnelRes, link *tcpEndpoint) {
+	sc := srv.connection(res.Channel, link)
+	if sc == nil {
+		return
+	}
+
+	select {
+	case sc.acks <- res.SeqNumber:
+	default:
+	}
+}
+
+// handle processes a packet of a client. The endpoints are those to which the answer is sent.
+func (srv *TunnelServer) handle(msg knxnet.Service, sender *net.UDPAddr, link *tcpEndpoint) error {
+	protocol := knxnet.UDP4
+	if link != nil {
+		protocol = knxnet.TCP4
+	}
+
+	// endpoint determines where to send answers to the client.
+	endpoint := func(info knxnet.HostInfo) serverEndpoint {
+		if link != nil {
+			return link
+		}
+
+		// A zero address indicates that the client is behind a NAT.
+		if info.Address == (knxnet.Address{}) || info.Port == 0 {
+			return &udpEndpoint{conn: srv.udp, addr: sender}
+		}
+
+		return &udpEndpoint{conn: srv.udp, addr: &net.UDPAddr{IP: info.Address[:], Port: int(info.Port)}}
+	}
+
+	switch msg := msg.(type) {
+	case *knxnet.ConnReq:
+		return srv.handleConnReq(msg, endpoint(msg.Control), endpoint(msg.Tunnel), link, protocol)
+
+	case *knxnet.ConnStateReq:
+		return srv.handleConnStateReq(msg, endpoint(msg.Control), link)
+
+	case *knxnet.DiscReq:
+		return srv.handleDiscReq(msg, endpoint(msg.Control), link)
+
+	case *knxnet.DiscRes:
+		return nil
+
+	case *knxnet.DescriptionReq:
+		if srv.config.Description == nil {
+			return nil
+		}
+
+		res := knxnet.DescriptionRes(completeDescription(*srv.config.Description))
+
+		return endpoint(msg.HostInfo).send(&res)
+
+	case *knxnet.TunnelReq:
+		return srv.handleTunnelReq(msg, link)
+
+	case *knxnet.TunnelRes:
+		srv.handleTunnelRes(msg, link)
+		return nil
+
+	default:
+		return fmt.Errorf("unexpected service %v", msg.Service())
+	}
+}
+
+// serveUDP is the receiving worker for UDP connections.
+func (srv *TunnelServer) serveUDP() {
+	defer srv.wait.Done()
+
+	buffer := [1024]byte{}
+
+	for {
+		n, sender, err := srv.udp.ReadFromUDP(buffer[:])
+		if err != nil {
+			// Closing the socket is not an error.
+			if !errors.Is(err, net.ErrClosed) {
+				srv.errors.emit(srv, err)
+			}
+
+			return
+		}
+
+		var msg knxnet.Service
+
+		if _, err := knxnet.Unpack(buffer[:n], &msg); err != nil {
+			srv.errors.emit(srv, fmt.Errorf("unpacking packet from %v: %w", sender, err))
+			continue
+		}
+
+		if err := srv.handle(msg, sender, nil); err != nil {
+			util.Log(srv, "Packet from %v: %v", sender, err)
+		}
+	}
+}
+
+// serveTCP accepts TCP connections.
+func (srv *TunnelServer) serveTCP() {
+	defer srv.wait.Done()
+
+	for {
+		conn, err := srv.tcp.Accept()
+		if err != nil {
+			// Closing the listener is not an error.
+			if !errors.Is(err, net.ErrClosed) {
+				srv.errors.emit(srv, err)
+			}
+
+			return
+		}
+
+		link := &tcpEndpoint{conn: conn}
+
+		srv.mu.Lock()
+		if srv.closed {
+			srv.mu.Unlock()
+
+			_ = conn.Close()
+
+			return
+		}
+
+		srv.links[link] = struct{}{}
+		srv.wait.Add(1)
+		srv.mu.Unlock()
+
+		go srv.serveLink(link)
+	}
+}
+
+// serveLink is the receiving worker for a TCP connection. The tunnelling connections that have
+// been established through it end with it.
+func (srv *TunnelServer) serveLink(link *tcpEndpoint) {
+	defer srv.wait.Done()
+
+	defer func() {
+		_ = link.conn.Close()
+
+		srv.mu.Lock()
+		delete(srv.links, link)
+
+		var conns []*serverConn
+
+		for _, sc := range srv.conns {
+			if sc.link == link {
+				conns = append(conns, sc)
+			}
+		}
+		srv.mu.Unlock()
+
+		for _, sc := range conns {
+			srv.remove(sc)
+		}
+	}()
+
+	reader := bufio.NewReader(link.conn)
+
+	for {
+		header, err := reader.Peek(6) // KNXnet/IP headers are 6 bytes long
+		if err != nil {
+			return
+		}
+
+		var (
+			serviceID knxnet.ServiceID
+			totalLen  uint16
+		)
+
+		if _, err := knxnet.UnpackHeader(header, &serviceID, &totalLen); err != nil {
+			srv.errors.emit(srv, fmt.Errorf("inspecting header from %v: %w", link.conn.RemoteAddr(), err))
+			return
+		}
+
+		buffer := make([]byte, totalLen)
+
+		if _, err := io.ReadFull(reader, buffer); err != nil {
+			return
+		}
+
+		var msg knxnet.Service
+
+		if _, err := knxnet.Unpack(buffer, &msg); err != nil {
+			srv.errors.emit(srv, fmt.Errorf("unpacking packet from %v: %w", link.conn.RemoteAddr(), err))
+			continue
+		}
+
+		if err := srv.handle(msg, nil, link); err != nil {
+			util.Log(srv, "Packet from %v: %v", link.conn.RemoteAddr(), err)
+		}
+	}
+}
+
+// serveForward sends the frames of the clients to the backend, in order, and confirms them.
+func (srv *TunnelServer) serveForward() {
+	defer srv.wait.Done()
+
+	for {
+		select {
+		case <-srv.done:
+			return
+
+		case fwd := <-srv.forward:
+			con := &cemi.LDataCon{LData: fwd.req.LData}
+
+			var frame cemi.Message = fwd.req
+			if _, ok := srv.backend.(*Router); ok {
+				frame = &cemi.LDataInd{LData: fwd.req.LData}
+			}
+
+			if err := srv.backend.Send(frame); err != nil {
+				srv.errors.emit(srv, fmt.Errorf("forwarding frame of channel %d: %w", fwd.conn.channel, err))
+				con.Control1 |= cemi.Control1HasError
+			}
+
+			fwd.conn.enqueue(con)
+		}
+	}
+}
+
+// serveBackend relays the frames from the backend to all clients.
+func (srv *TunnelServer) serveBackend() {
+	defer srv.wait.Done()
+
+	inbound := srv.backend.Inbound()
+
+	for {
+		select {
+		case <-srv.done:
+			return
+
+		case msg, open := <-inbound:
+			if !open {
+				util.Log(srv, "Backend has closed its inbound channel")
+				return
+			}
+
+			// Neither the interface nor the broadcast mode can be told to a tunnelling client.
+			if frame, ok := msg.(*RoutedFrame); ok {
+				msg = frame.Message
+			}
+
+			if bc, ok := msg.(*SystemBroadcast); ok {
+				msg = bc.Message
+			}
+
+			// Confirmations of the backend belong to the server's own requests.
+			if ind, ok := msg.(*cemi.LDataInd); ok {
+				srv.broadcast(ind, nil)
+			}
+		}
+	}
+}
@@ -0,0 +1,613 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knx
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/mobilarte/knx-exp/knx/cemi"
+	"github.com/mobilarte/knx-exp/knx/knxnet"
+)
+
+// chanBackend is a TunnelBackend which hands the frames over to the test.
+type chanBackend struct {
+	sent    chan cemi.Message
+	inbound chan cemi.Message
+}
+
+func newChanBackend() *chanBackend {
+	return &chanBackend{sent: make(chan cemi.Message, 16), inbound: make(chan cemi.Message)}
+}
+
+func (backend *chanBackend) Send(data cemi.Message) error {
+	backend.sent <- data
+	return nil
+}
+
+func (backend *chanBackend) Inbound() <-chan cemi.Message {
+	return backend.inbound
+}
+
+func makeTunnelServer(t *testing.T, backend TunnelBackend, pool ...cemi.IndividualAddr) *TunnelServer {
+	t.Helper()
+
+	srv, err := NewTunnelServer(backend, TunnelServerConfig{
+		Address:         "127.0.0.1:0",
+		AddressPool:     pool,
+		ResponseTimeout: 100 * time.Millisecond,
+	})
+	if err != nil {
+		t.Skipf("Cannot listen on loopback: %v", err)
+	}
+
+	t.Cleanup(func() {
+		if err := srv.Close(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	return srv
+}
+
+// receiveMessage waits for a message of the given type.
+func receiveMessage[T cemi.Message](t *testing.T, inbound <-chan cemi.Message) T {
+	t.Helper()
+
+	select {
+	case msg := <-inbound:
+		typed, ok := msg.(T)
+		if !ok {
+			t.Fatalf("Unexpected %T", msg)
+		}
+
+		return typed
+
+	case <-time.After(time.Second):
+		var zero T
+
+		t.Fatalf("No %T has been received", zero)
+
+		return zero
+	}
+}
+
+// failingBackend is a TunnelBackend which cannot send any frame.
+type failingBackend struct {
+	inbound chan cemi.Message
+}
+
+func (backend *failingBackend) Send(data cemi.Message) error {
+	return errors.New("bus is down")
+}
+
+func (backend *failingBackend) Inbound() <-chan cemi.Message {
+	return backend.inbound
+}
+
+// receiveService waits for the next packet on the raw socket.
+func receiveService(t *testing.T, sock *knxnet.TunnelSocket) knxnet.Service {
+	t.Helper()
+
+	select {
+	case msg := <-sock.Inbound():
+		return msg
+	case <-time.After(time.Second):
+		t.Fatal("No response")
+		return nil
+	}
+}
+
+// dialTunnelServer connects a raw socket to the server over UDP and returns it with the channel.
+func dialTunnelServer(t *testing.T, srv *TunnelServer) (*knxnet.TunnelSocket, uint8) {
+	t.Helper()
+
+	sock, err := knxnet.DialTunnelUDP(srv.UDPAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { _ = sock.Close() })
+
+	nat := knxnet.HostInfo{Protocol: knxnet.UDP4}
+
+	err = sock.Send(&knxnet.ConnReq{
+		Control:  nat,
+		Tunnel:   nat,
+		ConnType: knxnet.TunnelConnection,
+		Layer:    knxnet.TunnelLayerData,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, ok := receiveService(t, sock).(*knxnet.ConnRes)
+	if !ok || res.Status != knxnet.NoError {
+		t.Fatalf("Unexpected connection response %+v", res)
+	}
+
+	return sock, res.Channel
+}
+
+// receiveConfirmation acknowledges the next tunnel request of the server and returns its frame.
+func receiveConfirmation(t *testing.T, sock *knxnet.TunnelSocket) *cemi.LDataCon {
+	t.Helper()
+
+	req, ok := receiveService(t, sock).(*knxnet.TunnelReq)
+	if !ok {
+		t.Fatal("Expected a tunnel request")
+	}
+
+	if err := sock.Send(&knxnet.TunnelRes{Channel: req.Channel, SeqNumber: req.SeqNumber}); err != nil {
+		t.Fatal(err)
+	}
+
+	con, ok := req.Payload.(*cemi.LDataCon)
+	if !ok {
+		t.Fatalf("Unexpected payload %T", req.Payload)
+	}
+
+	return con
+}
+
+func TestTunnelServer(t *testing.T) {
+	backend := newChanBackend()
+	srv := makeTunnelServer(t, backend, 0x11f1, 0x11f2)
+
+	config := DefaultTunnelConfig
+	config.ResponseTimeout = 500 * time.Millisecond
+
+	udpClient, err := NewTunnel(srv.UDPAddr().String(), knxnet.TunnelLayerData, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { _ = udpClient.Close() })
+
+	config.UseTCP = true
+
+	tcpClient, err := NewTunnel(srv.TCPAddr().String(), knxnet.TunnelLayerData, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { _ = tcpClient.Close() })
+
+	if udpClient.IndividualAddr() != 0x11f1 || tcpClient.IndividualAddr() != 0x11f2 {
+		t.Errorf("Unexpected addresses %v and %v", udpClient.IndividualAddr(), tcpClient.IndividualAddr())
+	}
+
+	t.Run("Exhausted", func(t *testing.T) {
+		udpConfig := config
+		udpConfig.UseTCP = false
+
+		_, err := NewTunnel(srv.UDPAddr().String(), knxnet.TunnelLayerData, udpConfig)
+		if !errors.Is(err, knxnet.ErrCode(knxnet.ErrNoMoreConnections)) {
+			t.Errorf("Unexpected error %v", err)
+		}
+	})
+
+	for _, client := range []*Tunnel{udpClient, tcpClient} {
+		other := tcpClient
+		if client == tcpClient {
+			other = udpClient
+		}
+
+		req := &cemi.LDataReq{LData: makeRoutedLData(cemi.Control1NoSysBroadcast)}
+		req.Source = 0
+
+		if err := client.Send(req); err != nil {
+			t.Fatal(err)
+		}
+
+		// The frame is forwarded with the address of the connection.
+		sent := receiveMessage[*cemi.LDataReq](t, backend.sent)
+		if sent.Source != client.IndividualAddr() {
+			t.Errorf("Unexpected source %v", sent.Source)
+		}
+
+		// The other client sees the frame on its line.
+		ind := receiveMessage[*cemi.LDataInd](t, other.Inbound())
+		if ind.Source != client.IndividualAddr() {
+			t.Errorf("Unexpected source %v", ind.Source)
+		}
+
+		if con := receiveMessage[*cemi.LDataCon](t, client.Inbound()); con.Control1&cemi.Control1HasError != 0 {
+			t.Error("Negative confirmation")
+		}
+	}
+
+	// Frames from the backend reach all clients.
+	backend.inbound <- &cemi.LDataInd{LData: makeRoutedLData(cemi.Control1NoSysBroadcast)}
+
+	for _, client := range []*Tunnel{udpClient, tcpClient} {
+		if ind := receiveMessage[*cemi.LDataInd](t, client.Inbound()); ind.Source != 0x1101 {
+			t.Errorf("Unexpected source %v", ind.Source)
+		}
+	}
+}
+
+func TestTunnelServer_Sequence(t *testing.T) {
+	backend := newChanBackend()
+	srv := makeTunnelServer(t, backend, 0x11f1)
+
+	sock, err := knxnet.DialTunnelUDP(srv.UDPAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { _ = sock.Close() })
+
+	receive := func() knxnet.Service {
+		t.Helper()
+		return receiveService(t, sock)
+	}
+
+	nat := knxnet.HostInfo{Protocol: knxnet.UDP4}
+
+	err = sock.Send(&knxnet.ConnReq{
+		Control:  nat,
+		Tunnel:   nat,
+		ConnType: knxnet.TunnelConnection,
+		Layer:    knxnet.TunnelLayerRaw,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res, ok := receive().(*knxnet.ConnRes); !ok || res.Status != knxnet.ErrTunnellingLayer {
+		t.Fatalf("Expected the tunnelling layer to be rejected")
+	}
+
+	err = sock.Send(&knxnet.ConnReq{
+		Control:  nat,
+		Tunnel:   nat,
+		ConnType: knxnet.TunnelConnection,
+		Layer:    knxnet.TunnelLayerData,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, ok := receive().(*knxnet.ConnRes)
+	if !ok || res.Status != knxnet.NoError || res.Data.IndividualAddr != 0x11f1 {
+		t.Fatalf("Unexpected connection response %+v", res)
+	}
+
+	tunnelReq := func(seqNumber uint8) *knxnet.TunnelReq {
+		return &knxnet.TunnelReq{
+			Channel:   res.Channel,
+			SeqNumber: seqNumber,
+			Payload:   &cemi.LDataReq{LData: makeRoutedLData(cemi.Control1NoSysBroadcast)},
+		}
+	}
+
+	// The repetition is acknowledged, but not forwarded. Out of sequence requests are ignored.
+	for _, seqNumber := range []uint8{0, 0, 5, 1} {
+		if err := sock.Send(tunnelReq(seqNumber)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var acks []uint8
+
+	for confirmations := 0; len(acks) < 3 || confirmations < 2; {
+		switch msg := receive().(type) {
+		case *knxnet.TunnelRes:
+			acks = append(acks, msg.SeqNumber)
+
+		case *knxnet.TunnelReq:
+			confirmations++
+
+			if err := sock.Send(&knxnet.TunnelRes{Channel: msg.Channel, SeqNumber: msg.SeqNumber}); err != nil {
+				t.Fatal(err)
+			}
+
+		default:
+			t.Fatalf("Unexpected %T", msg)
+		}
+	}
+
+	if len(acks) != 3 || acks[0] != 0 || acks[1] != 0 || acks[2] != 1 {
+		t.Errorf("Unexpected acknowledgements %v", acks)
+	}
+
+	for range 2 {
+		receiveMessage[*cemi.LDataReq](t, backend.sent)
+	}
+
+	select {
+	case msg := <-backend.sent:
+		t.Errorf("Unexpected %T", msg)
+	default:
+	}
+
+	if err := sock.Send(&knxnet.ConnStateReq{Channel: res.Channel, Control: nat}); err != nil {
+		t.Fatal(err)
+	}
+
+	if state, ok := receive().(*knxnet.ConnStateRes); !ok || state.Status != knxnet.NoError {
+		t.Errorf("Unexpected connection state response %+v", state)
+	}
+
+	if err := sock.Send(&knxnet.DiscReq{Channel: res.Channel, Control: nat}); err != nil {
+		t.Fatal(err)
+	}
+
+	if disc, ok := receive().(*knxnet.DiscRes); !ok || disc.Status != knxnet.NoError {
+		t.Errorf("Unexpected disconnect response %+v", disc)
+	}
+
+	if err := sock.Send(&knxnet.ConnStateReq{Channel: res.Channel, Control: nat}); err != nil {
+		t.Fatal(err)
+	}
+
+	if state, ok := receive().(*knxnet.ConnStateRes); !ok || state.Status != knxnet.ErrConnectionID {
+		t.Errorf("Unexpected connection state response %+v", state)
+	}
+}
+
+func TestTunnelServer_Heartbeat(t *testing.T) {
+	srv := makeTunnelServer(t, newChanBackend(), 0x11f1)
+	sock, channel := dialTunnelServer(t, srv)
+
+	nat := knxnet.HostInfo{Protocol: knxnet.UDP4}
+
+	for _, test := range []struct {
+		channel uint8
+		status  knxnet.ErrCode
+	}{
+		{channel, knxnet.NoError},
+		{channel + 1, knxnet.ErrConnectionID},
+	} {
+		if err := sock.Send(&knxnet.ConnStateReq{Channel: test.channel, Control: nat}); err != nil {
+			t.Fatal(err)
+		}
+
+		res, ok := receiveService(t, sock).(*knxnet.ConnStateRes)
+		if !ok || res.Channel != test.channel || res.Status != test.status {
+			t.Errorf("Unexpected connection state response %+v for channel %d", res, test.channel)
+		}
+	}
+}
+
+func TestTunnelServer_Disconnect(t *testing.T) {
+	srv := makeTunnelServer(t, newChanBackend(), 0x11f1)
+	sock, channel := dialTunnelServer(t, srv)
+
+	nat := knxnet.HostInfo{Protocol: knxnet.UDP4}
+
+	if err := sock.Send(&knxnet.DiscReq{Channel: channel, Control: nat}); err != nil {
+		t.Fatal(err)
+	}
+
+	if res, ok := receiveService(t, sock).(*knxnet.DiscRes); !ok || res.Status != knxnet.NoError {
+		t.Fatalf("Unexpected disconnect response %+v", res)
+	}
+
+	if err := sock.Send(&knxnet.DiscReq{Channel: channel, Control: nat}); err != nil {
+		t.Fatal(err)
+	}
+
+	if res, ok := receiveService(t, sock).(*knxnet.DiscRes); !ok || res.Status != knxnet.ErrConnectionID {
+		t.Errorf("Unexpected disconnect response %+v", res)
+	}
+
+	// The only address of the pool is available again.
+	dialTunnelServer(t, srv)
+}
+
+func TestTunnelServer_UnknownChannel(t *testing.T) {
+	backend := newChanBackend()
+	srv := makeTunnelServer(t, backend, 0x11f1)
+	sock, channel := dialTunnelServer(t, srv)
+
+	err := sock.Send(&knxnet.TunnelReq{
+		Channel: channel + 1,
+		Payload: &cemi.LDataReq{LData: makeRoutedLData(cemi.Control1NoSysBroadcast)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The request is neither acknowledged nor forwarded, so the heartbeat is answered first.
+	nat := knxnet.HostInfo{Protocol: knxnet.UDP4}
+
+	if err := sock.Send(&knxnet.ConnStateReq{Channel: channel, Control: nat}); err != nil {
+		t.Fatal(err)
+	}
+
+	if res, ok := receiveService(t, sock).(*knxnet.ConnStateRes); !ok || res.Status != knxnet.NoError {
+		t.Errorf("Unexpected %+v", res)
+	}
+
+	select {
+	case msg := <-backend.sent:
+		t.Errorf("Unexpected %T", msg)
+	default:
+	}
+}
+
+func TestTunnelServer_BackendError(t *testing.T) {
+	srv := makeTunnelServer(t, &failingBackend{inbound: make(chan cemi.Message)}, 0x11f1)
+	sock, channel := dialTunnelServer(t, srv)
+
+	err := sock.Send(&knxnet.TunnelReq{
+		Channel: channel,
+		Payload: &cemi.LDataReq{LData: makeRoutedLData(cemi.Control1NoSysBroadcast)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res, ok := receiveService(t, sock).(*knxnet.TunnelRes); !ok || res.Status != knxnet.NoError {
+		t.Fatalf("Unexpected %+v", res)
+	}
+
+	if con := receiveConfirmation(t, sock); con.Control1&cemi.Control1HasError == 0 {
+		t.Error("Expected a negative confirmation")
+	}
+
+	select {
+	case err := <-srv.Errors():
+		if err == nil {
+			t.Error("Expected an error")
+		}
+	case <-time.After(time.Second):
+		t.Error("No error has been reported")
+	}
+}
+
+func TestTunnelServer_SlowBackend(t *testing.T) {
+	// The backend does not return from Send until the test receives the frame.
+	backend := &chanBackend{sent: make(chan cemi.Message), inbound: make(chan cemi.Message)}
+
+	srv, err := NewTunnelServer(backend, TunnelServerConfig{
+		Address:           "127.0.0.1:0",
+		AddressPool:       []cemi.IndividualAddr{0x11f1},
+		ResponseTimeout:   time.Second,
+		OutboundQueueSize: 1,
+	})
+	if err != nil {
+		t.Skipf("Cannot listen on loopback: %v", err)
+	}
+
+	t.Cleanup(func() { _ = srv.Close() })
+
+	sock, channel := dialTunnelServer(t, srv)
+
+	// One frame is being sent and at most one waits in the queue, so the last one is rejected.
+	for seqNumber := range uint8(3) {
+		err := sock.Send(&knxnet.TunnelReq{
+			Channel:   channel,
+			SeqNumber: seqNumber,
+			Payload:   &cemi.LDataReq{LData: makeRoutedLData(cemi.Control1NoSysBroadcast)},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The heartbeat is answered although the backend is stuck.
+	nat := knxnet.HostInfo{Protocol: knxnet.UDP4}
+
+	if err := sock.Send(&knxnet.ConnStateReq{Channel: channel, Control: nat}); err != nil {
+		t.Fatal(err)
+	}
+
+	var acks, rejected int
+
+	for state := false; !state || acks < 3 || rejected < 1; {
+		switch msg := receiveService(t, sock).(type) {
+		case *knxnet.TunnelRes:
+			acks++
+
+		case *knxnet.ConnStateRes:
+			state = msg.Status == knxnet.NoError
+
+		case *knxnet.TunnelReq:
+			if err := sock.Send(&knxnet.TunnelRes{Channel: msg.Channel, SeqNumber: msg.SeqNumber}); err != nil {
+				t.Fatal(err)
+			}
+
+			if con, ok := msg.Payload.(*cemi.LDataCon); !ok || con.Control1&cemi.Control1HasError == 0 {
+				t.Fatalf("Unexpected %+v", msg.Payload)
+			}
+
+			rejected++
+
+		default:
+			t.Fatalf("Unexpected %T", msg)
+		}
+	}
+
+	// The accepted frames are still forwarded and confirmed.
+	for range 3 - rejected {
+		receiveMessage[*cemi.LDataReq](t, backend.sent)
+
+		if con := receiveConfirmation(t, sock); con.Control1&cemi.Control1HasError != 0 {
+			t.Error("Negative confirmation")
+		}
+	}
+}
+
+func TestTunnelServer_MalformedPacket(t *testing.T) {
+	srv := makeTunnelServer(t, newChanBackend(), 0x11f1)
+
+	// Description responses with a zero and an oversized DIB length.
+	packets := [][]byte{
+		{0x06, 0x10, 0x02, 0x04, 0x00, 0x0a, 0x00, 0x07, 0x00, 0x00},
+		{0x06, 0x10, 0x02, 0x04, 0x00, 0x0a, 0xff, 0x01, 0x00, 0x00},
+	}
+
+	for _, addr := range []net.Addr{srv.UDPAddr(), srv.TCPAddr()} {
+		conn, err := net.Dial(addr.Network(), addr.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		t.Cleanup(func() { _ = conn.Close() })
+
+		for _, packet := range packets {
+			if _, err := conn.Write(packet); err != nil {
+				t.Fatal(err)
+			}
+
+			select {
+			case err := <-srv.Errors():
+				if err == nil {
+					t.Error("Expected an error")
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("No error has been reported for % x over %s", packet, addr.Network())
+			}
+		}
+	}
+
+	// The server keeps answering.
+	dialTunnelServer(t, srv)
+}
+
+func TestTunnelServer_RouterBackend(t *testing.T) {
+	sock, peer := newDummySockets()
+
+	router := makeRouter(sock, DefaultRouterConfig)
+	t.Cleanup(func() { _ = router.Close() })
+
+	srv := makeTunnelServer(t, router, 0x11f1)
+	client, channel := dialTunnelServer(t, srv)
+
+	err := client.Send(&knxnet.TunnelReq{
+		Channel: channel,
+		Payload: &cemi.LDataReq{LData: makeRoutedLData(cemi.Control1NoSysBroadcast)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Routers exchange indications, so the request of the client is converted.
+	select {
+	case msg := <-peer.Inbound():
+		ind, ok := msg.(*knxnet.RoutingInd)
+		if !ok {
+			t.Fatalf("Unexpected %T", msg)
+		}
+
+		if _, ok := ind.Payload.(*cemi.LDataInd); !ok {
+			t.Errorf("Unexpected payload %T", ind.Payload)
+		}
+
+	case <-time.After(time.Second):
+		t.Fatal("Frame has not been routed")
+	}
+
+	if res, ok := receiveService(t, client).(*knxnet.TunnelRes); !ok || res.Status != knxnet.NoError {
+		t.Fatalf("Unexpected %+v", res)
+	}
+
+	if con := receiveConfirmation(t, client); con.Control1&cemi.Control1HasError != 0 {
+		t.Error("Negative confirmation")
+	}
+}
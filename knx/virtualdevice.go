@@ -0,0 +1,362 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knx
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/mobilarte/knx-exp/knx/cemi"
+	"github.com/mobilarte/knx-exp/knx/dpt"
+)
+
+// GroupObjectFlags determine how a group object takes part in the group communication. They have
+// the same meaning as the communication flags in ETS.
+type GroupObjectFlags uint8
+
+// These are the flags of a group object.
+const (
+	// FlagCommunication connects the object to the bus. Without it, the other flags have no effect.
+	FlagCommunication GroupObjectFlags = 1 << iota
+
+	// FlagRead makes the object answer GroupRead on its main address with its current value.
+	FlagRead
+
+	// FlagWrite makes the object take over the value of a GroupWrite.
+	FlagWrite
+
+	// FlagTransmit makes the object send a GroupWrite on its main address when its value is set.
+	FlagTransmit
+
+	// FlagUpdate makes the object take over the value of a GroupResponse.
+	FlagUpdate
+
+	// FlagReadOnInit makes the device read the value of the object from the bus when it starts.
+	FlagReadOnInit
+)
+
+// String generates a string representation in the order of ETS, e.g. "CRWTU-".
+func (flags GroupObjectFlags) String() string {
+	letters := []byte("CRWTUI")
+
+	for i := range letters {
+		if flags&(1<<i) == 0 {
+			letters[i] = '-'
+		}
+	}
+
+	return string(letters)
+}
+
+// GroupObjectConfig describes a group object of a VirtualDevice.
+type GroupObjectConfig struct {
+	// Name identifies the object within the device.
+	Name string
+
+	// Address is the main group address. The object sends on it and answers read requests for it.
+	Address cemi.GroupAddr
+
+	// Listen contains further group addresses whose writes and responses update the object.
+	Listen []cemi.GroupAddr
+
+	// Datapoint is a pointer to a datapoint, e.g. new(dpt.DPT_9001). It determines the type of the
+	// object and holds its initial value.
+	Datapoint dpt.Datapoint
+
+	Flags GroupObjectFlags
+}
+
+// A GroupObject is a communication object of a VirtualDevice. It holds the current value in its
+// packed form.
+type GroupObject struct {
+	config GroupObjectConfig
+	device *VirtualDevice
+
+	mu        sync.Mutex
+	value     []byte
+	callbacks []func(GroupEvent)
+}
+
+// newDatapoint creates an empty datapoint of the same type as the prototype.
+func newDatapoint(prototype dpt.Datapoint) dpt.Datapoint {
+	return reflect.New(reflect.TypeOf(prototype).Elem()).Interface().(dpt.Datapoint)
+}
+
+// Name returns the name of the object.
+func (obj *GroupObject) Name() string {
+	return obj.config.Name
+}
+
+// Address returns the main group address of the object.
+func (obj *GroupObject) Address() cemi.GroupAddr {
+	return obj.config.Address
+}
+
+// Flags returns the communication flags of the object.
+func (obj *GroupObject) Flags() GroupObjectFlags {
+	return obj.config.Flags
+}
+
+// Bytes returns the current value in its packed form.
+func (obj *GroupObject) Bytes() []byte {
+	obj.mu.Lock()
+	defer obj.mu.Unlock()
+
+	return bytes.Clone(obj.value)
+}
+
+// Value returns the current value as a new datapoint of the type of the object.
+func (obj *GroupObject) Value() (dpt.Datapoint, error) {
+	value := newDatapoint(obj.config.Datapoint)
+	if err := value.Unpack(obj.Bytes()); err != nil {
+		return nil, err
+	}
+
+	return value, nil
+}
+
+// Set changes the value of the object. If the object has the transmit flag, the new value is sent
+// as GroupWrite on its main address.
+func (obj *GroupObject) Set(value dpt.DatapointValue) error {
+	data := value.Pack()
+
+	// The value must be valid for the type of the object.
+	if err := newDatapoint(obj.config.Datapoint).Unpack(data); err != nil {
+		return fmt.Errorf("setting %s: %w", obj.config.Name, err)
+	}
+
+	obj.mu.Lock()
+	obj.value = data
+	obj.mu.Unlock()
+
+	if !obj.has(FlagTransmit) {
+		return nil
+	}
+
+	return obj.device.send(GroupWrite, obj.config.Address, data)
+}
+
+// Read requests the value of the object from the bus. The response updates the object if it has
+// the update flag.
+func (obj *GroupObject) Read() error {
+	return obj.device.send(GroupRead, obj.config.Address, nil)
+}
+
+// OnUpdate registers a callback which is invoked whenever the bus changes the value of the object.
+// Local changes with Set do not invoke it.
+func (obj *GroupObject) OnUpdate(callback func(event GroupEvent)) {
+	obj.mu.Lock()
+	defer obj.mu.Unlock()
+
+	obj.callbacks = append(obj.callbacks, callback)
+}
+
+// has determines whether the object is connected to the bus and has all of the given flags.
+func (obj *GroupObject) has(flags GroupObjectFlags) bool {
+	flags |= FlagCommunication
+	return obj.config.Flags&flags == flags
+}
+
+// update takes over the value of the event, provided it is valid for the type of the object.
+func (obj *GroupObject) update(event GroupEvent) error {
+	if err := newDatapoint(obj.config.Datapoint).Unpack(event.Data); err != nil {
+		return fmt.Errorf("updating %s from %v: %w", obj.config.Name, event.Source, err)
+	}
+
+	obj.mu.Lock()
+	obj.value = bytes.Clone(event.Data)
+	callbacks := obj.callbacks
+	obj.mu.Unlock()
+
+	for _, callback := range callbacks {
+		callback(event)
+	}
+
+	return nil
+}
+
+// datapointOf constrains P to the pointer type of the datapoint V.
+type datapointOf[V any] interface {
+	*V
+	dpt.Datapoint
+}
+
+// GroupObjectValue returns the current value of the object as V, e.g. dpt.DPT_9001.
+func GroupObjectValue[V any, P datapointOf[V]](obj *GroupObject) (V, error) {
+	var value V
+
+	err := P(&value).Unpack(obj.Bytes())
+
+	return value, err
+}
+
+// OnGroupObjectUpdate registers a typed callback which receives the new value as V whenever the bus
+// changes the value of the object. Values which cannot be unpacked as V are skipped.
+func OnGroupObjectUpdate[V any, P datapointOf[V]](obj *GroupObject, callback func(value V, event GroupEvent)) {
+	obj.OnUpdate(func(event GroupEvent) {
+		var value V
+
+		if err := P(&value).Unpack(event.Data); err != nil {
+			obj.device.errors.emit(obj.device, fmt.Errorf("unpacking %s as %T: %w", obj.config.Name, value, err))
+			return
+		}
+
+		callback(value, event)
+	})
+}
+
+// A VirtualDevice emulates a KNX device with group objects on top of a GroupClient. It answers
+// read requests and keeps the values of its objects up to date. The device consumes the Inbound
+// channel of the client.
+type VirtualDevice struct {
+	client  GroupClient
+	source  cemi.IndividualAddr
+	objects map[string]*GroupObject
+
+	// Objects by the addresses they listen on, in the order in which they have been added
+	addresses map[cemi.GroupAddr][]*GroupObject
+
+	errors    *asyncErrors
+	done      chan struct{}
+	closeOnce sync.Once
+	wait      sync.WaitGroup
+}
+
+// NewVirtualDevice creates a device with the given group objects and starts serving the client.
+// The source is the individual address of the device; 0 lets the client fill it in. Objects with
+// the read-on-init flag are read from the bus immediately; failures are reported on Errors.
+func NewVirtualDevice(client GroupClient, source cemi.IndividualAddr,
+	objects ...GroupObjectConfig) (*VirtualDevice, error) {
+	dev := &VirtualDevice{
+		client:    client,
+		source:    source,
+		objects:   make(map[string]*GroupObject, len(objects)),
+		addresses: map[cemi.GroupAddr][]*GroupObject{},
+		errors:    newAsyncErrors(),
+		done:      make(chan struct{}),
+	}
+
+	for _, config := range objects {
+		if err := dev.add(config); err != nil {
+			return nil, err
+		}
+	}
+
+	dev.wait.Add(1)
+
+	go dev.serve()
+
+	for _, config := range objects {
+		if obj := dev.objects[config.Name]; obj.has(FlagReadOnInit) {
+			if err := obj.Read(); err != nil {
+				dev.errors.emit(dev, fmt.Errorf("reading %s: %w", obj.config.Name, err))
+			}
+		}
+	}
+
+	return dev, nil
+}
+
+// Object returns the group object with the given name, or nil if there is none.
+func (dev *VirtualDevice) Object(name string) *GroupObject {
+	return dev.objects[name]
+}
+
+// Errors returns the channel on which errors are reported that occur while serving the bus, e.g.
+// invalid values or failures to answer read requests. The channel is closed when the device is
+// closed.
+func (dev *VirtualDevice) Errors() <-chan error {
+	return dev.errors.channel()
+}
+
+// Close stops serving the client. The client itself is not closed. It is safe to call Close more
+// than once.
+func (dev *VirtualDevice) Close() error {
+	dev.closeOnce.Do(func() {
+		close(dev.done)
+		dev.wait.Wait()
+		dev.errors.close()
+	})
+
+	return nil
+}
+
+// add creates the group object.
+func (dev *VirtualDevice) add(config GroupObjectConfig) error {
+	if _, ok := dev.objects[config.Name]; ok {
+		return fmt.Errorf("duplicate group object %q", config.Name)
+	}
+
+	if config.Datapoint == nil || reflect.TypeOf(config.Datapoint).Kind() != reflect.Pointer {
+		return fmt.Errorf("group object %q needs a pointer to a datapoint", config.Name)
+	}
+
+	obj := &GroupObject{config: config, device: dev, value: config.Datapoint.Pack()}
+	dev.objects[config.Name] = obj
+
+	for _, addr := range append([]cemi.GroupAddr{config.Address}, config.Listen...) {
+		dev.addresses[addr] = append(dev.addresses[addr], obj)
+	}
+
+	return nil
+}
+
+// send transmits a group event from the device.
+func (dev *VirtualDevice) send(command GroupCommand, addr cemi.GroupAddr, data []byte) error {
+	return dev.client.Send(GroupEvent{Command: command, Source: dev.source, Destination: addr, Data: data})
+}
+
+// handle processes an event of the bus.
+func (dev *VirtualDevice) handle(event GroupEvent) {
+	answered := false
+
+	for _, obj := range dev.addresses[event.Destination] {
+		var err error
+
+		switch event.Command {
+		case GroupRead:
+			// Only the main address is answered, and only once.
+			if !answered && event.Destination == obj.config.Address && obj.has(FlagRead) {
+				answered = true
+				err = dev.send(GroupResponse, event.Destination, obj.Bytes())
+			}
+
+		case GroupWrite:
+			if obj.has(FlagWrite) {
+				err = obj.update(event)
+			}
+
+		case GroupResponse:
+			if obj.has(FlagUpdate) {
+				err = obj.update(event)
+			}
+		}
+
+		if err != nil {
+			dev.errors.emit(dev, err)
+		}
+	}
+}
+
+// serve is the worker which processes the events of the bus.
+func (dev *VirtualDevice) serve() {
+	defer dev.wait.Done()
+
+	inbound := dev.client.Inbound()
+
+	for {
+		select {
+		case event, open := <-inbound:
+			if !open {
+				return
+			}
+
+			dev.handle(event)
+
+		case <-dev.done:
+			return
+		}
+	}
+}
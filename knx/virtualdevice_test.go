@@ -0,0 +1,210 @@
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package knx
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/mobilarte/knx-exp/knx/cemi"
+	"github.com/mobilarte/knx-exp/knx/dpt"
+)
+
+// chanGroupClient is a GroupClient which hands the events over to the test.
+type chanGroupClient struct {
+	sent    chan GroupEvent
+	inbound chan GroupEvent
+}
+
+func newChanGroupClient() *chanGroupClient {
+	return &chanGroupClient{sent: make(chan GroupEvent, 16), inbound: make(chan GroupEvent)}
+}
+
+func (client *chanGroupClient) Send(event GroupEvent) error {
+	client.sent <- event
+	return nil
+}
+
+func (client *chanGroupClient) Inbound() <-chan GroupEvent {
+	return client.inbound
+}
+
+// receiveEvent waits for an event that the device sends.
+func receiveEvent(t *testing.T, client *chanGroupClient) GroupEvent {
+	t.Helper()
+
+	select {
+	case event := <-client.sent:
+		return event
+	case <-time.After(time.Second):
+		t.Fatal("No event has been sent")
+		return GroupEvent{}
+	}
+}
+
+func TestGroupObjectFlags_String(t *testing.T) {
+	if s := (FlagCommunication | FlagRead | FlagTransmit).String(); s != "CR-T--" {
+		t.Errorf("Unexpected flags %s", s)
+	}
+}
+
+func TestVirtualDevice(t *testing.T) {
+	client := newChanGroupClient()
+
+	temperature := dpt.DPT_9001(21.5)
+
+	dev, err := NewVirtualDevice(client, 0x1105,
+		GroupObjectConfig{
+			Name:      "temperature",
+			Address:   0x0801,
+			Datapoint: &temperature,
+			Flags:     FlagCommunication | FlagRead | FlagTransmit,
+		},
+		GroupObjectConfig{
+			Name:      "switch",
+			Address:   0x0901,
+			Listen:    []cemi.GroupAddr{0x0902},
+			Datapoint: new(dpt.DPT_1001),
+			Flags:     FlagCommunication | FlagWrite | FlagUpdate | FlagReadOnInit,
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { _ = dev.Close() })
+
+	if event := receiveEvent(t, client); event.Command != GroupRead || event.Destination != 0x0901 {
+		t.Errorf("Unexpected read on init %+v", event)
+	}
+
+	t.Run("Read", func(t *testing.T) {
+		client.inbound <- GroupEvent{Command: GroupRead, Source: 0x1101, Destination: 0x0801}
+
+		event := receiveEvent(t, client)
+		if event.Command != GroupResponse || event.Source != 0x1105 || !bytes.Equal(event.Data, temperature.Pack()) {
+			t.Errorf("Unexpected response %+v", event)
+		}
+
+		// The switch has no read flag.
+		client.inbound <- GroupEvent{Command: GroupRead, Source: 0x1101, Destination: 0x0901}
+
+		select {
+		case event := <-client.sent:
+			t.Errorf("Unexpected %+v", event)
+		case <-time.After(50 * time.Millisecond):
+		}
+	})
+
+	t.Run("Set", func(t *testing.T) {
+		value := dpt.DPT_9001(22)
+
+		if err := dev.Object("temperature").Set(&value); err != nil {
+			t.Fatal(err)
+		}
+
+		event := receiveEvent(t, client)
+		if event.Command != GroupWrite || event.Destination != 0x0801 || !bytes.Equal(event.Data, dpt.DPT_9001(22).Pack()) {
+			t.Errorf("Unexpected write %+v", event)
+		}
+
+		if value, err := GroupObjectValue[dpt.DPT_9001](dev.Object("temperature")); err != nil || value != 22 {
+			t.Errorf("Unexpected value %v: %v", value, err)
+		}
+
+		// The switch does not transmit.
+		on := dpt.DPT_1001(true)
+
+		if err := dev.Object("switch").Set(&on); err != nil {
+			t.Fatal(err)
+		}
+
+		select {
+		case event := <-client.sent:
+			t.Errorf("Unexpected %+v", event)
+		default:
+		}
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		updates := make(chan dpt.DPT_1001, 4)
+
+		OnGroupObjectUpdate(dev.Object("switch"), func(value dpt.DPT_1001, _ GroupEvent) {
+			updates <- value
+		})
+
+		for _, event := range []GroupEvent{
+			{Command: GroupWrite, Destination: 0x0901, Data: dpt.DPT_1001(false).Pack()},
+			{Command: GroupResponse, Destination: 0x0902, Data: dpt.DPT_1001(true).Pack()},
+			{Command: GroupWrite, Destination: 0x0901, Data: []byte{1, 2, 3}},
+		} {
+			client.inbound <- event
+		}
+
+		for _, expected := range []dpt.DPT_1001{false, true} {
+			select {
+			case value := <-updates:
+				if value != expected {
+					t.Errorf("Unexpected value %v", value)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("No update")
+			}
+		}
+
+		// The invalid value is reported and leaves the object unchanged.
+		select {
+		case err := <-dev.Errors():
+			if err == nil {
+				t.Error("Expected an error")
+			}
+		case <-time.After(time.Second):
+			t.Fatal("No error has been reported")
+		}
+
+		value, err := dev.Object("switch").Value()
+		if err != nil || value.String() != dpt.DPT_1001(true).String() {
+			t.Errorf("Unexpected value %v: %v", value, err)
+		}
+
+		// The temperature has no write flag.
+		client.inbound <- GroupEvent{Command: GroupWrite, Destination: 0x0801, Data: dpt.DPT_9001(5).Pack()}
+		client.inbound <- GroupEvent{Command: GroupRead, Destination: 0x0801}
+
+		if event := receiveEvent(t, client); !bytes.Equal(event.Data, dpt.DPT_9001(22).Pack()) {
+			t.Errorf("Unexpected response %+v", event)
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		if _, err := NewVirtualDevice(client, 0, GroupObjectConfig{Name: "x"}); err == nil {
+			t.Error("Missing datapoint has been accepted")
+		}
+
+		_, err := NewVirtualDevice(client, 0,
+			GroupObjectConfig{Name: "x", Datapoint: new(dpt.DPT_1001)},
+			GroupObjectConfig{Name: "x", Datapoint: new(dpt.DPT_1001)},
+		)
+		if err == nil {
+			t.Error("Duplicate name has been accepted")
+		}
+	})
+}
+
+func TestVirtualDevice_Close(t *testing.T) {
+	dev, err := NewVirtualDevice(newChanGroupClient(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for range 2 {
+		if err := dev.Close(); err != nil {
+			t.Error(err)
+		}
+	}
+
+	if _, open := <-dev.Errors(); open {
+		t.Error("Errors channel is still open")
+	}
+}